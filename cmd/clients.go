@@ -2,14 +2,45 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"os"
 
 	"github.com/appclacks/maizai/config"
 	"github.com/appclacks/maizai/internal/providers/anthropic"
 	"github.com/appclacks/maizai/internal/providers/mistral"
+	"github.com/appclacks/maizai/internal/providers/openai"
 	"github.com/appclacks/maizai/pkg/assistant"
 )
 
+func buildProvider(instance config.ProviderInstance) (assistant.Provider, error) {
+	switch instance.Type {
+	case config.AnthropicProvider:
+		return anthropic.New(anthropic.Config{
+			APIKey:       instance.APIKey,
+			BaseURL:      instance.BaseURL,
+			Timeout:      instance.Timeout,
+			DefaultModel: instance.DefaultModel,
+		}), nil
+	case config.MistralProvider:
+		return mistral.New(mistral.Config{
+			APIKey:                instance.APIKey,
+			BaseURL:               instance.BaseURL,
+			Timeout:               instance.Timeout,
+			DefaultModel:          instance.DefaultModel,
+			DefaultEmbeddingModel: instance.DefaultEmbeddingModel,
+		}), nil
+	case config.OpenAIProvider:
+		return openai.New(openai.Config{
+			APIKey:                instance.APIKey,
+			BaseURL:               instance.BaseURL,
+			Timeout:               instance.Timeout,
+			DefaultModel:          instance.DefaultModel,
+			DefaultEmbeddingModel: instance.DefaultEmbeddingModel,
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown provider type %s", instance.Type)
+}
+
 func BuildProviders(config config.ProvidersConfiguration) (map[string]assistant.Provider, error) {
 	clients := make(map[string]assistant.Provider)
 	if config.Anthropic != "" {
@@ -26,6 +57,24 @@ func BuildProviders(config config.ProvidersConfiguration) (map[string]assistant.
 		os.Unsetenv("MAIZAI_MISTRAL_API_KEY")
 		clients["mistral"] = mistral
 	}
+	if config.OpenAI != "" || config.OpenAIBaseURL != "" {
+		openai := openai.New(openai.Config{
+			APIKey:  config.OpenAI,
+			BaseURL: config.OpenAIBaseURL,
+		})
+		os.Unsetenv("MAIZAI_OPENAI_API_KEY")
+		clients["openai"] = openai
+	}
+	for _, instance := range config.Instances {
+		if _, ok := clients[instance.Name]; ok {
+			return nil, fmt.Errorf("provider %s is already configured", instance.Name)
+		}
+		client, err := buildProvider(instance)
+		if err != nil {
+			return nil, err
+		}
+		clients[instance.Name] = client
+	}
 	if len(clients) == 0 {
 		return nil, errors.New("No AI client configured")
 	}
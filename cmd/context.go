@@ -272,6 +272,80 @@ func messageUpdateCmd() *cobra.Command {
 	return cmd
 }
 
+func contextCompactCmd() *cobra.Command {
+	var id string
+	var name string
+	var provider string
+	var model string
+	var maxTokens uint64
+	var keepLast int
+	var prompt string
+	cmd := &cobra.Command{
+		Use:   "compact",
+		Short: "Summarize the oldest messages of a context and replace them by the summary. The compacted messages are copied to an archive context",
+		Run: func(cmd *cobra.Command, args []string) {
+			if id == "" && name == "" {
+				exitIfError(errors.New("the command expects either a context id or name as input"))
+			}
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			if id == "" {
+				context, err := c.GetContextByName(ctx, name)
+				exitIfError(err)
+				id = context.ID
+			}
+			input := client.CompactContextInput{
+				ID:        id,
+				Provider:  provider,
+				Model:     model,
+				MaxTokens: maxTokens,
+				KeepLast:  keepLast,
+				Prompt:    prompt,
+			}
+			compaction, err := c.CompactContext(ctx, input)
+			exitIfError(err)
+			printJson(*compaction)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&id, "id", "", "The ID of the context to compact")
+	cmd.PersistentFlags().StringVar(&name, "name", "", "The name of the context to compact")
+	cmd.PersistentFlags().StringVar(&provider, "provider", "", "Name of the AI provider used to summarize the messages")
+	err := cmd.MarkPersistentFlagRequired("provider")
+	exitIfError(err)
+	cmd.PersistentFlags().StringVar(&model, "model", "", "Model to use. If not set, the default model of the provider is used")
+	cmd.PersistentFlags().Uint64Var(&maxTokens, "max-tokens", 4096, "Maximum tokens for the summary")
+	cmd.PersistentFlags().IntVar(&keepLast, "keep-last", 10, "Number of recent messages which are not compacted")
+	cmd.PersistentFlags().StringVar(&prompt, "prompt", "", "Instructions replacing the default summarization prompt")
+	return cmd
+}
+
+func messagePinCmd() *cobra.Command {
+	var id string
+	var unpin bool
+	cmd := &cobra.Command{
+		Use:   "pin",
+		Short: "Pin a context message so it's kept when the context is truncated",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			input := client.PinContextMessageInput{
+				ID:     id,
+				Pinned: !unpin,
+			}
+			response, err := c.PinContextMessage(ctx, input)
+			exitIfError(err)
+			printJson(*response)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&id, "id", "", "The context message ID")
+	err := cmd.MarkPersistentFlagRequired("id")
+	exitIfError(err)
+	cmd.PersistentFlags().BoolVar(&unpin, "unpin", false, "Unpin the message instead of pinning it")
+	return cmd
+}
+
 func contextCreateCmd() *cobra.Command {
 	var name string
 	var description string
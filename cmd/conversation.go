@@ -3,9 +3,14 @@ package cmd
 import (
 	"bufio"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -33,6 +38,24 @@ func buildConversationCmd() *cobra.Command {
 	var ragModel string
 	var ragProvider string
 	var ragLimit uint32
+	var ragMode string
+	var ragDocumentIDs []string
+	var ragDocumentNames []string
+	var ragSource string
+	var ragLabels map[string]string
+	var ragMetadata map[string]string
+	var ragMinScore float64
+	var ragCandidates int32
+	var ragRerank string
+	var ragCite bool
+	var toolsFile string
+	var toolResults []string
+	var serverTools []string
+	var attachments []string
+	var budget uint64
+	var budgetStrategy string
+	var keepFirst int
+	var keepLast int
 	cmd := &cobra.Command{
 		Use: "conversation",
 		Short: `Send a message to an AI provider.
@@ -47,13 +70,45 @@ If a context ID is provided, it will be used as input for the conversation. Else
 				Temperature: temperature,
 				MaxTokens:   maxTokens,
 				Provider:    aiProvider,
+				ServerTools: serverTools,
+				Cite:        ragCite,
 				RagQuery: client.RagSearchQuery{
 					Input:    ragInput,
 					Provider: ragProvider,
 					Model:    ragModel,
 					Limit:    int32(ragLimit),
+					Mode:     ragMode,
+					// a document is searched if its ID or its name is selected
+					DocumentIDs:   ragDocumentIDs,
+					DocumentNames: ragDocumentNames,
+					Source:        ragSource,
+					Labels:        ragLabels,
+					Metadata:      ragMetadata,
+					Candidates:    ragCandidates,
+					Rerank:        ragRerank,
 				},
 			}
+			if cmd.Flags().Changed("rag-min-score") {
+				options.RagQuery.MinScore = &ragMinScore
+			}
+			if budget != 0 {
+				options.Budget = &client.Budget{
+					MaxTokens: budget,
+					Strategy:  budgetStrategy,
+					KeepFirst: keepFirst,
+					KeepLast:  keepLast,
+				}
+			}
+			if toolsFile != "" {
+				content, err := os.ReadFile(toolsFile)
+				if err != nil {
+					exitIfError(fmt.Errorf("fail to read file %s: %w", toolsFile, err))
+				}
+				err = json.Unmarshal(content, &options.Tools)
+				if err != nil {
+					exitIfError(fmt.Errorf("fail to parse tools from file %s: %w", toolsFile, err))
+				}
+			}
 			if contextID != "" && contextName != "" {
 				exitIfError(errors.New("You shoulh pass either a context ID or a context name"))
 			}
@@ -92,6 +147,26 @@ If a context ID is provided, it will be used as input for the conversation. Else
 					Content: string(content),
 				})
 			}
+			for _, result := range toolResults {
+				toolCallID, content, found := strings.Cut(result, ":")
+				if !found {
+					exitIfError(errors.New("tool results should start with the tool call ID"))
+				}
+				msg = append(msg, client.NewMessage{
+					Role:       "tool",
+					Content:    content,
+					ToolCallID: toolCallID,
+				})
+			}
+			parts := []client.ContentPart{}
+			for _, path := range attachments {
+				part, err := attachment(path)
+				exitIfError(err)
+				parts = append(parts, part)
+			}
+			if len(parts) != 0 && !interactive {
+				msg = attachParts(msg, parts)
+			}
 			input := &client.CreateConversationInput{
 				QueryOptions:      options,
 				NewContextOptions: contextOptions,
@@ -115,19 +190,32 @@ If a context ID is provided, it will be used as input for the conversation. Else
 						{
 							Role:    "user",
 							Content: prompt,
+							Parts:   parts,
 						},
 					}
+					// attachments are only sent with the first prompt
+					parts = nil
 					if stream {
 						eventChan, err := c.StreamConversation(ctx, *input)
 						exitIfError(err)
 						for event := range eventChan {
 							fmt.Print(event.Delta)
+							if event.ToolStep != nil {
+								fmt.Printf("\nTool %s called (%s): %s\n", event.ToolStep.ToolCall.Name, string(event.ToolStep.ToolCall.Arguments), event.ToolStep.Output)
+							}
 							if event.Error != "" {
 								fmt.Printf("\nerror: %s\n", event.Error)
 							}
 							if event.InputTokens != 0 {
 								fmt.Printf("\n\nInput tokens: %d, output tokens: %d\n", event.InputTokens, event.OutputTokens)
 							}
+							if event.Truncation != nil && len(event.Truncation.DroppedMessages) != 0 {
+								fmt.Printf("%d messages dropped to respect the token budget\n", len(event.Truncation.DroppedMessages))
+							}
+							if event.Compaction != nil {
+								fmt.Printf("%d messages compacted, archive context %s\n", event.Compaction.CompactedMessages, event.Compaction.ArchiveContext)
+							}
+							printCitations(event.Citations)
 							if event.Context != "" {
 								updatedContextID = event.Context
 							}
@@ -137,9 +225,20 @@ If a context ID is provided, it will be used as input for the conversation. Else
 						answer, err := c.CreateConversation(ctx, *input)
 						exitIfError(err)
 						fmt.Printf("\nAnswer (input tokens %d, output tokens %d):\n\n", answer.InputTokens, answer.OutputTokens)
+						if answer.Truncation != nil && len(answer.Truncation.DroppedMessages) != 0 {
+							fmt.Printf("%d messages dropped to respect the token budget\n", len(answer.Truncation.DroppedMessages))
+						}
+						if answer.Compaction != nil {
+							fmt.Printf("%d messages compacted, archive context %s\n", answer.Compaction.CompactedMessages, answer.Compaction.ArchiveContext)
+						}
 						for _, result := range answer.Results {
+							if result.ToolCall != nil {
+								fmt.Printf("\nTool call %s: %s %s\n", result.ToolCall.ID, result.ToolCall.Name, string(result.ToolCall.Arguments))
+								continue
+							}
 							fmt.Printf("\n%s\n", result.Text)
 						}
+						printCitations(answer.Citations)
 						updatedContextID = answer.Context
 					}
 					fmt.Printf("\nAnything else (write 'exit' to exit the program)?\n\n")
@@ -155,15 +254,13 @@ If a context ID is provided, it will be used as input for the conversation. Else
 		},
 	}
 
-	cmd.PersistentFlags().StringVar(&model, "model", "", "Model to use")
-	err := cmd.MarkPersistentFlagRequired("model")
-	exitIfError(err)
+	cmd.PersistentFlags().StringVar(&model, "model", "", "Model to use. If not set, the default model of the provider is used")
 
 	cmd.PersistentFlags().StringArrayVar(&messages, "message", []string{}, "The messages to send to the AI provider. You can use the {maizai_rag_data} placeholder: it will be replaced by RAG data if a rag input is provided")
 	cmd.PersistentFlags().StringArrayVar(&fileMessages, "message-from-file", []string{}, "A list of files paths, the content will be added to the context. They should be prefixed by the role name (example: user:/my/file)")
 
-	cmd.PersistentFlags().StringVar(&aiProvider, "provider", "", "AI provider to use")
-	err = cmd.MarkPersistentFlagRequired("provider")
+	cmd.PersistentFlags().StringVar(&aiProvider, "provider", "", "Name of the AI provider to use")
+	err := cmd.MarkPersistentFlagRequired("provider")
 	exitIfError(err)
 
 	cmd.PersistentFlags().StringVar(&system, "system", "", "System promt for the AI provider")
@@ -181,6 +278,77 @@ If a context ID is provided, it will be used as input for the conversation. Else
 	cmd.PersistentFlags().StringVar(&ragModel, "rag-model", "mistral-embed", "Model to use for the rag")
 	cmd.PersistentFlags().StringVar(&ragProvider, "rag-provider", "mistral", "The AI provider to use for the rag")
 	cmd.PersistentFlags().Uint32Var(&ragLimit, "rag-limit", 1, "The number of chunks to return from the RAG to enrich the context")
+	cmd.PersistentFlags().StringVar(&ragMode, "rag-mode", "vector", "The RAG search mode (vector or hybrid)")
+	cmd.PersistentFlags().StringSliceVar(&ragDocumentIDs, "rag-document-id", []string{}, "Only search the RAG documents with these IDs")
+	cmd.PersistentFlags().StringSliceVar(&ragDocumentNames, "rag-document-name", []string{}, "Only search the RAG documents with these names")
+	cmd.PersistentFlags().StringVar(&ragSource, "rag-source", "", "Only search the RAG documents with this source")
+	cmd.PersistentFlags().StringToStringVar(&ragLabels, "rag-label", map[string]string{}, "Only search the RAG documents with these labels, for example --rag-label product=maizai")
+	cmd.PersistentFlags().StringToStringVar(&ragMetadata, "rag-metadata", map[string]string{}, "Only search the RAG chunks with these metadata, for example --rag-metadata page=3")
+	cmd.PersistentFlags().Float64Var(&ragMinScore, "rag-min-score", 0, "Drop the RAG chunks with a lower score")
+	cmd.PersistentFlags().Int32Var(&ragCandidates, "rag-candidates", 0, "Number of RAG chunks retrieved before being reranked and trimmed to the limit")
+	cmd.PersistentFlags().StringVar(&ragRerank, "rag-rerank", "", "Name of the reranker used to reorder the RAG chunks")
+	cmd.PersistentFlags().BoolVar(&ragCite, "rag-cite", false, "Ask the AI provider to cite inline the RAG chunks used in its answer")
+	cmd.PersistentFlags().StringVar(&toolsFile, "tools-file", "", "Path to a JSON file containing the list of tools the AI provider can call (name, description, input-schema)")
+	cmd.PersistentFlags().StringArrayVar(&toolResults, "tool-result", []string{}, "Result of a tool call to send to the AI provider. It should be prefixed by the tool call ID (example: toolu_123:sunny)")
+	cmd.PersistentFlags().StringArrayVar(&attachments, "attach", []string{}, "Path of an image (jpeg, png, gif, webp) or PDF file to attach to the user message")
+	cmd.PersistentFlags().StringArrayVar(&serverTools, "server-tool", []string{}, "Name of a tool registered in MaizAI that the AI provider can call. The tool is executed by MaizAI")
+	cmd.PersistentFlags().Uint64Var(&budget, "budget", 0, "Maximum number of input tokens (estimated) sent to the AI provider. Context messages are dropped when the budget is exceeded")
+	cmd.PersistentFlags().StringVar(&budgetStrategy, "budget-strategy", "drop-oldest", "The strategy used to drop messages when the budget is exceeded: drop-oldest, keep-first-last or keep-pinned")
+	cmd.PersistentFlags().IntVar(&keepFirst, "keep-first", 0, "Number of messages kept at the beginning of the conversation by the keep-first-last strategy")
+	cmd.PersistentFlags().IntVar(&keepLast, "keep-last", 0, "Number of messages kept at the end of the conversation history by the keep-first-last strategy")
 	exitIfError(err)
 	return cmd
 }
+
+func printCitations(citations []client.Citation) {
+	if len(citations) == 0 {
+		return
+	}
+	fmt.Printf("\nSources:\n")
+	for _, citation := range citations {
+		if citation.Score != nil {
+			fmt.Printf("- chunk %s from document %s (score %.3f)\n", citation.ChunkID, citation.DocumentID, *citation.Score)
+		} else {
+			fmt.Printf("- chunk %s from document %s\n", citation.ChunkID, citation.DocumentID)
+		}
+	}
+}
+
+// attachment reads a file and builds an image or document content part from it
+func attachment(path string) (client.ContentPart, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return client.ContentPart{}, fmt.Errorf("fail to read file %s: %w", path, err)
+	}
+	mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(content)
+	}
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	partType := "image"
+	if mediaType == "application/pdf" {
+		partType = "document"
+	} else if !strings.HasPrefix(mediaType, "image/") {
+		return client.ContentPart{}, fmt.Errorf("unsupported media type %s for file %s: only images and PDF files can be attached", mediaType, path)
+	}
+	return client.ContentPart{
+		Type:      partType,
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(content),
+	}, nil
+}
+
+// attachParts adds the parts to the last user message, or to a new user message
+// if there is no user message
+func attachParts(messages []client.NewMessage, parts []client.ContentPart) []client.NewMessage {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			messages[i].Parts = append(messages[i].Parts, parts...)
+			return messages
+		}
+	}
+	return append(messages, client.NewMessage{
+		Role:  "user",
+		Parts: parts,
+	})
+}
@@ -2,30 +2,46 @@ package cmd
 
 import (
 	"context"
+	"os"
 
 	"github.com/appclacks/maizai/internal/http/client"
 	"github.com/spf13/cobra"
 )
 
 func documentListCmd() *cobra.Command {
+	var name string
+	var source string
+	var labels map[string]string
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List documents",
 		Run: func(cmd *cobra.Command, args []string) {
-			client, err := client.New()
+			c, err := client.New()
 			exitIfError(err)
 			ctx := context.Background()
-			contexts, err := client.ListDocuments(ctx)
+			contexts, err := c.ListDocuments(ctx, client.ListDocumentsInput{
+				Name:   name,
+				Source: source,
+				Labels: client.KeyValues(labels),
+			})
 			exitIfError(err)
 			printJson(contexts)
 		},
 	}
+	cmd.PersistentFlags().StringVar(&name, "name", "", "Only list the document with this name")
+	cmd.PersistentFlags().StringVar(&source, "source", "", "Only list the documents with this source")
+	cmd.PersistentFlags().StringToStringVar(&labels, "label", map[string]string{}, "Only list the documents with these labels, for example --label product=maizai")
 	return cmd
 }
 
 func documentCreateCmd() *cobra.Command {
 	var name string
 	var description string
+	var embeddingProvider string
+	var embeddingModel string
+	var dimension int
+	var labels map[string]string
+	var source string
 	cmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create a new document",
@@ -34,8 +50,13 @@ func documentCreateCmd() *cobra.Command {
 			exitIfError(err)
 			ctx := context.Background()
 			input := client.CreateDocumentInput{
-				Name:        name,
-				Description: description,
+				Name:              name,
+				Description:       description,
+				EmbeddingProvider: embeddingProvider,
+				EmbeddingModel:    embeddingModel,
+				Dimension:         dimension,
+				Labels:            labels,
+				Source:            source,
 			}
 			response, err := c.CreateDocument(ctx, input)
 			exitIfError(err)
@@ -46,6 +67,11 @@ func documentCreateCmd() *cobra.Command {
 	err := cmd.MarkPersistentFlagRequired("name")
 	exitIfError(err)
 	cmd.PersistentFlags().StringVar(&description, "description", "", "The description of the new document")
+	cmd.PersistentFlags().StringVar(&embeddingProvider, "embedding-provider", "", "The provider used to embed the document chunks. If not set, the provider used for the first chunk is recorded")
+	cmd.PersistentFlags().StringVar(&embeddingModel, "embedding-model", "", "The model used to embed the document chunks. If not set, the model used for the first chunk is recorded")
+	cmd.PersistentFlags().IntVar(&dimension, "dimension", 0, "The dimension of the document chunks embeddings. If not set, the dimension of the first chunk is recorded")
+	cmd.PersistentFlags().StringToStringVar(&labels, "label", map[string]string{}, "Document labels, for example --label product=maizai")
+	cmd.PersistentFlags().StringVar(&source, "source", "", "The URI the document content comes from, for example https://example.com/doc")
 	return cmd
 }
 
@@ -54,6 +80,7 @@ func documentEmbedCmd() *cobra.Command {
 	var model string
 	var aiProvider string
 	var docID string
+	var metadata map[string]string
 	cmd := &cobra.Command{
 		Use:   "embed",
 		Short: "Embed a chunk for a specific document, that will be stored in MaiZAI RAG",
@@ -66,6 +93,7 @@ func documentEmbedCmd() *cobra.Command {
 				Model:      model,
 				Input:      input,
 				Provider:   aiProvider,
+				Metadata:   metadata,
 			}
 			response, err := c.EmbedDocument(ctx, input)
 			exitIfError(err)
@@ -80,6 +108,55 @@ func documentEmbedCmd() *cobra.Command {
 	exitIfError(err)
 	cmd.PersistentFlags().StringVar(&model, "model", "mistral-embed", "The model to use")
 	cmd.PersistentFlags().StringVar(&aiProvider, "provider", "mistral", "The AI provider to use")
+	cmd.PersistentFlags().StringToStringVar(&metadata, "metadata", map[string]string{}, "Metadata stored with the chunk, for example --metadata page=3")
+	return cmd
+}
+
+func documentIngestCmd() *cobra.Command {
+	var file string
+	var model string
+	var aiProvider string
+	var docID string
+	var strategy string
+	var size int
+	var overlap int
+	var metadata map[string]string
+	cmd := &cobra.Command{
+		Use:   "ingest",
+		Short: "Split a text or Markdown file in chunks, embed them and store them in MaizAI RAG",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			content, err := os.ReadFile(file)
+			exitIfError(err)
+			ctx := context.Background()
+			input := client.IngestDocumentInput{
+				DocumentID: docID,
+				Content:    string(content),
+				Model:      model,
+				Provider:   aiProvider,
+				Strategy:   strategy,
+				Size:       size,
+				Overlap:    overlap,
+				Metadata:   metadata,
+			}
+			response, err := c.IngestDocument(ctx, input)
+			exitIfError(err)
+			printJson(*response)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&file, "file", "", "Path to the file to ingest")
+	err := cmd.MarkPersistentFlagRequired("file")
+	exitIfError(err)
+	cmd.PersistentFlags().StringVar(&docID, "document-id", "", "The ID of the document linked to this content")
+	err = cmd.MarkPersistentFlagRequired("document-id")
+	exitIfError(err)
+	cmd.PersistentFlags().StringVar(&model, "model", "mistral-embed", "The model to use")
+	cmd.PersistentFlags().StringVar(&aiProvider, "provider", "mistral", "The AI provider to use")
+	cmd.PersistentFlags().StringVar(&strategy, "strategy", "recursive", "The chunking strategy: fixed, paragraph, markdown or recursive")
+	cmd.PersistentFlags().IntVar(&size, "size", 1000, "The maximum size of a chunk in characters")
+	cmd.PersistentFlags().IntVar(&overlap, "overlap", 0, "The number of characters shared by two consecutive chunks (fixed strategy)")
+	cmd.PersistentFlags().StringToStringVar(&metadata, "metadata", map[string]string{}, "Metadata stored with all the chunks, for example --metadata section=intro")
 	return cmd
 }
 
@@ -128,6 +205,18 @@ func embeddingMatchCmd() *cobra.Command {
 	var input string
 	var model string
 	var aiProvider string
+	var mode string
+	var metric string
+	var efSearch int
+	var probes int
+	var documentIDs []string
+	var documentNames []string
+	var source string
+	var labels map[string]string
+	var metadata map[string]string
+	var minScore float64
+	var candidates int32
+	var rerank string
 	cmd := &cobra.Command{
 		Use:   "match",
 		Short: "Get closest chunks in MAizAI RAG for the input",
@@ -140,6 +229,21 @@ func embeddingMatchCmd() *cobra.Command {
 				Model:    model,
 				Provider: aiProvider,
 				Limit:    limit,
+				Mode:     mode,
+				Metric:   metric,
+				EfSearch: efSearch,
+				Probes:   probes,
+				// a document is searched if its ID or its name is selected
+				DocumentIDs:   documentIDs,
+				DocumentNames: documentNames,
+				Source:        source,
+				Labels:        labels,
+				Metadata:      metadata,
+				Candidates:    candidates,
+				Rerank:        rerank,
+			}
+			if cmd.Flags().Changed("min-score") {
+				input.MinScore = &minScore
 			}
 			contexts, err := c.MatchChunk(ctx, input)
 			exitIfError(err)
@@ -152,24 +256,41 @@ func embeddingMatchCmd() *cobra.Command {
 	cmd.PersistentFlags().StringVar(&model, "model", "mistral-embed", "The model to use")
 	cmd.PersistentFlags().StringVar(&aiProvider, "provider", "mistral", "The AI provider to use")
 	cmd.PersistentFlags().Int32Var(&limit, "limit", 1, "Number of chunks to return")
+	cmd.PersistentFlags().StringVar(&mode, "mode", "vector", "The search mode (vector or hybrid)")
+	cmd.PersistentFlags().StringVar(&metric, "metric", "l2", "The distance metric (l2, cosine or inner-product)")
+	cmd.PersistentFlags().IntVar(&efSearch, "ef-search", 0, "Size of the candidates list used by HNSW indexes during the search")
+	cmd.PersistentFlags().IntVar(&probes, "probes", 0, "Number of lists scanned by IVFFlat indexes during the search")
+	cmd.PersistentFlags().StringSliceVar(&documentIDs, "document-id", []string{}, "Only search the documents with these IDs")
+	cmd.PersistentFlags().StringSliceVar(&documentNames, "document-name", []string{}, "Only search the documents with these names")
+	cmd.PersistentFlags().StringVar(&source, "source", "", "Only search the documents with this source")
+	cmd.PersistentFlags().StringToStringVar(&labels, "label", map[string]string{}, "Only search the documents with these labels, for example --label product=maizai")
+	cmd.PersistentFlags().StringToStringVar(&metadata, "metadata", map[string]string{}, "Only search the chunks with these metadata, for example --metadata page=3")
+	cmd.PersistentFlags().Float64Var(&minScore, "min-score", 0, "Drop the chunks with a lower score")
+	cmd.PersistentFlags().Int32Var(&candidates, "candidates", 0, "Number of chunks retrieved before being reranked and trimmed to the limit")
+	cmd.PersistentFlags().StringVar(&rerank, "rerank", "", "Name of the reranker used to reorder the chunks")
 	return cmd
 }
 
 func documentChunkListCmd() *cobra.Command {
 	var id string
+	var metadata map[string]string
 	cmd := &cobra.Command{
 		Use:   "list-chunks",
 		Short: "List chunks for a document",
 		Run: func(cmd *cobra.Command, args []string) {
-			client, err := client.New()
+			c, err := client.New()
 			exitIfError(err)
 			ctx := context.Background()
-			contexts, err := client.ListDocumentsChunkForDocument(ctx, id)
+			contexts, err := c.ListDocumentsChunkForDocument(ctx, client.ListDocumentChunksForDocumentInput{
+				DocumentID: id,
+				Metadata:   client.KeyValues(metadata),
+			})
 			exitIfError(err)
 			printJson(contexts)
 		},
 	}
 	cmd.PersistentFlags().StringVar(&id, "id", "", "Document ID")
+	cmd.PersistentFlags().StringToStringVar(&metadata, "metadata", map[string]string{}, "Only list the chunks with these metadata, for example --metadata page=3")
 	err := cmd.MarkPersistentFlagRequired("id")
 	exitIfError(err)
 	return cmd
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/appclacks/maizai/internal/http/client"
+	"github.com/spf13/cobra"
+)
+
+func vectorIndexListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the vector indexes on documents chunks",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			indexes, err := c.ListVectorIndexes(ctx)
+			exitIfError(err)
+			printJson(indexes)
+		},
+	}
+	return cmd
+}
+
+func vectorIndexCreateCmd() *cobra.Command {
+	var indexType string
+	var metric string
+	var dimension int
+	var m int
+	var efConstruction int
+	var lists int
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a vector index for the documents chunks embeddings of a given dimension",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			input := client.CreateVectorIndexInput{
+				Type:           indexType,
+				Metric:         metric,
+				Dimension:      dimension,
+				M:              m,
+				EfConstruction: efConstruction,
+				Lists:          lists,
+			}
+			result, err := c.CreateVectorIndex(ctx, input)
+			exitIfError(err)
+			printJson(result)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&indexType, "type", "hnsw", "The index type (hnsw or ivfflat)")
+	cmd.PersistentFlags().StringVar(&metric, "metric", "l2", "The distance metric of the searches using the index (l2, cosine or inner-product)")
+	cmd.PersistentFlags().IntVar(&dimension, "dimension", 0, "The dimension of the embeddings to index")
+	err := cmd.MarkPersistentFlagRequired("dimension")
+	exitIfError(err)
+	cmd.PersistentFlags().IntVar(&m, "m", 0, "The maximum number of connections per layer (hnsw)")
+	cmd.PersistentFlags().IntVar(&efConstruction, "ef-construction", 0, "The size of the candidates list used to build the index (hnsw)")
+	cmd.PersistentFlags().IntVar(&lists, "lists", 0, "The number of inverted lists (ivfflat)")
+	return cmd
+}
+
+func vectorIndexRebuildCmd() *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Rebuild a vector index",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			result, err := c.RebuildVectorIndex(ctx, name)
+			exitIfError(err)
+			printJson(result)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&name, "name", "", "Index name")
+	err := cmd.MarkPersistentFlagRequired("name")
+	exitIfError(err)
+	return cmd
+}
+
+func vectorIndexDeleteCmd() *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete a vector index",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			result, err := c.DropVectorIndex(ctx, name)
+			exitIfError(err)
+			printJson(result)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&name, "name", "", "Index name")
+	err := cmd.MarkPersistentFlagRequired("name")
+	exitIfError(err)
+	return cmd
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/appclacks/maizai/internal/http/client"
+	"github.com/spf13/cobra"
+)
+
+func limitsGetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Get the rate limit and the daily tokens quotas, and the current usage",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			limits, err := c.GetLimits(ctx)
+			exitIfError(err)
+			printJson(limits)
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/appclacks/maizai/config"
+	"github.com/appclacks/maizai/internal/metrics"
+	"github.com/appclacks/maizai/internal/rerank"
+	"github.com/appclacks/maizai/pkg/assistant"
+	"github.com/appclacks/maizai/pkg/rag"
+)
+
+func BuildRerankers(definitions []config.RerankerDefinition, providers map[string]assistant.Provider, recorder assistant.UsageRecorder, metrics *metrics.Metrics) (map[string]rag.Reranker, error) {
+	result := make(map[string]rag.Reranker)
+	for _, definition := range definitions {
+		switch definition.Type {
+		case config.EndpointReranker:
+			result[definition.Name] = rerank.NewEndpoint(rerank.EndpointConfig{
+				Name:    definition.Name,
+				URL:     definition.URL,
+				APIKey:  definition.APIKey,
+				Model:   definition.Model,
+				Timeout: definition.Timeout,
+			})
+		case config.LLMReranker:
+			provider, ok := providers[definition.Provider]
+			if !ok {
+				return nil, fmt.Errorf("provider %s of reranker %s is not configured", definition.Provider, definition.Name)
+			}
+			result[definition.Name] = rerank.NewLLM(rerank.LLMConfig{
+				Name:     definition.Name,
+				Provider: definition.Provider,
+				Model:    definition.Model,
+				Usage:    recorder,
+				Metrics:  metrics,
+			}, provider)
+		}
+	}
+	return result, nil
+}
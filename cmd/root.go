@@ -82,11 +82,42 @@ func Run() error {
 		Use:   "embedding",
 		Short: "Embedding commands",
 	}
+	toolCmd := &cobra.Command{
+		Use:   "tool",
+		Short: "Tool subcommands",
+	}
+	vectorIndexCmd := &cobra.Command{
+		Use:   "vector-index",
+		Short: "Vector index subcommands",
+	}
+	tokenCmd := &cobra.Command{
+		Use:   "token",
+		Short: "API token subcommands",
+	}
+	limitsCmd := &cobra.Command{
+		Use:   "limits",
+		Short: "Rate limits and quotas subcommands",
+	}
+	usageCmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Usage and costs subcommands",
+	}
 	serverCmd := buildServerCmd()
 	embeddingCmd.AddCommand(embeddingMatchCmd())
+	toolCmd.AddCommand(toolListCmd())
+	vectorIndexCmd.AddCommand(vectorIndexListCmd())
+	vectorIndexCmd.AddCommand(vectorIndexCreateCmd())
+	vectorIndexCmd.AddCommand(vectorIndexRebuildCmd())
+	vectorIndexCmd.AddCommand(vectorIndexDeleteCmd())
+	tokenCmd.AddCommand(tokenListCmd())
+	tokenCmd.AddCommand(tokenCreateCmd())
+	tokenCmd.AddCommand(tokenRevokeCmd())
+	limitsCmd.AddCommand(limitsGetCmd())
+	usageCmd.AddCommand(usageReportCmd())
 	documentCmd.AddCommand(documentListCmd())
 	documentCmd.AddCommand(documentCreateCmd())
 	documentCmd.AddCommand(documentEmbedCmd())
+	documentCmd.AddCommand(documentIngestCmd())
 	documentCmd.AddCommand(documentDeleteCmd())
 	documentCmd.AddCommand(documentGetCmd())
 	documentCmd.AddCommand(documentChunkListCmd())
@@ -97,8 +128,10 @@ func Run() error {
 	contextCmd.AddCommand(contextListCmd())
 	contextCmd.AddCommand(contextDeleteCmd())
 	contextCmd.AddCommand(contextGetCmd())
+	contextCmd.AddCommand(contextCompactCmd())
 	contextMessageCmd.AddCommand(addMessagesToContextCmd())
 	contextMessageCmd.AddCommand(messageUpdateCmd())
+	contextMessageCmd.AddCommand(messagePinCmd())
 	contextMessageCmd.AddCommand(deleteContextMessageCmd())
 	contextMessageCmd.AddCommand(deleteContextMessagesCmd())
 	contextSourceCmd.AddCommand(contextSourceContextDeleteCmd())
@@ -111,6 +144,11 @@ func Run() error {
 	rootCmd.AddCommand(conversationCmd)
 	rootCmd.AddCommand(contextCmd)
 	rootCmd.AddCommand(serverCmd)
+	rootCmd.AddCommand(toolCmd)
+	rootCmd.AddCommand(vectorIndexCmd)
+	rootCmd.AddCommand(tokenCmd)
+	rootCmd.AddCommand(limitsCmd)
+	rootCmd.AddCommand(usageCmd)
 	shutdown, err := initOpentelemetry()
 	if err != nil {
 		return err
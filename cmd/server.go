@@ -8,12 +8,17 @@ import (
 	"syscall"
 
 	"github.com/appclacks/maizai/config"
-	"github.com/appclacks/maizai/internal/database"
 	"github.com/appclacks/maizai/internal/http"
 	"github.com/appclacks/maizai/internal/http/handlers"
+	"github.com/appclacks/maizai/internal/metrics"
 	"github.com/appclacks/maizai/pkg/assistant"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	"github.com/appclacks/maizai/pkg/auth"
 	ct "github.com/appclacks/maizai/pkg/context"
+	"github.com/appclacks/maizai/pkg/limits"
 	"github.com/appclacks/maizai/pkg/rag"
+	"github.com/appclacks/maizai/pkg/usage"
+	usagedata "github.com/appclacks/maizai/pkg/usage/aggregates"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 )
@@ -39,21 +44,72 @@ func RunServer() error {
 	registry := prometheus.DefaultRegisterer.(*prometheus.Registry)
 	config, err := config.Load()
 	exitIfError(err)
-	db, err := database.New(config.Store.PostgreSQL)
+	contextStore, ragStore, tokenStore, usageStore, err := BuildStores(config.Store)
 	exitIfError(err)
 	clients, err := BuildProviders(config.Providers)
 	exitIfError(err)
 	embeddingProviders := map[string]rag.AI{}
-	if mistral, ok := clients["mistral"]; ok {
-		embeddingProviders["mistral"] = mistral.(rag.AI)
+	for name, client := range clients {
+		if embeddingClient, ok := client.(rag.AI); ok {
+			embeddingProviders[name] = embeddingClient
+		}
+	}
+	manager := ct.New(contextStore)
+	metrics, err := metrics.New(registry)
+	exitIfError(err)
+	prices := []usagedata.Price{}
+	for _, price := range config.Usage.Prices {
+		prices = append(prices, usagedata.Price{
+			Provider: price.Provider,
+			Model:    price.Model,
+			Input:    price.Input,
+			Output:   price.Output,
+		})
 	}
-	manager := ct.New(db)
+	usageManager := usage.New(usageStore, usage.Config{
+		Prices: prices,
+	})
+	limiter := limits.New(limits.Config{
+		RequestsPerMinute: config.Limits.RequestsPerMinute,
+		DailyInputTokens:  config.Limits.DailyInputTokens,
+		DailyOutputTokens: config.Limits.DailyOutputTokens,
+	})
+	// the tokens used by the LLM calls count in the quotas
+	recorder := usage.Recorders{usageManager, limiter}
+	rerankers, err := BuildRerankers(config.Rag.Rerankers, clients, recorder, metrics)
+	exitIfError(err)
 
-	rag := rag.New(db, embeddingProviders)
-	ai := assistant.New(clients, manager, rag)
+	rag := rag.New(ragStore, embeddingProviders, rag.Config{
+		EmbeddingBatchSize: config.Rag.EmbeddingBatchSize,
+		Rerankers:          rerankers,
+		Metrics:            metrics,
+		Usage:              usageManager,
+	})
+	ai := assistant.New(clients, manager, rag, BuildTools(config.Tools), recorder, assistant.Config{
+		MaxToolIterations: config.Tools.MaxIterations,
+		MaxSourcesDepth:   config.Contexts.SourcesMaxDepth,
+		Compaction: assistant.CompactionConfig{
+			Threshold: config.Contexts.Compaction.Threshold,
+			Options: aggregates.CompactOptions{
+				Provider:  config.Contexts.Compaction.Provider,
+				Model:     config.Contexts.Compaction.Model,
+				MaxTokens: config.Contexts.Compaction.MaxTokens,
+				KeepLast:  config.Contexts.Compaction.KeepLast,
+			},
+		},
+		Metrics: metrics,
+	})
+
+	tokens := auth.New(tokenStore, auth.Config{
+		AdminToken: config.Auth.AdminToken,
+	})
+	var authenticator http.Authenticator
+	if config.Auth.Enabled {
+		authenticator = tokens
+	}
 
-	handlersBuilder := handlers.NewBuilder(ai, manager, rag)
-	server, err := http.New(config.HTTP, registry, handlersBuilder)
+	handlersBuilder := handlers.NewBuilder(ai, manager, rag, tokens, limiter, usageManager)
+	server, err := http.New(config.HTTP, registry, handlersBuilder, authenticator, limiter)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/appclacks/maizai/config"
+	contextmemory "github.com/appclacks/maizai/internal/contextstore/memory"
+	"github.com/appclacks/maizai/internal/database"
+	ragmemory "github.com/appclacks/maizai/internal/ragstore/memory"
+	"github.com/appclacks/maizai/internal/sqlite"
+	tokenmemory "github.com/appclacks/maizai/internal/tokenstore/memory"
+	usagememory "github.com/appclacks/maizai/internal/usagestore/memory"
+	"github.com/appclacks/maizai/pkg/auth"
+	ct "github.com/appclacks/maizai/pkg/context"
+	"github.com/appclacks/maizai/pkg/rag"
+	"github.com/appclacks/maizai/pkg/usage"
+)
+
+func BuildStores(storeConfig config.StoreConfiguration) (ct.ContextStore, rag.Store, auth.Store, usage.Store, error) {
+	switch storeConfig.Type {
+	case config.MemoryStore:
+		return contextmemory.New(), ragmemory.New(), tokenmemory.New(), usagememory.New(), nil
+	case config.PostgreSQLStore:
+		db, err := database.New(storeConfig.PostgreSQL)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		return db, db, db, db, nil
+	case config.SQLiteStore:
+		db, err := sqlite.New(storeConfig.SQLite)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		return db, db, db, db, nil
+	}
+	return nil, nil, nil, nil, fmt.Errorf("unknown store type %s", storeConfig.Type)
+}
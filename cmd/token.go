@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/appclacks/maizai/internal/http/client"
+	"github.com/spf13/cobra"
+)
+
+func tokenListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the API tokens",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			tokens, err := c.ListTokens(ctx)
+			exitIfError(err)
+			printJson(tokens)
+		},
+	}
+	return cmd
+}
+
+func tokenCreateCmd() *cobra.Command {
+	var name string
+	var project string
+	var scopes []string
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API token. The token value is only displayed once",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			result, err := c.CreateToken(ctx, client.CreateTokenInput{
+				Name:    name,
+				Project: project,
+				Scopes:  scopes,
+			})
+			exitIfError(err)
+			printJson(result)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&name, "name", "", "Token name")
+	err := cmd.MarkPersistentFlagRequired("name")
+	exitIfError(err)
+	cmd.PersistentFlags().StringVar(&project, "project", "", "Restrict the token to this project. The token can access all projects if not set")
+	cmd.PersistentFlags().StringSliceVar(&scopes, "scope", []string{"read"}, "Token scopes: read (read-only endpoints), conversation (read-only endpoints, conversations and contexts management) or admin (all endpoints)")
+	return cmd
+}
+
+func tokenRevokeCmd() *cobra.Command {
+	var id string
+	cmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke an API token",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			result, err := c.RevokeToken(ctx, id)
+			exitIfError(err)
+			printJson(result)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&id, "id", "", "Token ID")
+	err := cmd.MarkPersistentFlagRequired("id")
+	exitIfError(err)
+	return cmd
+}
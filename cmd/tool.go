@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/appclacks/maizai/internal/http/client"
+	"github.com/spf13/cobra"
+)
+
+func toolListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the tools registered in MaizAI",
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			tools, err := client.ListTools(ctx)
+			exitIfError(err)
+			printJson(tools)
+		},
+	}
+	return cmd
+}
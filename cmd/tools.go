@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"encoding/json"
+
+	"github.com/appclacks/maizai/config"
+	"github.com/appclacks/maizai/internal/tools"
+	"github.com/appclacks/maizai/pkg/assistant"
+)
+
+func BuildTools(config config.ToolsConfiguration) []assistant.ToolRunner {
+	result := []assistant.ToolRunner{}
+	for _, definition := range config.Definitions {
+		// the schema was already validated when loading the configuration
+		schema, _ := json.Marshal(definition.InputSchema)
+		result = append(result, tools.NewHTTPTool(tools.Config{
+			Name:        definition.Name,
+			Description: definition.Description,
+			InputSchema: schema,
+			URL:         definition.URL,
+			Timeout:     definition.Timeout,
+			Headers:     definition.Headers,
+		}))
+	}
+	return result
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/appclacks/maizai/internal/http/client"
+	"github.com/spf13/cobra"
+)
+
+func usageReportCmd() *cobra.Command {
+	var groupBy []string
+	var since string
+	var until string
+	var contextID string
+	var token string
+	var model string
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Get a report of the AI providers usage: requests, errors, tokens, latency and cost",
+		Run: func(cmd *cobra.Command, args []string) {
+			c, err := client.New()
+			exitIfError(err)
+			ctx := context.Background()
+			report, err := c.UsageReport(ctx, client.UsageReportInput{
+				GroupBy: strings.Join(groupBy, ","),
+				Since:   since,
+				Until:   until,
+				Context: contextID,
+				Token:   token,
+				Model:   model,
+			})
+			exitIfError(err)
+			printJson(report)
+		},
+	}
+	cmd.PersistentFlags().StringSliceVar(&groupBy, "group-by", []string{}, "The dimensions of the report rows: day, model, context or token")
+	cmd.PersistentFlags().StringVar(&since, "since", "", "Only report the usage since this date (RFC3339 or YYYY-MM-DD), included")
+	cmd.PersistentFlags().StringVar(&until, "until", "", "Only report the usage until this date (RFC3339 or YYYY-MM-DD), excluded")
+	cmd.PersistentFlags().StringVar(&contextID, "context", "", "Only report the usage of this context ID")
+	cmd.PersistentFlags().StringVar(&token, "token", "", "Only report the usage of this token ID")
+	cmd.PersistentFlags().StringVar(&model, "model", "", "Only report the usage of this model")
+	return cmd
+}
@@ -2,25 +2,115 @@ package config
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/appclacks/maizai/internal/database"
 	"github.com/appclacks/maizai/internal/http"
+	"github.com/appclacks/maizai/internal/sqlite"
 	"github.com/sethvargo/go-envconfig"
 )
 
+const MemoryStore = "memory"
+const PostgreSQLStore = "postgresql"
+const SQLiteStore = "sqlite"
+
 type StoreConfiguration struct {
-	Type       string `env:"MAIZAI_STORE_TYPE, default=memory"`
+	Type       string `env:"MAIZAI_STORE_TYPE, default=postgresql"`
 	PostgreSQL database.Configuration
+	SQLite     sqlite.Configuration
 }
 
 type ProvidersConfiguration struct {
-	Anthropic string `env:"MAIZAI_ANTHROPIC_API_KEY"`
-	Mistral   string `env:"MAIZAI_MISTRAL_API_KEY"`
+	Anthropic     string `env:"MAIZAI_ANTHROPIC_API_KEY"`
+	Mistral       string `env:"MAIZAI_MISTRAL_API_KEY"`
+	OpenAI        string `env:"MAIZAI_OPENAI_API_KEY"`
+	OpenAIBaseURL string `env:"MAIZAI_OPENAI_BASE_URL"`
+	ConfigPath    string `env:"MAIZAI_PROVIDERS_CONFIG_PATH"`
+	Instances     []ProviderInstance
+}
+
+type ToolsConfiguration struct {
+	ConfigPath string `env:"MAIZAI_TOOLS_CONFIG_PATH"`
+	// MaxIterations limits the number of provider calls done for a single
+	// conversation when server-side tools are used
+	MaxIterations int `env:"MAIZAI_TOOLS_MAX_ITERATIONS, default=10"`
+	Definitions   []ToolDefinition
+}
+
+type CompactionConfiguration struct {
+	// Threshold is the number of messages above which a context is compacted
+	// after a conversation. Automatic compaction is disabled when set to 0.
+	Threshold int    `env:"MAIZAI_CONTEXT_COMPACTION_THRESHOLD, default=0"`
+	Provider  string `env:"MAIZAI_CONTEXT_COMPACTION_PROVIDER"`
+	Model     string `env:"MAIZAI_CONTEXT_COMPACTION_MODEL"`
+	MaxTokens uint64 `env:"MAIZAI_CONTEXT_COMPACTION_MAX_TOKENS, default=4096"`
+	// KeepLast is the number of recent messages which are not compacted
+	KeepLast int `env:"MAIZAI_CONTEXT_COMPACTION_KEEP_LAST, default=10"`
+}
+
+func (c CompactionConfiguration) Validate() error {
+	if c.Threshold <= 0 {
+		return nil
+	}
+	if c.Provider == "" {
+		return errors.New("MAIZAI_CONTEXT_COMPACTION_PROVIDER is mandatory when automatic compaction is enabled")
+	}
+	if c.KeepLast < 0 || c.KeepLast >= c.Threshold {
+		return fmt.Errorf("the number of messages kept by the compaction (%d) should be lower than the compaction threshold (%d)", c.KeepLast, c.Threshold)
+	}
+	return nil
+}
+
+type ContextsConfiguration struct {
+	// SourcesMaxDepth limits the depth of the context sources used to enrich a conversation
+	SourcesMaxDepth int `env:"MAIZAI_CONTEXT_SOURCES_MAX_DEPTH, default=10"`
+	Compaction      CompactionConfiguration
+}
+
+type RagConfiguration struct {
+	// EmbeddingBatchSize is the maximum number of chunks embedded in a single
+	// provider call when a document is ingested
+	EmbeddingBatchSize int `env:"MAIZAI_RAG_EMBEDDING_BATCH_SIZE, default=32"`
+	// RerankersConfigPath is the path of the YAML file defining the rerankers
+	RerankersConfigPath string `env:"MAIZAI_RERANKERS_CONFIG_PATH"`
+	Rerankers           []RerankerDefinition
+}
+
+type AuthConfiguration struct {
+	// Enabled requires a token with the right scope on the API endpoints
+	Enabled bool `env:"MAIZAI_AUTH_ENABLED, default=false"`
+	// AdminToken is a token with the admin scope which is not stored in the database.
+	// It can be used to create the first tokens.
+	AdminToken string `env:"MAIZAI_AUTH_ADMIN_TOKEN"`
+}
+
+type LimitsConfiguration struct {
+	// RequestsPerMinute is the number of API requests allowed per minute for
+	// each token, or for all the requests when the authentication is disabled
+	RequestsPerMinute int `env:"MAIZAI_LIMITS_REQUESTS_PER_MINUTE, default=0"`
+	// DailyInputTokens and DailyOutputTokens are the number of tokens allowed
+	// per day for each token (or for all the requests), provider and model
+	DailyInputTokens  uint64 `env:"MAIZAI_LIMITS_DAILY_INPUT_TOKENS, default=0"`
+	DailyOutputTokens uint64 `env:"MAIZAI_LIMITS_DAILY_OUTPUT_TOKENS, default=0"`
+}
+
+type UsageConfiguration struct {
+	// PricesConfigPath is the path of the YAML file defining the models prices
+	// used to compute the cost of the usage reports
+	PricesConfigPath string `env:"MAIZAI_PRICES_CONFIG_PATH"`
+	Prices           []PriceDefinition
 }
 
 type Configuration struct {
 	Providers ProvidersConfiguration
+	Tools     ToolsConfiguration
+	Contexts  ContextsConfiguration
+	Rag       RagConfiguration
 	Store     StoreConfiguration
+	Auth      AuthConfiguration
+	Limits    LimitsConfiguration
+	Usage     UsageConfiguration
 	HTTP      http.Configuration
 }
 
@@ -29,5 +119,36 @@ func Load() (*Configuration, error) {
 	if err := envconfig.Process(context.Background(), &c); err != nil {
 		return nil, err
 	}
+	if err := c.Contexts.Compaction.Validate(); err != nil {
+		return nil, err
+	}
+	if c.Providers.ConfigPath != "" {
+		instances, err := loadProviders(c.Providers.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		c.Providers.Instances = instances
+	}
+	if c.Tools.ConfigPath != "" {
+		definitions, err := loadTools(c.Tools.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		c.Tools.Definitions = definitions
+	}
+	if c.Rag.RerankersConfigPath != "" {
+		rerankers, err := loadRerankers(c.Rag.RerankersConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		c.Rag.Rerankers = rerankers
+	}
+	if c.Usage.PricesConfigPath != "" {
+		prices, err := loadPrices(c.Usage.PricesConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		c.Usage.Prices = prices
+	}
 	return &c, nil
 }
@@ -0,0 +1,237 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/appclacks/maizai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadProviders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.yaml")
+	content := `
+providers:
+  - name: mistral-eu
+    type: mistral
+    api-key-env: TEST_MISTRAL_EU_KEY
+    timeout: 30s
+    default-model: mistral-small-latest
+    default-embedding-model: mistral-embed
+  - name: local
+    type: openai
+    base-url: http://localhost:8000
+`
+	err := os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	t.Setenv("TEST_MISTRAL_EU_KEY", "secret")
+	t.Setenv("MAIZAI_PROVIDERS_CONFIG_PATH", path)
+
+	c, err := config.Load()
+	assert.NoError(t, err)
+	assert.Len(t, c.Providers.Instances, 2)
+	assert.Equal(t, "mistral-eu", c.Providers.Instances[0].Name)
+	assert.Equal(t, config.MistralProvider, c.Providers.Instances[0].Type)
+	assert.Equal(t, "secret", c.Providers.Instances[0].APIKey)
+	assert.Equal(t, 30*time.Second, c.Providers.Instances[0].Timeout)
+	assert.Equal(t, "mistral-small-latest", c.Providers.Instances[0].DefaultModel)
+	assert.Equal(t, "mistral-embed", c.Providers.Instances[0].DefaultEmbeddingModel)
+	assert.Equal(t, "local", c.Providers.Instances[1].Name)
+	assert.Equal(t, "http://localhost:8000", c.Providers.Instances[1].BaseURL)
+	assert.Empty(t, os.Getenv("TEST_MISTRAL_EU_KEY"))
+
+	content = `
+providers:
+  - name: foo
+    type: mistral
+  - name: foo
+    type: anthropic
+`
+	err = os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "defined multiple times")
+
+	content = `
+providers:
+  - name: foo
+    type: unknown
+`
+	err = os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "Invalid type unknown")
+}
+
+func TestLoadTools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tools.yaml")
+	content := `
+tools:
+  - name: weather
+    description: Get the weather for a city
+    url: http://localhost:9000/weather
+    timeout: 5s
+    headers:
+      Authorization: Bearer foo
+    input-schema:
+      type: object
+      properties:
+        city:
+          type: string
+      required:
+        - city
+`
+	err := os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	t.Setenv("MAIZAI_TOOLS_CONFIG_PATH", path)
+
+	c, err := config.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 10, c.Tools.MaxIterations)
+	assert.Len(t, c.Tools.Definitions, 1)
+	assert.Equal(t, "weather", c.Tools.Definitions[0].Name)
+	assert.Equal(t, "http://localhost:9000/weather", c.Tools.Definitions[0].URL)
+	assert.Equal(t, 5*time.Second, c.Tools.Definitions[0].Timeout)
+	assert.Equal(t, "Bearer foo", c.Tools.Definitions[0].Headers["Authorization"])
+	assert.Equal(t, "object", c.Tools.Definitions[0].InputSchema["type"])
+
+	content = `
+tools:
+  - name: weather
+    url: localhost
+    input-schema:
+      type: object
+`
+	err = os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "Invalid URL")
+
+	content = `
+tools:
+  - name: weather
+    url: http://localhost:9000
+`
+	err = os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "An input schema is mandatory")
+}
+
+func TestCompactionConfigurationValidate(t *testing.T) {
+	assert.NoError(t, config.CompactionConfiguration{}.Validate())
+	assert.NoError(t, config.CompactionConfiguration{Threshold: 50, Provider: "anthropic", KeepLast: 10}.Validate())
+	assert.ErrorContains(t, config.CompactionConfiguration{Threshold: 50, KeepLast: 10}.Validate(), "MAIZAI_CONTEXT_COMPACTION_PROVIDER is mandatory")
+	assert.ErrorContains(t, config.CompactionConfiguration{Threshold: 10, Provider: "anthropic", KeepLast: 10}.Validate(), "should be lower than the compaction threshold")
+}
+
+func TestLoadRerankers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rerankers.yaml")
+	content := `
+rerankers:
+  - name: cohere
+    type: endpoint
+    url: https://api.cohere.com/v2/rerank
+    api-key-env: TEST_COHERE_KEY
+    model: rerank-v3.5
+    timeout: 10s
+  - name: judge
+    type: llm
+    provider: mistral
+    model: mistral-small-latest
+`
+	err := os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	t.Setenv("TEST_COHERE_KEY", "secret")
+	t.Setenv("MAIZAI_RERANKERS_CONFIG_PATH", path)
+
+	c, err := config.Load()
+	assert.NoError(t, err)
+	assert.Len(t, c.Rag.Rerankers, 2)
+	assert.Equal(t, "cohere", c.Rag.Rerankers[0].Name)
+	assert.Equal(t, config.EndpointReranker, c.Rag.Rerankers[0].Type)
+	assert.Equal(t, "https://api.cohere.com/v2/rerank", c.Rag.Rerankers[0].URL)
+	assert.Equal(t, "secret", c.Rag.Rerankers[0].APIKey)
+	assert.Equal(t, 10*time.Second, c.Rag.Rerankers[0].Timeout)
+	assert.Equal(t, "judge", c.Rag.Rerankers[1].Name)
+	assert.Equal(t, "mistral", c.Rag.Rerankers[1].Provider)
+	assert.Empty(t, os.Getenv("TEST_COHERE_KEY"))
+
+	content = `
+rerankers:
+  - name: cohere
+    type: endpoint
+    url: localhost
+`
+	err = os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "Invalid URL")
+
+	content = `
+rerankers:
+  - name: judge
+    type: llm
+`
+	err = os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "The provider of the reranker judge is mandatory")
+}
+
+func TestLoadPrices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.yaml")
+	content := `
+prices:
+  - provider: anthropic
+    model: claude-sonnet-4-5
+    input: 3
+    output: 15
+  - provider: mistral
+    input: 0.1
+    output: 0.3
+`
+	err := os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	t.Setenv("MAIZAI_PRICES_CONFIG_PATH", path)
+
+	c, err := config.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []config.PriceDefinition{
+		{Provider: "anthropic", Model: "claude-sonnet-4-5", Input: 3, Output: 15},
+		{Provider: "mistral", Input: 0.1, Output: 0.3},
+	}, c.Usage.Prices)
+
+	content = `
+prices:
+  - provider: mistral
+    input: -1
+`
+	err = os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "can't be negative")
+
+	content = `
+prices:
+  - provider: mistral
+    model: small
+  - provider: mistral
+    model: small
+`
+	err = os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "defined multiple times")
+
+	content = `
+prices:
+  - model: small
+`
+	err = os.WriteFile(path, []byte(content), 0600)
+	assert.NoError(t, err)
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "The provider of a price is mandatory")
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PriceDefinition is the price of a model for one million tokens. The calls are
+// recorded with the model used by the provider, even when it's the default model.
+type PriceDefinition struct {
+	Provider string  `yaml:"provider"`
+	Model    string  `yaml:"model"`
+	Input    float64 `yaml:"input"`
+	Output   float64 `yaml:"output"`
+}
+
+type pricesFile struct {
+	Prices []PriceDefinition `yaml:"prices"`
+}
+
+func (p PriceDefinition) Validate() error {
+	if p.Provider == "" {
+		return errors.New("The provider of a price is mandatory")
+	}
+	if p.Input < 0 || p.Output < 0 {
+		return fmt.Errorf("The price of the model %s of provider %s can't be negative", p.Model, p.Provider)
+	}
+	return nil
+}
+
+func loadPrices(path string) ([]PriceDefinition, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read prices configuration file %s: %w", path, err)
+	}
+	var file pricesFile
+	err = yaml.Unmarshal(content, &file)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse prices configuration file %s: %w", path, err)
+	}
+	type key struct {
+		provider string
+		model    string
+	}
+	defined := make(map[key]bool)
+	for _, price := range file.Prices {
+		err := price.Validate()
+		if err != nil {
+			return nil, err
+		}
+		k := key{provider: price.Provider, model: price.Model}
+		if defined[k] {
+			return nil, fmt.Errorf("the price of the model %s of provider %s is defined multiple times", price.Model, price.Provider)
+		}
+		defined[k] = true
+	}
+	return file.Prices, nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const AnthropicProvider = "anthropic"
+const MistralProvider = "mistral"
+const OpenAIProvider = "openai"
+
+type ProviderInstance struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	// APIKey can be omitted in favor of APIKeyEnv to avoid storing secrets in the file
+	APIKey       string        `yaml:"api-key"`
+	APIKeyEnv    string        `yaml:"api-key-env"`
+	BaseURL      string        `yaml:"base-url"`
+	Timeout      time.Duration `yaml:"timeout"`
+	DefaultModel string        `yaml:"default-model"`
+	// DefaultEmbeddingModel is used by the embedding queries without model
+	DefaultEmbeddingModel string `yaml:"default-embedding-model"`
+}
+
+type providersFile struct {
+	Providers []ProviderInstance `yaml:"providers"`
+}
+
+func (p ProviderInstance) Validate() error {
+	if p.Name == "" {
+		return errors.New("A provider name is mandatory")
+	}
+	if p.Type != AnthropicProvider && p.Type != MistralProvider && p.Type != OpenAIProvider {
+		return fmt.Errorf("Invalid type %s for provider %s: the type should be %s, %s or %s", p.Type, p.Name, AnthropicProvider, MistralProvider, OpenAIProvider)
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("Invalid timeout for provider %s", p.Name)
+	}
+	return nil
+}
+
+func loadProviders(path string) ([]ProviderInstance, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read providers configuration file %s: %w", path, err)
+	}
+	var file providersFile
+	err = yaml.Unmarshal(content, &file)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse providers configuration file %s: %w", path, err)
+	}
+	names := make(map[string]bool)
+	for i, provider := range file.Providers {
+		err := provider.Validate()
+		if err != nil {
+			return nil, err
+		}
+		if names[provider.Name] {
+			return nil, fmt.Errorf("provider %s is defined multiple times", provider.Name)
+		}
+		names[provider.Name] = true
+		if provider.APIKeyEnv != "" {
+			file.Providers[i].APIKey = os.Getenv(provider.APIKeyEnv)
+			os.Unsetenv(provider.APIKeyEnv)
+		}
+	}
+	return file.Providers, nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EndpointReranker calls a rerank HTTP endpoint compatible with the Cohere rerank API
+const EndpointReranker = "endpoint"
+
+// LLMReranker asks a model of a configured provider to judge the relevance of the chunks
+const LLMReranker = "llm"
+
+type RerankerDefinition struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	// URL is the rerank endpoint URL, for endpoint rerankers
+	URL string `yaml:"url"`
+	// APIKey can be omitted in favor of APIKeyEnv to avoid storing secrets in the file
+	APIKey    string `yaml:"api-key"`
+	APIKeyEnv string `yaml:"api-key-env"`
+	// Provider is the name of the provider used by llm rerankers
+	Provider string        `yaml:"provider"`
+	Model    string        `yaml:"model"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+type rerankersFile struct {
+	Rerankers []RerankerDefinition `yaml:"rerankers"`
+}
+
+func (r RerankerDefinition) Validate() error {
+	if r.Name == "" {
+		return errors.New("A reranker name is mandatory")
+	}
+	switch r.Type {
+	case EndpointReranker:
+		u, err := url.Parse(r.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Invalid URL %s for reranker %s: an HTTP or HTTPS URL is mandatory", r.URL, r.Name)
+		}
+	case LLMReranker:
+		if r.Provider == "" {
+			return fmt.Errorf("The provider of the reranker %s is mandatory", r.Name)
+		}
+	default:
+		return fmt.Errorf("Invalid type %s for reranker %s: the type should be %s or %s", r.Type, r.Name, EndpointReranker, LLMReranker)
+	}
+	if r.Timeout < 0 {
+		return fmt.Errorf("Invalid timeout for reranker %s", r.Name)
+	}
+	return nil
+}
+
+func loadRerankers(path string) ([]RerankerDefinition, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read rerankers configuration file %s: %w", path, err)
+	}
+	var file rerankersFile
+	err = yaml.Unmarshal(content, &file)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse rerankers configuration file %s: %w", path, err)
+	}
+	names := make(map[string]bool)
+	for i, reranker := range file.Rerankers {
+		err := reranker.Validate()
+		if err != nil {
+			return nil, err
+		}
+		if names[reranker.Name] {
+			return nil, fmt.Errorf("reranker %s is defined multiple times", reranker.Name)
+		}
+		names[reranker.Name] = true
+		if reranker.APIKeyEnv != "" {
+			file.Rerankers[i].APIKey = os.Getenv(reranker.APIKeyEnv)
+			os.Unsetenv(reranker.APIKeyEnv)
+		}
+	}
+	return file.Rerankers, nil
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ToolDefinition struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// InputSchema is the JSON schema of the tool arguments, written in YAML
+	InputSchema map[string]any `yaml:"input-schema"`
+	// URL is the HTTP endpoint receiving the tool arguments in a POST request
+	URL     string            `yaml:"url"`
+	Timeout time.Duration     `yaml:"timeout"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+type toolsFile struct {
+	Tools []ToolDefinition `yaml:"tools"`
+}
+
+func (t ToolDefinition) Validate() error {
+	if t.Name == "" {
+		return errors.New("A tool name is mandatory")
+	}
+	if len(t.InputSchema) == 0 {
+		return fmt.Errorf("An input schema is mandatory for tool %s", t.Name)
+	}
+	if _, err := json.Marshal(t.InputSchema); err != nil {
+		return fmt.Errorf("Invalid input schema for tool %s: %w", t.Name, err)
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid URL %s for tool %s: an HTTP or HTTPS URL is mandatory", t.URL, t.Name)
+	}
+	return nil
+}
+
+func loadTools(path string) ([]ToolDefinition, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read tools configuration file %s: %w", path, err)
+	}
+	var file toolsFile
+	err = yaml.Unmarshal(content, &file)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse tools configuration file %s: %w", path, err)
+	}
+	names := make(map[string]bool)
+	for _, tool := range file.Tools {
+		err := tool.Validate()
+		if err != nil {
+			return nil, err
+		}
+		if names[tool.Name] {
+			return nil, fmt.Errorf("tool %s is defined multiple times", tool.Name)
+		}
+		names[tool.Name] = true
+	}
+	return file.Tools, nil
+}
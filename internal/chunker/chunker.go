@@ -0,0 +1,233 @@
+package chunker
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Fixed splits the text in chunks of the same size, with an optional overlap
+	Fixed = "fixed"
+	// Paragraph splits the text on blank lines and merges the small paragraphs
+	Paragraph = "paragraph"
+	// Markdown splits the text on Markdown headings
+	Markdown = "markdown"
+	// Recursive splits the text on paragraphs, then lines, then sentences, then words
+	Recursive = "recursive"
+)
+
+const DefaultSize = 1000
+
+var headingRegexp = regexp.MustCompile(`^#{1,6}\s`)
+
+// recursiveSeparators are tried in order to split a text which is too large
+var recursiveSeparators = []string{"\n\n", "\n", ". ", " "}
+
+type Options struct {
+	Strategy string
+	// Size is the maximum size of a chunk, in characters
+	Size int
+	// Overlap is the number of characters shared by two consecutive chunks
+	// when a text is split at a fixed size
+	Overlap int
+}
+
+func (o Options) Validate() error {
+	switch o.Strategy {
+	case Fixed, Paragraph, Markdown, Recursive:
+	default:
+		return fmt.Errorf("Invalid chunking strategy %s, supported strategies are %s, %s, %s and %s", o.Strategy, Fixed, Paragraph, Markdown, Recursive)
+	}
+	if o.Size <= 0 {
+		return errors.New("The chunk size should be greater than 0")
+	}
+	if o.Overlap < 0 || o.Overlap >= o.Size {
+		return errors.New("The chunk overlap should be positive and lower than the chunk size")
+	}
+	return nil
+}
+
+// Chunk is a part of a text. Start and End are the positions (in characters)
+// of the chunk in the text.
+type Chunk struct {
+	Text  string
+	Start int
+	End   int
+}
+
+type span struct {
+	start int
+	end   int
+}
+
+type splitter struct {
+	text    []rune
+	size    int
+	overlap int
+}
+
+func (s *splitter) fixed(sp span) []span {
+	result := []span{}
+	for start := sp.start; start < sp.end; start += s.size - s.overlap {
+		end := min(start+s.size, sp.end)
+		result = append(result, span{start: start, end: end})
+		if end == sp.end {
+			break
+		}
+	}
+	return result
+}
+
+func (s *splitter) matches(position int, separator []rune) bool {
+	for i, r := range separator {
+		if s.text[position+i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// split splits the span on the separator. The separator is kept at the end of each part.
+func (s *splitter) split(sp span, separator string) []span {
+	sep := []rune(separator)
+	result := []span{}
+	start := sp.start
+	for i := sp.start; i+len(sep) <= sp.end; i++ {
+		if s.matches(i, sep) {
+			result = append(result, span{start: start, end: i + len(sep)})
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	if start < sp.end {
+		result = append(result, span{start: start, end: sp.end})
+	}
+	return result
+}
+
+// length returns the size of the span without its leading and trailing whitespaces
+func (s *splitter) length(sp span) int {
+	trimmed := s.trim(sp)
+	return trimmed.end - trimmed.start
+}
+
+// merge merges consecutive parts while they fit in a chunk. The parts
+// which are too large are split recursively using the separators.
+func (s *splitter) merge(parts []span, separators []string) []span {
+	result := []span{}
+	var current *span
+	for _, part := range parts {
+		if current != nil && s.length(span{start: current.start, end: part.end}) <= s.size {
+			current.end = part.end
+			continue
+		}
+		if current != nil {
+			result = append(result, *current)
+			current = nil
+		}
+		if s.length(part) > s.size {
+			result = append(result, s.recursive(part, separators)...)
+			continue
+		}
+		p := part
+		current = &p
+	}
+	if current != nil {
+		result = append(result, *current)
+	}
+	return result
+}
+
+func (s *splitter) recursive(sp span, separators []string) []span {
+	if s.length(sp) <= s.size {
+		return []span{sp}
+	}
+	for i, separator := range separators {
+		parts := s.split(sp, separator)
+		if len(parts) > 1 {
+			return s.merge(parts, separators[i+1:])
+		}
+	}
+	return s.fixed(sp)
+}
+
+func (s *splitter) paragraphs(sp span) []span {
+	return s.merge(s.split(sp, "\n\n"), recursiveSeparators[1:])
+}
+
+// sections splits the text before each Markdown heading. Headings in code blocks are ignored.
+func (s *splitter) sections(sp span) []span {
+	result := []span{}
+	start := sp.start
+	inCode := false
+	for _, line := range s.split(sp, "\n") {
+		content := string(s.text[line.start:line.end])
+		if strings.HasPrefix(content, "```") {
+			inCode = !inCode
+		}
+		if !inCode && headingRegexp.MatchString(content) && line.start > start {
+			result = append(result, span{start: start, end: line.start})
+			start = line.start
+		}
+	}
+	if start < sp.end {
+		result = append(result, span{start: start, end: sp.end})
+	}
+	chunks := []span{}
+	for _, section := range result {
+		chunks = append(chunks, s.recursive(section, recursiveSeparators)...)
+	}
+	return chunks
+}
+
+// trim removes the whitespaces at the beginning and at the end of the span
+func (s *splitter) trim(sp span) span {
+	for sp.start < sp.end && unicode.IsSpace(s.text[sp.start]) {
+		sp.start++
+	}
+	for sp.end > sp.start && unicode.IsSpace(s.text[sp.end-1]) {
+		sp.end--
+	}
+	return sp
+}
+
+// Split splits a text into chunks using the strategy. Empty chunks are ignored.
+func Split(text string, options Options) ([]Chunk, error) {
+	err := options.Validate()
+	if err != nil {
+		return nil, err
+	}
+	s := &splitter{
+		text:    []rune(text),
+		size:    options.Size,
+		overlap: options.Overlap,
+	}
+	all := span{start: 0, end: len(s.text)}
+	var spans []span
+	switch options.Strategy {
+	case Fixed:
+		spans = s.fixed(all)
+	case Paragraph:
+		spans = s.paragraphs(all)
+	case Markdown:
+		spans = s.sections(all)
+	case Recursive:
+		spans = s.recursive(all, recursiveSeparators)
+	}
+	result := []Chunk{}
+	for _, sp := range spans {
+		sp = s.trim(sp)
+		if sp.start == sp.end {
+			continue
+		}
+		result = append(result, Chunk{
+			Text:  string(s.text[sp.start:sp.end]),
+			Start: sp.start,
+			End:   sp.end,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package chunker_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/chunker"
+	"github.com/stretchr/testify/assert"
+)
+
+func texts(chunks []chunker.Chunk) []string {
+	result := []string{}
+	for _, chunk := range chunks {
+		result = append(result, chunk.Text)
+	}
+	return result
+}
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		name    string
+		text    string
+		options chunker.Options
+		result  []string
+	}{
+		{
+			name:    "fixed",
+			text:    "abcdefghij",
+			options: chunker.Options{Strategy: chunker.Fixed, Size: 4},
+			result:  []string{"abcd", "efgh", "ij"},
+		},
+		{
+			name:    "fixed with overlap",
+			text:    "abcdefghij",
+			options: chunker.Options{Strategy: chunker.Fixed, Size: 4, Overlap: 2},
+			result:  []string{"abcd", "cdef", "efgh", "ghij"},
+		},
+		{
+			name:    "paragraph",
+			text:    "first paragraph\n\nsecond\n\nthird paragraph is longer\n\n",
+			options: chunker.Options{Strategy: chunker.Paragraph, Size: 25},
+			result:  []string{"first paragraph\n\nsecond", "third paragraph is longer"},
+		},
+		{
+			name:    "paragraph too large",
+			text:    "small\n\nthis paragraph. is too large",
+			options: chunker.Options{Strategy: chunker.Paragraph, Size: 16},
+			result:  []string{"small", "this paragraph.", "is too large"},
+		},
+		{
+			name:    "markdown",
+			text:    "intro\n# Title\ncontent\n```\n# not a heading\n```\n## Subtitle\nmore content",
+			options: chunker.Options{Strategy: chunker.Markdown, Size: 100},
+			result:  []string{"intro", "# Title\ncontent\n```\n# not a heading\n```", "## Subtitle\nmore content"},
+		},
+		{
+			name:    "recursive",
+			text:    "line one\nline two is long\n\nlast",
+			options: chunker.Options{Strategy: chunker.Recursive, Size: 12},
+			result:  []string{"line one", "line two is", "long", "last"},
+		},
+		{
+			name:    "unicode",
+			text:    "héllowörld",
+			options: chunker.Options{Strategy: chunker.Fixed, Size: 5},
+			result:  []string{"héllo", "wörld"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			chunks, err := chunker.Split(c.text, c.options)
+			assert.NoError(t, err)
+			assert.Equal(t, c.result, texts(chunks))
+			runes := []rune(c.text)
+			for _, chunk := range chunks {
+				assert.Equal(t, chunk.Text, string(runes[chunk.Start:chunk.End]))
+				assert.LessOrEqual(t, chunk.End-chunk.Start, c.options.Size)
+			}
+		})
+	}
+}
+
+func TestSplitLargeText(t *testing.T) {
+	text := strings.Repeat("This is a sentence. ", 500)
+	for _, strategy := range []string{chunker.Fixed, chunker.Paragraph, chunker.Markdown, chunker.Recursive} {
+		chunks, err := chunker.Split(text, chunker.Options{Strategy: strategy, Size: 300, Overlap: 20})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, chunks)
+		for _, chunk := range chunks {
+			assert.LessOrEqual(t, len([]rune(chunk.Text)), 300)
+		}
+	}
+}
+
+func TestSplitInvalidOptions(t *testing.T) {
+	_, err := chunker.Split("text", chunker.Options{Strategy: "unknown", Size: 10})
+	assert.ErrorContains(t, err, "Invalid chunking strategy unknown")
+	_, err = chunker.Split("text", chunker.Options{Strategy: chunker.Fixed})
+	assert.ErrorContains(t, err, "The chunk size should be greater than 0")
+	_, err = chunker.Split("text", chunker.Options{Strategy: chunker.Fixed, Size: 10, Overlap: 10})
+	assert.ErrorContains(t, err, "lower than the chunk size")
+}
@@ -3,6 +3,7 @@ package memory
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/appclacks/maizai/pkg/shared"
@@ -20,51 +21,82 @@ func New() *MemoryContextStore {
 	}
 }
 
-func (m *MemoryContextStore) DeleteContext(ctx context.Context, id string) error {
+// clone returns a copy of a context so callers can't modify the store state
+func clone(c *shared.Context) *shared.Context {
+	result := *c
+	result.Sources.Contexts = append([]string{}, c.Sources.Contexts...)
+	result.Messages = []shared.Message{}
+	for _, message := range c.Messages {
+		message.ToolCalls = append([]shared.ToolCall(nil), message.ToolCalls...)
+		message.Parts = append([]shared.ContentPart(nil), message.Parts...)
+		result.Messages = append(result.Messages, message)
+	}
+	return &result
+}
+
+func (m *MemoryContextStore) DeleteContext(ctx context.Context, project string, id string) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	if _, err := m.get(ctx, project, id); err != nil {
+		return nil
+	}
 	delete(m.state, id)
+	// also remove the context from the sources of other contexts
+	for _, context := range m.state {
+		sources := []string{}
+		for _, source := range context.Sources.Contexts {
+			if source != id {
+				sources = append(sources, source)
+			}
+		}
+		context.Sources.Contexts = sources
+	}
 	return nil
 }
 
-func (m *MemoryContextStore) ContextExists(ctx context.Context, id string) (bool, error) {
+func (m *MemoryContextStore) ContextExists(ctx context.Context, project string, id string) (bool, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
-	_, ok := m.state[id]
-	return ok, nil
+	context, ok := m.state[id]
+	return ok && context.Project == project, nil
 }
 
-func (m *MemoryContextStore) ContextExistsByName(ctx context.Context, name string) (bool, error) {
+func (m *MemoryContextStore) ContextExistsByName(ctx context.Context, project string, name string) (bool, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 	for _, context := range m.state {
-		if context.Name == name {
+		if context.Project == project && context.Name == name {
 			return true, nil
 		}
 	}
 	return false, nil
 }
 
-func (m *MemoryContextStore) get(_ context.Context, id string) (*shared.Context, error) {
+// get returns the context if it belongs to the project
+func (m *MemoryContextStore) get(_ context.Context, project string, id string) (*shared.Context, error) {
 	context, ok := m.state[id]
-	if !ok {
+	if !ok || context.Project != project {
 		return nil, er.Newf("context %s doesn't exist", er.NotFound, true, id)
 	}
 	return context, nil
 }
 
-func (m *MemoryContextStore) GetContext(ctx context.Context, id string) (*shared.Context, error) {
+func (m *MemoryContextStore) GetContext(ctx context.Context, project string, id string) (*shared.Context, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
-	return m.get(ctx, id)
+	context, err := m.get(ctx, project, id)
+	if err != nil {
+		return nil, err
+	}
+	return clone(context), nil
 }
 
-func (m *MemoryContextStore) GetByName(ctx context.Context, name string) (*shared.Context, error) {
+func (m *MemoryContextStore) GetByName(ctx context.Context, project string, name string) (*shared.Context, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 	for _, context := range m.state {
-		if context.Name == name {
-			return context, nil
+		if context.Project == project && context.Name == name {
+			return clone(context), nil
 		}
 	}
 	return nil, fmt.Errorf("context %s doesn't exist", name)
@@ -73,30 +105,39 @@ func (m *MemoryContextStore) GetByName(ctx context.Context, name string) (*share
 func (m *MemoryContextStore) CreateContext(ctx context.Context, context shared.Context) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	m.state[context.ID] = &context
+	m.state[context.ID] = clone(&context)
 	return nil
 }
 
-func (m *MemoryContextStore) ListContexts(ctx context.Context) ([]shared.ContextMetadata, error) {
+func (m *MemoryContextStore) ListContexts(ctx context.Context, project string) ([]shared.ContextMetadata, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 	result := []shared.ContextMetadata{}
 	for k, v := range m.state {
+		if v.Project != project {
+			continue
+		}
 		result = append(result, shared.ContextMetadata{
 			ID:          k,
+			Project:     v.Project,
 			Name:        v.Name,
 			Description: v.Description,
 			CreatedAt:   v.CreatedAt,
-			Sources:     v.Sources,
+			Sources: shared.ContextSources{
+				Contexts: append([]string{}, v.Sources.Contexts...),
+			},
 		})
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
 	return result, nil
 }
 
-func (m *MemoryContextStore) AddMessages(ctx context.Context, id string, messages []shared.Message) error {
+func (m *MemoryContextStore) AddMessages(ctx context.Context, project string, id string, messages []shared.Message) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	context, err := m.get(ctx, id)
+	context, err := m.get(ctx, project, id)
 	if err != nil {
 		return err
 	}
@@ -104,10 +145,13 @@ func (m *MemoryContextStore) AddMessages(ctx context.Context, id string, message
 	return nil
 }
 
-func (m *MemoryContextStore) DeleteContextMessage(ctx context.Context, messageID string) error {
+func (m *MemoryContextStore) DeleteContextMessage(ctx context.Context, project string, messageID string) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	for _, context := range m.state {
+		if context.Project != project {
+			continue
+		}
 		for i := range context.Messages {
 			msgID := context.Messages[i].ID
 			if msgID == messageID {
@@ -117,25 +161,25 @@ func (m *MemoryContextStore) DeleteContextMessage(ctx context.Context, messageID
 			}
 		}
 	}
-	return nil
+	return er.Newf("context message %s doesn't exist", er.NotFound, true, messageID)
 }
 
-func (m *MemoryContextStore) DeleteContextMessages(ctx context.Context, contextID string) error {
+func (m *MemoryContextStore) DeleteContextMessages(ctx context.Context, project string, contextID string) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	context, found := m.state[contextID]
-	if !found {
+	if !found || context.Project != project {
 		return fmt.Errorf("context %s doesn't exist", contextID)
 	}
 	context.Messages = []shared.Message{}
 	return nil
 }
 
-func (m *MemoryContextStore) DeleteContextSourceContext(ctx context.Context, contextID string, sourceContextID string) error {
+func (m *MemoryContextStore) DeleteContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	context, ok := m.state[contextID]
-	if !ok {
+	if !ok || context.Project != project {
 		return fmt.Errorf("context %s doesn't exist", contextID)
 	}
 	sources := []string{}
@@ -154,10 +198,61 @@ func (m *MemoryContextStore) DeleteContextSourceContext(ctx context.Context, con
 	return nil
 }
 
-func (m *MemoryContextStore) UpdateContextMessage(ctx context.Context, messageID string, role string, content string) error {
+func (m *MemoryContextStore) CompactContext(ctx context.Context, project string, contextID string, messageIDs []string, summary shared.Message, archive shared.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	context, err := m.get(ctx, project, contextID)
+	if err != nil {
+		return err
+	}
+	compacted := make(map[string]bool)
+	for _, id := range messageIDs {
+		compacted[id] = true
+	}
+	messages := []shared.Message{}
+	for _, message := range context.Messages {
+		if !compacted[message.ID] {
+			messages = append(messages, message)
+			continue
+		}
+		// the summary takes the place of the oldest compacted message
+		if len(compacted) == len(messageIDs) {
+			messages = append(messages, summary)
+		}
+		delete(compacted, message.ID)
+	}
+	if len(compacted) != 0 {
+		return er.Newf("the messages of context %s were modified during the compaction", er.Conflict, true, contextID)
+	}
+	context.Messages = messages
+	m.state[archive.ID] = clone(&archive)
+	return nil
+}
+
+func (m *MemoryContextStore) PinContextMessage(ctx context.Context, project string, messageID string, pinned bool) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, context := range m.state {
+		if context.Project != project {
+			continue
+		}
+		for i := range context.Messages {
+			if context.Messages[i].ID == messageID {
+				context.Messages[i].Pinned = pinned
+				return nil
+			}
+		}
+	}
+	return er.Newf("context message %s doesn't exist", er.NotFound, true, messageID)
+}
+
+func (m *MemoryContextStore) UpdateContextMessage(ctx context.Context, project string, messageID string, role string, content string) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	for _, context := range m.state {
+		if context.Project != project {
+			continue
+		}
 		for i := range context.Messages {
 			msgID := context.Messages[i].ID
 			if msgID == messageID {
@@ -167,15 +262,23 @@ func (m *MemoryContextStore) UpdateContextMessage(ctx context.Context, messageID
 			}
 		}
 	}
-	return nil
+	return er.Newf("context message %s doesn't exist", er.NotFound, true, messageID)
 }
 
-func (m *MemoryContextStore) CreateContextSourceContext(ctx context.Context, contextID string, sourceContextID string) error {
+func (m *MemoryContextStore) CreateContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	context, ok := m.state[contextID]
-	if !ok {
-		return fmt.Errorf("context %s doesn't exist", sourceContextID)
+	context, err := m.get(ctx, project, contextID)
+	if err != nil {
+		return err
+	}
+	if _, err := m.get(ctx, project, sourceContextID); err != nil {
+		return err
+	}
+	for _, source := range context.Sources.Contexts {
+		if source == sourceContextID {
+			return fmt.Errorf("context %s is already a source of context %s", sourceContextID, contextID)
+		}
 	}
 	context.Sources.Contexts = append(context.Sources.Contexts, sourceContextID)
 	return nil
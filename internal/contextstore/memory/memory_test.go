@@ -13,12 +13,13 @@ import (
 func TestMemoryStore(t *testing.T) {
 	store := memory.New()
 	ctx := context.Background()
-	contexts, err := store.ListContexts(ctx)
+	contexts, err := store.ListContexts(ctx, shared.DefaultProject)
 	assert.NoError(t, err)
 	assert.Len(t, contexts, 0)
 
 	context := shared.Context{
 		ID:          uuid.New().String(),
+		Project:     shared.DefaultProject,
 		Name:        "foo",
 		Description: "bar",
 		Sources: shared.ContextSources{
@@ -33,17 +34,17 @@ func TestMemoryStore(t *testing.T) {
 		},
 	}
 
-	exists, err := store.ContextExists(ctx, context.ID)
+	exists, err := store.ContextExists(ctx, shared.DefaultProject, context.ID)
 	assert.NoError(t, err)
 	assert.False(t, exists)
 
-	_, err = store.GetContext(ctx, context.ID)
+	_, err = store.GetContext(ctx, shared.DefaultProject, context.ID)
 	assert.Error(t, err)
 
 	err = store.CreateContext(ctx, context)
 	assert.NoError(t, err)
 
-	contexts, err = store.ListContexts(ctx)
+	contexts, err = store.ListContexts(ctx, shared.DefaultProject)
 	assert.NoError(t, err)
 	assert.Len(t, contexts, 1)
 	assert.Equal(t, contexts[0].ID, context.ID)
@@ -51,11 +52,11 @@ func TestMemoryStore(t *testing.T) {
 	assert.Equal(t, contexts[0].Sources.Contexts[0], context.Sources.Contexts[0])
 	assert.Equal(t, contexts[0].Description, context.Description)
 
-	exists, err = store.ContextExists(ctx, context.ID)
+	exists, err = store.ContextExists(ctx, shared.DefaultProject, context.ID)
 	assert.NoError(t, err)
 	assert.True(t, exists)
 
-	result, err := store.GetContext(ctx, context.ID)
+	result, err := store.GetContext(ctx, shared.DefaultProject, context.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, context.Description, result.Description)
 	assert.Len(t, context.Messages, 1)
@@ -72,37 +73,49 @@ func TestMemoryStore(t *testing.T) {
 			Content: "message3",
 		},
 	}
-	err = store.AddMessages(ctx, context.ID, newMessages)
+	err = store.AddMessages(ctx, shared.DefaultProject, context.ID, newMessages)
 	assert.NoError(t, err)
 
-	result, err = store.GetContext(ctx, context.ID)
+	result, err = store.GetContext(ctx, shared.DefaultProject, context.ID)
 	assert.Equal(t, context.Description, result.Description)
 	assert.NoError(t, err)
 	assert.Len(t, result.Messages, 3)
 
-	err = store.DeleteContextSourceContext(ctx, context.ID, context.Sources.Contexts[0])
+	err = store.DeleteContextSourceContext(ctx, shared.DefaultProject, context.ID, context.Sources.Contexts[0])
 	assert.NoError(t, err)
 
-	err = store.UpdateContextMessage(ctx, context.Messages[0].ID, shared.AssistantRole, "updated content")
+	err = store.UpdateContextMessage(ctx, shared.DefaultProject, context.Messages[0].ID, shared.AssistantRole, "updated content")
 	assert.NoError(t, err)
 
-	result, err = store.GetContext(ctx, context.ID)
+	result, err = store.GetContext(ctx, shared.DefaultProject, context.ID)
 	assert.Equal(t, context.Description, result.Description)
 	assert.NoError(t, err)
 	assert.Len(t, result.Messages, 3)
 	assert.Equal(t, result.Messages[0].Content, "updated content")
 	assert.Equal(t, result.Messages[0].Role, shared.AssistantRole)
 
-	err = store.DeleteContextMessage(ctx, context.Messages[0].ID)
+	err = store.DeleteContextMessage(ctx, shared.DefaultProject, context.Messages[0].ID)
 	assert.NoError(t, err)
-	result, err = store.GetContext(ctx, context.ID)
+	result, err = store.GetContext(ctx, shared.DefaultProject, context.ID)
 	assert.NoError(t, err)
 	assert.Len(t, result.Messages, 2)
 
-	err = store.DeleteContext(ctx, context.ID)
+	_, err = store.GetContext(ctx, "other", context.ID)
+	assert.ErrorContains(t, err, "doesn't exist")
+	err = store.UpdateContextMessage(ctx, "other", result.Messages[0].ID, shared.UserRole, "updated")
+	assert.ErrorContains(t, err, "doesn't exist")
+	err = store.PinContextMessage(ctx, "other", result.Messages[0].ID, true)
+	assert.ErrorContains(t, err, "doesn't exist")
+	err = store.DeleteContextMessage(ctx, shared.DefaultProject, context.Messages[0].ID)
+	assert.ErrorContains(t, err, "doesn't exist")
+	contexts, err = store.ListContexts(ctx, "other")
+	assert.NoError(t, err)
+	assert.Len(t, contexts, 0)
+
+	err = store.DeleteContext(ctx, shared.DefaultProject, context.ID)
 	assert.NoError(t, err)
 
-	contexts, err = store.ListContexts(ctx)
+	contexts, err = store.ListContexts(ctx, shared.DefaultProject)
 	assert.NoError(t, err)
 	assert.Len(t, contexts, 0)
 }
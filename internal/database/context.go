@@ -2,36 +2,57 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 
 	"github.com/appclacks/maizai/internal/database/queries"
 	"github.com/appclacks/maizai/pkg/shared"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	er "github.com/mcorbin/corbierror"
 )
 
-func (c *Database) CreateContext(ctx context.Context, context shared.Context) error {
-	tx, err := c.conn.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		return err
+func createContextMessage(ctx context.Context, qtx *queries.Queries, contextID string, message shared.Message) error {
+	var toolCalls []byte
+	if len(message.ToolCalls) != 0 {
+		var err error
+		toolCalls, err = json.Marshal(message.ToolCalls)
+		if err != nil {
+			return err
+		}
 	}
-	shouldRollback := true
-	defer func() {
-		if shouldRollback {
-			err := tx.Rollback(ctx)
-			if err != nil {
-				slog.Error(err.Error())
-			}
+	var parts []byte
+	if len(message.Parts) != 0 {
+		var err error
+		parts, err = json.Marshal(message.Parts)
+		if err != nil {
+			return err
 		}
-	}()
-	qtx := c.queries.WithTx(tx)
+	}
+	_, err := qtx.CreateContextMessage(
+		ctx,
+		queries.CreateContextMessageParams{
+			ID:         pgxID(message.ID),
+			Role:       message.Role,
+			Content:    message.Content,
+			CreatedAt:  pgxTime(message.CreatedAt),
+			ContextID:  pgxID(contextID),
+			ToolCalls:  toolCalls,
+			ToolCallID: pgtype.Text{String: message.ToolCallID, Valid: message.ToolCallID != ""},
+			Parts:      parts,
+			Pinned:     message.Pinned,
+		})
+	return err
+}
 
-	_, err = qtx.CreateContext(ctx, queries.CreateContextParams{
+func createContext(ctx context.Context, qtx *queries.Queries, context shared.Context) error {
+	_, err := qtx.CreateContext(ctx, queries.CreateContextParams{
 		ID:          pgxID(context.ID),
 		Name:        context.Name,
 		Description: pgxText(context.Description),
 		CreatedAt:   pgxTime(context.CreatedAt),
+		Project:     context.Project,
 	})
 	if err != nil {
 		return err
@@ -47,25 +68,42 @@ func (c *Database) CreateContext(ctx context.Context, context shared.Context) er
 		}
 	}
 	for _, message := range context.Messages {
-		_, err := qtx.CreateContextMessage(
-			ctx,
-			queries.CreateContextMessageParams{
-				ID:        pgxID(message.ID),
-				Role:      message.Role,
-				Content:   message.Content,
-				CreatedAt: pgxTime(message.CreatedAt),
-				ContextID: pgxID(context.ID),
-			})
+		err := createContextMessage(ctx, qtx, context.ID, message)
 		if err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+func (c *Database) CreateContext(ctx context.Context, context shared.Context) error {
+	tx, err := c.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	shouldRollback := true
+	defer func() {
+		if shouldRollback {
+			err := tx.Rollback(ctx)
+			if err != nil {
+				slog.Error(err.Error())
+			}
+		}
+	}()
+	qtx := c.queries.WithTx(tx)
+	err = createContext(ctx, qtx, context)
+	if err != nil {
+		return err
+	}
 	shouldRollback = false
 	return tx.Commit(ctx)
 }
 
-func (c *Database) GetContext(ctx context.Context, id string) (*shared.Context, error) {
-	context, err := c.queries.GetContext(ctx, pgxID(id))
+func (c *Database) GetContext(ctx context.Context, project string, id string) (*shared.Context, error) {
+	context, err := c.queries.GetContext(ctx, queries.GetContextParams{
+		ID:      pgxID(id),
+		Project: project,
+	})
 	if err != nil {
 		if err != pgx.ErrNoRows {
 			return nil, err
@@ -74,6 +112,7 @@ func (c *Database) GetContext(ctx context.Context, id string) (*shared.Context,
 	}
 	result := shared.Context{
 		ID:          context.ID.String(),
+		Project:     context.Project,
 		Name:        context.Name,
 		Description: context.Description.String,
 		CreatedAt:   context.CreatedAt.Time,
@@ -86,12 +125,27 @@ func (c *Database) GetContext(ctx context.Context, id string) (*shared.Context,
 		return nil, err
 	}
 	for _, message := range messages {
-		result.Messages = append(result.Messages, shared.Message{
-			ID:        message.ID.String(),
-			Role:      message.Role,
-			Content:   message.Content,
-			CreatedAt: message.CreatedAt.Time,
-		})
+		msg := shared.Message{
+			ID:         message.ID.String(),
+			Role:       message.Role,
+			Content:    message.Content,
+			CreatedAt:  message.CreatedAt.Time,
+			ToolCallID: message.ToolCallID.String,
+			Pinned:     message.Pinned,
+		}
+		if message.ToolCalls != nil {
+			err := json.Unmarshal(message.ToolCalls, &msg.ToolCalls)
+			if err != nil {
+				return nil, fmt.Errorf("fail to read tool calls for message %s: %w", msg.ID, err)
+			}
+		}
+		if message.Parts != nil {
+			err := json.Unmarshal(message.Parts, &msg.Parts)
+			if err != nil {
+				return nil, fmt.Errorf("fail to read content parts for message %s: %w", msg.ID, err)
+			}
+		}
+		result.Messages = append(result.Messages, msg)
 	}
 	sources, err := c.queries.GetContextSourcesForContext(ctx, pgxID(id))
 	if err != nil {
@@ -104,14 +158,14 @@ func (c *Database) GetContext(ctx context.Context, id string) (*shared.Context,
 
 }
 
-func (c *Database) AddMessages(ctx context.Context, id string, messages []shared.Message) error {
+func (c *Database) AddMessages(ctx context.Context, project string, id string, messages []shared.Message) error {
 
 	tx, qtx, rollbackFn, err := c.beginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
 	}
 	defer rollbackFn()
-	exists, err := c.exists(qtx, ctx, id)
+	exists, err := c.exists(qtx, ctx, project, id)
 	if err != nil {
 		return err
 	}
@@ -119,15 +173,7 @@ func (c *Database) AddMessages(ctx context.Context, id string, messages []shared
 		return er.Newf("context %s doesn't exist", er.NotFound, true, id)
 	}
 	for _, message := range messages {
-		_, err := qtx.CreateContextMessage(
-			ctx,
-			queries.CreateContextMessageParams{
-				ID:        pgxID(message.ID),
-				Role:      message.Role,
-				Content:   message.Content,
-				CreatedAt: pgxTime(message.CreatedAt),
-				ContextID: pgxID(id),
-			})
+		err := createContextMessage(ctx, qtx, id, message)
 		if err != nil {
 			return err
 		}
@@ -135,13 +181,71 @@ func (c *Database) AddMessages(ctx context.Context, id string, messages []shared
 	return tx.Commit(ctx)
 }
 
-func (c *Database) DeleteContext(ctx context.Context, id string) error {
+// CompactContext creates the archive context and replaces the messages of the context by the summary message.
+// The summary takes the place of the oldest replaced message.
+func (c *Database) CompactContext(ctx context.Context, project string, contextID string, messageIDs []string, summary shared.Message, archive shared.Context) error {
+	tx, qtx, rollbackFn, err := c.beginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer rollbackFn()
+	exists, err := c.exists(qtx, ctx, project, contextID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return er.Newf("context %s doesn't exist", er.NotFound, true, contextID)
+	}
+	err = createContext(ctx, qtx, archive)
+	if err != nil {
+		return err
+	}
+	ids := []pgtype.UUID{}
+	for _, id := range messageIDs {
+		ids = append(ids, pgxID(id))
+	}
+	orderings, err := qtx.DeleteContextMessagesByIDs(ctx, queries.DeleteContextMessagesByIDsParams{
+		ContextID: pgxID(contextID),
+		Ids:       ids,
+	})
+	if err != nil {
+		return err
+	}
+	if len(orderings) != len(messageIDs) {
+		return er.Newf("the messages of context %s were modified during the compaction", er.Conflict, true, contextID)
+	}
+	err = createContextMessage(ctx, qtx, contextID, summary)
+	if err != nil {
+		return err
+	}
+	ordering := orderings[0]
+	for _, o := range orderings {
+		ordering = min(ordering, o)
+	}
+	err = qtx.UpdateContextMessageOrdering(ctx, queries.UpdateContextMessageOrderingParams{
+		ID:       pgxID(summary.ID),
+		Ordering: ordering,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (c *Database) DeleteContext(ctx context.Context, project string, id string) error {
 	tx, err := c.conn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(ctx) //nolint
 	qtx := c.queries.WithTx(tx)
+	exists, err := c.exists(qtx, ctx, project, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
 	err = qtx.CleanContextSourcesForContext(ctx, pgxID(id))
 	if err != nil {
 		return err
@@ -161,12 +265,15 @@ func (c *Database) DeleteContext(ctx context.Context, id string) error {
 	return tx.Commit(ctx)
 }
 
-func (c *Database) ContextExists(ctx context.Context, id string) (bool, error) {
-	return c.exists(c.queries, ctx, id)
+func (c *Database) ContextExists(ctx context.Context, project string, id string) (bool, error) {
+	return c.exists(c.queries, ctx, project, id)
 }
 
-func (c *Database) exists(queries *queries.Queries, ctx context.Context, id string) (bool, error) {
-	_, err := queries.GetContextNameByID(ctx, pgxID(id))
+func (c *Database) exists(q *queries.Queries, ctx context.Context, project string, id string) (bool, error) {
+	_, err := q.GetContextNameByID(ctx, queries.GetContextNameByIDParams{
+		ID:      pgxID(id),
+		Project: project,
+	})
 	if err != nil {
 		if err != pgx.ErrNoRows {
 			return false, fmt.Errorf("fail to get context %s: %w", id, err)
@@ -176,8 +283,11 @@ func (c *Database) exists(queries *queries.Queries, ctx context.Context, id stri
 	return true, nil
 }
 
-func (c *Database) ContextExistsByName(ctx context.Context, name string) (bool, error) {
-	_, err := c.queries.GetContextIDByName(ctx, name)
+func (c *Database) ContextExistsByName(ctx context.Context, project string, name string) (bool, error) {
+	_, err := c.queries.GetContextIDByName(ctx, queries.GetContextIDByNameParams{
+		Project: project,
+		Name:    name,
+	})
 	if err != nil {
 		if err != pgx.ErrNoRows {
 			return false, fmt.Errorf("fail to get context %s: %w", name, err)
@@ -187,8 +297,8 @@ func (c *Database) ContextExistsByName(ctx context.Context, name string) (bool,
 	return true, nil
 }
 
-func (c *Database) ListContexts(ctx context.Context) ([]shared.ContextMetadata, error) {
-	metadata, err := c.queries.ListContexts(ctx)
+func (c *Database) ListContexts(ctx context.Context, project string) ([]shared.ContextMetadata, error) {
+	metadata, err := c.queries.ListContexts(ctx, project)
 	if err != nil {
 		return nil, err
 	}
@@ -196,6 +306,7 @@ func (c *Database) ListContexts(ctx context.Context) ([]shared.ContextMetadata,
 	for _, m := range metadata {
 		metadata := shared.ContextMetadata{
 			ID:          m.ID.String(),
+			Project:     m.Project,
 			Name:        m.Name,
 			Description: m.Description.String,
 			CreatedAt:   m.CreatedAt.Time,
@@ -213,34 +324,93 @@ func (c *Database) ListContexts(ctx context.Context) ([]shared.ContextMetadata,
 
 }
 
-func (c *Database) UpdateContextMessage(ctx context.Context, messageID string, role string, content string) error {
-	return c.queries.UpdateContextMessage(ctx, queries.UpdateContextMessageParams{
+func (c *Database) UpdateContextMessage(ctx context.Context, project string, messageID string, role string, content string) error {
+	affected, err := c.queries.UpdateContextMessage(ctx, queries.UpdateContextMessageParams{
 		ID:      pgxID(messageID),
 		Role:    role,
 		Content: content,
+		Project: project,
 	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return er.Newf("context message %s doesn't exist", er.NotFound, true, messageID)
+	}
+	return nil
 }
 
-func (c *Database) DeleteContextMessage(ctx context.Context, messageID string) error {
-	return c.queries.DeleteContextMessage(ctx, pgxID(messageID))
+func (c *Database) PinContextMessage(ctx context.Context, project string, messageID string, pinned bool) error {
+	affected, err := c.queries.PinContextMessage(ctx, queries.PinContextMessageParams{
+		ID:      pgxID(messageID),
+		Pinned:  pinned,
+		Project: project,
+	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return er.Newf("context message %s doesn't exist", er.NotFound, true, messageID)
+	}
+	return nil
+}
+
+func (c *Database) DeleteContextMessage(ctx context.Context, project string, messageID string) error {
+	affected, err := c.queries.DeleteContextMessage(ctx, queries.DeleteContextMessageParams{
+		ID:      pgxID(messageID),
+		Project: project,
+	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return er.Newf("context message %s doesn't exist", er.NotFound, true, messageID)
+	}
+	return nil
 }
 
-func (c *Database) DeleteContextSourceContext(ctx context.Context, contextID string, sourceContextID string) error {
+func (c *Database) DeleteContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error {
 	return c.queries.DeleteContextSource(ctx, queries.DeleteContextSourceParams{
 		ContextID:       pgxID(contextID),
 		SourceContextID: pgxID(sourceContextID),
+		Project:         project,
 	})
 }
 
-func (c *Database) CreateContextSourceContext(ctx context.Context, contextID string, sourceContextID string) error {
-	return c.queries.CreateContexSource(ctx, queries.CreateContexSourceParams{
+func (c *Database) CreateContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error {
+	tx, qtx, rollbackFn, err := c.beginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer rollbackFn()
+	for _, id := range []string{contextID, sourceContextID} {
+		exists, err := c.exists(qtx, ctx, project, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return er.Newf("context %s doesn't exist", er.NotFound, true, id)
+		}
+	}
+	err = qtx.CreateContexSource(ctx, queries.CreateContexSourceParams{
 		ContextID:       pgxID(contextID),
 		SourceContextID: pgxID(sourceContextID),
 	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
-func (c *Database) DeleteContextMessages(ctx context.Context, contextID string) error {
-	err := c.queries.DeleteContextMessagesForContext(ctx, pgxID(contextID))
+func (c *Database) DeleteContextMessages(ctx context.Context, project string, contextID string) error {
+	exists, err := c.ContextExists(ctx, project, contextID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	err = c.queries.DeleteContextMessagesForContext(ctx, pgxID(contextID))
 	if err != nil {
 		return fmt.Errorf("failed to delete messages for context %s: %w", contextID, err)
 	}
@@ -2,20 +2,75 @@ package database
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 
 	"github.com/appclacks/maizai/internal/database/queries"
 	"github.com/appclacks/maizai/pkg/rag/aggregates"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	er "github.com/mcorbin/corbierror"
 	"github.com/pgvector/pgvector-go"
 )
 
+func toDocument(document queries.Document) (*aggregates.Document, error) {
+	result := &aggregates.Document{
+		ID:                document.ID.String(),
+		Project:           document.Project,
+		Name:              document.Name,
+		Description:       document.Description.String,
+		CreatedAt:         document.CreatedAt.Time,
+		EmbeddingProvider: document.EmbeddingProvider,
+		EmbeddingModel:    document.EmbeddingModel,
+		Dimension:         int(document.Dimension),
+		Source:            document.Source,
+	}
+	labels, err := decodeMap(document.Labels)
+	if err != nil {
+		return nil, err
+	}
+	result.Labels = labels
+	return result, nil
+}
+
+// encodeMap encodes labels or metadata as JSON
+func encodeMap(values map[string]string) ([]byte, error) {
+	if values == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(values)
+}
+
+// decodeMap decodes labels or metadata. Empty values are decoded as nil.
+func decodeMap(encoded []byte) (map[string]string, error) {
+	var result map[string]string
+	err := json.Unmarshal(encoded, &result)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
 func (c *Database) CreateDocument(ctx context.Context, document aggregates.Document) error {
-	err := c.queries.CreateDocument(ctx, queries.CreateDocumentParams{
-		ID:          pgxID(document.ID),
-		Name:        document.Name,
-		Description: pgxText(document.Description),
-		CreatedAt:   pgxTime(document.CreatedAt),
+	labels, err := encodeMap(document.Labels)
+	if err != nil {
+		return err
+	}
+	err = c.queries.CreateDocument(ctx, queries.CreateDocumentParams{
+		ID:                pgxID(document.ID),
+		Name:              document.Name,
+		Description:       pgxText(document.Description),
+		CreatedAt:         pgxTime(document.CreatedAt),
+		EmbeddingProvider: document.EmbeddingProvider,
+		EmbeddingModel:    document.EmbeddingModel,
+		Dimension:         int32(document.Dimension),
+		Labels:            labels,
+		Source:            document.Source,
+		Project:           document.Project,
 	})
 	if err != nil {
 		return err
@@ -23,8 +78,11 @@ func (c *Database) CreateDocument(ctx context.Context, document aggregates.Docum
 	return nil
 }
 
-func (c *Database) GetDocument(ctx context.Context, id string) (*aggregates.Document, error) {
-	document, err := c.queries.GetDocument(ctx, pgxID(id))
+func (c *Database) GetDocument(ctx context.Context, project string, id string) (*aggregates.Document, error) {
+	document, err := c.queries.GetDocument(ctx, queries.GetDocumentParams{
+		ID:      pgxID(id),
+		Project: project,
+	})
 	if err != nil {
 		if err != pgx.ErrNoRows {
 			return nil, err
@@ -32,39 +90,39 @@ func (c *Database) GetDocument(ctx context.Context, id string) (*aggregates.Docu
 		return nil, er.Newf("document %s doesn't exist", er.NotFound, true, id)
 	}
 
-	return &aggregates.Document{
-		ID:          document.ID.String(),
-		Name:        document.Name,
-		Description: document.Description.String,
-		CreatedAt:   document.CreatedAt.Time,
-	}, nil
+	return toDocument(document)
 }
 
-func (c *Database) ListDocuments(ctx context.Context) ([]aggregates.Document, error) {
-	documents, err := c.queries.ListDocuments(ctx)
+func (c *Database) ListDocuments(ctx context.Context, project string) ([]aggregates.Document, error) {
+	documents, err := c.queries.ListDocuments(ctx, project)
 	if err != nil {
 		return nil, err
 	}
 	result := []aggregates.Document{}
 
 	for _, document := range documents {
-		doc := aggregates.Document{
-			ID:          document.ID.String(),
-			Name:        document.Name,
-			Description: document.Description.String,
-			CreatedAt:   document.CreatedAt.Time,
+		doc, err := toDocument(document)
+		if err != nil {
+			return nil, err
 		}
-		result = append(result, doc)
+		result = append(result, *doc)
 	}
 	return result, nil
 }
 
-func (c *Database) DeleteDocument(ctx context.Context, id string) error {
+func (c *Database) DeleteDocument(ctx context.Context, project string, id string) error {
 	tx, qtx, rollbackFn, err := c.beginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
 	}
 	defer rollbackFn()
+	exists, err := c.documentExists(qtx, ctx, project, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return er.Newf("document %s doesn't exist", er.NotFound, true, id)
+	}
 	err = qtx.DeleteDocumentChunkForDocument(ctx, pgxID(id))
 	if err != nil {
 		return err
@@ -79,8 +137,11 @@ func (c *Database) DeleteDocument(ctx context.Context, id string) error {
 	return tx.Commit(ctx)
 }
 
-func (c *Database) documentExists(queries *queries.Queries, ctx context.Context, id string) (bool, error) {
-	_, err := queries.GetDocument(ctx, pgxID(id))
+func (c *Database) documentExists(q *queries.Queries, ctx context.Context, project string, id string) (bool, error) {
+	_, err := q.GetDocument(ctx, queries.GetDocumentParams{
+		ID:      pgxID(id),
+		Project: project,
+	})
 	if err != nil {
 		if err != pgx.ErrNoRows {
 			return false, err
@@ -91,12 +152,20 @@ func (c *Database) documentExists(queries *queries.Queries, ctx context.Context,
 }
 
 func (c *Database) CreateDocumentChunk(ctx context.Context, documentChunk aggregates.DocumentChunk) error {
-	err := c.queries.CreateDocumentChunk(ctx, queries.CreateDocumentChunkParams{
-		ID:         pgxID(documentChunk.ID),
-		DocumentID: pgxID(documentChunk.DocumentID),
-		Fragment:   pgxText(documentChunk.Fragment),
-		Embedding:  pgvector.NewVector(documentChunk.Embedding),
-		CreatedAt:  pgxTime(documentChunk.CreatedAt),
+	metadata, err := encodeMap(documentChunk.Metadata)
+	if err != nil {
+		return err
+	}
+	err = c.queries.CreateDocumentChunk(ctx, queries.CreateDocumentChunkParams{
+		ID:          pgxID(documentChunk.ID),
+		DocumentID:  pgxID(documentChunk.DocumentID),
+		Fragment:    pgxText(documentChunk.Fragment),
+		Embedding:   pgvector.NewVector(documentChunk.Embedding),
+		CreatedAt:   pgxTime(documentChunk.CreatedAt),
+		Ordinal:     int32(documentChunk.Ordinal),
+		StartOffset: int32(documentChunk.Start),
+		EndOffset:   int32(documentChunk.End),
+		Metadata:    metadata,
 	})
 	if err != nil {
 		return err
@@ -104,45 +173,283 @@ func (c *Database) CreateDocumentChunk(ctx context.Context, documentChunk aggreg
 	return nil
 }
 
-func (c *Database) FindClosestChunks(ctx context.Context, limit int32, chunk []float32) ([]aggregates.DocumentChunk, error) {
-	chunks, err := c.queries.FindClosestChunks(ctx, queries.FindClosestChunksParams{
-		Limit:     limit,
-		Embedding: pgvector.NewVector(chunk),
+// CreateDocumentChunks stores all chunks using the COPY protocol
+func (c *Database) CreateDocumentChunks(ctx context.Context, documentChunks []aggregates.DocumentChunk) error {
+	params := []queries.CreateDocumentChunksParams{}
+	for _, documentChunk := range documentChunks {
+		metadata, err := encodeMap(documentChunk.Metadata)
+		if err != nil {
+			return err
+		}
+		params = append(params, queries.CreateDocumentChunksParams{
+			ID:          pgxID(documentChunk.ID),
+			DocumentID:  pgxID(documentChunk.DocumentID),
+			Fragment:    pgxText(documentChunk.Fragment),
+			Embedding:   pgvector.NewVector(documentChunk.Embedding),
+			CreatedAt:   pgxTime(documentChunk.CreatedAt),
+			Ordinal:     int32(documentChunk.Ordinal),
+			StartOffset: int32(documentChunk.Start),
+			EndOffset:   int32(documentChunk.End),
+			Metadata:    metadata,
+		})
+	}
+	_, err := c.queries.CreateDocumentChunks(ctx, params)
+	return err
+}
+
+// UpdateDocumentEmbedding records the embedding settings of a document. It fails if the
+// document already uses different settings.
+func (c *Database) UpdateDocumentEmbedding(ctx context.Context, project string, id string, provider string, model string, dimension int) error {
+	affected, err := c.queries.UpdateDocumentEmbedding(ctx, queries.UpdateDocumentEmbeddingParams{
+		ID:                pgxID(id),
+		EmbeddingProvider: provider,
+		EmbeddingModel:    model,
+		Dimension:         int32(dimension),
+		Project:           project,
 	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		exists, err := c.documentExists(c.queries, ctx, project, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return er.Newf("document %s doesn't exist", er.NotFound, true, id)
+		}
+		return er.Newf("document %s already uses other embedding settings", er.Conflict, true, id)
+	}
+	return nil
+}
+
+// findClosestChunksQuery can't be generated by sqlc: the embeddings are cast to the dimension
+// of the search so the partial vector indexes (see index.go) can be used, and the distance
+// operator depends on the metric
+const findClosestChunksQuery = `SELECT c.id, c.document_id, c.fragment, c.embedding, c.created_at, c.ordinal, c.start_offset, c.end_offset, c.metadata,
+c.embedding::vector(%[1]d) %[2]s $1::vector(%[1]d) AS distance
+FROM document_chunk c
+JOIN document d ON d.id = c.document_id
+WHERE d.embedding_provider = $2 AND d.embedding_model = $3 AND d.dimension = %[1]d AND vector_dims(c.embedding) = %[1]d
+AND ((cardinality($5::uuid[]) = 0 AND cardinality($6::text[]) = 0) OR d.id = ANY($5::uuid[]) OR d.name = ANY($6::text[]))
+AND ($8::text = '' OR d.source = $8::text)
+AND d.labels @> $7::jsonb
+AND c.metadata @> $9::jsonb
+AND d.project = $10
+ORDER BY distance LIMIT $4`
+
+// findChunksByKeywordsQuery returns the chunks containing at least one word of the query.
+// It uses the same document filter as findClosestChunksQuery. The tsvector expression must
+// match the one of the idx_document_chunk_fragment_tsv index (see index.go).
+const findChunksByKeywordsQuery = `SELECT c.id, c.document_id, c.fragment, c.embedding, c.created_at, c.ordinal, c.start_offset, c.end_offset, c.metadata,
+ts_rank_cd(to_tsvector('simple', c.fragment), q.query) AS rank
+FROM document_chunk c
+JOIN document d ON d.id = c.document_id,
+to_tsquery('simple', replace(plainto_tsquery('simple', $1)::text, ' & ', ' | ')) AS q(query)
+WHERE to_tsvector('simple', c.fragment) @@ q.query
+AND ((cardinality($3::uuid[]) = 0 AND cardinality($4::text[]) = 0) OR d.id = ANY($3::uuid[]) OR d.name = ANY($4::text[]))
+AND ($6::text = '' OR d.source = $6::text)
+AND d.labels @> $5::jsonb
+AND c.metadata @> $7::jsonb
+AND d.project = $8
+ORDER BY rank DESC, c.id LIMIT $2`
+
+// searchedChunk is a chunk returned by a search, with its distance or its rank
+type searchedChunk struct {
+	ID          pgtype.UUID
+	DocumentID  pgtype.UUID
+	Fragment    pgtype.Text
+	Embedding   pgvector.Vector
+	CreatedAt   pgtype.Timestamp
+	Ordinal     int32
+	StartOffset int32
+	EndOffset   int32
+	Metadata    []byte
+	Value       float64
+}
+
+func (chunk searchedChunk) toDocumentChunk() (aggregates.DocumentChunk, error) {
+	metadata, err := decodeMap(chunk.Metadata)
+	if err != nil {
+		return aggregates.DocumentChunk{}, err
+	}
+	return aggregates.DocumentChunk{
+		ID:         chunk.ID.String(),
+		DocumentID: chunk.DocumentID.String(),
+		Fragment:   chunk.Fragment.String,
+		Embedding:  chunk.Embedding.Slice(),
+		CreatedAt:  chunk.CreatedAt.Time,
+		Ordinal:    int(chunk.Ordinal),
+		Start:      int(chunk.StartOffset),
+		End:        int(chunk.EndOffset),
+		Metadata:   metadata,
+	}, nil
+}
+
+// filterArgs returns the document IDs, names and labels arguments of the search queries.
+// The source and the chunks metadata are passed separately.
+func filterArgs(filter aggregates.DocumentFilter) ([]pgtype.UUID, []string, []byte, error) {
+	documentIDs := []pgtype.UUID{}
+	for _, id := range filter.IDs {
+		documentIDs = append(documentIDs, pgxID(id))
+	}
+	documentNames := filter.Names
+	if documentNames == nil {
+		documentNames = []string{}
+	}
+	labels, err := encodeMap(filter.Labels)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return documentIDs, documentNames, labels, nil
+}
+
+func (c *Database) FindClosestChunks(ctx context.Context, search aggregates.ChunkSearch) ([]aggregates.DocumentChunk, error) {
+	if len(search.Embedding) == 0 {
+		return nil, errors.New("Invalid embedding")
+	}
+	if search.Metric == "" {
+		search.Metric = aggregates.L2
+	}
+	operator, ok := operators[search.Metric]
+	if !ok {
+		return nil, fmt.Errorf("unsupported metric %s", search.Metric)
+	}
+	tx, _, rollbackFn, err := c.beginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer rollbackFn()
+	// the index parameters are only set for the transaction
+	if search.EfSearch > 0 {
+		_, err = tx.Exec(ctx, fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", search.EfSearch))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if search.Probes > 0 {
+		_, err = tx.Exec(ctx, fmt.Sprintf("SET LOCAL ivfflat.probes = %d", search.Probes))
+		if err != nil {
+			return nil, err
+		}
+	}
+	documentIDs, documentNames, labels, err := filterArgs(search.Filter)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := encodeMap(search.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx,
+		fmt.Sprintf(findClosestChunksQuery, len(search.Embedding), operator),
+		pgvector.NewVector(search.Embedding),
+		search.Provider,
+		search.Model,
+		search.Limit,
+		documentIDs,
+		documentNames,
+		labels,
+		search.Filter.Source,
+		metadata,
+		search.Project)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := pgx.CollectRows(rows, pgx.RowToStructByPos[searchedChunk])
 	if err != nil {
 		return nil, err
 	}
 	result := []aggregates.DocumentChunk{}
 	for _, chunk := range chunks {
-		result = append(result, aggregates.DocumentChunk{
-			ID:         chunk.ID.String(),
-			DocumentID: chunk.DocumentID.String(),
-			Fragment:   chunk.Fragment.String,
-			Embedding:  chunk.Embedding.Slice(),
-			CreatedAt:  chunk.CreatedAt.Time,
-		})
+		c, err := chunk.toDocumentChunk()
+		if err != nil {
+			return nil, err
+		}
+		c.SetDistance(search.Metric, chunk.Value)
+		result = append(result, c)
 	}
-	return result, nil
+	return result, tx.Commit(ctx)
 }
 
-func (c *Database) DeleteDocumentChunk(ctx context.Context, id string) error {
-	err := c.queries.DeleteDocumentChunk(ctx, pgxID(id))
+func (c *Database) FindChunksByKeywords(ctx context.Context, search aggregates.KeywordSearch) ([]aggregates.DocumentChunk, error) {
+	documentIDs, documentNames, labels, err := filterArgs(search.Filter)
 	if err != nil {
-		if err != pgx.ErrNoRows {
-			return err
+		return nil, err
+	}
+	metadata, err := encodeMap(search.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.conn.Query(ctx, findChunksByKeywordsQuery,
+		search.Query,
+		search.Limit,
+		documentIDs,
+		documentNames,
+		labels,
+		search.Filter.Source,
+		metadata,
+		search.Project)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := pgx.CollectRows(rows, pgx.RowToStructByPos[searchedChunk])
+	if err != nil {
+		return nil, err
+	}
+	result := []aggregates.DocumentChunk{}
+	for _, chunk := range chunks {
+		c, err := chunk.toDocumentChunk()
+		if err != nil {
+			return nil, err
 		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func (c *Database) DeleteDocumentChunk(ctx context.Context, project string, id string) error {
+	affected, err := c.queries.DeleteDocumentChunk(ctx, queries.DeleteDocumentChunkParams{
+		ID:      pgxID(id),
+		Project: project,
+	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
 		return er.Newf("document chunk %s doesn't exist", er.NotFound, true, id)
 	}
 	return nil
 }
 
-func (c *Database) ListDocumentChunksForDocument(ctx context.Context, docID string) ([]aggregates.DocumentChunk, error) {
+// NextChunkOrdinal returns the ordinal of the next chunk added to the document
+func (c *Database) NextChunkOrdinal(ctx context.Context, project string, docID string) (int, error) {
+	tx, qtx, rollbackFn, err := c.beginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer rollbackFn()
+	exists, err := c.documentExists(qtx, ctx, project, docID)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, er.Newf("document %s doesn't exist", er.NotFound, true, docID)
+	}
+	ordinal, err := qtx.NextDocumentChunkOrdinal(ctx, pgxID(docID))
+	if err != nil {
+		return 0, err
+	}
+	return int(ordinal), tx.Commit(ctx)
+}
+
+func (c *Database) ListDocumentChunksForDocument(ctx context.Context, project string, docID string) ([]aggregates.DocumentChunk, error) {
 	tx, qtx, rollbackFn, err := c.beginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return nil, err
 	}
 	defer rollbackFn()
-	exists, err := c.documentExists(qtx, ctx, docID)
+	exists, err := c.documentExists(qtx, ctx, project, docID)
 	if err != nil {
 		return nil, err
 	}
@@ -156,12 +463,20 @@ func (c *Database) ListDocumentChunksForDocument(ctx context.Context, docID stri
 	result := []aggregates.DocumentChunk{}
 
 	for _, chunk := range chunks {
+		metadata, err := decodeMap(chunk.Metadata)
+		if err != nil {
+			return nil, err
+		}
 		chunk := aggregates.DocumentChunk{
 			ID:         chunk.ID.String(),
 			CreatedAt:  chunk.CreatedAt.Time,
 			Fragment:   chunk.Fragment.String,
 			DocumentID: docID,
 			Embedding:  chunk.Embedding.Slice(),
+			Ordinal:    int(chunk.Ordinal),
+			Start:      int(chunk.StartOffset),
+			End:        int(chunk.EndOffset),
+			Metadata:   metadata,
 		}
 		result = append(result, chunk)
 	}
@@ -2,80 +2,132 @@ package database_test
 
 import (
 	"context"
+	"math"
 	"testing"
 	"time"
 
+	"github.com/appclacks/maizai/internal/storetest"
 	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestDocumentCRUD(t *testing.T) {
+	storetest.DocumentCRUD(t, TestComponent)
+}
+
+func TestCreateDocumentChunks(t *testing.T) {
+	storetest.CreateDocumentChunks(t, TestComponent)
+}
+
+func TestUpdateDocumentEmbedding(t *testing.T) {
+	storetest.UpdateDocumentEmbedding(t, TestComponent)
+}
+
+func TestFindChunksByKeywords(t *testing.T) {
+	storetest.FindChunksByKeywords(t, TestComponent)
+}
+
+func TestDocumentProjects(t *testing.T) {
+	storetest.DocumentProjects(t, TestComponent)
+}
+
+func TestFindClosestChunks(t *testing.T) {
 	ctx := context.Background()
 	doc := aggregates.Document{
-		ID:          uuid.NewString(),
-		Name:        "doc1",
-		CreatedAt:   time.Now().UTC(),
-		Description: "desc1",
+		ID:        uuid.NewString(),
+		Project:   shared.DefaultProject,
+		Name:      "closest",
+		CreatedAt: time.Now().UTC(),
+		Labels:    map[string]string{"team": "core"},
+		Source:    "https://example.com/closest",
 	}
 	err := TestComponent.CreateDocument(ctx, doc)
 	assert.NoError(t, err)
-
-	retrieved, err := TestComponent.GetDocument(ctx, doc.ID)
-	assert.NoError(t, err)
-	assert.Equal(t, doc.ID, retrieved.ID)
-	assert.Equal(t, doc.Name, retrieved.Name)
-	assert.Equal(t, doc.Description, retrieved.Description)
-
-	doc2 := aggregates.Document{
-		ID:          uuid.NewString(),
-		Name:        "doc2",
-		CreatedAt:   time.Now().UTC(),
-		Description: "desc2",
+	embeddings := [][]float32{{1, 0, 0}, {10, 10, 0}, {1, 1, 0}}
+	for _, embedding := range embeddings {
+		chunk, err := aggregates.NewDocumentChunk(doc.ID, "fragment", embedding)
+		assert.NoError(t, err)
+		chunk.Metadata = map[string]string{"kind": "text"}
+		err = TestComponent.CreateDocumentChunk(ctx, *chunk)
+		assert.NoError(t, err)
 	}
-	err = TestComponent.CreateDocument(ctx, doc2)
+	err = TestComponent.UpdateDocumentEmbedding(ctx, shared.DefaultProject, doc.ID, "mistral", "mistral-embed", 3)
 	assert.NoError(t, err)
 
-	list, err := TestComponent.ListDocuments(ctx)
+	closest, err := TestComponent.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{9, 9, 0},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     2,
+	})
 	assert.NoError(t, err)
-	assert.Len(t, list, 2)
+	assert.Len(t, closest, 2)
+	assert.Equal(t, []float32{10, 10, 0}, closest[0].Embedding)
+	assert.InDelta(t, math.Sqrt(2), *closest[0].Distance, 0.0001)
 
-	err = TestComponent.DeleteDocument(ctx, doc.ID)
+	byCosine, err := TestComponent.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{1, 0, 0},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     1,
+		Metric:    aggregates.Cosine,
+	})
 	assert.NoError(t, err)
-	_, err = TestComponent.GetDocument(ctx, doc.ID)
-	assert.ErrorContains(t, err, "doesn't exist")
+	assert.Equal(t, []float32{1, 0, 0}, byCosine[0].Embedding)
+	assert.InDelta(t, 1, *byCosine[0].Score, 0.0001)
 
-	list, err = TestComponent.ListDocuments(ctx)
+	byProduct, err := TestComponent.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{1, 1, 0},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     1,
+		Metric:    aggregates.InnerProduct,
+	})
 	assert.NoError(t, err)
-	assert.Len(t, list, 1)
+	assert.Equal(t, []float32{10, 10, 0}, byProduct[0].Embedding)
+	assert.InDelta(t, 20, *byProduct[0].Score, 0.0001)
 
-	embedding := []float32{}
-	for i := 0; i < 1024; i++ {
-		embedding = append(embedding, float32(i))
+	result, err := TestComponent.GetDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Labels, result.Labels)
+	assert.Equal(t, doc.Source, result.Source)
+	assert.Equal(t, map[string]string{"kind": "text"}, closest[0].Metadata)
+	filters := []struct {
+		filter   aggregates.DocumentFilter
+		metadata map[string]string
+		count    int
+	}{
+		{filter: aggregates.DocumentFilter{IDs: []string{doc.ID}}, count: 2},
+		{filter: aggregates.DocumentFilter{IDs: []string{uuid.NewString()}}, count: 0},
+		{filter: aggregates.DocumentFilter{Names: []string{"closest"}}, count: 2},
+		{filter: aggregates.DocumentFilter{IDs: []string{uuid.NewString()}, Names: []string{"closest"}}, count: 2},
+		{filter: aggregates.DocumentFilter{Labels: map[string]string{"team": "core"}}, count: 2},
+		{filter: aggregates.DocumentFilter{Labels: map[string]string{"team": "other"}}, count: 0},
+		{filter: aggregates.DocumentFilter{Names: []string{"closest"}, Labels: map[string]string{"env": "prod"}}, count: 0},
+		{filter: aggregates.DocumentFilter{Source: "https://example.com/closest"}, count: 2},
+		{filter: aggregates.DocumentFilter{Source: "https://example.com/other"}, count: 0},
+		{metadata: map[string]string{"kind": "text"}, count: 2},
+		{metadata: map[string]string{"kind": "image"}, count: 0},
 	}
-	chunk := aggregates.DocumentChunk{
-		ID:         uuid.NewString(),
-		DocumentID: doc2.ID,
-		Fragment:   "hello world",
-		Embedding:  embedding,
+	for _, f := range filters {
+		filtered, err := TestComponent.FindClosestChunks(ctx, aggregates.ChunkSearch{
+			Project:   shared.DefaultProject,
+			Embedding: []float32{9, 9, 0},
+			Provider:  "mistral",
+			Model:     "mistral-embed",
+			Limit:     2,
+			Filter:    f.filter,
+			Metadata:  f.metadata,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, filtered, f.count)
 	}
-	err = TestComponent.CreateDocumentChunk(ctx, chunk)
-	assert.NoError(t, err)
 
-	chunks, err := TestComponent.ListDocumentChunksForDocument(ctx, doc2.ID)
+	err = TestComponent.DeleteDocument(ctx, shared.DefaultProject, doc.ID)
 	assert.NoError(t, err)
-	assert.Len(t, chunks, 1)
-	assert.Equal(t, chunk.ID, chunks[0].ID)
-	assert.Equal(t, chunk.DocumentID, chunks[0].DocumentID)
-	assert.Equal(t, chunk.Fragment, chunks[0].Fragment)
-
-	err = TestComponent.DeleteDocumentChunk(ctx, chunk.ID)
-	assert.NoError(t, err)
-
-	chunks, err = TestComponent.ListDocumentChunksForDocument(ctx, doc2.ID)
-	assert.NoError(t, err)
-	assert.Len(t, chunks, 0)
-
-	_, err = TestComponent.ListDocumentChunksForDocument(ctx, doc.ID)
-	assert.ErrorContains(t, err, "doesn't exist")
 }
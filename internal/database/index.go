@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/jackc/pgx/v5"
+	er "github.com/mcorbin/corbierror"
+)
+
+// The vector indexes are partial indexes on the chunks of a given dimension, because
+// pgvector indexes need a fixed dimension while the embedding column accepts any dimension.
+// They are built concurrently so the searches are not blocked, which can't be done in a transaction.
+
+// operators are the pgvector distance operators for each metric
+var operators = map[string]string{
+	aggregates.L2:           "<->",
+	aggregates.Cosine:       "<=>",
+	aggregates.InnerProduct: "<#>",
+}
+
+// opclasses are the pgvector index operator classes for each metric
+var opclasses = map[string]string{
+	aggregates.L2:           "vector_l2_ops",
+	aggregates.Cosine:       "vector_cosine_ops",
+	aggregates.InnerProduct: "vector_ip_ops",
+}
+
+func opclassMetric(opclass string) string {
+	for metric, o := range opclasses {
+		if o == opclass {
+			return metric
+		}
+	}
+	return ""
+}
+
+const listVectorIndexesQuery = `SELECT c.relname, am.amname, opc.opcname, coalesce(c.reloptions, '{}'), i.indisvalid
+FROM pg_index i
+JOIN pg_class c ON c.oid = i.indexrelid
+JOIN pg_class t ON t.oid = i.indrelid
+JOIN pg_am am ON am.oid = c.relam
+JOIN pg_opclass opc ON opc.oid = i.indclass[0]
+WHERE t.relname = 'document_chunk' AND am.amname IN ('hnsw', 'ivfflat')
+ORDER BY c.relname`
+
+func (c *Database) ListVectorIndexes(ctx context.Context) ([]aggregates.VectorIndex, error) {
+	rows, err := c.conn.Query(ctx, listVectorIndexesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []aggregates.VectorIndex{}
+	for rows.Next() {
+		var index aggregates.VectorIndex
+		var options []string
+		var opclass string
+		if err := rows.Scan(&index.Name, &index.Type, &opclass, &options, &index.Valid); err != nil {
+			return nil, err
+		}
+		index.Metric = opclassMetric(opclass)
+		// the dimension is the suffix of the index name
+		parts := strings.Split(index.Name, "_")
+		index.Dimension, _ = strconv.Atoi(parts[len(parts)-1])
+		for _, option := range options {
+			key, value, _ := strings.Cut(option, "=")
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "m":
+				index.M = number
+			case "ef_construction":
+				index.EfConstruction = number
+			case "lists":
+				index.Lists = number
+			}
+		}
+		result = append(result, index)
+	}
+	return result, rows.Err()
+}
+
+func (c *Database) CreateVectorIndex(ctx context.Context, index aggregates.VectorIndex) error {
+	var options string
+	switch index.Type {
+	case aggregates.HNSW:
+		options = fmt.Sprintf("m = %d, ef_construction = %d", index.M, index.EfConstruction)
+	case aggregates.IVFFlat:
+		options = fmt.Sprintf("lists = %d", index.Lists)
+	}
+	_, err := c.conn.Exec(ctx, fmt.Sprintf(
+		"CREATE INDEX CONCURRENTLY %s ON document_chunk USING %s ((embedding::vector(%d)) %s) WITH (%s) WHERE vector_dims(embedding) = %d",
+		pgx.Identifier{index.Name}.Sanitize(), index.Type, index.Dimension, opclasses[index.Metric], options, index.Dimension))
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return er.Newf("vector index %s already exists", er.Conflict, true, index.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Database) vectorIndexExists(ctx context.Context, name string) error {
+	var exists bool
+	err := c.conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE tablename = 'document_chunk' AND indexname = $1)", name).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return er.Newf("vector index %s doesn't exist", er.NotFound, true, name)
+	}
+	return nil
+}
+
+func (c *Database) RebuildVectorIndex(ctx context.Context, name string) error {
+	err := c.vectorIndexExists(ctx, name)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Exec(ctx, fmt.Sprintf("REINDEX INDEX CONCURRENTLY %s", pgx.Identifier{name}.Sanitize()))
+	return err
+}
+
+func (c *Database) DropVectorIndex(ctx context.Context, name string) error {
+	err := c.vectorIndexExists(ctx, name)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Exec(ctx, fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", pgx.Identifier{name}.Sanitize()))
+	return err
+}
+
+// searchIndexes are the indexes used by the keyword search and the metadata filter.
+// They are not created by the migrations: building them locks the document_chunk table,
+// so they are built concurrently once the migrations are applied.
+var searchIndexes = map[string]string{
+	"idx_document_chunk_fragment_tsv": "CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_document_chunk_fragment_tsv ON document_chunk USING gin (to_tsvector('simple', fragment))",
+	"idx_document_chunk_metadata":     "CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_document_chunk_metadata ON document_chunk USING gin (metadata)",
+}
+
+// createSearchIndexes builds the missing search indexes. A failed concurrent build
+// leaves an invalid index behind, which is dropped and built again.
+func (c *Database) createSearchIndexes(ctx context.Context) error {
+	for name, query := range searchIndexes {
+		var valid bool
+		err := c.conn.QueryRow(ctx, "SELECT i.indisvalid FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid WHERE c.relname = $1", name).Scan(&valid)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if err == nil && valid {
+			continue
+		}
+		if err == nil {
+			_, err = c.conn.Exec(ctx, fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", pgx.Identifier{name}.Sanitize()))
+			if err != nil {
+				return err
+			}
+		}
+		slog.Info(fmt.Sprintf("Building the %s index", name))
+		_, err = c.conn.Exec(ctx, query)
+		if err != nil {
+			return fmt.Errorf("fail to create the %s index: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVectorIndexes(t *testing.T) {
+	ctx := context.Background()
+	hnsw := aggregates.NewVectorIndex(aggregates.HNSW, "", 1024, 0, 0, 0)
+	err := TestComponent.CreateVectorIndex(ctx, hnsw)
+	assert.NoError(t, err)
+	indexes, err := TestComponent.ListVectorIndexes(ctx)
+	assert.NoError(t, err)
+	assert.Contains(t, indexes, aggregates.VectorIndex{
+		Name:           "idx_document_chunk_embedding_hnsw_1024",
+		Type:           aggregates.HNSW,
+		Metric:         aggregates.L2,
+		Dimension:      1024,
+		M:              16,
+		EfConstruction: 64,
+		Valid:          true,
+	})
+	err = TestComponent.DropVectorIndex(ctx, hnsw.Name)
+	assert.NoError(t, err)
+
+	index := aggregates.NewVectorIndex(aggregates.IVFFlat, aggregates.Cosine, 3, 0, 0, 10)
+	err = TestComponent.CreateVectorIndex(ctx, index)
+	assert.NoError(t, err)
+	err = TestComponent.CreateVectorIndex(ctx, index)
+	assert.ErrorContains(t, err, "already exists")
+	indexes, err = TestComponent.ListVectorIndexes(ctx)
+	assert.NoError(t, err)
+	index.Valid = true
+	assert.Contains(t, indexes, index)
+
+	err = TestComponent.RebuildVectorIndex(ctx, index.Name)
+	assert.NoError(t, err)
+	err = TestComponent.DropVectorIndex(ctx, index.Name)
+	assert.NoError(t, err)
+	err = TestComponent.DropVectorIndex(ctx, index.Name)
+	assert.ErrorContains(t, err, "doesn't exist")
+}
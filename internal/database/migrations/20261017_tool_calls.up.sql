@@ -0,0 +1,4 @@
+ALTER TABLE context_message ADD COLUMN IF NOT EXISTS tool_calls jsonb;
+--;;
+ALTER TABLE context_message ADD COLUMN IF NOT EXISTS tool_call_id varchar(255);
+--;;
@@ -0,0 +1,2 @@
+ALTER TABLE context_message ADD COLUMN IF NOT EXISTS pinned boolean NOT NULL DEFAULT false;
+--;;
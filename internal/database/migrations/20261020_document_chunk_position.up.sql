@@ -0,0 +1,6 @@
+ALTER TABLE document_chunk ADD COLUMN IF NOT EXISTS ordinal integer NOT NULL DEFAULT 0;
+--;;
+ALTER TABLE document_chunk ADD COLUMN IF NOT EXISTS start_offset integer NOT NULL DEFAULT 0;
+--;;
+ALTER TABLE document_chunk ADD COLUMN IF NOT EXISTS end_offset integer NOT NULL DEFAULT 0;
+--;;
@@ -0,0 +1,11 @@
+ALTER TABLE document ADD COLUMN IF NOT EXISTS embedding_provider varchar(255) NOT NULL DEFAULT '';
+--;;
+ALTER TABLE document ADD COLUMN IF NOT EXISTS embedding_model varchar(255) NOT NULL DEFAULT '';
+--;;
+ALTER TABLE document ADD COLUMN IF NOT EXISTS dimension integer NOT NULL DEFAULT 0;
+--;;
+ALTER TABLE document_chunk ALTER COLUMN embedding TYPE vector;
+--;;
+UPDATE document SET embedding_provider = 'mistral', embedding_model = 'mistral-embed', dimension = 1024
+WHERE dimension = 0 AND id IN (SELECT document_id FROM document_chunk);
+--;;
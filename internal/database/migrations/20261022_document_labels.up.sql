@@ -0,0 +1,4 @@
+ALTER TABLE document ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+--;;
+CREATE INDEX IF NOT EXISTS idx_document_labels ON document USING gin (labels);
+--;;
@@ -0,0 +1,4 @@
+ALTER TABLE document ADD COLUMN IF NOT EXISTS source text NOT NULL DEFAULT '';
+--;;
+ALTER TABLE document_chunk ADD COLUMN IF NOT EXISTS metadata jsonb NOT NULL DEFAULT '{}';
+--;;
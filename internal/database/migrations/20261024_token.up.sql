@@ -0,0 +1,8 @@
+create table if not exists token (
+  id uuid not null primary key,
+  name varchar(255) not null,
+  scopes text[] not null,
+  hash varchar(64) not null unique,
+  created_at timestamp not null
+);
+--;;
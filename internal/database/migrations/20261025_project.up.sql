@@ -0,0 +1,16 @@
+ALTER TABLE context ADD COLUMN IF NOT EXISTS project varchar(63) NOT NULL DEFAULT 'default';
+--;;
+ALTER TABLE context DROP CONSTRAINT IF EXISTS context_name_key;
+--;;
+DROP INDEX IF EXISTS idx_context_name;
+--;;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_context_project_name ON context(project, name);
+--;;
+ALTER TABLE document ADD COLUMN IF NOT EXISTS project varchar(63) NOT NULL DEFAULT 'default';
+--;;
+ALTER TABLE document DROP CONSTRAINT IF EXISTS document_name_key;
+--;;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_document_project_name ON document(project, name);
+--;;
+ALTER TABLE token ADD COLUMN IF NOT EXISTS project varchar(63) NOT NULL DEFAULT '';
+--;;
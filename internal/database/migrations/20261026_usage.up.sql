@@ -0,0 +1,16 @@
+create table if not exists usage_record (
+  id uuid not null primary key,
+  project varchar(63) not null,
+  context_id varchar(255) not null,
+  token varchar(255) not null,
+  provider varchar(255) not null,
+  model varchar(255) not null,
+  input_tokens bigint not null,
+  output_tokens bigint not null,
+  latency_ms bigint not null,
+  status varchar(16) not null,
+  created_at timestamp not null
+);
+--;;
+create index if not exists idx_usage_record_project_created_at on usage_record (project, created_at);
+--;;
@@ -13,11 +13,11 @@ import (
 
 const createContext = `-- name: CreateContext :one
 INSERT INTO context (
-  id, name, description, created_at
+  id, name, description, created_at, project
 ) VALUES (
-  $1, $2, $3, $4
+  $1, $2, $3, $4, $5
 )
-RETURNING id, name, description, created_at
+RETURNING id, name, description, created_at, project
 `
 
 type CreateContextParams struct {
@@ -25,6 +25,7 @@ type CreateContextParams struct {
 	Name        string
 	Description pgtype.Text
 	CreatedAt   pgtype.Timestamp
+	Project     string
 }
 
 func (q *Queries) CreateContext(ctx context.Context, arg CreateContextParams) (Context, error) {
@@ -33,6 +34,7 @@ func (q *Queries) CreateContext(ctx context.Context, arg CreateContextParams) (C
 		arg.Name,
 		arg.Description,
 		arg.CreatedAt,
+		arg.Project,
 	)
 	var i Context
 	err := row.Scan(
@@ -40,6 +42,7 @@ func (q *Queries) CreateContext(ctx context.Context, arg CreateContextParams) (C
 		&i.Name,
 		&i.Description,
 		&i.CreatedAt,
+		&i.Project,
 	)
 	return i, err
 }
@@ -55,29 +58,40 @@ func (q *Queries) DeleteContext(ctx context.Context, id pgtype.UUID) error {
 }
 
 const getContext = `-- name: GetContext :one
-SELECT id, name, description, created_at FROM context
-WHERE id = $1
+SELECT id, name, description, created_at, project FROM context
+WHERE id = $1 AND project = $2
 `
 
-func (q *Queries) GetContext(ctx context.Context, id pgtype.UUID) (Context, error) {
-	row := q.db.QueryRow(ctx, getContext, id)
+type GetContextParams struct {
+	ID      pgtype.UUID
+	Project string
+}
+
+func (q *Queries) GetContext(ctx context.Context, arg GetContextParams) (Context, error) {
+	row := q.db.QueryRow(ctx, getContext, arg.ID, arg.Project)
 	var i Context
 	err := row.Scan(
 		&i.ID,
 		&i.Name,
 		&i.Description,
 		&i.CreatedAt,
+		&i.Project,
 	)
 	return i, err
 }
 
 const getContextIDByName = `-- name: GetContextIDByName :one
 SELECT id FROM context
-WHERE name = $1
+WHERE project = $1 AND name = $2
 `
 
-func (q *Queries) GetContextIDByName(ctx context.Context, name string) (pgtype.UUID, error) {
-	row := q.db.QueryRow(ctx, getContextIDByName, name)
+type GetContextIDByNameParams struct {
+	Project string
+	Name    string
+}
+
+func (q *Queries) GetContextIDByName(ctx context.Context, arg GetContextIDByNameParams) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, getContextIDByName, arg.Project, arg.Name)
 	var id pgtype.UUID
 	err := row.Scan(&id)
 	return id, err
@@ -85,22 +99,28 @@ func (q *Queries) GetContextIDByName(ctx context.Context, name string) (pgtype.U
 
 const getContextNameByID = `-- name: GetContextNameByID :one
 SELECT name FROM context
-WHERE id = $1
+WHERE id = $1 AND project = $2
 `
 
-func (q *Queries) GetContextNameByID(ctx context.Context, id pgtype.UUID) (string, error) {
-	row := q.db.QueryRow(ctx, getContextNameByID, id)
+type GetContextNameByIDParams struct {
+	ID      pgtype.UUID
+	Project string
+}
+
+func (q *Queries) GetContextNameByID(ctx context.Context, arg GetContextNameByIDParams) (string, error) {
+	row := q.db.QueryRow(ctx, getContextNameByID, arg.ID, arg.Project)
 	var name string
 	err := row.Scan(&name)
 	return name, err
 }
 
 const listContexts = `-- name: ListContexts :many
-SELECT id, name, description, created_at FROM context
+SELECT id, name, description, created_at, project FROM context
+WHERE project = $1
 `
 
-func (q *Queries) ListContexts(ctx context.Context) ([]Context, error) {
-	rows, err := q.db.Query(ctx, listContexts)
+func (q *Queries) ListContexts(ctx context.Context, project string) ([]Context, error) {
+	rows, err := q.db.Query(ctx, listContexts, project)
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +133,7 @@ func (q *Queries) ListContexts(ctx context.Context) ([]Context, error) {
 			&i.Name,
 			&i.Description,
 			&i.CreatedAt,
+			&i.Project,
 		); err != nil {
 			return nil, err
 		}
@@ -13,19 +13,23 @@ import (
 
 const createContextMessage = `-- name: CreateContextMessage :one
 INSERT INTO context_message (
-  id, role, content, created_at, context_id
+  id, role, content, created_at, context_id, tool_calls, tool_call_id, parts, pinned
 ) VALUES (
-  $1, $2, $3, $4, $5
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
 )
-RETURNING ordering, id, role, content, created_at, context_id
+RETURNING ordering, id, role, content, created_at, context_id, tool_calls, tool_call_id, parts, pinned
 `
 
 type CreateContextMessageParams struct {
-	ID        pgtype.UUID
-	Role      string
-	Content   string
-	CreatedAt pgtype.Timestamp
-	ContextID pgtype.UUID
+	ID         pgtype.UUID
+	Role       string
+	Content    string
+	CreatedAt  pgtype.Timestamp
+	ContextID  pgtype.UUID
+	ToolCalls  []byte
+	ToolCallID pgtype.Text
+	Parts      []byte
+	Pinned     bool
 }
 
 func (q *Queries) CreateContextMessage(ctx context.Context, arg CreateContextMessageParams) (ContextMessage, error) {
@@ -35,6 +39,10 @@ func (q *Queries) CreateContextMessage(ctx context.Context, arg CreateContextMes
 		arg.Content,
 		arg.CreatedAt,
 		arg.ContextID,
+		arg.ToolCalls,
+		arg.ToolCallID,
+		arg.Parts,
+		arg.Pinned,
 	)
 	var i ContextMessage
 	err := row.Scan(
@@ -44,18 +52,61 @@ func (q *Queries) CreateContextMessage(ctx context.Context, arg CreateContextMes
 		&i.Content,
 		&i.CreatedAt,
 		&i.ContextID,
+		&i.ToolCalls,
+		&i.ToolCallID,
+		&i.Parts,
+		&i.Pinned,
 	)
 	return i, err
 }
 
-const deleteContextMessage = `-- name: DeleteContextMessage :exec
+const deleteContextMessage = `-- name: DeleteContextMessage :execrows
 DELETE FROM context_message
-WHERE id = $1
+WHERE id = $1 AND context_id IN (SELECT id FROM context WHERE project = $2)
 `
 
-func (q *Queries) DeleteContextMessage(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, deleteContextMessage, id)
-	return err
+type DeleteContextMessageParams struct {
+	ID      pgtype.UUID
+	Project string
+}
+
+func (q *Queries) DeleteContextMessage(ctx context.Context, arg DeleteContextMessageParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteContextMessage, arg.ID, arg.Project)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteContextMessagesByIDs = `-- name: DeleteContextMessagesByIDs :many
+DELETE FROM context_message
+WHERE context_id = $1 AND id = ANY($2::uuid[])
+RETURNING ordering
+`
+
+type DeleteContextMessagesByIDsParams struct {
+	ContextID pgtype.UUID
+	Ids       []pgtype.UUID
+}
+
+func (q *Queries) DeleteContextMessagesByIDs(ctx context.Context, arg DeleteContextMessagesByIDsParams) ([]int64, error) {
+	rows, err := q.db.Query(ctx, deleteContextMessagesByIDs, arg.ContextID, arg.Ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var ordering int64
+		if err := rows.Scan(&ordering); err != nil {
+			return nil, err
+		}
+		items = append(items, ordering)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const deleteContextMessagesForContext = `-- name: DeleteContextMessagesForContext :exec
@@ -69,16 +120,20 @@ func (q *Queries) DeleteContextMessagesForContext(ctx context.Context, contextID
 }
 
 const getContextMessages = `-- name: GetContextMessages :many
-SELECT id, role, content, created_at FROM context_message
+SELECT id, role, content, created_at, tool_calls, tool_call_id, parts, pinned FROM context_message
 WHERE context_id = $1
 ORDER BY ordering
 `
 
 type GetContextMessagesRow struct {
-	ID        pgtype.UUID
-	Role      string
-	Content   string
-	CreatedAt pgtype.Timestamp
+	ID         pgtype.UUID
+	Role       string
+	Content    string
+	CreatedAt  pgtype.Timestamp
+	ToolCalls  []byte
+	ToolCallID pgtype.Text
+	Parts      []byte
+	Pinned     bool
 }
 
 func (q *Queries) GetContextMessages(ctx context.Context, contextID pgtype.UUID) ([]GetContextMessagesRow, error) {
@@ -95,6 +150,10 @@ func (q *Queries) GetContextMessages(ctx context.Context, contextID pgtype.UUID)
 			&i.Role,
 			&i.Content,
 			&i.CreatedAt,
+			&i.ToolCalls,
+			&i.ToolCallID,
+			&i.Parts,
+			&i.Pinned,
 		); err != nil {
 			return nil, err
 		}
@@ -106,19 +165,59 @@ func (q *Queries) GetContextMessages(ctx context.Context, contextID pgtype.UUID)
 	return items, nil
 }
 
-const updateContextMessage = `-- name: UpdateContextMessage :exec
+const pinContextMessage = `-- name: PinContextMessage :execrows
+UPDATE context_message
+SET pinned = $2
+WHERE id = $1 AND context_id IN (SELECT id FROM context WHERE project = $3)
+`
+
+type PinContextMessageParams struct {
+	ID      pgtype.UUID
+	Pinned  bool
+	Project string
+}
+
+func (q *Queries) PinContextMessage(ctx context.Context, arg PinContextMessageParams) (int64, error) {
+	result, err := q.db.Exec(ctx, pinContextMessage, arg.ID, arg.Pinned, arg.Project)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateContextMessage = `-- name: UpdateContextMessage :execrows
 UPDATE context_message
 SET content = $2, role=$3
-WHERE id = $1
+WHERE id = $1 AND context_id IN (SELECT id FROM context WHERE project = $4)
 `
 
 type UpdateContextMessageParams struct {
 	ID      pgtype.UUID
 	Content string
 	Role    string
+	Project string
+}
+
+func (q *Queries) UpdateContextMessage(ctx context.Context, arg UpdateContextMessageParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateContextMessage, arg.ID, arg.Content, arg.Role, arg.Project)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateContextMessageOrdering = `-- name: UpdateContextMessageOrdering :exec
+UPDATE context_message
+SET ordering = $2
+WHERE id = $1
+`
+
+type UpdateContextMessageOrderingParams struct {
+	ID       pgtype.UUID
+	Ordering int64
 }
 
-func (q *Queries) UpdateContextMessage(ctx context.Context, arg UpdateContextMessageParams) error {
-	_, err := q.db.Exec(ctx, updateContextMessage, arg.ID, arg.Content, arg.Role)
+func (q *Queries) UpdateContextMessageOrdering(ctx context.Context, arg UpdateContextMessageOrderingParams) error {
+	_, err := q.db.Exec(ctx, updateContextMessageOrdering, arg.ID, arg.Ordering)
 	return err
 }
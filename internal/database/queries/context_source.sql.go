@@ -41,16 +41,17 @@ func (q *Queries) CreateContexSource(ctx context.Context, arg CreateContexSource
 
 const deleteContextSource = `-- name: DeleteContextSource :exec
 DELETE FROM context_source
-WHERE context_id=$1 AND source_context_id=$2
+WHERE context_id=$1 AND source_context_id=$2 AND context_id IN (SELECT id FROM context WHERE project = $3)
 `
 
 type DeleteContextSourceParams struct {
 	ContextID       pgtype.UUID
 	SourceContextID pgtype.UUID
+	Project         string
 }
 
 func (q *Queries) DeleteContextSource(ctx context.Context, arg DeleteContextSourceParams) error {
-	_, err := q.db.Exec(ctx, deleteContextSource, arg.ContextID, arg.SourceContextID)
+	_, err := q.db.Exec(ctx, deleteContextSource, arg.ContextID, arg.SourceContextID, arg.Project)
 	return err
 }
 
@@ -14,17 +14,23 @@ import (
 
 const createDocument = `-- name: CreateDocument :exec
 INSERT INTO document (
-  id, name, description, created_at)
+  id, name, description, created_at, embedding_provider, embedding_model, dimension, labels, source, project)
 VALUES (
-  $1, $2, $3, $4
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 )
 `
 
 type CreateDocumentParams struct {
-	ID          pgtype.UUID
-	Name        string
-	Description pgtype.Text
-	CreatedAt   pgtype.Timestamp
+	ID                pgtype.UUID
+	Name              string
+	Description       pgtype.Text
+	CreatedAt         pgtype.Timestamp
+	EmbeddingProvider string
+	EmbeddingModel    string
+	Dimension         int32
+	Labels            []byte
+	Source            string
+	Project           string
 }
 
 func (q *Queries) CreateDocument(ctx context.Context, arg CreateDocumentParams) error {
@@ -33,24 +39,34 @@ func (q *Queries) CreateDocument(ctx context.Context, arg CreateDocumentParams)
 		arg.Name,
 		arg.Description,
 		arg.CreatedAt,
+		arg.EmbeddingProvider,
+		arg.EmbeddingModel,
+		arg.Dimension,
+		arg.Labels,
+		arg.Source,
+		arg.Project,
 	)
 	return err
 }
 
 const createDocumentChunk = `-- name: CreateDocumentChunk :exec
 INSERT INTO document_chunk (
-  id, document_id, fragment, embedding, created_at)
+  id, document_id, fragment, embedding, created_at, ordinal, start_offset, end_offset, metadata)
 VALUES (
-  $1, $2, $3, $4, $5
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
 )
 `
 
 type CreateDocumentChunkParams struct {
-	ID         pgtype.UUID
-	DocumentID pgtype.UUID
-	Fragment   pgtype.Text
-	Embedding  pgvector.Vector
-	CreatedAt  pgtype.Timestamp
+	ID          pgtype.UUID
+	DocumentID  pgtype.UUID
+	Fragment    pgtype.Text
+	Embedding   pgvector.Vector
+	CreatedAt   pgtype.Timestamp
+	Ordinal     int32
+	StartOffset int32
+	EndOffset   int32
+	Metadata    []byte
 }
 
 func (q *Queries) CreateDocumentChunk(ctx context.Context, arg CreateDocumentChunkParams) error {
@@ -60,10 +76,26 @@ func (q *Queries) CreateDocumentChunk(ctx context.Context, arg CreateDocumentChu
 		arg.Fragment,
 		arg.Embedding,
 		arg.CreatedAt,
+		arg.Ordinal,
+		arg.StartOffset,
+		arg.EndOffset,
+		arg.Metadata,
 	)
 	return err
 }
 
+type CreateDocumentChunksParams struct {
+	ID          pgtype.UUID
+	DocumentID  pgtype.UUID
+	Fragment    pgtype.Text
+	Embedding   pgvector.Vector
+	CreatedAt   pgtype.Timestamp
+	Ordinal     int32
+	StartOffset int32
+	EndOffset   int32
+	Metadata    []byte
+}
+
 const deleteDocument = `-- name: DeleteDocument :exec
 DELETE FROM document
 WHERE id = $1
@@ -74,14 +106,22 @@ func (q *Queries) DeleteDocument(ctx context.Context, id pgtype.UUID) error {
 	return err
 }
 
-const deleteDocumentChunk = `-- name: DeleteDocumentChunk :exec
+const deleteDocumentChunk = `-- name: DeleteDocumentChunk :execrows
 DELETE FROM document_chunk
-WHERE id = $1
+WHERE id = $1 AND document_id IN (SELECT id FROM document WHERE project = $2)
 `
 
-func (q *Queries) DeleteDocumentChunk(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, deleteDocumentChunk, id)
-	return err
+type DeleteDocumentChunkParams struct {
+	ID      pgtype.UUID
+	Project string
+}
+
+func (q *Queries) DeleteDocumentChunk(ctx context.Context, arg DeleteDocumentChunkParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteDocumentChunk, arg.ID, arg.Project)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const deleteDocumentChunkForDocument = `-- name: DeleteDocumentChunkForDocument :exec
@@ -94,71 +134,50 @@ func (q *Queries) DeleteDocumentChunkForDocument(ctx context.Context, documentID
 	return err
 }
 
-const findClosestChunks = `-- name: FindClosestChunks :many
-SELECT id, document_id, fragment, embedding, created_at
-FROM document_chunk
-ORDER BY embedding <-> $1 LIMIT $2
+const getDocument = `-- name: GetDocument :one
+SELECT id, name, description, created_at, embedding_provider, embedding_model, dimension, labels, source, project FROM document
+WHERE id = $1 AND project = $2
 `
 
-type FindClosestChunksParams struct {
-	Embedding pgvector.Vector
-	Limit     int32
+type GetDocumentParams struct {
+	ID      pgtype.UUID
+	Project string
 }
 
-func (q *Queries) FindClosestChunks(ctx context.Context, arg FindClosestChunksParams) ([]DocumentChunk, error) {
-	rows, err := q.db.Query(ctx, findClosestChunks, arg.Embedding, arg.Limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []DocumentChunk
-	for rows.Next() {
-		var i DocumentChunk
-		if err := rows.Scan(
-			&i.ID,
-			&i.DocumentID,
-			&i.Fragment,
-			&i.Embedding,
-			&i.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
-}
-
-const getDocument = `-- name: GetDocument :one
-SELECT id, name, description, created_at FROM document
-WHERE id = $1
-`
-
-func (q *Queries) GetDocument(ctx context.Context, id pgtype.UUID) (Document, error) {
-	row := q.db.QueryRow(ctx, getDocument, id)
+func (q *Queries) GetDocument(ctx context.Context, arg GetDocumentParams) (Document, error) {
+	row := q.db.QueryRow(ctx, getDocument, arg.ID, arg.Project)
 	var i Document
 	err := row.Scan(
 		&i.ID,
 		&i.Name,
 		&i.Description,
 		&i.CreatedAt,
+		&i.EmbeddingProvider,
+		&i.EmbeddingModel,
+		&i.Dimension,
+		&i.Labels,
+		&i.Source,
+		&i.Project,
 	)
 	return i, err
 }
 
 const listDocumentChunksForDocument = `-- name: ListDocumentChunksForDocument :many
-SELECT id, fragment, created_at, embedding
+SELECT id, fragment, created_at, embedding, ordinal, start_offset, end_offset, metadata
 FROM document_chunk
 WHERE document_id = $1
+ORDER BY ordinal, created_at
 `
 
 type ListDocumentChunksForDocumentRow struct {
-	ID        pgtype.UUID
-	Fragment  pgtype.Text
-	CreatedAt pgtype.Timestamp
-	Embedding pgvector.Vector
+	ID          pgtype.UUID
+	Fragment    pgtype.Text
+	CreatedAt   pgtype.Timestamp
+	Embedding   pgvector.Vector
+	Ordinal     int32
+	StartOffset int32
+	EndOffset   int32
+	Metadata    []byte
 }
 
 func (q *Queries) ListDocumentChunksForDocument(ctx context.Context, documentID pgtype.UUID) ([]ListDocumentChunksForDocumentRow, error) {
@@ -175,6 +194,10 @@ func (q *Queries) ListDocumentChunksForDocument(ctx context.Context, documentID
 			&i.Fragment,
 			&i.CreatedAt,
 			&i.Embedding,
+			&i.Ordinal,
+			&i.StartOffset,
+			&i.EndOffset,
+			&i.Metadata,
 		); err != nil {
 			return nil, err
 		}
@@ -187,12 +210,13 @@ func (q *Queries) ListDocumentChunksForDocument(ctx context.Context, documentID
 }
 
 const listDocuments = `-- name: ListDocuments :many
-SELECT id, name, description, created_at
+SELECT id, name, description, created_at, embedding_provider, embedding_model, dimension, labels, source, project
 FROM document
+WHERE project = $1
 `
 
-func (q *Queries) ListDocuments(ctx context.Context) ([]Document, error) {
-	rows, err := q.db.Query(ctx, listDocuments)
+func (q *Queries) ListDocuments(ctx context.Context, project string) ([]Document, error) {
+	rows, err := q.db.Query(ctx, listDocuments, project)
 	if err != nil {
 		return nil, err
 	}
@@ -205,6 +229,12 @@ func (q *Queries) ListDocuments(ctx context.Context) ([]Document, error) {
 			&i.Name,
 			&i.Description,
 			&i.CreatedAt,
+			&i.EmbeddingProvider,
+			&i.EmbeddingModel,
+			&i.Dimension,
+			&i.Labels,
+			&i.Source,
+			&i.Project,
 		); err != nil {
 			return nil, err
 		}
@@ -215,3 +245,46 @@ func (q *Queries) ListDocuments(ctx context.Context) ([]Document, error) {
 	}
 	return items, nil
 }
+
+const nextDocumentChunkOrdinal = `-- name: NextDocumentChunkOrdinal :one
+SELECT (COALESCE(MAX(ordinal) + 1, 0))::integer AS next_ordinal
+FROM document_chunk
+WHERE document_id = $1
+`
+
+func (q *Queries) NextDocumentChunkOrdinal(ctx context.Context, documentID pgtype.UUID) (int32, error) {
+	row := q.db.QueryRow(ctx, nextDocumentChunkOrdinal, documentID)
+	var next_ordinal int32
+	err := row.Scan(&next_ordinal)
+	return next_ordinal, err
+}
+
+const updateDocumentEmbedding = `-- name: UpdateDocumentEmbedding :execrows
+UPDATE document SET embedding_provider = $2, embedding_model = $3, dimension = $4
+WHERE id = $1 AND project = $5
+AND (embedding_provider = '' OR embedding_provider = $2)
+AND (embedding_model = '' OR embedding_model = $3)
+AND (dimension = 0 OR dimension = $4)
+`
+
+type UpdateDocumentEmbeddingParams struct {
+	ID                pgtype.UUID
+	EmbeddingProvider string
+	EmbeddingModel    string
+	Dimension         int32
+	Project           string
+}
+
+func (q *Queries) UpdateDocumentEmbedding(ctx context.Context, arg UpdateDocumentEmbeddingParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateDocumentEmbedding,
+		arg.ID,
+		arg.EmbeddingProvider,
+		arg.EmbeddingModel,
+		arg.Dimension,
+		arg.Project,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
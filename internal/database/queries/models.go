@@ -14,15 +14,20 @@ type Context struct {
 	Name        string
 	Description pgtype.Text
 	CreatedAt   pgtype.Timestamp
+	Project     string
 }
 
 type ContextMessage struct {
-	Ordering  pgtype.Int8
-	ID        pgtype.UUID
-	Role      string
-	Content   string
-	CreatedAt pgtype.Timestamp
-	ContextID pgtype.UUID
+	Ordering   pgtype.Int8
+	ID         pgtype.UUID
+	Role       string
+	Content    string
+	CreatedAt  pgtype.Timestamp
+	ContextID  pgtype.UUID
+	ToolCalls  []byte
+	ToolCallID pgtype.Text
+	Parts      []byte
+	Pinned     bool
 }
 
 type ContextSource struct {
@@ -32,16 +37,50 @@ type ContextSource struct {
 }
 
 type Document struct {
+	ID                pgtype.UUID
+	Name              string
+	Description       pgtype.Text
+	CreatedAt         pgtype.Timestamp
+	EmbeddingProvider string
+	EmbeddingModel    string
+	Dimension         int32
+	Labels            []byte
+	Source            string
+	Project           string
+}
+
+type DocumentChunk struct {
 	ID          pgtype.UUID
-	Name        string
-	Description pgtype.Text
+	DocumentID  pgtype.UUID
+	Fragment    pgtype.Text
+	Embedding   pgvector.Vector
 	CreatedAt   pgtype.Timestamp
+	Ordinal     int32
+	StartOffset int32
+	EndOffset   int32
+	FragmentTsv interface{}
+	Metadata    []byte
 }
 
-type DocumentChunk struct {
-	ID         pgtype.UUID
-	DocumentID pgtype.UUID
-	Fragment   pgtype.Text
-	Embedding  pgvector.Vector
-	CreatedAt  pgtype.Timestamp
+type Token struct {
+	ID        pgtype.UUID
+	Name      string
+	Scopes    []string
+	Hash      string
+	CreatedAt pgtype.Timestamp
+	Project   string
+}
+
+type UsageRecord struct {
+	ID           pgtype.UUID
+	Project      string
+	ContextID    string
+	Token        string
+	Provider     string
+	Model        string
+	InputTokens  int64
+	OutputTokens int64
+	LatencyMs    int64
+	Status       string
+	CreatedAt    pgtype.Timestamp
 }
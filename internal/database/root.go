@@ -18,6 +18,7 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
+	pgxvec "github.com/pgvector/pgvector-go/pgx"
 )
 
 //go:embed migrations/*.sql
@@ -34,6 +35,8 @@ var CleanupQueries = []string{
 	"TRUNCATE context CASCADE",
 	"TRUNCATE document_chunk CASCADE",
 	"TRUNCATE document CASCADE",
+	"TRUNCATE token CASCADE",
+	"TRUNCATE usage_record CASCADE",
 	"TRUNCATE schema_migrations CASCADE",
 }
 
@@ -46,6 +49,11 @@ func New(config Configuration) (*Database, error) {
 		return nil, err
 	}
 	cfg.ConnConfig.Tracer = otelpgx.NewTracer()
+	// the vector codec is needed to send embeddings with the binary COPY protocol.
+	// Connections are opened lazily, so the vector extension exists at this point.
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		return pgxvec.RegisterTypes(ctx, conn)
+	}
 	conn, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, err
@@ -78,10 +86,16 @@ func New(config Configuration) (*Database, error) {
 		return nil, fmt.Errorf("fail to apply migrations: %w", err)
 	}
 	slog.Info("Migrations applied")
-	return &Database{
+	database := &Database{
 		conn:    conn,
 		queries: queries,
-	}, nil
+	}
+	// no timeout: the indexes are built concurrently and can take a while on big tables
+	err = database.createSearchIndexes(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return database, nil
 }
 
 func (d *Database) Exec(query string) (sql.Result, error) {
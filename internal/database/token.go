@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+
+	"github.com/appclacks/maizai/internal/database/queries"
+	"github.com/appclacks/maizai/pkg/auth/aggregates"
+	"github.com/jackc/pgx/v5"
+	er "github.com/mcorbin/corbierror"
+)
+
+func toToken(token queries.Token) aggregates.Token {
+	return aggregates.Token{
+		ID:        token.ID.String(),
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		Project:   token.Project,
+		Hash:      token.Hash,
+		CreatedAt: token.CreatedAt.Time,
+	}
+}
+
+func (c *Database) CreateToken(ctx context.Context, token aggregates.Token) error {
+	return c.queries.CreateToken(ctx, queries.CreateTokenParams{
+		ID:        pgxID(token.ID),
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		Project:   token.Project,
+		Hash:      token.Hash,
+		CreatedAt: pgxTime(token.CreatedAt),
+	})
+}
+
+func (c *Database) ListTokens(ctx context.Context) ([]aggregates.Token, error) {
+	tokens, err := c.queries.ListTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := []aggregates.Token{}
+	for _, token := range tokens {
+		result = append(result, toToken(token))
+	}
+	return result, nil
+}
+
+func (c *Database) GetTokenByHash(ctx context.Context, hash string) (*aggregates.Token, error) {
+	token, err := c.queries.GetTokenByHash(ctx, hash)
+	if err != nil {
+		if err != pgx.ErrNoRows {
+			return nil, err
+		}
+		return nil, er.New("token doesn't exist", er.NotFound, true)
+	}
+	result := toToken(token)
+	return &result, nil
+}
+
+func (c *Database) DeleteToken(ctx context.Context, id string) error {
+	affected, err := c.queries.DeleteToken(ctx, pgxID(id))
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return er.Newf("token %s doesn't exist", er.NotFound, true, id)
+	}
+	return nil
+}
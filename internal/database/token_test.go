@@ -0,0 +1,11 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/appclacks/maizai/internal/storetest"
+)
+
+func TestTokenCRUD(t *testing.T) {
+	storetest.TokenCRUD(t, TestComponent)
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/appclacks/maizai/internal/database/queries"
+	"github.com/appclacks/maizai/pkg/usage/aggregates"
+)
+
+func (c *Database) CreateUsageRecord(ctx context.Context, record aggregates.Record) error {
+	return c.queries.CreateUsageRecord(ctx, queries.CreateUsageRecordParams{
+		ID:           pgxID(record.ID),
+		Project:      record.Project,
+		ContextID:    record.Context,
+		Token:        record.Token,
+		Provider:     record.Provider,
+		Model:        record.Model,
+		InputTokens:  int64(record.InputTokens),
+		OutputTokens: int64(record.OutputTokens),
+		LatencyMs:    record.Latency.Milliseconds(),
+		Status:       record.Status,
+		CreatedAt:    pgxTime(record.CreatedAt),
+	})
+}
+
+func (c *Database) ListUsageRecords(ctx context.Context, project string, filter aggregates.Filter) ([]aggregates.Record, error) {
+	params := queries.ListUsageRecordsParams{
+		Project:   project,
+		ContextID: filter.Context,
+		Token:     filter.Token,
+		Model:     filter.Model,
+	}
+	if !filter.Since.IsZero() {
+		params.Since = pgxTime(filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		params.Until = pgxTime(filter.Until.UTC())
+	}
+	records, err := c.queries.ListUsageRecords(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	result := []aggregates.Record{}
+	for _, record := range records {
+		result = append(result, aggregates.Record{
+			ID:           record.ID.String(),
+			Project:      record.Project,
+			Context:      record.ContextID,
+			Token:        record.Token,
+			Provider:     record.Provider,
+			Model:        record.Model,
+			InputTokens:  uint64(record.InputTokens),
+			OutputTokens: uint64(record.OutputTokens),
+			Latency:      time.Duration(record.LatencyMs) * time.Millisecond,
+			Status:       record.Status,
+			CreatedAt:    record.CreatedAt.Time,
+		})
+	}
+	return result, nil
+}
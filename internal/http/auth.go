@@ -0,0 +1,49 @@
+package http
+
+import (
+	"context"
+	"strings"
+
+	"github.com/appclacks/maizai/internal/http/handlers"
+	"github.com/appclacks/maizai/pkg/auth/aggregates"
+	"github.com/appclacks/maizai/pkg/usage"
+	"github.com/labstack/echo/v4"
+	er "github.com/mcorbin/corbierror"
+)
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*aggregates.Token, error)
+}
+
+// authMiddleware checks that the request bearer token has the scope required by the endpoint
+// and can access the project of the request
+func authMiddleware(authenticator Authenticator, scope string, global bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ec echo.Context) error {
+			value, found := strings.CutPrefix(ec.Request().Header.Get("Authorization"), "Bearer ")
+			if !found || value == "" {
+				ec.Response().Header().Set("WWW-Authenticate", "Bearer")
+				return er.New("Missing bearer token in the Authorization header", er.Unauthorized, true)
+			}
+			token, err := authenticator.Authenticate(ec.Request().Context(), value)
+			if err != nil {
+				ec.Response().Header().Set("WWW-Authenticate", "Bearer")
+				return err
+			}
+			if !token.Allows(scope) {
+				return er.Newf("Token %s doesn't have the %s scope", er.Forbidden, true, token.Name, scope)
+			}
+			if global && token.Project != "" {
+				return er.Newf("Token %s is restricted to the project %s", er.Forbidden, true, token.Name, token.Project)
+			}
+			handlers.SetTokenProject(ec, token.Project)
+			handlers.SetTokenTenant(ec, *token)
+			ec.SetRequest(ec.Request().WithContext(usage.WithToken(ec.Request().Context(), token.Identifier())))
+			project := handlers.Project(ec)
+			if !global && !token.AllowsProject(project) {
+				return er.Newf("Token %s can't access the project %s", er.Forbidden, true, token.Name, project)
+			}
+			return next(ec)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/appclacks/maizai/internal/tls"
 )
@@ -42,7 +44,27 @@ func New() (*Client, error) {
 	return client, nil
 }
 
-func (c *Client) sendRequest(ctx context.Context, url string, method string, body any, result any, queryParams map[string]string) (*http.Response, error) {
+// globalPrefixes are the API paths which are not scoped by project
+var globalPrefixes = []string{"/api/v1/token", "/api/v1/vector-index"}
+
+// projectPath prefixes the API path with the configured project
+func (c *Client) projectPath(path string) string {
+	if c.config.Project == "" {
+		return path
+	}
+	for _, prefix := range globalPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return path
+		}
+	}
+	suffix, found := strings.CutPrefix(path, "/api/v1")
+	if !found {
+		return path
+	}
+	return fmt.Sprintf("/api/v1/projects/%s%s", url.PathEscape(c.config.Project), suffix)
+}
+
+func (c *Client) sendRequest(ctx context.Context, path string, method string, body any, result any, queryParams map[string]string) (*http.Response, error) {
 	var reqBody io.Reader
 	if body != nil {
 		json, err := json.Marshal(body)
@@ -54,7 +76,7 @@ func (c *Client) sendRequest(ctx context.Context, url string, method string, bod
 	request, err := http.NewRequestWithContext(
 		ctx,
 		method,
-		fmt.Sprintf("%s%s", c.config.Endpoint, url),
+		fmt.Sprintf("%s%s", c.config.Endpoint, c.projectPath(path)),
 		reqBody)
 	if err != nil {
 		return nil, err
@@ -67,6 +89,9 @@ func (c *Client) sendRequest(ctx context.Context, url string, method string, bod
 		request.URL.RawQuery = q.Encode()
 	}
 	request.Header.Add("content-type", "application/json")
+	if c.config.Token != "" {
+		request.Header.Add("Authorization", "Bearer "+c.config.Token)
+	}
 	response, err := c.http.Do(request)
 	if err != nil {
 		return nil, err
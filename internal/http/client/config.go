@@ -12,6 +12,9 @@ type Configuration struct {
 	Cert     string `env:"MAIZAI_HTTP_TLS_CERT_PATH"`
 	Cacert   string `env:"MAIZAI_HTTP_TLS_CACERT_PATH"`
 	Insecure bool   `env:"MAIZAI_HTTP_TLS_INSECURE"`
+	Token    string `env:"MAIZAI_HTTP_TOKEN"`
+	// Project scopes the requests to a project. The project of the token or the default project is used if empty
+	Project string `env:"MAIZAI_PROJECT"`
 }
 
 func Load() (*Configuration, error) {
@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -11,6 +12,7 @@ import (
 
 type Context struct {
 	ID          string         `json:"id" description:"The context ID"`
+	Project     string         `json:"project" description:"The context project"`
 	Name        string         `json:"name" description:"The context name"`
 	Description string         `json:"description,omitempty" description:"The context description"`
 	Sources     ContextSources `json:"sources" description:"Sources for this context"`
@@ -20,6 +22,7 @@ type Context struct {
 
 type ContextMetadata struct {
 	ID          string         `json:"id" description:"The context ID"`
+	Project     string         `json:"project" description:"The context project"`
 	Name        string         `json:"name" description:"The context name"`
 	Description string         `json:"description,omitempty" description:"The context description"`
 	CreatedAt   time.Time      `json:"created-at" description:"The context creation date"`
@@ -42,16 +45,38 @@ type DeleteContextMessagesInput struct {
 	ID string `param:"id" path:"id"`
 }
 
+type ToolCall struct {
+	ID        string          `json:"id" required:"true" description:"The tool call ID"`
+	Name      string          `json:"name" required:"true" description:"The name of the tool to call"`
+	Arguments json.RawMessage `json:"arguments,omitempty" description:"The tool arguments, as a JSON object"`
+}
+
+type ContentPart struct {
+	Type      string `json:"type" required:"true" description:"The part type: text, image or document (PDF)"`
+	Text      string `json:"text,omitempty" description:"The text, for text parts"`
+	MediaType string `json:"media-type,omitempty" description:"The media type of the base64 encoded data (image/jpeg, image/png, image/gif, image/webp or application/pdf)"`
+	Data      string `json:"data,omitempty" description:"The base64 encoded image or document"`
+	URL       string `json:"url,omitempty" description:"The URL of the image or document, if data is not provided"`
+}
+
 type Message struct {
-	ID        string    `json:"id" description:"The message ID"`
-	Role      string    `json:"role" description:"The message role"`
-	Content   string    `json:"content" description:"The message content"`
-	CreatedAt time.Time `json:"created-at" description:"The message creation date"`
+	ID         string        `json:"id" description:"The message ID"`
+	Role       string        `json:"role" description:"The message role"`
+	Content    string        `json:"content" description:"The message content"`
+	ToolCalls  []ToolCall    `json:"tool-calls,omitempty" description:"The tools the assistant asked to call"`
+	ToolCallID string        `json:"tool-call-id,omitempty" description:"The ID of the tool call answered by this tool message"`
+	Parts      []ContentPart `json:"parts,omitempty" description:"Content parts (text, images, documents) following the message content"`
+	Pinned     bool          `json:"pinned,omitempty" description:"Pinned messages are kept when the context is truncated"`
+	CreatedAt  time.Time     `json:"created-at" description:"The message creation date"`
 }
 
 type NewMessage struct {
-	Role    string `json:"role" required:"true" description:"The message role"`
-	Content string `json:"content" required:"true" description:"The message content"`
+	Role       string        `json:"role" required:"true" description:"The message role (user, assistant or tool)"`
+	Content    string        `json:"content" description:"The message content. For tool messages, the result of the tool call"`
+	ToolCalls  []ToolCall    `json:"tool-calls,omitempty" description:"The tools the assistant asked to call, for assistant messages"`
+	ToolCallID string        `json:"tool-call-id,omitempty" description:"The ID of the tool call answered by this message, for tool messages"`
+	Parts      []ContentPart `json:"parts,omitempty" description:"Content parts (text, images, documents) sent after the message content. Images and documents are only supported in user messages"`
+	Pinned     bool          `json:"pinned,omitempty" description:"Pinned messages are kept when the context is truncated with the keep-pinned strategy"`
 }
 
 type AddMessagesToContextInput struct {
@@ -65,6 +90,11 @@ type UpdateContextMessageInput struct {
 	Content string `json:"content" required:"true" description:"The message content"`
 }
 
+type PinContextMessageInput struct {
+	ID     string `json:"-" param:"id" path:"id"`
+	Pinned bool   `json:"pinned" description:"Pin (true) or unpin (false) the message"`
+}
+
 type DeleteContextMessageInput struct {
 	ID string `json:"-" param:"id" path:"id"`
 }
@@ -86,6 +116,24 @@ type CreateContextSourceContextInput struct {
 	SourceContextID string `json:"-" param:"source-context-id" path:"source-context-id"`
 }
 
+type CompactContextInput struct {
+	ID        string `json:"-" param:"id" path:"id"`
+	Provider  string `json:"provider" required:"true" description:"The name of the AI provider used to summarize the messages"`
+	Model     string `json:"model" description:"The model to use. If not set, the default model of the provider is used"`
+	MaxTokens uint64 `json:"max-tokens" description:"The maximum number of tokens for the summary (4096 by default)"`
+	KeepLast  int    `json:"keep-last" description:"The number of recent messages which are not compacted"`
+	Prompt    string `json:"prompt,omitempty" description:"Instructions replacing the default summarization prompt"`
+}
+
+type Compaction struct {
+	Context           string `json:"context" description:"The ID of the compacted context"`
+	ArchiveContext    string `json:"archive-context" description:"The ID of the context containing a copy of the compacted messages"`
+	SummaryMessage    string `json:"summary-message" description:"The ID of the summary message replacing the compacted messages"`
+	CompactedMessages int    `json:"compacted-messages" description:"The number of compacted messages"`
+	InputTokens       uint64 `json:"input-tokens" description:"The number of input tokens used for the summary"`
+	OutputTokens      uint64 `json:"output-tokens" description:"The number of output tokens used for the summary"`
+}
+
 type ListContextOutput struct {
 	Contexts []ContextMetadata `json:"contexts"`
 }
@@ -172,6 +220,15 @@ func (c *Client) UpdateContextMessage(ctx context.Context, input UpdateContextMe
 	return &result, nil
 }
 
+func (c *Client) PinContextMessage(ctx context.Context, input PinContextMessageInput) (*Response, error) {
+	var result Response
+	_, err := c.sendRequest(ctx, fmt.Sprintf("/api/v1/message/%s/pin", input.ID), http.MethodPut, input, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 func (c *Client) DeleteContextMessage(ctx context.Context, input DeleteContextMessageInput) (*Response, error) {
 	var result Response
 	_, err := c.sendRequest(ctx, fmt.Sprintf("/api/v1/message/%s", input.ID), http.MethodDelete, input, &result, nil)
@@ -198,3 +255,12 @@ func (c *Client) CreateContextSourceContext(ctx context.Context, input CreateCon
 	}
 	return &result, nil
 }
+
+func (c *Client) CompactContext(ctx context.Context, input CompactContextInput) (*Compaction, error) {
+	var result Compaction
+	_, err := c.sendRequest(ctx, fmt.Sprintf("/api/v1/context/%s/compact", input.ID), http.MethodPost, input, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
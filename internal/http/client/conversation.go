@@ -12,12 +12,51 @@ import (
 )
 
 type QueryOptions struct {
-	Model       string         `json:"model" required:"true" description:"The model to use"`
+	Model       string         `json:"model" description:"The model to use. If not set, the default model of the provider is used"`
 	System      string         `json:"system" description:"The system prompt"`
 	Temperature float64        `json:"temperature" description:"The temperature parameter passed to the AI provider"`
 	MaxTokens   uint64         `json:"max-tokens" required:"true" description:"The maximum number of tokens for the output"`
-	Provider    string         `json:"provider" required:"true" description:"The AI provider to use"`
+	Provider    string         `json:"provider" required:"true" description:"The name of the AI provider to use"`
 	RagQuery    RagSearchQuery `json:"rag,omitempty" description:"RAG query configuration"`
+	Tools       []Tool         `json:"tools,omitempty" description:"Tools the AI provider can ask to call"`
+	ServerTools []string       `json:"server-tools,omitempty" description:"Names of the tools registered in MaizAI that the AI provider can call. These tools are executed by MaizAI."`
+	Budget      *Budget        `json:"budget,omitempty" description:"Token budget for the conversation input. Messages from the context are dropped according to the strategy when the budget is exceeded"`
+	Cite        bool           `json:"cite,omitempty" description:"Ask the AI provider to cite inline the RAG chunks used in its answer"`
+}
+
+type Budget struct {
+	MaxTokens uint64 `json:"max-tokens" required:"true" description:"The maximum number of input tokens (estimated) sent to the AI provider, including the system prompt and the tools"`
+	Strategy  string `json:"strategy" required:"true" description:"The truncation strategy: drop-oldest, keep-first-last or keep-pinned"`
+	KeepFirst int    `json:"keep-first,omitempty" description:"The number of messages kept at the beginning of the conversation by the keep-first-last strategy"`
+	KeepLast  int    `json:"keep-last,omitempty" description:"The number of messages kept at the end of the conversation history by the keep-first-last strategy"`
+}
+
+type Truncation struct {
+	DroppedMessages      []string `json:"dropped-messages" description:"The IDs of the messages dropped from the conversation"`
+	EstimatedInputTokens uint64   `json:"estimated-input-tokens" description:"The estimated number of input tokens sent to the AI provider"`
+}
+
+type Citation struct {
+	ChunkID    string   `json:"chunk-id" description:"The ID of the RAG chunk injected in the conversation"`
+	DocumentID string   `json:"document-id" description:"The ID of the document of the chunk"`
+	Score      *float64 `json:"score,omitempty" description:"The score of the chunk for the RAG search"`
+}
+
+type Tool struct {
+	Name        string          `json:"name" required:"true" description:"The tool name"`
+	Description string          `json:"description" description:"The tool description"`
+	InputSchema json.RawMessage `json:"input-schema" required:"true" description:"The JSON schema of the tool arguments"`
+}
+
+type ListToolsOutput struct {
+	Tools []Tool `json:"tools"`
+}
+
+type ToolStep struct {
+	Iteration int      `json:"iteration" description:"The conversation iteration during which the tool was called"`
+	ToolCall  ToolCall `json:"tool-call" description:"The tool call executed by MaizAI"`
+	Output    string   `json:"output" description:"The tool output sent to the AI provider"`
+	Error     bool     `json:"error,omitempty" description:"True if the tool call failed"`
 }
 
 type ContextOptions struct {
@@ -35,22 +74,31 @@ type CreateConversationInput struct {
 }
 
 type Result struct {
-	Text string `json:"text"`
+	Text     string    `json:"text"`
+	ToolCall *ToolCall `json:"tool-call,omitempty" description:"Set when the AI provider asks to call a tool"`
 }
 
 type ConversationAnswer struct {
-	Results      []Result `json:"result" description:"The result returned by the AI provider"`
-	InputTokens  uint64   `json:"input-tokens" description:"The number of input tokens"`
-	OutputTokens uint64   `json:"output-tokens" description:"The number of output tokens"`
-	Context      string   `json:"context" description:"The ID of the context used for this conversation"`
+	Results      []Result    `json:"result" description:"The result returned by the AI provider"`
+	InputTokens  uint64      `json:"input-tokens" description:"The number of input tokens"`
+	OutputTokens uint64      `json:"output-tokens" description:"The number of output tokens"`
+	Context      string      `json:"context" description:"The ID of the context used for this conversation"`
+	Truncation   *Truncation `json:"truncation,omitempty" description:"The messages dropped to respect the token budget"`
+	Compaction   *Compaction `json:"compaction,omitempty" description:"Set when the context was automatically compacted after the conversation"`
+	Citations    []Citation  `json:"citations,omitempty" description:"The RAG chunks injected in the conversation"`
 }
 
 type ConversationStreamEvent struct {
-	Delta        string `json:"delta,omitempty"`
-	Error        string `json:"error,omitempty"`
-	InputTokens  uint64 `json:"input-tokens,omitempty"`
-	OutputTokens uint64 `json:"output-tokens,omitempty"`
-	Context      string `json:"context,omitempty"`
+	Delta        string      `json:"delta,omitempty"`
+	Error        string      `json:"error,omitempty"`
+	InputTokens  uint64      `json:"input-tokens,omitempty"`
+	OutputTokens uint64      `json:"output-tokens,omitempty"`
+	Context      string      `json:"context,omitempty"`
+	ToolCalls    []ToolCall  `json:"tool-calls,omitempty"`
+	ToolStep     *ToolStep   `json:"tool-step,omitempty"`
+	Truncation   *Truncation `json:"truncation,omitempty"`
+	Compaction   *Compaction `json:"compaction,omitempty"`
+	Citations    []Citation  `json:"citations,omitempty"`
 }
 
 func (c *Client) CreateConversation(ctx context.Context, input CreateConversationInput) (*ConversationAnswer, error) {
@@ -62,6 +110,15 @@ func (c *Client) CreateConversation(ctx context.Context, input CreateConversatio
 	return &result, nil
 }
 
+func (c *Client) ListTools(ctx context.Context) (*ListToolsOutput, error) {
+	var result ListToolsOutput
+	_, err := c.sendRequest(ctx, "/api/v1/tool", http.MethodGet, nil, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 func (c *Client) StreamConversation(ctx context.Context, input CreateConversationInput) (<-chan ConversationStreamEvent, error) {
 	eventChan := make(chan ConversationStreamEvent)
 	var reqBody io.Reader
@@ -73,12 +130,15 @@ func (c *Client) StreamConversation(ctx context.Context, input CreateConversatio
 	request, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodPost,
-		fmt.Sprintf("%s/api/v1/conversation", c.config.Endpoint),
+		fmt.Sprintf("%s%s", c.config.Endpoint, c.projectPath("/api/v1/conversation")),
 		reqBody)
 	if err != nil {
 		return nil, err
 	}
 	request.Header.Add("content-type", "application/json")
+	if c.config.Token != "" {
+		request.Header.Add("Authorization", "Bearer "+c.config.Token)
+	}
 	response, err := c.http.Do(request)
 	if err != nil {
 		return nil, err
@@ -4,26 +4,48 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 )
 
 type Document struct {
 	ID          string    `json:"id" description:"The document ID"`
+	Project     string    `json:"project" description:"The document project"`
 	Name        string    `json:"name" description:"The document name"`
 	Description string    `json:"description" description:"The document description"`
 	CreatedAt   time.Time `json:"created-at" description:"The document creation date"`
+	// EmbeddingProvider, EmbeddingModel and Dimension are recorded when the first chunk is embedded if not set at creation
+	EmbeddingProvider string            `json:"embedding-provider,omitempty" description:"The provider used to embed the document chunks"`
+	EmbeddingModel    string            `json:"embedding-model,omitempty" description:"The model used to embed the document chunks"`
+	Dimension         int               `json:"dimension,omitempty" description:"The dimension of the document chunks embeddings"`
+	Labels            map[string]string `json:"labels,omitempty" description:"The document labels"`
+	Source            string            `json:"source,omitempty" description:"The URI the document content comes from"`
 }
 
 type DocumentChunk struct {
-	ID         string    `json:"id" description:"The document chunk ID"`
-	DocumentID string    `json:"document-id,omitempty" description:"The related document ID"`
-	Fragment   string    `json:"fragment" description:"The text fragment embedded for this document"`
-	Embedding  []float32 `json:"-"`
-	CreatedAt  time.Time `json:"created-at" description:"The document chunk creation date"`
+	ID         string            `json:"id" description:"The document chunk ID"`
+	DocumentID string            `json:"document-id,omitempty" description:"The related document ID"`
+	Fragment   string            `json:"fragment" description:"The text fragment embedded for this document"`
+	Embedding  []float32         `json:"-"`
+	CreatedAt  time.Time         `json:"created-at" description:"The document chunk creation date"`
+	Ordinal    int               `json:"ordinal" description:"The position of the chunk in the document"`
+	Start      int               `json:"start" description:"The position (in characters) of the beginning of the fragment in the ingested content"`
+	End        int               `json:"end" description:"The position (in characters) of the end of the fragment in the ingested content"`
+	Metadata   map[string]string `json:"metadata,omitempty" description:"Free-form information about the chunk, for example a page number"`
+	Distance   *float64          `json:"distance,omitempty" description:"The distance between the chunk and the search input, using the search metric. Lower is closer"`
+	Score      *float64          `json:"score,omitempty" description:"The relevance of the chunk for the search input, higher is better: the cosine similarity for the cosine metric, the inner product for the inner-product metric, and 1 / (1 + distance) for the l2 metric"`
 }
 
 type ListDocumentChunksForDocumentInput struct {
 	DocumentID string `param:"id" path:"id"`
+	Metadata   string `query:"metadata" description:"Only list the chunks having all these metadata, as a comma separated list of key=value pairs (page=3,section=intro)"`
+}
+
+type ListDocumentsInput struct {
+	Name   string `query:"name" description:"Only list the document with this name"`
+	Source string `query:"source" description:"Only list the documents with this source"`
+	Labels string `query:"labels" description:"Only list the documents having all these labels, as a comma separated list of key=value pairs (product=maizai,team=ai)"`
 }
 
 type GetDocumentInput struct {
@@ -39,15 +61,32 @@ type DeleteDocumentChunkInput struct {
 }
 
 type CreateDocumentInput struct {
-	Name        string `json:"name" required:"true"`
-	Description string `json:"description"`
+	Name              string            `json:"name" required:"true"`
+	Description       string            `json:"description"`
+	EmbeddingProvider string            `json:"embedding-provider" description:"The provider used to embed the document chunks. If not set, the provider used for the first chunk is recorded"`
+	EmbeddingModel    string            `json:"embedding-model" description:"The model used to embed the document chunks. If not set, the model used for the first chunk is recorded"`
+	Dimension         int               `json:"dimension" description:"The dimension of the document chunks embeddings. If not set, the dimension of the first chunk is recorded"`
+	Labels            map[string]string `json:"labels" description:"Labels which can be used to filter the documents during a search"`
+	Source            string            `json:"source" description:"The URI the document content comes from, for example https://example.com/doc"`
 }
 
 type EmbedDocumentInput struct {
-	DocumentID string `json:"-" param:"document-id" path:"document-id"`
-	Model      string `json:"model" required:"true" description:"The embedding model to use"`
-	Input      string `json:"input" required:"true" description:"The query that will be executed on the RAG"`
-	Provider   string `json:"provider" required:"true" description:"The provider to use for embedding"`
+	DocumentID string            `json:"-" param:"document-id" path:"document-id"`
+	Model      string            `json:"model" description:"The embedding model to use. If not set, the embedding model of the document or the default model of the provider is used"`
+	Input      string            `json:"input" required:"true" description:"The query that will be executed on the RAG"`
+	Provider   string            `json:"provider" description:"The provider to use for embedding. If not set, the embedding provider of the document is used"`
+	Metadata   map[string]string `json:"metadata" description:"Free-form information stored with the chunk"`
+}
+
+type IngestDocumentInput struct {
+	DocumentID string            `json:"-" param:"id" path:"id"`
+	Content    string            `json:"content" required:"true" description:"The text or Markdown content to split in chunks and embed"`
+	Model      string            `json:"model" description:"The embedding model to use. If not set, the embedding model of the document or the default model of the provider is used"`
+	Provider   string            `json:"provider" description:"The provider to use for embedding. If not set, the embedding provider of the document is used"`
+	Strategy   string            `json:"strategy" description:"The chunking strategy: fixed, paragraph, markdown or recursive (default)"`
+	Size       int               `json:"size" description:"The maximum size of a chunk in characters (default 1000)"`
+	Overlap    int               `json:"overlap" description:"The number of characters shared by two consecutive chunks, for the fixed strategy"`
+	Metadata   map[string]string `json:"metadata" description:"Free-form information stored with all the chunks"`
 }
 
 type ListDocumentsOutput struct {
@@ -60,14 +99,47 @@ type ListDocumentChunksOutput struct {
 
 type RagSearchQuery struct {
 	Input    string `json:"input" required:"true" description:"The query that will be executed on the RAG"`
-	Model    string `json:"model" required:"true" description:"The embedding model to use"`
+	Model    string `json:"model" description:"The embedding model to use. If not set, the default model of the provider is used"`
 	Provider string `json:"provider" required:"true" description:"The provider to use for embedding"`
 	Limit    int32  `json:"limit" required:"true" description:"The number of results to return from the RAG database. Results will be concatenated and passed as context."`
+	Mode     string `json:"mode,omitempty" description:"The search mode: vector (default) or hybrid. Hybrid searches merge the vector search results with a keyword search using reciprocal rank fusion"`
+	Metric   string `json:"metric,omitempty" description:"The distance metric used to compare the query with the chunks: l2 (default), cosine or inner-product"`
+	EfSearch int    `json:"ef-search,omitempty" description:"The size of the candidates list used by HNSW indexes for this search. A higher value improves the recall but slows down the search"`
+	Probes   int    `json:"probes,omitempty" description:"The number of lists scanned by IVFFlat indexes for this search. A higher value improves the recall but slows down the search"`
+	// DocumentIDs and DocumentNames select the searched documents: a document is searched if its ID or its name is in the lists
+	DocumentIDs   []string          `json:"document-ids,omitempty" description:"Only search the documents with these IDs (or with the names in document-names)"`
+	DocumentNames []string          `json:"document-names,omitempty" description:"Only search the documents with these names (or with the IDs in document-ids)"`
+	Source        string            `json:"source,omitempty" description:"Only search the documents with this source"`
+	Labels        map[string]string `json:"labels,omitempty" description:"Only search the documents having all these labels"`
+	Metadata      map[string]string `json:"metadata,omitempty" description:"Only search the chunks having all these metadata"`
+	MinScore      *float64          `json:"min-score,omitempty" description:"Drop the chunks with a lower score. It applies to the scores of the vector search, before reranking"`
+	Candidates    int32             `json:"candidates,omitempty" description:"The number of chunks retrieved before being reranked and trimmed to the limit. Defaults to 4 times the limit for hybrid and reranked searches"`
+	Rerank        string            `json:"rerank,omitempty" description:"The name of a configured reranker used to reorder the candidates. The chunk scores are the reranker scores"`
+}
+
+// KeyValues encodes labels or metadata as a query parameter, for example product=maizai,team=ai
+func KeyValues(values map[string]string) string {
+	pairs := []string{}
+	for k, v := range values {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
 }
 
-func (c *Client) ListDocuments(ctx context.Context) (*ListDocumentsOutput, error) {
+func (c *Client) ListDocuments(ctx context.Context, input ListDocumentsInput) (*ListDocumentsOutput, error) {
 	var result ListDocumentsOutput
-	_, err := c.sendRequest(ctx, "/api/v1/document", http.MethodGet, nil, &result, nil)
+	queryParams := map[string]string{}
+	if input.Name != "" {
+		queryParams["name"] = input.Name
+	}
+	if input.Source != "" {
+		queryParams["source"] = input.Source
+	}
+	if input.Labels != "" {
+		queryParams["labels"] = input.Labels
+	}
+	_, err := c.sendRequest(ctx, "/api/v1/document", http.MethodGet, nil, &result, queryParams)
 	if err != nil {
 		return nil, err
 	}
@@ -92,6 +164,15 @@ func (c *Client) EmbedDocument(ctx context.Context, input EmbedDocumentInput) (*
 	return &result, nil
 }
 
+func (c *Client) IngestDocument(ctx context.Context, input IngestDocumentInput) (*ListDocumentChunksOutput, error) {
+	var result ListDocumentChunksOutput
+	_, err := c.sendRequest(ctx, fmt.Sprintf("/api/v1/document/%s/ingest", input.DocumentID), http.MethodPost, input, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 func (c *Client) GetDocument(ctx context.Context, id string) (*Document, error) {
 	var result Document
 	_, err := c.sendRequest(ctx, fmt.Sprintf("/api/v1/document/%s", id), http.MethodGet, nil, &result, nil)
@@ -128,9 +209,13 @@ func (c *Client) MatchChunk(ctx context.Context, input RagSearchQuery) (*ListDoc
 	return &result, nil
 }
 
-func (c *Client) ListDocumentsChunkForDocument(ctx context.Context, id string) (*ListDocumentChunksOutput, error) {
+func (c *Client) ListDocumentsChunkForDocument(ctx context.Context, input ListDocumentChunksForDocumentInput) (*ListDocumentChunksOutput, error) {
 	var result ListDocumentChunksOutput
-	_, err := c.sendRequest(ctx, fmt.Sprintf("/api/v1/document/%s/chunks", id), http.MethodGet, nil, &result, nil)
+	queryParams := map[string]string{}
+	if input.Metadata != "" {
+		queryParams["metadata"] = input.Metadata
+	}
+	_, err := c.sendRequest(ctx, fmt.Sprintf("/api/v1/document/%s/chunks", input.DocumentID), http.MethodGet, nil, &result, queryParams)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type VectorIndex struct {
+	Name           string `json:"name" description:"The index name"`
+	Type           string `json:"type" description:"The index type (hnsw or ivfflat)"`
+	Metric         string `json:"metric" description:"The distance metric of the searches using the index (l2, cosine or inner-product)"`
+	Dimension      int    `json:"dimension" description:"The dimension of the embeddings stored in the index"`
+	M              int    `json:"m,omitempty" description:"The maximum number of connections per layer (hnsw)"`
+	EfConstruction int    `json:"ef-construction,omitempty" description:"The size of the candidates list used to build the index (hnsw)"`
+	Lists          int    `json:"lists,omitempty" description:"The number of inverted lists (ivfflat)"`
+	Valid          bool   `json:"valid" description:"False if the index build failed or is in progress"`
+}
+
+type ListVectorIndexesOutput struct {
+	Indexes []VectorIndex `json:"indexes"`
+}
+
+type CreateVectorIndexInput struct {
+	Type           string `json:"type" required:"true" description:"The index type: hnsw or ivfflat"`
+	Metric         string `json:"metric" description:"The distance metric of the searches using the index: l2 (default), cosine or inner-product"`
+	Dimension      int    `json:"dimension" required:"true" description:"The index contains the chunks embeddings of this dimension"`
+	M              int    `json:"m" description:"The maximum number of connections per layer for hnsw indexes (default 16)"`
+	EfConstruction int    `json:"ef-construction" description:"The size of the candidates list used to build hnsw indexes (default 64)"`
+	Lists          int    `json:"lists" description:"The number of inverted lists for ivfflat indexes (default 100)"`
+}
+
+type VectorIndexInput struct {
+	Name string `param:"name" path:"name"`
+}
+
+func (c *Client) ListVectorIndexes(ctx context.Context) (*ListVectorIndexesOutput, error) {
+	var result ListVectorIndexesOutput
+	_, err := c.sendRequest(ctx, "/api/v1/vector-index", http.MethodGet, nil, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) CreateVectorIndex(ctx context.Context, input CreateVectorIndexInput) (*Response, error) {
+	var result Response
+	_, err := c.sendRequest(ctx, "/api/v1/vector-index", http.MethodPost, input, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) RebuildVectorIndex(ctx context.Context, name string) (*Response, error) {
+	var result Response
+	_, err := c.sendRequest(ctx, fmt.Sprintf("/api/v1/vector-index/%s/rebuild", name), http.MethodPost, nil, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) DropVectorIndex(ctx context.Context, name string) (*Response, error) {
+	var result Response
+	_, err := c.sendRequest(ctx, fmt.Sprintf("/api/v1/vector-index/%s", name), http.MethodDelete, nil, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
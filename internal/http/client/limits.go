@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+type ModelUsage struct {
+	Provider     string `json:"provider" description:"The AI provider"`
+	Model        string `json:"model" description:"The model, empty if the provider default model is used"`
+	InputTokens  uint64 `json:"input-tokens" description:"The input tokens used today"`
+	OutputTokens uint64 `json:"output-tokens" description:"The output tokens used today"`
+}
+
+type LimitsOutput struct {
+	Tenant            string       `json:"tenant" description:"The tenant of the limits: the token if the authentication is enabled, global otherwise"`
+	Requests          int          `json:"requests" description:"The number of requests done during the last minute"`
+	RequestsPerMinute int          `json:"requests-per-minute" description:"The number of requests allowed per minute, 0 if unlimited"`
+	DailyInputTokens  uint64       `json:"daily-input-tokens" description:"The number of input tokens allowed per day for each provider and model, 0 if unlimited"`
+	DailyOutputTokens uint64       `json:"daily-output-tokens" description:"The number of output tokens allowed per day for each provider and model, 0 if unlimited"`
+	Models            []ModelUsage `json:"models" description:"The tokens used today for each provider and model"`
+}
+
+func (c *Client) GetLimits(ctx context.Context) (*LimitsOutput, error) {
+	var result LimitsOutput
+	_, err := c.sendRequest(ctx, "/api/v1/limits", http.MethodGet, nil, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type Token struct {
+	ID        string    `json:"id" description:"The token ID"`
+	Name      string    `json:"name" description:"The token name"`
+	Project   string    `json:"project,omitempty" description:"The project the token is restricted to, all projects if empty"`
+	Scopes    []string  `json:"scopes" description:"The token scopes: read, conversation or admin"`
+	CreatedAt time.Time `json:"created-at" description:"The token creation date"`
+}
+
+type ListTokensOutput struct {
+	Tokens []Token `json:"tokens"`
+}
+
+type CreateTokenInput struct {
+	Name    string   `json:"name" required:"true" description:"The token name"`
+	Project string   `json:"project,omitempty" description:"Restrict the token to this project. The token can access all projects if empty"`
+	Scopes  []string `json:"scopes" required:"true" description:"The token scopes: read (read-only endpoints), conversation (read-only endpoints, conversations and contexts management) or admin (all endpoints)"`
+}
+
+type CreateTokenOutput struct {
+	Token
+	Value string `json:"value" description:"The token to use in the Authorization header. It is only returned at creation"`
+}
+
+type RevokeTokenInput struct {
+	ID string `param:"id" path:"id"`
+}
+
+func (c *Client) ListTokens(ctx context.Context) (*ListTokensOutput, error) {
+	var result ListTokensOutput
+	_, err := c.sendRequest(ctx, "/api/v1/token", http.MethodGet, nil, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) CreateToken(ctx context.Context, input CreateTokenInput) (*CreateTokenOutput, error) {
+	var result CreateTokenOutput
+	_, err := c.sendRequest(ctx, "/api/v1/token", http.MethodPost, input, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) RevokeToken(ctx context.Context, id string) (*Response, error) {
+	var result Response
+	_, err := c.sendRequest(ctx, fmt.Sprintf("/api/v1/token/%s", id), http.MethodDelete, nil, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+type UsageReportInput struct {
+	GroupBy string `query:"group-by" description:"The dimensions of the report rows, as a comma separated list of day, model, context and token"`
+	Since   string `query:"since" description:"Only report the usage since this date (RFC3339 or YYYY-MM-DD), included"`
+	Until   string `query:"until" description:"Only report the usage until this date (RFC3339 or YYYY-MM-DD), excluded"`
+	Context string `query:"context" description:"Only report the usage of this context ID"`
+	Token   string `query:"token" description:"Only report the usage of this token ID"`
+	Model   string `query:"model" description:"Only report the usage of this model"`
+}
+
+type UsageRow struct {
+	Day                   string  `json:"day,omitempty" description:"The day (YYYY-MM-DD, UTC), if the report is grouped by day"`
+	Provider              string  `json:"provider,omitempty" description:"The AI provider, if the report is grouped by model"`
+	Model                 string  `json:"model,omitempty" description:"The model, if the report is grouped by model. Empty if the provider default model is used"`
+	Context               string  `json:"context,omitempty" description:"The context ID, if the report is grouped by context"`
+	Token                 string  `json:"token,omitempty" description:"The token ID, if the report is grouped by token"`
+	Requests              uint64  `json:"requests" description:"The number of provider calls"`
+	Errors                uint64  `json:"errors" description:"The number of provider calls which failed"`
+	InputTokens           uint64  `json:"input-tokens" description:"The input tokens used"`
+	OutputTokens          uint64  `json:"output-tokens" description:"The output tokens used"`
+	AverageLatencySeconds float64 `json:"average-latency-seconds" description:"The average duration of the provider calls, in seconds"`
+	Cost                  float64 `json:"cost" description:"The cost computed from the configured prices, 0 for the models without price"`
+}
+
+type UsageReportOutput struct {
+	Rows []UsageRow `json:"rows"`
+}
+
+func (c *Client) UsageReport(ctx context.Context, input UsageReportInput) (*UsageReportOutput, error) {
+	var result UsageReportOutput
+	queryParams := map[string]string{}
+	if input.GroupBy != "" {
+		queryParams["group-by"] = input.GroupBy
+	}
+	if input.Since != "" {
+		queryParams["since"] = input.Since
+	}
+	if input.Until != "" {
+		queryParams["until"] = input.Until
+	}
+	if input.Context != "" {
+		queryParams["context"] = input.Context
+	}
+	if input.Token != "" {
+		queryParams["token"] = input.Token
+	}
+	if input.Model != "" {
+		queryParams["model"] = input.Model
+	}
+	_, err := c.sendRequest(ctx, "/api/v1/usage", http.MethodGet, nil, &result, queryParams)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
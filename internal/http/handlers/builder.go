@@ -5,37 +5,64 @@ import (
 
 	"github.com/appclacks/maizai/internal/http/client"
 	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	auth "github.com/appclacks/maizai/pkg/auth/aggregates"
+	limits "github.com/appclacks/maizai/pkg/limits/aggregates"
 	rag "github.com/appclacks/maizai/pkg/rag/aggregates"
 	"github.com/appclacks/maizai/pkg/shared"
+	usage "github.com/appclacks/maizai/pkg/usage/aggregates"
 )
 
 type Assistant interface {
-	Pipeline(ctx context.Context, options aggregates.QueryOptions, contextOptions shared.ContextOptions, context string, messages []shared.Message) (*aggregates.Answer, error)
-	StreamPipeline(ctx context.Context, options aggregates.QueryOptions, contextOptions shared.ContextOptions, contextID string, messages []shared.Message) (<-chan aggregates.Event, error)
+	Pipeline(ctx context.Context, project string, options aggregates.QueryOptions, contextOptions shared.ContextOptions, context string, messages []shared.Message) (*aggregates.Answer, error)
+	StreamPipeline(ctx context.Context, project string, options aggregates.QueryOptions, contextOptions shared.ContextOptions, contextID string, messages []shared.Message) (<-chan aggregates.Event, error)
+	ListTools() []aggregates.Tool
+	Compact(ctx context.Context, project string, contextID string, options aggregates.CompactOptions) (*aggregates.Compaction, error)
+	Model(provider string, model string) string
 }
 
 type ContextManager interface {
-	ListContexts(ctx context.Context) ([]shared.ContextMetadata, error)
+	ListContexts(ctx context.Context, project string) ([]shared.ContextMetadata, error)
 	CreateContext(ctx context.Context, context shared.Context) error
-	GetContext(ctx context.Context, id string) (*shared.Context, error)
-	DeleteContext(ctx context.Context, id string) error
-	AddMessagesToContext(ctx context.Context, id string, messages []shared.Message) error
-	DeleteContextMessage(ctx context.Context, id string) error
-	UpdateContextMessage(ctx context.Context, messageID string, role string, content string) error
-	DeleteContextSourceContext(ctx context.Context, contextID string, sourceContextID string) error
-	CreateContextSourceContext(ctx context.Context, contextID string, sourceContextID string) error
-	DeleteContextMessages(ctx context.Context, contextID string) error
+	GetContext(ctx context.Context, project string, id string) (*shared.Context, error)
+	DeleteContext(ctx context.Context, project string, id string) error
+	AddMessagesToContext(ctx context.Context, project string, id string, messages []shared.Message) error
+	DeleteContextMessage(ctx context.Context, project string, id string) error
+	UpdateContextMessage(ctx context.Context, project string, messageID string, role string, content string) error
+	PinContextMessage(ctx context.Context, project string, messageID string, pinned bool) error
+	DeleteContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error
+	CreateContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error
+	DeleteContextMessages(ctx context.Context, project string, contextID string) error
 }
 
 type Rag interface {
-	GetDocument(ctx context.Context, id string) (*rag.Document, error)
-	DeleteDocument(ctx context.Context, id string) error
-	DeleteDocumentChunk(ctx context.Context, id string) error
+	GetDocument(ctx context.Context, project string, id string) (*rag.Document, error)
+	DeleteDocument(ctx context.Context, project string, id string) error
+	DeleteDocumentChunk(ctx context.Context, project string, id string) error
 	CreateDocument(ctx context.Context, document rag.Document) error
-	ListDocuments(ctx context.Context) ([]rag.Document, error)
-	Embed(ctx context.Context, docID string, query rag.EmbeddingQuery) error
-	Match(ctx context.Context, query rag.SearchQuery) ([]rag.DocumentChunk, error)
-	ListDocumentChunksForDocument(ctx context.Context, id string) ([]rag.DocumentChunk, error)
+	ListDocuments(ctx context.Context, project string, filter rag.DocumentFilter) ([]rag.Document, error)
+	Embed(ctx context.Context, project string, docID string, query rag.EmbeddingQuery) error
+	Ingest(ctx context.Context, project string, docID string, query rag.IngestQuery) ([]rag.DocumentChunk, error)
+	Match(ctx context.Context, project string, query rag.SearchQuery) ([]rag.DocumentChunk, error)
+	ListDocumentChunksForDocument(ctx context.Context, project string, id string, metadata map[string]string) ([]rag.DocumentChunk, error)
+	ListVectorIndexes(ctx context.Context) ([]rag.VectorIndex, error)
+	CreateVectorIndex(ctx context.Context, index rag.VectorIndex) error
+	RebuildVectorIndex(ctx context.Context, name string) error
+	DropVectorIndex(ctx context.Context, name string) error
+}
+
+type TokenManager interface {
+	CreateToken(ctx context.Context, name string, project string, scopes []string) (*auth.Token, string, error)
+	ListTokens(ctx context.Context) ([]auth.Token, error)
+	RevokeToken(ctx context.Context, id string) error
+}
+
+type Limiter interface {
+	CheckQuota(tenant string, provider string, model string) error
+	Usage(tenant string) limits.Usage
+}
+
+type UsageManager interface {
+	Report(ctx context.Context, project string, query usage.ReportQuery) ([]usage.ReportRow, error)
 }
 
 func newResponse(messages ...string) client.Response {
@@ -45,15 +72,21 @@ func newResponse(messages ...string) client.Response {
 }
 
 type Builder struct {
-	assistant  Assistant
-	ctxManager ContextManager
-	ragManager Rag
+	assistant    Assistant
+	ctxManager   ContextManager
+	ragManager   Rag
+	tokenManager TokenManager
+	limiter      Limiter
+	usageManager UsageManager
 }
 
-func NewBuilder(assistant Assistant, ctxManager ContextManager, ragManager Rag) *Builder {
+func NewBuilder(assistant Assistant, ctxManager ContextManager, ragManager Rag, tokenManager TokenManager, limiter Limiter, usageManager UsageManager) *Builder {
 	return &Builder{
-		assistant:  assistant,
-		ctxManager: ctxManager,
-		ragManager: ragManager,
+		assistant:    assistant,
+		ctxManager:   ctxManager,
+		ragManager:   ragManager,
+		tokenManager: tokenManager,
+		limiter:      limiter,
+		usageManager: usageManager,
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/appclacks/maizai/internal/http/client"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
 	"github.com/appclacks/maizai/pkg/context"
 	"github.com/appclacks/maizai/pkg/shared"
 	"github.com/labstack/echo/v4"
@@ -12,6 +13,7 @@ import (
 func toClientMetadata(context shared.ContextMetadata) client.ContextMetadata {
 	result := client.ContextMetadata{
 		ID:          context.ID,
+		Project:     context.Project,
 		Name:        context.Name,
 		Description: context.Description,
 		Sources: client.ContextSources{
@@ -25,6 +27,7 @@ func toClientMetadata(context shared.ContextMetadata) client.ContextMetadata {
 func toClientContext(context shared.Context) client.Context {
 	result := client.Context{
 		ID:          context.ID,
+		Project:     context.Project,
 		Name:        context.Name,
 		Description: context.Description,
 		Sources: client.ContextSources{
@@ -35,17 +38,91 @@ func toClientContext(context shared.Context) client.Context {
 	}
 	for _, message := range context.Messages {
 		result.Messages = append(result.Messages, client.Message{
-			ID:        message.ID,
-			Role:      message.Role,
-			Content:   message.Content,
-			CreatedAt: message.CreatedAt,
+			ID:         message.ID,
+			Role:       message.Role,
+			Content:    message.Content,
+			ToolCalls:  toClientToolCalls(message.ToolCalls),
+			ToolCallID: message.ToolCallID,
+			Parts:      toClientParts(message.Parts),
+			Pinned:     message.Pinned,
+			CreatedAt:  message.CreatedAt,
 		})
 	}
 	return result
 }
 
+func toClientToolCall(toolCall shared.ToolCall) client.ToolCall {
+	return client.ToolCall{
+		ID:        toolCall.ID,
+		Name:      toolCall.Name,
+		Arguments: toolCall.Arguments,
+	}
+}
+
+func toClientToolCalls(toolCalls []shared.ToolCall) []client.ToolCall {
+	var result []client.ToolCall
+	for _, toolCall := range toolCalls {
+		result = append(result, toClientToolCall(toolCall))
+	}
+	return result
+}
+
+func toClientParts(parts []shared.ContentPart) []client.ContentPart {
+	var result []client.ContentPart
+	for _, part := range parts {
+		result = append(result, client.ContentPart{
+			Type:      part.Type,
+			Text:      part.Text,
+			MediaType: part.MediaType,
+			Data:      part.Data,
+			URL:       part.URL,
+		})
+	}
+	return result
+}
+
+func toMessage(message client.NewMessage) (*shared.Message, error) {
+	result, err := shared.NewMessage(message.Role, message.Content)
+	if err != nil {
+		return nil, err
+	}
+	result.ToolCallID = message.ToolCallID
+	result.Pinned = message.Pinned
+	for _, toolCall := range message.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, shared.ToolCall{
+			ID:        toolCall.ID,
+			Name:      toolCall.Name,
+			Arguments: toolCall.Arguments,
+		})
+	}
+	for _, part := range message.Parts {
+		result.Parts = append(result.Parts, shared.ContentPart{
+			Type:      part.Type,
+			Text:      part.Text,
+			MediaType: part.MediaType,
+			Data:      part.Data,
+			URL:       part.URL,
+		})
+	}
+	return result, nil
+}
+
+func toClientCompaction(compaction *aggregates.Compaction) *client.Compaction {
+	if compaction == nil {
+		return nil
+	}
+	return &client.Compaction{
+		Context:           compaction.Context,
+		ArchiveContext:    compaction.ArchiveContext,
+		SummaryMessage:    compaction.SummaryMessage,
+		CompactedMessages: compaction.CompactedMessages,
+		InputTokens:       compaction.InputTokens,
+		OutputTokens:      compaction.OutputTokens,
+	}
+}
+
 func (b *Builder) ListContexts(ec echo.Context) error {
-	contexts, err := b.ctxManager.ListContexts(ec.Request().Context())
+	contexts, err := b.ctxManager.ListContexts(ec.Request().Context(), Project(ec))
 	if err != nil {
 		return err
 	}
@@ -63,7 +140,7 @@ func (b *Builder) GetContext(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	context, err := b.ctxManager.GetContext(ec.Request().Context(), payload.ID)
+	context, err := b.ctxManager.GetContext(ec.Request().Context(), Project(ec), payload.ID)
 	if err != nil {
 		return err
 	}
@@ -80,12 +157,12 @@ func (b *Builder) CreateContext(ec echo.Context) error {
 		Description: payload.Description,
 		Sources:     payload.Sources,
 	}
-	context, err := context.NewContext(options)
+	context, err := context.NewContext(Project(ec), options)
 	if err != nil {
 		return err
 	}
 	for _, message := range payload.Messages {
-		newMessage, err := shared.NewMessage(message.Role, message.Content)
+		newMessage, err := toMessage(message)
 		if err != nil {
 			return err
 		}
@@ -104,7 +181,7 @@ func (b *Builder) DeleteContext(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	err := b.ctxManager.DeleteContext(ec.Request().Context(), payload.ID)
+	err := b.ctxManager.DeleteContext(ec.Request().Context(), Project(ec), payload.ID)
 	if err != nil {
 		return err
 	}
@@ -118,13 +195,13 @@ func (b *Builder) AddMessagesToContext(ec echo.Context) error {
 	}
 	messages := []shared.Message{}
 	for _, message := range payload.Messages {
-		msg, err := shared.NewMessage(message.Role, message.Content)
+		msg, err := toMessage(message)
 		if err != nil {
 			return err
 		}
 		messages = append(messages, *msg)
 	}
-	err := b.ctxManager.AddMessagesToContext(ec.Request().Context(), payload.ID, messages)
+	err := b.ctxManager.AddMessagesToContext(ec.Request().Context(), Project(ec), payload.ID, messages)
 	if err != nil {
 		return err
 	}
@@ -136,7 +213,7 @@ func (b *Builder) DeleteContextMessage(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	err := b.ctxManager.DeleteContextMessage(ec.Request().Context(), payload.ID)
+	err := b.ctxManager.DeleteContextMessage(ec.Request().Context(), Project(ec), payload.ID)
 	if err != nil {
 		return err
 	}
@@ -148,19 +225,34 @@ func (b *Builder) UpdateContextMessage(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	err := b.ctxManager.UpdateContextMessage(ec.Request().Context(), payload.ID, payload.Role, payload.Content)
+	err := b.ctxManager.UpdateContextMessage(ec.Request().Context(), Project(ec), payload.ID, payload.Role, payload.Content)
 	if err != nil {
 		return err
 	}
 	return ec.JSON(http.StatusOK, newResponse("message updated"))
 }
 
+func (b *Builder) PinContextMessage(ec echo.Context) error {
+	var payload client.PinContextMessageInput
+	if err := ec.Bind(&payload); err != nil {
+		return err
+	}
+	err := b.ctxManager.PinContextMessage(ec.Request().Context(), Project(ec), payload.ID, payload.Pinned)
+	if err != nil {
+		return err
+	}
+	if payload.Pinned {
+		return ec.JSON(http.StatusOK, newResponse("message pinned"))
+	}
+	return ec.JSON(http.StatusOK, newResponse("message unpinned"))
+}
+
 func (b *Builder) DeleteContextSourceContext(ec echo.Context) error {
 	var payload client.DeleteContextSourceContextInput
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	err := b.ctxManager.DeleteContextSourceContext(ec.Request().Context(), payload.ID, payload.SourceContextID)
+	err := b.ctxManager.DeleteContextSourceContext(ec.Request().Context(), Project(ec), payload.ID, payload.SourceContextID)
 	if err != nil {
 		return err
 	}
@@ -172,7 +264,7 @@ func (b *Builder) CreateContextSourceContext(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	err := b.ctxManager.CreateContextSourceContext(ec.Request().Context(), payload.ID, payload.SourceContextID)
+	err := b.ctxManager.CreateContextSourceContext(ec.Request().Context(), Project(ec), payload.ID, payload.SourceContextID)
 	if err != nil {
 		return err
 	}
@@ -184,9 +276,32 @@ func (b *Builder) DeleteContextMessages(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	err := b.ctxManager.DeleteContextMessages(ec.Request().Context(), payload.ID)
+	err := b.ctxManager.DeleteContextMessages(ec.Request().Context(), Project(ec), payload.ID)
 	if err != nil {
 		return err
 	}
 	return ec.JSON(http.StatusOK, newResponse("Context messages deleted"))
 }
+
+func (b *Builder) CompactContext(ec echo.Context) error {
+	var payload client.CompactContextInput
+	if err := ec.Bind(&payload); err != nil {
+		return err
+	}
+	tenant := Tenant(ec)
+	err := b.limiter.CheckQuota(tenant, payload.Provider, b.assistant.Model(payload.Provider, payload.Model))
+	if err != nil {
+		return err
+	}
+	compaction, err := b.assistant.Compact(ec.Request().Context(), Project(ec), payload.ID, aggregates.CompactOptions{
+		Provider:  payload.Provider,
+		Model:     payload.Model,
+		MaxTokens: payload.MaxTokens,
+		KeepLast:  payload.KeepLast,
+		Prompt:    payload.Prompt,
+	})
+	if err != nil {
+		return err
+	}
+	return ec.JSON(http.StatusOK, toClientCompaction(compaction))
+}
@@ -12,6 +12,45 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+func toClientTruncation(truncation *aggregates.Truncation) *client.Truncation {
+	if truncation == nil {
+		return nil
+	}
+	return &client.Truncation{
+		DroppedMessages:      truncation.DroppedMessages,
+		EstimatedInputTokens: truncation.EstimatedInputTokens,
+	}
+}
+
+func toClientCitations(citations []aggregates.Citation) []client.Citation {
+	if len(citations) == 0 {
+		return nil
+	}
+	result := []client.Citation{}
+	for _, citation := range citations {
+		result = append(result, client.Citation{
+			ChunkID:    citation.ChunkID,
+			DocumentID: citation.DocumentID,
+			Score:      citation.Score,
+		})
+	}
+	return result
+}
+
+func (b *Builder) ListTools(ec echo.Context) error {
+	result := client.ListToolsOutput{
+		Tools: []client.Tool{},
+	}
+	for _, tool := range b.assistant.ListTools() {
+		result.Tools = append(result.Tools, client.Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.InputSchema,
+		})
+	}
+	return ec.JSON(http.StatusOK, result)
+}
+
 func (b *Builder) Conversation(ec echo.Context) error {
 	var payload client.CreateConversationInput
 	if err := ec.Bind(&payload); err != nil {
@@ -20,7 +59,7 @@ func (b *Builder) Conversation(ec echo.Context) error {
 
 	messages := []shared.Message{}
 	for _, m := range payload.Messages {
-		msg, err := shared.NewMessage(m.Role, m.Content)
+		msg, err := toMessage(m)
 		if err != nil {
 			return err
 		}
@@ -34,13 +73,42 @@ func (b *Builder) Conversation(ec echo.Context) error {
 		Temperature: payload.QueryOptions.Temperature,
 		MaxTokens:   payload.QueryOptions.MaxTokens,
 		Provider:    payload.QueryOptions.Provider,
+		ServerTools: payload.QueryOptions.ServerTools,
+		Cite:        payload.QueryOptions.Cite,
 		RagQuery: ragdata.SearchQuery{
-			Input:    payload.QueryOptions.RagQuery.Input,
-			Model:    payload.QueryOptions.RagQuery.Model,
-			Provider: payload.QueryOptions.RagQuery.Provider,
-			Limit:    payload.QueryOptions.RagQuery.Limit,
+			Input:         payload.QueryOptions.RagQuery.Input,
+			Model:         payload.QueryOptions.RagQuery.Model,
+			Provider:      payload.QueryOptions.RagQuery.Provider,
+			Limit:         payload.QueryOptions.RagQuery.Limit,
+			Mode:          payload.QueryOptions.RagQuery.Mode,
+			Metric:        payload.QueryOptions.RagQuery.Metric,
+			EfSearch:      payload.QueryOptions.RagQuery.EfSearch,
+			Probes:        payload.QueryOptions.RagQuery.Probes,
+			DocumentIDs:   payload.QueryOptions.RagQuery.DocumentIDs,
+			DocumentNames: payload.QueryOptions.RagQuery.DocumentNames,
+			Source:        payload.QueryOptions.RagQuery.Source,
+			Labels:        payload.QueryOptions.RagQuery.Labels,
+			Metadata:      payload.QueryOptions.RagQuery.Metadata,
+			MinScore:      payload.QueryOptions.RagQuery.MinScore,
+			Candidates:    payload.QueryOptions.RagQuery.Candidates,
+			Rerank:        payload.QueryOptions.RagQuery.Rerank,
 		},
 	}
+	if payload.QueryOptions.Budget != nil {
+		queryOpts.Budget = &aggregates.Budget{
+			MaxTokens: payload.QueryOptions.Budget.MaxTokens,
+			Strategy:  payload.QueryOptions.Budget.Strategy,
+			KeepFirst: payload.QueryOptions.Budget.KeepFirst,
+			KeepLast:  payload.QueryOptions.Budget.KeepLast,
+		}
+	}
+	for _, tool := range payload.QueryOptions.Tools {
+		queryOpts.Tools = append(queryOpts.Tools, aggregates.Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.InputSchema,
+		})
+	}
 	contextOpts := shared.ContextOptions{
 		Name:        payload.NewContextOptions.Name,
 		Description: payload.NewContextOptions.Description,
@@ -48,8 +116,13 @@ func (b *Builder) Conversation(ec echo.Context) error {
 			Contexts: payload.NewContextOptions.Sources.Contexts,
 		},
 	}
+	tenant := Tenant(ec)
+	err := b.limiter.CheckQuota(tenant, queryOpts.Provider, b.assistant.Model(queryOpts.Provider, queryOpts.Model))
+	if err != nil {
+		return err
+	}
 	if payload.Stream {
-		eventChan, err := b.assistant.StreamPipeline(ctx, queryOpts, contextOpts, payload.ContextID, messages)
+		eventChan, err := b.assistant.StreamPipeline(ctx, Project(ec), queryOpts, contextOpts, payload.ContextID, messages)
 		if err != nil {
 			return err
 		}
@@ -65,10 +138,26 @@ func (b *Builder) Conversation(ec echo.Context) error {
 			if event.Error != nil {
 				e.Error = event.Error.Error()
 			}
+			if event.ToolStep != nil {
+				e.ToolStep = &client.ToolStep{
+					Iteration: event.ToolStep.Iteration,
+					ToolCall:  toClientToolCall(event.ToolStep.ToolCall),
+					Output:    event.ToolStep.Output,
+					Error:     event.ToolStep.Error,
+				}
+			}
 			if event.Answer != nil {
 				e.InputTokens = event.Answer.InputTokens
 				e.OutputTokens = event.Answer.OutputTokens
 				e.Context = event.Answer.Context
+				e.Truncation = toClientTruncation(event.Answer.Truncation)
+				e.Compaction = toClientCompaction(event.Answer.Compaction)
+				e.Citations = toClientCitations(event.Answer.Citations)
+				for _, result := range event.Answer.Results {
+					if result.ToolCall != nil {
+						e.ToolCalls = append(e.ToolCalls, toClientToolCall(*result.ToolCall))
+					}
+				}
 			}
 			j, err := json.Marshal(e)
 			if err != nil {
@@ -87,7 +176,7 @@ func (b *Builder) Conversation(ec echo.Context) error {
 		}
 		return nil
 	} else {
-		answer, err := b.assistant.Pipeline(ctx, queryOpts, contextOpts, payload.ContextID, messages)
+		answer, err := b.assistant.Pipeline(ctx, Project(ec), queryOpts, contextOpts, payload.ContextID, messages)
 		if err != nil {
 			return err
 		}
@@ -96,11 +185,19 @@ func (b *Builder) Conversation(ec echo.Context) error {
 			InputTokens:  answer.InputTokens,
 			OutputTokens: answer.OutputTokens,
 			Context:      answer.Context,
+			Truncation:   toClientTruncation(answer.Truncation),
+			Compaction:   toClientCompaction(answer.Compaction),
+			Citations:    toClientCitations(answer.Citations),
 		}
 		for _, result := range answer.Results {
-			response.Results = append(response.Results, client.Result{
+			r := client.Result{
 				Text: result.Text,
-			})
+			}
+			if result.ToolCall != nil {
+				toolCall := toClientToolCall(*result.ToolCall)
+				r.ToolCall = &toolCall
+			}
+			response.Results = append(response.Results, r)
 		}
 		return ec.JSON(http.StatusOK, response)
 	}
@@ -2,18 +2,42 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/appclacks/maizai/internal/http/client"
 	"github.com/appclacks/maizai/pkg/rag/aggregates"
 	"github.com/labstack/echo/v4"
+	er "github.com/mcorbin/corbierror"
 )
 
+// parseKeyValues parses a query parameter containing key=value pairs separated by commas
+func parseKeyValues(name string, value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return nil, er.Newf("Invalid %s parameter %s: it should be a list of key=value pairs separated by commas", er.BadRequest, true, name, value)
+		}
+		result[k] = v
+	}
+	return result, nil
+}
+
 func toClientDocument(document aggregates.Document) client.Document {
 	return client.Document{
-		ID:          document.ID,
-		Name:        document.Name,
-		Description: document.Description,
-		CreatedAt:   document.CreatedAt,
+		ID:                document.ID,
+		Project:           document.Project,
+		Name:              document.Name,
+		Description:       document.Description,
+		CreatedAt:         document.CreatedAt,
+		EmbeddingProvider: document.EmbeddingProvider,
+		EmbeddingModel:    document.EmbeddingModel,
+		Dimension:         document.Dimension,
+		Labels:            document.Labels,
+		Source:            document.Source,
 	}
 }
 
@@ -24,11 +48,32 @@ func toClientDocumentChunk(chunk aggregates.DocumentChunk) client.DocumentChunk
 		Fragment:   chunk.Fragment,
 		Embedding:  chunk.Embedding,
 		CreatedAt:  chunk.CreatedAt,
+		Ordinal:    chunk.Ordinal,
+		Start:      chunk.Start,
+		End:        chunk.End,
+		Metadata:   chunk.Metadata,
+		Distance:   chunk.Distance,
+		Score:      chunk.Score,
 	}
 }
 
 func (b *Builder) ListDocuments(ec echo.Context) error {
-	documents, err := b.ragManager.ListDocuments(ec.Request().Context())
+	var payload client.ListDocumentsInput
+	if err := ec.Bind(&payload); err != nil {
+		return err
+	}
+	labels, err := parseKeyValues("labels", payload.Labels)
+	if err != nil {
+		return err
+	}
+	filter := aggregates.DocumentFilter{
+		Source: payload.Source,
+		Labels: labels,
+	}
+	if payload.Name != "" {
+		filter.Names = []string{payload.Name}
+	}
+	documents, err := b.ragManager.ListDocuments(ec.Request().Context(), Project(ec), filter)
 	if err != nil {
 		return err
 	}
@@ -46,10 +91,15 @@ func (b *Builder) CreateDocument(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	document, err := aggregates.NewDocument(payload.Name, payload.Description)
+	document, err := aggregates.NewDocument(Project(ec), payload.Name, payload.Description)
 	if err != nil {
 		return err
 	}
+	document.EmbeddingProvider = payload.EmbeddingProvider
+	document.EmbeddingModel = payload.EmbeddingModel
+	document.Dimension = payload.Dimension
+	document.Labels = payload.Labels
+	document.Source = payload.Source
 	err = b.ragManager.CreateDocument(ec.Request().Context(), *document)
 	if err != nil {
 		return err
@@ -66,20 +116,48 @@ func (b *Builder) EmbedDocument(ec echo.Context) error {
 		Model:    payload.Model,
 		Input:    payload.Input,
 		Provider: payload.Provider,
+		Metadata: payload.Metadata,
 	}
-	err := b.ragManager.Embed(ec.Request().Context(), payload.DocumentID, query)
+	err := b.ragManager.Embed(ec.Request().Context(), Project(ec), payload.DocumentID, query)
 	if err != nil {
 		return err
 	}
 	return ec.JSON(http.StatusOK, newResponse("document chunk created"))
 }
 
+func (b *Builder) IngestDocument(ec echo.Context) error {
+	var payload client.IngestDocumentInput
+	if err := ec.Bind(&payload); err != nil {
+		return err
+	}
+	query := aggregates.IngestQuery{
+		Content:  payload.Content,
+		Model:    payload.Model,
+		Provider: payload.Provider,
+		Strategy: payload.Strategy,
+		Size:     payload.Size,
+		Overlap:  payload.Overlap,
+		Metadata: payload.Metadata,
+	}
+	chunks, err := b.ragManager.Ingest(ec.Request().Context(), Project(ec), payload.DocumentID, query)
+	if err != nil {
+		return err
+	}
+	response := client.ListDocumentChunksOutput{
+		Chunks: []client.DocumentChunk{},
+	}
+	for _, c := range chunks {
+		response.Chunks = append(response.Chunks, toClientDocumentChunk(c))
+	}
+	return ec.JSON(http.StatusOK, response)
+}
+
 func (b *Builder) GetDocument(ec echo.Context) error {
 	var payload client.GetDocumentInput
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	doc, err := b.ragManager.GetDocument(ec.Request().Context(), payload.ID)
+	doc, err := b.ragManager.GetDocument(ec.Request().Context(), Project(ec), payload.ID)
 	if err != nil {
 		return err
 	}
@@ -91,7 +169,7 @@ func (b *Builder) DeleteDocument(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	err := b.ragManager.DeleteDocument(ec.Request().Context(), payload.ID)
+	err := b.ragManager.DeleteDocument(ec.Request().Context(), Project(ec), payload.ID)
 	if err != nil {
 		return err
 	}
@@ -103,7 +181,7 @@ func (b *Builder) DeleteDocumentChunk(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	err := b.ragManager.DeleteDocumentChunk(ec.Request().Context(), payload.ID)
+	err := b.ragManager.DeleteDocumentChunk(ec.Request().Context(), Project(ec), payload.ID)
 	if err != nil {
 		return err
 	}
@@ -115,11 +193,23 @@ func (b *Builder) MatchChunk(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	chunks, err := b.ragManager.Match(ec.Request().Context(), aggregates.SearchQuery{
-		Input:    payload.Input,
-		Model:    payload.Model,
-		Provider: payload.Provider,
-		Limit:    payload.Limit,
+	chunks, err := b.ragManager.Match(ec.Request().Context(), Project(ec), aggregates.SearchQuery{
+		Input:         payload.Input,
+		Model:         payload.Model,
+		Provider:      payload.Provider,
+		Limit:         payload.Limit,
+		Mode:          payload.Mode,
+		Metric:        payload.Metric,
+		EfSearch:      payload.EfSearch,
+		Probes:        payload.Probes,
+		DocumentIDs:   payload.DocumentIDs,
+		DocumentNames: payload.DocumentNames,
+		Source:        payload.Source,
+		Labels:        payload.Labels,
+		Metadata:      payload.Metadata,
+		MinScore:      payload.MinScore,
+		Candidates:    payload.Candidates,
+		Rerank:        payload.Rerank,
 	})
 	if err != nil {
 		return err
@@ -138,7 +228,11 @@ func (b *Builder) ListDocumentChunksForDocument(ec echo.Context) error {
 	if err := ec.Bind(&payload); err != nil {
 		return err
 	}
-	chunks, err := b.ragManager.ListDocumentChunksForDocument(ec.Request().Context(), payload.DocumentID)
+	metadata, err := parseKeyValues("metadata", payload.Metadata)
+	if err != nil {
+		return err
+	}
+	chunks, err := b.ragManager.ListDocumentChunksForDocument(ec.Request().Context(), Project(ec), payload.DocumentID, metadata)
 	if err != nil {
 		return err
 	}
@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
+	limits "github.com/appclacks/maizai/pkg/limits/aggregates"
 	"github.com/labstack/echo/v4"
 	er "github.com/mcorbin/corbierror"
 )
@@ -17,6 +21,19 @@ func ErrorHandler() func(err error, c echo.Context) {
 		// with nil passed, like for the rate limiter
 		if err != nil {
 			errLoggedMsg := err.Error() + " on " + c.Request().Method + " " + c.Request().URL.Path
+			var limitError *limits.LimitError
+			if errors.As(err, &limitError) {
+				slog.Warn(errLoggedMsg)
+				c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitError.RetryAfter.Seconds()))))
+				err := c.JSON(http.StatusTooManyRequests, er.Error{
+					Messages: []string{limitError.Message},
+				})
+				if err != nil {
+					slog.Error(err.Error())
+					c.Response().Status = http.StatusInternalServerError
+				}
+				return
+			}
 			corbiError, ok := err.(*er.Error)
 			if ok {
 				if corbiError.Type == er.Forbidden {
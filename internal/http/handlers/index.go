@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/appclacks/maizai/internal/http/client"
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/labstack/echo/v4"
+)
+
+func toClientVectorIndex(index aggregates.VectorIndex) client.VectorIndex {
+	return client.VectorIndex{
+		Name:           index.Name,
+		Type:           index.Type,
+		Metric:         index.Metric,
+		Dimension:      index.Dimension,
+		M:              index.M,
+		EfConstruction: index.EfConstruction,
+		Lists:          index.Lists,
+		Valid:          index.Valid,
+	}
+}
+
+func (b *Builder) ListVectorIndexes(ec echo.Context) error {
+	indexes, err := b.ragManager.ListVectorIndexes(ec.Request().Context())
+	if err != nil {
+		return err
+	}
+	response := client.ListVectorIndexesOutput{
+		Indexes: []client.VectorIndex{},
+	}
+	for _, index := range indexes {
+		response.Indexes = append(response.Indexes, toClientVectorIndex(index))
+	}
+	return ec.JSON(http.StatusOK, response)
+}
+
+func (b *Builder) CreateVectorIndex(ec echo.Context) error {
+	var payload client.CreateVectorIndexInput
+	if err := ec.Bind(&payload); err != nil {
+		return err
+	}
+	index := aggregates.NewVectorIndex(payload.Type, payload.Metric, payload.Dimension, payload.M, payload.EfConstruction, payload.Lists)
+	err := b.ragManager.CreateVectorIndex(ec.Request().Context(), index)
+	if err != nil {
+		return err
+	}
+	return ec.JSON(http.StatusOK, newResponse("vector index created"))
+}
+
+func (b *Builder) RebuildVectorIndex(ec echo.Context) error {
+	var payload client.VectorIndexInput
+	if err := ec.Bind(&payload); err != nil {
+		return err
+	}
+	err := b.ragManager.RebuildVectorIndex(ec.Request().Context(), payload.Name)
+	if err != nil {
+		return err
+	}
+	return ec.JSON(http.StatusOK, newResponse("vector index rebuilt"))
+}
+
+func (b *Builder) DropVectorIndex(ec echo.Context) error {
+	var payload client.VectorIndexInput
+	if err := ec.Bind(&payload); err != nil {
+		return err
+	}
+	err := b.ragManager.DropVectorIndex(ec.Request().Context(), payload.Name)
+	if err != nil {
+		return err
+	}
+	return ec.JSON(http.StatusOK, newResponse("vector index deleted"))
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/appclacks/maizai/internal/http/client"
+	auth "github.com/appclacks/maizai/pkg/auth/aggregates"
+	"github.com/labstack/echo/v4"
+)
+
+const tenantKey = "tenant"
+
+// globalTenant is the tenant of all the requests when the authentication is disabled.
+// The project can't be used: it's chosen by the caller.
+const globalTenant = "global"
+
+// SetTokenTenant uses the request token as the tenant of the rate limits and the quotas
+func SetTokenTenant(ec echo.Context, token auth.Token) {
+	ec.Set(tenantKey, "token:"+token.Identifier())
+}
+
+// Tenant returns the tenant of the request: its token if the authentication
+// is enabled, the global tenant otherwise
+func Tenant(ec echo.Context) string {
+	if tenant, ok := ec.Get(tenantKey).(string); ok && tenant != "" {
+		return tenant
+	}
+	return globalTenant
+}
+
+func (b *Builder) GetLimits(ec echo.Context) error {
+	usage := b.limiter.Usage(Tenant(ec))
+	result := client.LimitsOutput{
+		Tenant:            usage.Tenant,
+		Requests:          usage.Requests,
+		RequestsPerMinute: usage.RequestsPerMinute,
+		DailyInputTokens:  usage.DailyInputTokens,
+		DailyOutputTokens: usage.DailyOutputTokens,
+		Models:            []client.ModelUsage{},
+	}
+	for _, model := range usage.Models {
+		result.Models = append(result.Models, client.ModelUsage{
+			Provider:     model.Provider,
+			Model:        model.Model,
+			InputTokens:  model.InputTokens,
+			OutputTokens: model.OutputTokens,
+		})
+	}
+	return ec.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/labstack/echo/v4"
+)
+
+const tokenProjectKey = "token-project"
+
+// SetTokenProject records the project the request token is restricted to
+func SetTokenProject(ec echo.Context, project string) {
+	ec.Set(tokenProjectKey, project)
+}
+
+// Project returns the project of the request: the project of the path, or the
+// project of the token if the path doesn't contain one, or the default project.
+func Project(ec echo.Context) string {
+	if project := ec.Param("project"); project != "" {
+		return project
+	}
+	if project, ok := ec.Get(tokenProjectKey).(string); ok && project != "" {
+		return project
+	}
+	return shared.DefaultProject
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/appclacks/maizai/internal/http/client"
+	"github.com/appclacks/maizai/pkg/auth/aggregates"
+	"github.com/labstack/echo/v4"
+)
+
+func toClientToken(token aggregates.Token) client.Token {
+	return client.Token{
+		ID:        token.ID,
+		Name:      token.Name,
+		Project:   token.Project,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt,
+	}
+}
+
+func (b *Builder) ListTokens(ec echo.Context) error {
+	tokens, err := b.tokenManager.ListTokens(ec.Request().Context())
+	if err != nil {
+		return err
+	}
+	response := client.ListTokensOutput{
+		Tokens: []client.Token{},
+	}
+	for _, token := range tokens {
+		response.Tokens = append(response.Tokens, toClientToken(token))
+	}
+	return ec.JSON(http.StatusOK, response)
+}
+
+func (b *Builder) CreateToken(ec echo.Context) error {
+	var payload client.CreateTokenInput
+	if err := ec.Bind(&payload); err != nil {
+		return err
+	}
+	token, value, err := b.tokenManager.CreateToken(ec.Request().Context(), payload.Name, payload.Project, payload.Scopes)
+	if err != nil {
+		return err
+	}
+	return ec.JSON(http.StatusOK, client.CreateTokenOutput{
+		Token: toClientToken(*token),
+		Value: value,
+	})
+}
+
+func (b *Builder) RevokeToken(ec echo.Context) error {
+	var payload client.RevokeTokenInput
+	if err := ec.Bind(&payload); err != nil {
+		return err
+	}
+	err := b.tokenManager.RevokeToken(ec.Request().Context(), payload.ID)
+	if err != nil {
+		return err
+	}
+	return ec.JSON(http.StatusOK, newResponse("token revoked"))
+}
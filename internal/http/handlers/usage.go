@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/appclacks/maizai/internal/http/client"
+	"github.com/appclacks/maizai/pkg/usage/aggregates"
+	"github.com/labstack/echo/v4"
+	er "github.com/mcorbin/corbierror"
+)
+
+func parseDate(name string, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, er.Newf("Invalid %s parameter %s: it should be a RFC3339 date or a YYYY-MM-DD day", er.BadRequest, true, name, value)
+}
+
+func (b *Builder) UsageReport(ec echo.Context) error {
+	var payload client.UsageReportInput
+	if err := ec.Bind(&payload); err != nil {
+		return err
+	}
+	since, err := parseDate("since", payload.Since)
+	if err != nil {
+		return err
+	}
+	until, err := parseDate("until", payload.Until)
+	if err != nil {
+		return err
+	}
+	query := aggregates.ReportQuery{
+		Filter: aggregates.Filter{
+			Since:   since,
+			Until:   until,
+			Context: payload.Context,
+			Token:   payload.Token,
+			Model:   payload.Model,
+		},
+	}
+	if payload.GroupBy != "" {
+		query.GroupBy = strings.Split(payload.GroupBy, ",")
+	}
+	rows, err := b.usageManager.Report(ec.Request().Context(), Project(ec), query)
+	if err != nil {
+		return err
+	}
+	result := client.UsageReportOutput{
+		Rows: []client.UsageRow{},
+	}
+	for _, row := range rows {
+		result.Rows = append(result.Rows, client.UsageRow{
+			Day:                   row.Day,
+			Provider:              row.Provider,
+			Model:                 row.Model,
+			Context:               row.Context,
+			Token:                 row.Token,
+			Requests:              row.Requests,
+			Errors:                row.Errors,
+			InputTokens:           row.InputTokens,
+			OutputTokens:          row.OutputTokens,
+			AverageLatencySeconds: row.AverageLatency.Seconds(),
+			Cost:                  row.Cost,
+		})
+	}
+	return ec.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,28 @@
+package http
+
+import (
+	"github.com/appclacks/maizai/internal/http/handlers"
+	"github.com/appclacks/maizai/pkg/limits"
+	"github.com/labstack/echo/v4"
+)
+
+type RateLimiter interface {
+	Allow(tenant string) error
+}
+
+// rateLimitMiddleware rejects the requests of the tenants exceeding their rate limit, and
+// attaches the tenant to the request context so the tokens used by the request are counted.
+// It should be executed after the authentication middleware which sets the tenant.
+func rateLimitMiddleware(limiter RateLimiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ec echo.Context) error {
+			tenant := handlers.Tenant(ec)
+			err := limiter.Allow(tenant)
+			if err != nil {
+				return err
+			}
+			ec.SetRequest(ec.Request().WithContext(limits.WithTenant(ec.Request().Context(), tenant)))
+			return next(ec)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/appclacks/maizai/pkg/shared"
 	"github.com/labstack/echo/v4"
+	er "github.com/mcorbin/corbierror"
 	"github.com/swaggest/openapi-go/openapi3"
 )
 
@@ -15,6 +17,25 @@ type apiv1 struct {
 	payload     any
 	response    any
 	description string
+	// scope is the token scope required to call the endpoint when the authentication is enabled
+	scope string
+	// global endpoints are not scoped by project and are only registered once
+	global bool
+}
+
+type projectInput struct {
+	Project string `param:"project" path:"project" description:"The project name"`
+}
+
+// projectMiddleware validates the project of the request path
+func projectMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ec echo.Context) error {
+		err := shared.ValidateProject(ec.Param("project"))
+		if err != nil {
+			return er.New(err.Error(), er.BadRequest, true)
+		}
+		return next(ec)
+	}
 }
 
 func openapiPath(path string) string {
@@ -40,7 +61,23 @@ func openapiPath(path string) string {
 	return string(result)
 }
 
-func openapiSpec(e *echo.Echo, definitions []apiv1) error {
+func addOperation(reflector *openapi3.Reflector, definition apiv1, path string, params any) error {
+	operation, err := reflector.NewOperationContext(definition.method, path)
+	if err != nil {
+		return err
+	}
+	if params != nil {
+		operation.AddReqStructure(params)
+	}
+	if definition.payload != nil {
+		operation.AddReqStructure(definition.payload)
+	}
+	operation.SetDescription(definition.description)
+	operation.AddRespStructure(definition.response)
+	return reflector.AddOperation(operation)
+}
+
+func openapiSpec(e *echo.Echo, definitions []apiv1, authenticator Authenticator, rateLimiter RateLimiter) error {
 	apiGroup := e.Group("/api/v1")
 	reflector := openapi3.Reflector{}
 	reflector.Spec = &openapi3.Spec{Openapi: "3.0.3"}
@@ -48,19 +85,25 @@ func openapiSpec(e *echo.Echo, definitions []apiv1) error {
 		WithTitle("MaizAI API").
 		WithVersion("0.0.1").
 		WithDescription("Maizai HTTP API spec")
+	projectGroup := apiGroup.Group("/projects/:project", projectMiddleware)
 	for _, definition := range definitions {
-		apiGroup.Add(definition.method, definition.path, definition.handler)
-		path := fmt.Sprintf("/api/v1%s", openapiPath(definition.path))
-		operation, err := reflector.NewOperationContext(definition.method, path)
+		middlewares := []echo.MiddlewareFunc{}
+		if authenticator != nil {
+			middlewares = append(middlewares, authMiddleware(authenticator, definition.scope, definition.global))
+		}
+		if rateLimiter != nil {
+			middlewares = append(middlewares, rateLimitMiddleware(rateLimiter))
+		}
+		apiGroup.Add(definition.method, definition.path, definition.handler, middlewares...)
+		err := addOperation(&reflector, definition, fmt.Sprintf("/api/v1%s", openapiPath(definition.path)), nil)
 		if err != nil {
 			return err
 		}
-		if definition.payload != nil {
-			operation.AddReqStructure(definition.payload)
+		if definition.global {
+			continue
 		}
-		operation.SetDescription(definition.description)
-		operation.AddRespStructure(definition.response)
-		err = reflector.AddOperation(operation)
+		projectGroup.Add(definition.method, definition.path, definition.handler, middlewares...)
+		err = addOperation(&reflector, definition, fmt.Sprintf("/api/v1/projects/{project}%s", openapiPath(definition.path)), projectInput{})
 		if err != nil {
 			return err
 		}
@@ -13,6 +13,7 @@ import (
 	"github.com/appclacks/maizai/internal/http/client"
 	"github.com/appclacks/maizai/internal/http/handlers"
 	"github.com/appclacks/maizai/internal/tls"
+	"github.com/appclacks/maizai/pkg/auth/aggregates"
 	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -35,7 +36,9 @@ type Server struct {
 	wg     sync.WaitGroup
 }
 
-func New(config Configuration, registry *prometheus.Registry, builder *handlers.Builder) (*Server, error) {
+// New creates the HTTP server. The API endpoints require a token if the authenticator is not nil,
+// and are rate limited if the rate limiter is not nil.
+func New(config Configuration, registry *prometheus.Registry, builder *handlers.Builder, authenticator Authenticator, rateLimiter RateLimiter) (*Server, error) {
 	if config.Host == "" || config.Port == 0 {
 		return nil, errors.New("Invalid HTTP configuration: host and port are mandatory")
 	}
@@ -43,9 +46,11 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 	e.HideBanner = true
 	e.HidePort = true
 
+	// conversations can last minutes
 	buckets := []float64{
 		0.05, 0.1, 0.2, 0.4, 0.8, 1,
-		1.5, 2, 3, 5}
+		1.5, 2, 3, 5, 10, 20, 30, 60,
+		120, 300}
 
 	respCounter := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -88,6 +93,15 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.CreateConversationInput{},
 			response:    client.ConversationAnswer{},
 			description: "Send a message to the AI provider. If a context ID is passed as parameter, use this context as a base. Else, a new context whose name will be the context named as parameter will be created.",
+			scope:       aggregates.ConversationScope,
+		},
+		{
+			path:        "/tool",
+			method:      http.MethodGet,
+			handler:     builder.ListTools,
+			response:    client.ListToolsOutput{},
+			description: "List the tools registered in MaizAI",
+			scope:       aggregates.ReadScope,
 		},
 		{
 			path:        "/context",
@@ -96,6 +110,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     nil,
 			response:    client.ListContextOutput{},
 			description: "List contexts",
+			scope:       aggregates.ReadScope,
 		},
 		{
 			path:        "/context/:id",
@@ -104,6 +119,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.GetContextInput{},
 			response:    client.Context{},
 			description: "Get a context by ID",
+			scope:       aggregates.ReadScope,
 		},
 		{
 			path:        "/context",
@@ -112,6 +128,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.CreateContextInput{},
 			response:    client.Response{},
 			description: "Create a new context",
+			scope:       aggregates.ConversationScope,
 		},
 		{
 			path:        "/context/:id",
@@ -120,6 +137,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.DeleteContextInput{},
 			response:    client.Response{},
 			description: "Delete a context by ID",
+			scope:       aggregates.ConversationScope,
 		},
 		{
 			path:        "/context/:id/sources/context/:source-context-id",
@@ -128,6 +146,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.DeleteContextSourceContextInput{},
 			response:    client.Response{},
 			description: "Remove a context used as a source for a given context",
+			scope:       aggregates.ConversationScope,
 		},
 		{
 			path:        "/context/:id/sources/context/:source-context-id",
@@ -136,6 +155,16 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.CreateContextSourceContextInput{},
 			response:    client.Response{},
 			description: "Add a context as a source for a given context",
+			scope:       aggregates.ConversationScope,
+		},
+		{
+			path:        "/context/:id/compact",
+			method:      http.MethodPost,
+			handler:     builder.CompactContext,
+			payload:     client.CompactContextInput{},
+			response:    client.Compaction{},
+			description: "Summarize the oldest messages of a context with an AI provider and replace them by the summary. The compacted messages are copied to an archive context",
+			scope:       aggregates.ConversationScope,
 		},
 		{
 			path:        "/context/:id/message",
@@ -144,6 +173,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.AddMessagesToContextInput{},
 			response:    client.Response{},
 			description: "Add new messages for a given context",
+			scope:       aggregates.ConversationScope,
 		},
 		{
 			path:        "/context/:id/message",
@@ -152,6 +182,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.DeleteContextMessagesInput{},
 			response:    client.Response{},
 			description: "Delete all messages for a given context",
+			scope:       aggregates.ConversationScope,
 		},
 		{
 			path:        "/message/:id",
@@ -160,6 +191,16 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.UpdateContextMessageInput{},
 			response:    client.Response{},
 			description: "Update an existing message",
+			scope:       aggregates.ConversationScope,
+		},
+		{
+			path:        "/message/:id/pin",
+			method:      http.MethodPut,
+			handler:     builder.PinContextMessage,
+			payload:     client.PinContextMessageInput{},
+			response:    client.Response{},
+			description: "Pin or unpin a message. Pinned messages are kept when the context is truncated with the keep-pinned strategy",
+			scope:       aggregates.ConversationScope,
 		},
 		{
 			path:        "/message/:id",
@@ -168,14 +209,16 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.DeleteContextMessageInput{},
 			response:    client.Response{},
 			description: "Delete a message by ID",
+			scope:       aggregates.ConversationScope,
 		},
 		{
 			path:        "/document",
 			method:      http.MethodGet,
 			handler:     builder.ListDocuments,
-			payload:     nil,
+			payload:     client.ListDocumentsInput{},
 			response:    client.ListDocumentsOutput{},
 			description: "List documents",
+			scope:       aggregates.ReadScope,
 		},
 		{
 			path:        "/document",
@@ -184,6 +227,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.CreateDocumentInput{},
 			response:    client.Response{},
 			description: "Create a new document",
+			scope:       aggregates.AdminScope,
 		},
 		{
 			path:        "/document/:document-id",
@@ -192,6 +236,16 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.EmbedDocumentInput{},
 			response:    client.Response{},
 			description: "Embed the input passed as parameter for the given document, to use it later in MaizAI's RAG",
+			scope:       aggregates.AdminScope,
+		},
+		{
+			path:        "/document/:id/ingest",
+			method:      http.MethodPost,
+			handler:     builder.IngestDocument,
+			payload:     client.IngestDocumentInput{},
+			response:    client.ListDocumentChunksOutput{},
+			description: "Split the content in chunks using a chunking strategy, embed the chunks and store them for the given document",
+			scope:       aggregates.AdminScope,
 		},
 		{
 			path:        "/document/:id",
@@ -200,6 +254,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.GetDocumentInput{},
 			response:    client.Document{},
 			description: "Get a document by ID",
+			scope:       aggregates.ReadScope,
 		},
 		{
 			path:        "/document/:id/chunks",
@@ -208,6 +263,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.ListDocumentChunksForDocumentInput{},
 			response:    client.ListDocumentChunksOutput{},
 			description: "List chunks for a given document",
+			scope:       aggregates.ReadScope,
 		},
 		{
 			path:        "/document/:id",
@@ -216,6 +272,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.DeleteDocumentInput{},
 			response:    client.Response{},
 			description: "Delete a document by ID",
+			scope:       aggregates.AdminScope,
 		},
 		{
 			path:        "/document-chunk/:id",
@@ -224,6 +281,7 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.DeleteDocumentChunkInput{},
 			response:    client.Response{},
 			description: "Delete a document chunk by ID",
+			scope:       aggregates.AdminScope,
 		},
 		{
 			path:        "/document-chunk",
@@ -232,10 +290,97 @@ func New(config Configuration, registry *prometheus.Registry, builder *handlers.
 			payload:     client.RagSearchQuery{},
 			response:    client.ListDocumentChunksOutput{},
 			description: "Return chunks matching the provided input",
+			scope:       aggregates.ConversationScope,
+		},
+		{
+			path:        "/vector-index",
+			method:      http.MethodGet,
+			handler:     builder.ListVectorIndexes,
+			payload:     nil,
+			response:    client.ListVectorIndexesOutput{},
+			description: "List the vector indexes on the document chunks embeddings",
+			scope:       aggregates.ReadScope,
+			global:      true,
+		},
+		{
+			path:        "/vector-index",
+			method:      http.MethodPost,
+			handler:     builder.CreateVectorIndex,
+			payload:     client.CreateVectorIndexInput{},
+			response:    client.Response{},
+			description: "Create an HNSW or IVFFlat index on the document chunks embeddings of a given dimension. The index is built concurrently",
+			scope:       aggregates.AdminScope,
+			global:      true,
+		},
+		{
+			path:        "/vector-index/:name/rebuild",
+			method:      http.MethodPost,
+			handler:     builder.RebuildVectorIndex,
+			payload:     client.VectorIndexInput{},
+			response:    client.Response{},
+			description: "Rebuild a vector index concurrently",
+			scope:       aggregates.AdminScope,
+			global:      true,
+		},
+		{
+			path:        "/vector-index/:name",
+			method:      http.MethodDelete,
+			handler:     builder.DropVectorIndex,
+			payload:     client.VectorIndexInput{},
+			response:    client.Response{},
+			description: "Delete a vector index",
+			scope:       aggregates.AdminScope,
+			global:      true,
+		},
+		{
+			path:        "/token",
+			method:      http.MethodGet,
+			handler:     builder.ListTokens,
+			response:    client.ListTokensOutput{},
+			description: "List the API tokens",
+			scope:       aggregates.AdminScope,
+			global:      true,
+		},
+		{
+			path:        "/token",
+			method:      http.MethodPost,
+			handler:     builder.CreateToken,
+			payload:     client.CreateTokenInput{},
+			response:    client.CreateTokenOutput{},
+			description: "Create an API token. The token value is only returned in the response",
+			scope:       aggregates.AdminScope,
+			global:      true,
+		},
+		{
+			path:        "/token/:id",
+			method:      http.MethodDelete,
+			handler:     builder.RevokeToken,
+			payload:     client.RevokeTokenInput{},
+			response:    client.Response{},
+			description: "Revoke an API token",
+			scope:       aggregates.AdminScope,
+			global:      true,
+		},
+		{
+			path:        "/limits",
+			method:      http.MethodGet,
+			handler:     builder.GetLimits,
+			response:    client.LimitsOutput{},
+			description: "Get the rate limit and the daily tokens quotas of the caller, and its current usage",
+			scope:       aggregates.ReadScope,
+		},
+		{
+			path:        "/usage",
+			method:      http.MethodGet,
+			handler:     builder.UsageReport,
+			payload:     client.UsageReportInput{},
+			response:    client.UsageReportOutput{},
+			description: "Get a report of the AI providers usage of the project: requests, errors, tokens, latency and cost",
+			scope:       aggregates.ReadScope,
 		},
 	}
 
-	err = openapiSpec(e, definitions)
+	err = openapiSpec(e, definitions, authenticator, rateLimiter)
 	if err != nil {
 		return nil, err
 	}
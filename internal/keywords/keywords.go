@@ -0,0 +1,58 @@
+package keywords
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+)
+
+// Tokens splits a text into lower case words. Underscores are kept so
+// identifiers like error codes are not split.
+func Tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// Rank returns the number of occurrences of the query words in the text.
+// It returns 0 if the text contains none of the words.
+func Rank(query []string, text string) float64 {
+	words := make(map[string]bool, len(query))
+	for _, word := range query {
+		words[word] = true
+	}
+	rank := 0.0
+	for _, token := range Tokens(text) {
+		if words[token] {
+			rank++
+		}
+	}
+	return rank
+}
+
+// Match returns the chunks containing words of the query, sorted by decreasing rank.
+// It is used by the stores without full-text search support.
+func Match(query string, chunks []aggregates.DocumentChunk, limit int) []aggregates.DocumentChunk {
+	words := Tokens(query)
+	ranks := make(map[string]float64, len(chunks))
+	result := []aggregates.DocumentChunk{}
+	for _, chunk := range chunks {
+		rank := Rank(words, chunk.Fragment)
+		if rank > 0 {
+			ranks[chunk.ID] = rank
+			result = append(result, chunk)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if ranks[result[i].ID] == ranks[result[j].ID] {
+			return result[i].ID < result[j].ID
+		}
+		return ranks[result[i].ID] > ranks[result[j].ID]
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
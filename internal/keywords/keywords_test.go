@@ -0,0 +1,20 @@
+package keywords_test
+
+import (
+	"testing"
+
+	"github.com/appclacks/maizai/internal/keywords"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokens(t *testing.T) {
+	assert.Equal(t, []string{"error", "err_42", "in", "fetchuser"}, keywords.Tokens("Error ERR_42 in fetchUser()"))
+	assert.Empty(t, keywords.Tokens(" ,;"))
+}
+
+func TestRank(t *testing.T) {
+	query := keywords.Tokens("ERR_42 timeout")
+	assert.Equal(t, float64(0), keywords.Rank(query, "unrelated content"))
+	assert.Equal(t, float64(1), keywords.Rank(query, "the request failed with err_42"))
+	assert.Equal(t, float64(3), keywords.Rank(query, "Timeout: ERR_42. Increase the timeout"))
+}
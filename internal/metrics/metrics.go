@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	QueryMode  = "query"
+	StreamMode = "stream"
+)
+
+// otherModel is the model label of the calls to the models which never answered, so the
+// number of series doesn't depend on the model names sent by the clients
+const otherModel = "other"
+
+// LLMBuckets are the buckets of the histograms measuring calls to the AI providers,
+// which can take minutes for long answers
+var LLMBuckets = []float64{
+	0.1, 0.25, 0.5, 1, 2, 5,
+	10, 20, 30, 60, 120, 300}
+
+// Metrics contains the metrics of the AI providers calls and of the RAG.
+// All methods can be called on a nil Metrics, in which case nothing is recorded.
+type Metrics struct {
+	providerRequests  *prometheus.CounterVec
+	providerErrors    *prometheus.CounterVec
+	inputTokens       *prometheus.CounterVec
+	outputTokens      *prometheus.CounterVec
+	providerDuration  *prometheus.HistogramVec
+	timeToFirstToken  *prometheus.HistogramVec
+	embeddingRequests *prometheus.CounterVec
+	embeddingErrors   *prometheus.CounterVec
+	ragMatchDuration  *prometheus.HistogramVec
+	lock              sync.Mutex
+	// models contains the models which answered, by provider
+	models map[string]map[string]bool
+}
+
+func New(registry *prometheus.Registry) (*Metrics, error) {
+	m := &Metrics{
+		providerRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "llm_requests_total",
+				Help: "Count the number of calls to the AI providers",
+			},
+			[]string{"provider", "model", "mode"}),
+		providerErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "llm_errors_total",
+				Help: "Count the number of calls to the AI providers which failed",
+			},
+			[]string{"provider", "model", "mode"}),
+		inputTokens: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "llm_input_tokens_total",
+				Help: "Count the number of input tokens used by the AI providers calls",
+			},
+			[]string{"provider", "model"}),
+		outputTokens: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "llm_output_tokens_total",
+				Help: "Count the number of output tokens used by the AI providers calls",
+			},
+			[]string{"provider", "model"}),
+		providerDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "llm_request_duration_seconds",
+				Help:    "Time to execute the calls to the AI providers, until the complete answer is received",
+				Buckets: LLMBuckets,
+			},
+			[]string{"provider", "model", "mode"}),
+		timeToFirstToken: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "llm_time_to_first_token_seconds",
+				Help:    "Time to receive the first text of the streamed answers of the AI providers",
+				Buckets: LLMBuckets,
+			},
+			[]string{"provider", "model"}),
+		embeddingRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "embedding_requests_total",
+				Help: "Count the number of embedding calls to the AI providers",
+			},
+			[]string{"provider", "model"}),
+		embeddingErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "embedding_errors_total",
+				Help: "Count the number of embedding calls to the AI providers which failed",
+			},
+			[]string{"provider", "model"}),
+		ragMatchDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "rag_match_duration_seconds",
+				Help:    "Time to search the document chunks matching a query, including the embedding of the query and the reranking",
+				Buckets: LLMBuckets,
+			},
+			[]string{"mode"}),
+		models: make(map[string]map[string]bool),
+	}
+	collectors := []prometheus.Collector{
+		m.providerRequests,
+		m.providerErrors,
+		m.inputTokens,
+		m.outputTokens,
+		m.providerDuration,
+		m.timeToFirstToken,
+		m.embeddingRequests,
+		m.embeddingErrors,
+		m.ragMatchDuration,
+	}
+	for _, collector := range collectors {
+		err := registry.Register(collector)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// modelLabel returns the label of the model. The models are only used as label once they
+// answered: a model used by the provider exists, unlike the models requested by the clients.
+func (m *Metrics) modelLabel(provider string, model string, answered bool) string {
+	if model == "" {
+		return otherModel
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if answered {
+		if m.models[provider] == nil {
+			m.models[provider] = make(map[string]bool)
+		}
+		m.models[provider][model] = true
+		return model
+	}
+	if m.models[provider][model] {
+		return model
+	}
+	return otherModel
+}
+
+// ProviderCall records a call to an AI provider which took duration. The model is the model
+// used by the provider if the call succeeded, the requested model otherwise. The tokens
+// are only recorded if the call succeeded.
+func (m *Metrics) ProviderCall(provider string, model string, mode string, duration time.Duration, inputTokens uint64, outputTokens uint64, err error) {
+	if m == nil {
+		return
+	}
+	model = m.modelLabel(provider, model, err == nil)
+	m.providerRequests.With(prometheus.Labels{"provider": provider, "model": model, "mode": mode}).Inc()
+	m.providerDuration.With(prometheus.Labels{"provider": provider, "model": model, "mode": mode}).Observe(duration.Seconds())
+	if err != nil {
+		m.providerErrors.With(prometheus.Labels{"provider": provider, "model": model, "mode": mode}).Inc()
+		return
+	}
+	m.inputTokens.With(prometheus.Labels{"provider": provider, "model": model}).Add(float64(inputTokens))
+	m.outputTokens.With(prometheus.Labels{"provider": provider, "model": model}).Add(float64(outputTokens))
+}
+
+// FirstToken records the time to receive the first text of a streamed answer.
+// The model is the model used by the provider.
+func (m *Metrics) FirstToken(provider string, model string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.timeToFirstToken.With(prometheus.Labels{"provider": provider, "model": m.modelLabel(provider, model, true)}).Observe(duration.Seconds())
+}
+
+// EmbeddingCall records an embedding call to an AI provider. The model is the model
+// used by the provider if the call succeeded, the requested model otherwise.
+func (m *Metrics) EmbeddingCall(provider string, model string, err error) {
+	if m == nil {
+		return
+	}
+	labels := prometheus.Labels{"provider": provider, "model": m.modelLabel(provider, model, err == nil)}
+	m.embeddingRequests.With(labels).Inc()
+	if err != nil {
+		m.embeddingErrors.With(labels).Inc()
+	}
+}
+
+// RagMatch records the duration of a RAG search
+func (m *Metrics) RagMatch(mode string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.ragMatchDuration.With(prometheus.Labels{"mode": mode}).Observe(duration.Seconds())
+}
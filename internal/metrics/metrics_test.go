@@ -0,0 +1,73 @@
+package metrics_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appclacks/maizai/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := metrics.New(registry)
+	assert.NoError(t, err)
+	_, err = metrics.New(registry)
+	assert.Error(t, err)
+
+	m.ProviderCall("anthropic", "claude", metrics.QueryMode, 2*time.Second, 10, 20, nil)
+	m.ProviderCall("anthropic", "claude", metrics.QueryMode, time.Second, 0, 0, errors.New("unavailable"))
+	m.ProviderCall("mistral", "mistral-small", metrics.StreamMode, 40*time.Second, 5, 7, nil)
+	// the models which never answered are not used as label
+	m.ProviderCall("mistral", "unknown-model", metrics.QueryMode, time.Second, 0, 0, errors.New("unknown model"))
+	m.ProviderCall("mistral", "", metrics.QueryMode, time.Second, 0, 0, errors.New("A model name is mandatory"))
+	m.EmbeddingCall("mistral", "mistral-embed", nil)
+	m.EmbeddingCall("mistral", "mistral-embed", errors.New("unavailable"))
+	m.EmbeddingCall("mistral", "", errors.New("unavailable"))
+	m.RagMatch("hybrid", 500*time.Millisecond)
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP llm_requests_total Count the number of calls to the AI providers
+# TYPE llm_requests_total counter
+llm_requests_total{mode="query",model="claude",provider="anthropic"} 2
+llm_requests_total{mode="query",model="other",provider="mistral"} 2
+llm_requests_total{mode="stream",model="mistral-small",provider="mistral"} 1
+# HELP llm_errors_total Count the number of calls to the AI providers which failed
+# TYPE llm_errors_total counter
+llm_errors_total{mode="query",model="claude",provider="anthropic"} 1
+llm_errors_total{mode="query",model="other",provider="mistral"} 2
+# HELP llm_input_tokens_total Count the number of input tokens used by the AI providers calls
+# TYPE llm_input_tokens_total counter
+llm_input_tokens_total{model="claude",provider="anthropic"} 10
+llm_input_tokens_total{model="mistral-small",provider="mistral"} 5
+# HELP llm_output_tokens_total Count the number of output tokens used by the AI providers calls
+# TYPE llm_output_tokens_total counter
+llm_output_tokens_total{model="claude",provider="anthropic"} 20
+llm_output_tokens_total{model="mistral-small",provider="mistral"} 7
+# HELP embedding_requests_total Count the number of embedding calls to the AI providers
+# TYPE embedding_requests_total counter
+embedding_requests_total{model="mistral-embed",provider="mistral"} 2
+embedding_requests_total{model="other",provider="mistral"} 1
+# HELP embedding_errors_total Count the number of embedding calls to the AI providers which failed
+# TYPE embedding_errors_total counter
+embedding_errors_total{model="mistral-embed",provider="mistral"} 1
+embedding_errors_total{model="other",provider="mistral"} 1
+`), "llm_requests_total", "llm_errors_total", "llm_input_tokens_total", "llm_output_tokens_total", "embedding_requests_total", "embedding_errors_total")
+	assert.NoError(t, err)
+
+	count, err := testutil.GatherAndCount(registry, "llm_request_duration_seconds", "rag_match_duration_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *metrics.Metrics
+	m.ProviderCall("anthropic", "claude", metrics.QueryMode, time.Second, 10, 20, nil)
+	m.FirstToken("anthropic", "claude", time.Second)
+	m.EmbeddingCall("mistral", "mistral-embed", nil)
+	m.RagMatch("vector", time.Second)
+}
@@ -2,13 +2,17 @@ package anthropic
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptrace"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/appclacks/maizai/internal/otelspan"
+	"github.com/appclacks/maizai/internal/tokens"
 	"github.com/appclacks/maizai/pkg/assistant/aggregates"
 	"github.com/appclacks/maizai/pkg/shared"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
@@ -19,11 +23,18 @@ import (
 )
 
 type Client struct {
-	client *anthropic.Client
+	client       *anthropic.Client
+	defaultModel string
 }
 
 type Config struct {
 	APIKey string
+	// BaseURL overrides the Anthropic API URL
+	BaseURL string
+	// Timeout applies to the whole request, including the streaming of the answer
+	Timeout time.Duration
+	// DefaultModel is used when no model is provided in a query
+	DefaultModel string
 }
 
 func New(config Config) *Client {
@@ -34,17 +45,171 @@ func New(config Config) *Client {
 				return otelhttptrace.NewClientTrace(ctx)
 			}),
 		),
+		Timeout: config.Timeout,
 	}
-	client := anthropic.NewClient(
+	options := []option.RequestOption{
 		option.WithAPIKey(config.APIKey),
 		option.WithHTTPClient(httpClient),
-	)
+	}
+	if config.BaseURL != "" {
+		options = append(options, option.WithBaseURL(config.BaseURL))
+	}
+	client := anthropic.NewClient(options...)
 	return &Client{
-		client: &client,
+		client:       &client,
+		defaultModel: config.DefaultModel,
 	}
 }
 
+// DefaultModel returns the model used by the queries without model
+func (c *Client) DefaultModel() string {
+	return c.defaultModel
+}
+
+func (c *Client) model(model string) (string, error) {
+	if model == "" {
+		model = c.defaultModel
+	}
+	if model == "" {
+		return "", errors.New("A model name is mandatory")
+	}
+	return model, nil
+}
+
+// estimator approximates the Anthropic tokenizer. Images are resized to about 1600 tokens
+// and PDF pages are sent both as text and as an image.
+var estimator = tokens.Estimator{
+	CharsPerToken:   3.5,
+	ImageTokens:     1600,
+	PageTokens:      2000,
+	MessageOverhead: 4,
+}
+
+// EstimateTokens returns the estimated number of input tokens of a message
+func (c *Client) EstimateTokens(message shared.Message) uint64 {
+	return estimator.Message(message)
+}
+
+func buildContentBlock(part shared.ContentPart) (anthropic.ContentBlockParamUnion, error) {
+	switch part.Type {
+	case shared.TextPart:
+		return anthropic.NewTextBlock(part.Text), nil
+	case shared.ImagePart:
+		if part.URL != "" {
+			return anthropic.ContentBlockParamOfRequestImageBlock(anthropic.URLImageSourceParam{URL: part.URL}), nil
+		}
+		return anthropic.NewImageBlockBase64(part.MediaType, part.Data), nil
+	case shared.DocumentPart:
+		if part.URL != "" {
+			return anthropic.ContentBlockParamOfRequestDocumentBlock(anthropic.URLPDFSourceParam{URL: part.URL}), nil
+		}
+		return anthropic.ContentBlockParamOfRequestDocumentBlock(anthropic.Base64PDFSourceParam{Data: part.Data}), nil
+	}
+	return anthropic.ContentBlockParamUnion{}, fmt.Errorf("unknown content part type %s", part.Type)
+}
+
+func buildMessages(messages []shared.Message) ([]anthropic.MessageParam, error) {
+	result := []anthropic.MessageParam{}
+	for _, message := range messages {
+		switch message.Role {
+		case shared.UserRole:
+			blocks := []anthropic.ContentBlockParamUnion{}
+			for _, part := range message.AllParts() {
+				block, err := buildContentBlock(part)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, block)
+			}
+			result = append(result, anthropic.NewUserMessage(blocks...))
+		case shared.AssistantRole:
+			blocks := []anthropic.ContentBlockParamUnion{}
+			if message.Content != "" {
+				blocks = append(blocks, anthropic.NewTextBlock(message.Content))
+			}
+			for _, toolCall := range message.ToolCalls {
+				var input any = map[string]any{}
+				if len(toolCall.Arguments) != 0 {
+					input = toolCall.Arguments
+				}
+				blocks = append(blocks, anthropic.ContentBlockParamUnion{
+					OfRequestToolUseBlock: &anthropic.ToolUseBlockParam{
+						ID:    toolCall.ID,
+						Name:  toolCall.Name,
+						Input: input,
+					},
+				})
+			}
+			result = append(result, anthropic.NewAssistantMessage(blocks...))
+		case shared.ToolRole:
+			block := anthropic.NewToolResultBlock(message.ToolCallID, message.Content, false)
+			// results for parallel tool calls must be sent in the same user message
+			last := len(result) - 1
+			if last >= 0 && result[last].Role == anthropic.MessageParamRoleUser && result[last].Content[0].OfRequestToolResultBlock != nil {
+				result[last].Content = append(result[last].Content, block)
+			} else {
+				result = append(result, anthropic.NewUserMessage(block))
+			}
+		default:
+			return nil, fmt.Errorf("unknown role %s", message.Role)
+		}
+	}
+	return result, nil
+}
+
+func buildTools(tools []aggregates.Tool) ([]anthropic.ToolUnionParam, error) {
+	result := []anthropic.ToolUnionParam{}
+	for _, tool := range tools {
+		schema := map[string]any{}
+		err := json.Unmarshal(tool.InputSchema, &schema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input schema for tool %s: %w", tool.Name, err)
+		}
+		inputSchema := anthropic.ToolInputSchemaParam{
+			Properties:  schema["properties"],
+			ExtraFields: map[string]any{},
+		}
+		for k, v := range schema {
+			if k != "properties" && k != "type" {
+				inputSchema.ExtraFields[k] = v
+			}
+		}
+		param := anthropic.ToolUnionParamOfTool(inputSchema, tool.Name)
+		if tool.Description != "" {
+			param.OfTool.Description = anthropic.String(tool.Description)
+		}
+		result = append(result, param)
+	}
+	return result, nil
+}
+
+func buildResults(content []anthropic.ContentBlockUnion) []aggregates.Result {
+	result := []aggregates.Result{}
+	for _, block := range content {
+		switch block.Type {
+		case "tool_use":
+			result = append(result, aggregates.Result{
+				ToolCall: &shared.ToolCall{
+					ID:        block.ID,
+					Name:      block.Name,
+					Arguments: block.Input,
+				},
+			})
+		default:
+			result = append(result, aggregates.Result{
+				Text: block.Text,
+			})
+		}
+	}
+	return result
+}
+
 func (c *Client) Query(ctx context.Context, messages []shared.Message, options aggregates.QueryOptions) (*aggregates.Answer, error) {
+	model, err := c.model(options.Model)
+	if err != nil {
+		return nil, err
+	}
+	options.Model = model
 	tracer := otel.Tracer("ai")
 	ctx, span := tracer.Start(ctx, "Provider message")
 	defer span.End()
@@ -52,23 +217,21 @@ func (c *Client) Query(ctx context.Context, messages []shared.Message, options a
 	span.SetAttributes(semconv.GenAIRequestModel(options.Model))
 	span.SetAttributes(semconv.GenAIRequestMaxTokens(int(options.MaxTokens)))
 	span.SetAttributes(semconv.GenAISystemAnthropic)
-	messagesParam := []anthropic.MessageParam{}
-	for _, message := range messages {
-		switch message.Role {
-		case "user":
-			messagesParam = append(messagesParam, anthropic.NewUserMessage(anthropic.NewTextBlock(message.Content)))
-		case "assistant":
-			messagesParam = append(messagesParam, anthropic.NewAssistantMessage(anthropic.NewTextBlock(message.Content)))
-		default:
-			err := fmt.Errorf("unknown role %s", message.Role)
-			otelspan.Error(span, err, "unknown role")
-			return nil, err
-		}
+	messagesParam, err := buildMessages(messages)
+	if err != nil {
+		otelspan.Error(span, err, "invalid messages")
+		return nil, err
+	}
+	tools, err := buildTools(options.Tools)
+	if err != nil {
+		otelspan.Error(span, err, "invalid tools")
+		return nil, err
 	}
 	messageParam := anthropic.MessageNewParams{
 		Model:     options.Model,
 		MaxTokens: int64(options.MaxTokens),
 		Messages:  messagesParam,
+		Tools:     tools,
 	}
 	if options.System != "" {
 		messageParam.System = []anthropic.TextBlockParam{
@@ -81,15 +244,9 @@ func (c *Client) Query(ctx context.Context, messages []shared.Message, options a
 		return nil, err
 	}
 
-	result := []aggregates.Result{}
-
-	for _, m := range message.Content {
-		result = append(result, aggregates.Result{
-			Text: m.Text,
-		})
-	}
 	answer := aggregates.Answer{
-		Results:      result,
+		Results:      buildResults(message.Content),
+		Model:        options.Model,
 		InputTokens:  uint64(message.Usage.InputTokens),
 		OutputTokens: uint64(message.Usage.OutputTokens),
 	}
@@ -100,6 +257,11 @@ func (c *Client) Query(ctx context.Context, messages []shared.Message, options a
 }
 
 func (c *Client) Stream(ctx context.Context, messages []shared.Message, options aggregates.QueryOptions) (<-chan aggregates.Event, error) {
+	model, err := c.model(options.Model)
+	if err != nil {
+		return nil, err
+	}
+	options.Model = model
 	tracer := otel.Tracer("ai")
 	ctx, span := tracer.Start(ctx, "Stream message")
 	defer span.End()
@@ -107,23 +269,21 @@ func (c *Client) Stream(ctx context.Context, messages []shared.Message, options
 	span.SetAttributes(semconv.GenAIRequestModel(options.Model))
 	span.SetAttributes(semconv.GenAIRequestMaxTokens(int(options.MaxTokens)))
 	span.SetAttributes(semconv.GenAISystemAnthropic)
-	messagesParam := []anthropic.MessageParam{}
-	for _, message := range messages {
-		switch message.Role {
-		case "user":
-			messagesParam = append(messagesParam, anthropic.NewUserMessage(anthropic.NewTextBlock(message.Content)))
-		case "assistant":
-			messagesParam = append(messagesParam, anthropic.NewAssistantMessage(anthropic.NewTextBlock(message.Content)))
-		default:
-			err := fmt.Errorf("unknown role %s", message.Role)
-			otelspan.Error(span, err, "unknown role")
-			return nil, err
-		}
+	messagesParam, err := buildMessages(messages)
+	if err != nil {
+		otelspan.Error(span, err, "invalid messages")
+		return nil, err
+	}
+	tools, err := buildTools(options.Tools)
+	if err != nil {
+		otelspan.Error(span, err, "invalid tools")
+		return nil, err
 	}
 	messageParam := anthropic.MessageNewParams{
 		Model:     options.Model,
 		MaxTokens: int64(options.MaxTokens),
 		Messages:  messagesParam,
+		Tools:     tools,
 	}
 	if options.System != "" {
 		messageParam.System = []anthropic.TextBlockParam{
@@ -133,6 +293,8 @@ func (c *Client) Stream(ctx context.Context, messages []shared.Message, options
 	stream := c.client.Messages.NewStreaming(ctx, messageParam)
 	eventChan := make(chan aggregates.Event)
 	go func() {
+		defer close(eventChan)
+		defer stream.Close()
 		ctx, bspan := tracer.Start(ctx, "Streaming background")
 		defer bspan.End()
 		message := anthropic.Message{}
@@ -142,12 +304,12 @@ func (c *Client) Stream(ctx context.Context, messages []shared.Message, options
 			err := message.Accumulate(event)
 			if err != nil {
 				otelspan.Error(espan, err, "failed to accumulate message")
+				espan.End()
+				// the answer is incomplete: only the error is sent
 				eventChan <- aggregates.Event{
 					Error: err,
 				}
-				espan.End()
-				// TODO should completely exit in case of error?
-				break
+				return
 			}
 			switch eventVariant := event.AsAny().(type) {
 			case anthropic.ContentBlockDeltaEvent:
@@ -163,15 +325,18 @@ func (c *Client) Stream(ctx context.Context, messages []shared.Message, options
 			espan.SetStatus(codes.Ok, "success")
 			espan.End()
 		}
-		result := []aggregates.Result{}
-		for _, m := range message.Content {
-			result = append(result, aggregates.Result{
-				Text: m.Text,
-			})
+		// API errors, rate limits and timeouts stop the stream
+		if err := stream.Err(); err != nil {
+			otelspan.Error(bspan, err, "anthropic error")
+			eventChan <- aggregates.Event{
+				Error: err,
+			}
+			return
 		}
 		eventChan <- aggregates.Event{
 			Answer: &aggregates.Answer{
-				Results:      result,
+				Results:      buildResults(message.Content),
+				Model:        options.Model,
 				OutputTokens: uint64(message.Usage.OutputTokens),
 				InputTokens:  uint64(message.Usage.InputTokens),
 			},
@@ -179,8 +344,6 @@ func (c *Client) Stream(ctx context.Context, messages []shared.Message, options
 		bspan.SetAttributes(semconv.GenAIUsageInputTokens(int(message.Usage.InputTokens)))
 		bspan.SetAttributes(semconv.GenAIUsageOutputTokens(int(message.Usage.OutputTokens)))
 		bspan.SetStatus(codes.Ok, "success")
-
-		close(eventChan)
 	}()
 	span.SetStatus(codes.Ok, "success")
 	return eventChan, nil
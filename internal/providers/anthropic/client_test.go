@@ -0,0 +1,39 @@
+package anthropic_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/providers/anthropic"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"invalid_request_error","message":"invalid model"}}`)
+	}))
+	defer server.Close()
+	client := anthropic.New(anthropic.Config{APIKey: "secret", BaseURL: server.URL})
+	messages, err := shared.NewUserMessages("hello")
+	assert.NoError(t, err)
+	eventChan, err := client.Stream(context.Background(), messages, aggregates.QueryOptions{
+		Model:     "test-model",
+		MaxTokens: 100,
+	})
+	assert.NoError(t, err)
+	// the channel is closed after the error, and no answer is sent
+	received := []aggregates.Event{}
+	for event := range eventChan {
+		received = append(received, event)
+	}
+	assert.Len(t, received, 1)
+	assert.ErrorContains(t, received[0].Error, "invalid model")
+	assert.Nil(t, received[0].Answer)
+}
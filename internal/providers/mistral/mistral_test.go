@@ -0,0 +1,97 @@
+package mistral_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/providers/mistral"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentParts(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var payload map[string]any
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+		messages := payload["messages"].([]any)
+		parts := messages[0].(map[string]any)["content"].([]any)
+		assert.Len(t, parts, 3)
+		assert.Equal(t, map[string]any{"type": "text", "text": "describe these files"}, parts[0])
+		assert.Equal(t, map[string]any{"type": "image_url", "image_url": "data:image/png;base64,aGVsbG8="}, parts[1])
+		assert.Equal(t, map[string]any{"type": "document_url", "document_url": "https://example.com/doc.pdf"}, parts[2])
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"a cat and a PDF"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := mistral.New(mistral.Config{APIKey: "secret", BaseURL: server.URL})
+	message, err := shared.NewMessage(shared.UserRole, "describe these files")
+	assert.NoError(t, err)
+	message.Parts = []shared.ContentPart{
+		{Type: shared.ImagePart, MediaType: "image/png", Data: "aGVsbG8="},
+		{Type: shared.DocumentPart, URL: "https://example.com/doc.pdf"},
+	}
+	answer, err := client.Query(context.Background(), []shared.Message{*message}, aggregates.QueryOptions{Model: "mistral-small"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a cat and a PDF", answer.Results[0].Text)
+	assert.Equal(t, uint64(12), answer.InputTokens)
+}
+
+func TestStream(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+		// Mistral sends the usage without stream options
+		assert.NotContains(t, payload, "stream_options")
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\n")
+		if payload["model"] == "truncated" {
+			return
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\" world\"}}],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":3}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := mistral.New(mistral.Config{BaseURL: server.URL, DefaultModel: "mistral-small"})
+	messages, err := shared.NewUserMessages("hello")
+	assert.NoError(t, err)
+
+	events, err := client.Stream(context.Background(), messages, aggregates.QueryOptions{})
+	assert.NoError(t, err)
+	deltas := ""
+	var answer *aggregates.Answer
+	for event := range events {
+		assert.NoError(t, event.Error)
+		deltas += event.Delta
+		if event.Answer != nil {
+			answer = event.Answer
+		}
+	}
+	assert.Equal(t, "Hello world", deltas)
+	assert.Equal(t, "Hello world", answer.Results[0].Text)
+	assert.Equal(t, "mistral-small", answer.Model)
+	assert.Equal(t, uint64(12), answer.InputTokens)
+	assert.Equal(t, uint64(3), answer.OutputTokens)
+
+	// a stream stopped before its end only returns an error
+	events, err = client.Stream(context.Background(), messages, aggregates.QueryOptions{Model: "truncated"})
+	assert.NoError(t, err)
+	errors := 0
+	for event := range events {
+		assert.Nil(t, event.Answer)
+		if event.Error != nil {
+			errors++
+		}
+	}
+	assert.Equal(t, 1, errors)
+}
@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"errors"
+
+	"github.com/appclacks/maizai/internal/providers/openaicompat"
+	"github.com/appclacks/maizai/internal/tokens"
+	"github.com/appclacks/maizai/pkg/shared"
+	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
+)
+
+const DefaultBaseURL = "https://api.openai.com"
+
+type Config = openaicompat.Config
+
+// Client uses the OpenAI API or any OpenAI compatible server
+type Client struct {
+	*openaicompat.Client
+}
+
+func New(config Config) *Client {
+	return &Client{
+		Client: openaicompat.New(config, openaicompat.Dialect{
+			Name:           "OpenAI",
+			DefaultBaseURL: DefaultBaseURL,
+			System:         semconv.GenAISystemOpenai,
+			ContentPart:    contentPart,
+			// usage is only sent on the last chunk when explicitly requested
+			StreamUsage: true,
+		}),
+	}
+}
+
+// estimator approximates the OpenAI tokenizers. Images are counted as high detail 512px tiles.
+var estimator = tokens.Estimator{
+	CharsPerToken:   4,
+	ImageTokens:     765,
+	PageTokens:      1500,
+	MessageOverhead: 4,
+}
+
+// EstimateTokens returns the estimated number of input tokens of a message
+func (c *Client) EstimateTokens(message shared.Message) uint64 {
+	return estimator.Message(message)
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+type file struct {
+	Filename string `json:"filename"`
+	// FileData is a base64 data URL
+	FileData string `json:"file_data"`
+}
+
+type part struct {
+	Type     string    `json:"type"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+	File     *file     `json:"file,omitempty"`
+}
+
+func contentPart(p shared.ContentPart) (any, error) {
+	if p.Type == shared.ImagePart {
+		return part{Type: "image_url", ImageURL: &imageURL{URL: p.DataURL()}}, nil
+	}
+	if p.URL != "" {
+		return nil, errors.New("documents URLs are not supported by the OpenAI provider, documents should be base64 encoded")
+	}
+	return part{Type: "file", File: &file{Filename: "document.pdf", FileData: p.DataURL()}}, nil
+}
@@ -0,0 +1,285 @@
+package openai_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/providers/openai"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	rag "github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var payload map[string]any
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+		assert.Equal(t, "test-model", payload["model"])
+		messages := payload["messages"].([]any)
+		assert.Len(t, messages, 2)
+		assert.Equal(t, "system", messages[0].(map[string]any)["role"])
+		assert.Equal(t, "you are a bot", messages[0].(map[string]any)["content"])
+		assert.Equal(t, "hello", messages[1].(map[string]any)["content"])
+		if payload["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hello\"}}]}\n\n")
+			fmt.Fprint(w, ": keepalive\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\" world\"}}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":3}}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hello world"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`)
+	})
+	mux.HandleFunc("/v1/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+		assert.Equal(t, "embed-model", payload["model"])
+		assert.Equal(t, []any{"some text"}, payload["input"])
+		fmt.Fprint(w, `{"object":"list","data":[{"index":0,"embedding":[0.1,0.2,0.3]}],"usage":{"prompt_tokens":2,"total_tokens":2}}`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestQuery(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+	client := openai.New(openai.Config{APIKey: "secret", BaseURL: server.URL + "/"})
+	messages, err := shared.NewUserMessages("hello")
+	assert.NoError(t, err)
+	answer, err := client.Query(context.Background(), messages, aggregates.QueryOptions{
+		Model:  "test-model",
+		System: "you are a bot",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, answer.Results, 1)
+	assert.Equal(t, "Hello world", answer.Results[0].Text)
+	assert.Equal(t, uint64(12), answer.InputTokens)
+	assert.Equal(t, uint64(3), answer.OutputTokens)
+	assert.Equal(t, "test-model", answer.Model)
+
+	// the answer contains the default model when no model is requested
+	client = openai.New(openai.Config{APIKey: "secret", BaseURL: server.URL + "/", DefaultModel: "test-model"})
+	answer, err = client.Query(context.Background(), messages, aggregates.QueryOptions{System: "you are a bot"})
+	assert.NoError(t, err)
+	assert.Equal(t, "test-model", answer.Model)
+}
+
+func TestStream(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+	client := openai.New(openai.Config{APIKey: "secret", BaseURL: server.URL})
+	messages, err := shared.NewUserMessages("hello")
+	assert.NoError(t, err)
+	eventChan, err := client.Stream(context.Background(), messages, aggregates.QueryOptions{
+		Model:  "test-model",
+		System: "you are a bot",
+	})
+	assert.NoError(t, err)
+	deltas := ""
+	var answer *aggregates.Answer
+	for event := range eventChan {
+		assert.NoError(t, event.Error)
+		deltas += event.Delta
+		if event.Answer != nil {
+			answer = event.Answer
+		}
+	}
+	assert.Equal(t, "Hello world", deltas)
+	assert.NotNil(t, answer)
+	assert.Equal(t, "Hello world", answer.Results[0].Text)
+	assert.Equal(t, uint64(12), answer.InputTokens)
+	assert.Equal(t, uint64(3), answer.OutputTokens)
+	assert.Equal(t, "test-model", answer.Model)
+}
+
+func TestEmbedding(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+	client := openai.New(openai.Config{APIKey: "secret", BaseURL: server.URL})
+	answer, err := client.Embedding(context.Background(), rag.EmbeddingQuery{
+		Input: "some text",
+		Model: "embed-model",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, answer.Data, 1)
+	assert.Equal(t, []float32{0.1, 0.2, 0.3}, answer.Data[0].Embedding)
+	assert.Equal(t, uint64(2), answer.InputTokens)
+}
+
+func TestEmbeddingDefaultModel(t *testing.T) {
+	server := testServer(t)
+	defer server.Close()
+	client := openai.New(openai.Config{APIKey: "secret", BaseURL: server.URL, DefaultModel: "test-model", DefaultEmbeddingModel: "embed-model"})
+	answer, err := client.Embedding(context.Background(), rag.EmbeddingQuery{
+		Input: "some text",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "embed-model", answer.Model)
+	assert.Len(t, answer.Data, 1)
+
+	// the chat default model is never used for embeddings
+	client = openai.New(openai.Config{APIKey: "secret", BaseURL: server.URL, DefaultModel: "test-model"})
+	_, err = client.Embedding(context.Background(), rag.EmbeddingQuery{
+		Input: "some text",
+	})
+	assert.ErrorContains(t, err, "An embedding model name is mandatory")
+}
+
+func TestBatchEmbedding(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+		inputs := payload["input"].([]any)
+		assert.Equal(t, []any{"first", "second"}, inputs[:2])
+		// the embeddings are not returned in the order of the inputs
+		fmt.Fprint(w, `{"object":"list","data":[{"index":1,"embedding":[0.2]},{"index":0,"embedding":[0.1]}],"usage":{"prompt_tokens":4,"total_tokens":4}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := openai.New(openai.Config{APIKey: "secret", BaseURL: server.URL})
+	answer, err := client.BatchEmbedding(context.Background(), rag.BatchEmbeddingQuery{
+		Inputs: []string{"first", "second"},
+		Model:  "embed-model",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, answer.Data, 2)
+	assert.Equal(t, []float32{0.1}, answer.Data[0].Embedding)
+	assert.Equal(t, []float32{0.2}, answer.Data[1].Embedding)
+
+	_, err = client.BatchEmbedding(context.Background(), rag.BatchEmbeddingQuery{
+		Inputs: []string{"first", "second", "third"},
+		Model:  "embed-model",
+	})
+	assert.ErrorContains(t, err, "returned 2 embeddings for 3 inputs")
+}
+
+func TestToolCalls(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+		tools := payload["tools"].([]any)
+		assert.Len(t, tools, 1)
+		function := tools[0].(map[string]any)["function"].(map[string]any)
+		assert.Equal(t, "weather", function["name"])
+		assert.Equal(t, "object", function["parameters"].(map[string]any)["type"])
+		messages := payload["messages"].([]any)
+		assert.Len(t, messages, 3)
+		toolCalls := messages[1].(map[string]any)["tool_calls"].([]any)
+		assert.Equal(t, "call_0", toolCalls[0].(map[string]any)["id"])
+		assert.Equal(t, `{"city":"Lyon"}`, toolCalls[0].(map[string]any)["function"].(map[string]any)["arguments"])
+		assert.Equal(t, "tool", messages[2].(map[string]any)["role"])
+		assert.Equal(t, "call_0", messages[2].(map[string]any)["tool_call_id"])
+		if payload["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"type\":\"function\",\"function\":{\"name\":\"weather\",\"arguments\":\"\"}}]}}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"{\\\"city\\\":\"}}]}}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"\\\"Paris\\\"}\"}}]}}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":3}}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := openai.New(openai.Config{BaseURL: server.URL})
+	messages, err := shared.NewUserMessages("what's the weather in Lyon?")
+	assert.NoError(t, err)
+	assistantMessage, err := shared.NewMessage(shared.AssistantRole, "")
+	assert.NoError(t, err)
+	assistantMessage.ToolCalls = []shared.ToolCall{
+		{ID: "call_0", Name: "weather", Arguments: json.RawMessage(`{"city":"Lyon"}`)},
+	}
+	toolMessage, err := shared.NewToolMessage("call_0", "rainy")
+	assert.NoError(t, err)
+	messages = append(messages, *assistantMessage, *toolMessage)
+	options := aggregates.QueryOptions{
+		Model: "test-model",
+		Tools: []aggregates.Tool{
+			{
+				Name:        "weather",
+				InputSchema: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+			},
+		},
+	}
+	answer, err := client.Query(context.Background(), messages, options)
+	assert.NoError(t, err)
+	assert.Len(t, answer.Results, 1)
+	assert.Equal(t, "call_1", answer.Results[0].ToolCall.ID)
+	assert.Equal(t, "weather", answer.Results[0].ToolCall.Name)
+	assert.JSONEq(t, `{"city":"Paris"}`, string(answer.Results[0].ToolCall.Arguments))
+
+	eventChan, err := client.Stream(context.Background(), messages, options)
+	assert.NoError(t, err)
+	var streamAnswer *aggregates.Answer
+	for event := range eventChan {
+		assert.NoError(t, event.Error)
+		if event.Answer != nil {
+			streamAnswer = event.Answer
+		}
+	}
+	assert.NotNil(t, streamAnswer)
+	assert.Len(t, streamAnswer.Results, 1)
+	assert.Equal(t, "call_1", streamAnswer.Results[0].ToolCall.ID)
+	assert.JSONEq(t, `{"city":"Paris"}`, string(streamAnswer.Results[0].ToolCall.Arguments))
+}
+
+func TestContentParts(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+		messages := payload["messages"].([]any)
+		assert.Len(t, messages, 2)
+		parts := messages[0].(map[string]any)["content"].([]any)
+		assert.Len(t, parts, 3)
+		assert.Equal(t, map[string]any{"type": "text", "text": "describe these files"}, parts[0])
+		assert.Equal(t, map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,aGVsbG8="}}, parts[1])
+		assert.Equal(t, "file", parts[2].(map[string]any)["type"])
+		assert.Equal(t, "data:application/pdf;base64,aGVsbG8=", parts[2].(map[string]any)["file"].(map[string]any)["file_data"])
+		// text only messages are still sent as strings
+		assert.Equal(t, "thanks", messages[1].(map[string]any)["content"])
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"a cat and a PDF"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := openai.New(openai.Config{BaseURL: server.URL})
+	message, err := shared.NewMessage(shared.UserRole, "describe these files")
+	assert.NoError(t, err)
+	message.Parts = []shared.ContentPart{
+		{Type: shared.ImagePart, MediaType: "image/png", Data: "aGVsbG8="},
+		{Type: shared.DocumentPart, MediaType: shared.PDFMediaType, Data: "aGVsbG8="},
+	}
+	assert.NoError(t, message.Validate())
+	thanks, err := shared.NewMessage(shared.UserRole, "thanks")
+	assert.NoError(t, err)
+	answer, err := client.Query(context.Background(), []shared.Message{*message, *thanks}, aggregates.QueryOptions{Model: "test-model"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a cat and a PDF", answer.Results[0].Text)
+
+	message.Parts = []shared.ContentPart{{Type: shared.DocumentPart, URL: "https://example.com/doc.pdf"}}
+	_, err = client.Query(context.Background(), []shared.Message{*message}, aggregates.QueryOptions{Model: "test-model"})
+	assert.ErrorContains(t, err, "documents URLs are not supported")
+
+	message.Parts = []shared.ContentPart{{Type: shared.ImagePart, MediaType: "image/bmp", Data: "aGVsbG8="}}
+	assert.ErrorContains(t, message.Validate(), "Invalid media type image/bmp")
+	message.Role = shared.AssistantRole
+	message.Parts = []shared.ContentPart{{Type: shared.ImagePart, URL: "https://example.com/cat.png"}}
+	assert.ErrorContains(t, message.Validate(), "Only user messages can contain images or documents")
+}
@@ -0,0 +1,648 @@
+// Package openaicompat implements the chat completions and embeddings APIs
+// shared by OpenAI and the providers exposing an OpenAI compatible API.
+package openaicompat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appclacks/maizai/internal/otelspan"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	rag "github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
+)
+
+type Config struct {
+	APIKey string
+	// BaseURL is the URL of the API, without the /v1 suffix
+	BaseURL string
+	// Timeout applies to the whole request, including the streaming of the answer
+	Timeout time.Duration
+	// DefaultModel is used when no model is provided in a query
+	DefaultModel string
+	// DefaultEmbeddingModel is used when no model is provided in an embedding query
+	DefaultEmbeddingModel string
+}
+
+// Dialect contains what differs between the OpenAI compatible APIs
+type Dialect struct {
+	// Name is the provider name used in the errors
+	Name string
+	// DefaultBaseURL is used when the configuration has no base URL
+	DefaultBaseURL string
+	// System is the gen_ai.system attribute of the spans
+	System attribute.KeyValue
+	// ContentPart builds the image and document parts of a message
+	ContentPart func(part shared.ContentPart) (any, error)
+	// StreamUsage asks for the token usage in the last chunk of a stream,
+	// for the APIs which only send it on demand
+	StreamUsage bool
+}
+
+type Client struct {
+	config  Config
+	dialect Dialect
+	client  *http.Client
+}
+
+func New(config Config, dialect Dialect) *Client {
+	httpClient := &http.Client{
+		Transport: otelhttp.NewTransport(
+			http.DefaultTransport,
+			otelhttp.WithClientTrace(func(ctx context.Context) *httptrace.ClientTrace {
+				return otelhttptrace.NewClientTrace(ctx)
+			}),
+		),
+		Timeout: config.Timeout,
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = dialect.DefaultBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	return &Client{
+		config:  config,
+		dialect: dialect,
+		client:  httpClient,
+	}
+}
+
+type functionCall struct {
+	Name string `json:"name,omitempty"`
+	// Arguments are JSON encoded as a string
+	Arguments string `json:"arguments"`
+}
+
+type toolCall struct {
+	// Index is only set when streaming, to reassemble tool calls split in several chunks
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function functionCall `json:"function"`
+}
+
+type textPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type message struct {
+	Role string `json:"role"`
+	// Content is either a string or a list of content parts
+	Content    any        `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type function struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type tool struct {
+	Type     string   `json:"type"`
+	Function function `json:"function"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type queryPayload struct {
+	Model         string         `json:"model"`
+	Temperature   float64        `json:"temperature,omitempty"`
+	Messages      []message      `json:"messages"`
+	MaxTokens     uint64         `json:"max_tokens,omitempty"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	Tools         []tool         `json:"tools,omitempty"`
+}
+
+type usage struct {
+	PromptTokens     uint64 `json:"prompt_tokens"`
+	CompletionTokens uint64 `json:"completion_tokens"`
+	TotalTokens      uint64 `json:"total_tokens"`
+}
+
+type answerMessage struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls"`
+}
+
+type delta struct {
+	Content   string     `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls"`
+}
+
+type choice struct {
+	Index        uint64        `json:"index"`
+	Message      answerMessage `json:"message"`
+	FinishReason string        `json:"finish_reason"`
+	Delta        delta         `json:"delta"`
+}
+
+type queryResponse struct {
+	ID      string   `json:"id"`
+	Usage   usage    `json:"usage"`
+	Object  string   `json:"object"`
+	Choices []choice `json:"choices"`
+}
+
+type embeddingData struct {
+	Index     uint64    `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+type embeddingResponse struct {
+	Usage usage           `json:"usage"`
+	Data  []embeddingData `json:"data"`
+}
+
+type embeddingQuery struct {
+	Input []string `json:"input"`
+	Model string   `json:"model"`
+}
+
+func eventData(body string) (*queryResponse, error) {
+	after, found := strings.CutPrefix(body, "data: ")
+	if !found {
+		return nil, fmt.Errorf("invalid event data %s", body)
+	}
+	var result queryResponse
+	err := json.Unmarshal([]byte(after), &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+
+}
+
+func (c *Client) buildPayload(messages []shared.Message, options aggregates.QueryOptions) (queryPayload, error) {
+	payload := queryPayload{
+		Model:       options.Model,
+		Temperature: options.Temperature,
+		MaxTokens:   options.MaxTokens,
+		Messages:    []message{},
+	}
+	if options.System != "" {
+		payload.Messages = append(payload.Messages, message{
+			Role:    "system",
+			Content: options.System,
+		})
+	}
+	for _, msg := range messages {
+		m, err := c.buildMessage(msg)
+		if err != nil {
+			return payload, err
+		}
+		payload.Messages = append(payload.Messages, m)
+	}
+	if len(options.Tools) != 0 {
+		payload.Tools = buildTools(options.Tools)
+	}
+	return payload, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, path string, body any) (*http.Request, error) {
+	jsonBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("%s%s", c.config.BaseURL, path),
+		bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		return nil, err
+	}
+	// self-hosted gateways are often deployed without authentication
+	if c.config.APIKey != "" {
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.config.APIKey))
+	}
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Accept", "application/json")
+	return request, nil
+}
+
+func (c *Client) buildContent(msg shared.Message) (any, error) {
+	// plain strings are kept for text messages, some OpenAI compatible
+	// servers don't support content parts
+	if len(msg.Parts) == 0 {
+		return msg.Content, nil
+	}
+	result := []any{}
+	for _, part := range msg.AllParts() {
+		switch part.Type {
+		case shared.TextPart:
+			result = append(result, textPart{Type: "text", Text: part.Text})
+		case shared.ImagePart, shared.DocumentPart:
+			p, err := c.dialect.ContentPart(part)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, p)
+		default:
+			return nil, fmt.Errorf("unknown content part type %s", part.Type)
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) buildMessage(msg shared.Message) (message, error) {
+	content, err := c.buildContent(msg)
+	if err != nil {
+		return message{}, err
+	}
+	result := message{
+		Role:       msg.Role,
+		Content:    content,
+		ToolCallID: msg.ToolCallID,
+	}
+	for _, call := range msg.ToolCalls {
+		arguments := "{}"
+		if len(call.Arguments) != 0 {
+			arguments = string(call.Arguments)
+		}
+		result.ToolCalls = append(result.ToolCalls, toolCall{
+			ID:   call.ID,
+			Type: "function",
+			Function: functionCall{
+				Name:      call.Name,
+				Arguments: arguments,
+			},
+		})
+	}
+	return result, nil
+}
+
+func buildTools(tools []aggregates.Tool) []tool {
+	result := []tool{}
+	for _, t := range tools {
+		result = append(result, tool{
+			Type: "function",
+			Function: function{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.InputSchema,
+			},
+		})
+	}
+	return result
+}
+
+func toSharedToolCall(call toolCall) *shared.ToolCall {
+	result := &shared.ToolCall{
+		ID:   call.ID,
+		Name: call.Function.Name,
+	}
+	if call.Function.Arguments != "" {
+		result.Arguments = json.RawMessage(call.Function.Arguments)
+	}
+	return result
+}
+
+func buildResults(msg answerMessage) []aggregates.Result {
+	result := []aggregates.Result{}
+	if msg.Content != "" || len(msg.ToolCalls) == 0 {
+		result = append(result, aggregates.Result{
+			Text: msg.Content,
+		})
+	}
+	for _, call := range msg.ToolCalls {
+		result = append(result, aggregates.Result{
+			ToolCall: toSharedToolCall(call),
+		})
+	}
+	return result
+}
+
+// accumulateToolCalls merges tool calls received in streaming deltas
+func accumulateToolCalls(calls []toolCall, deltas []toolCall) []toolCall {
+	for i, d := range deltas {
+		index := i
+		if d.Index != nil {
+			index = *d.Index
+		}
+		found := false
+		for j := range calls {
+			if *calls[j].Index == index {
+				if d.ID != "" {
+					calls[j].ID = d.ID
+				}
+				if d.Function.Name != "" {
+					calls[j].Function.Name = d.Function.Name
+				}
+				calls[j].Function.Arguments += d.Function.Arguments
+				found = true
+				break
+			}
+		}
+		if !found {
+			d.Index = &index
+			calls = append(calls, d)
+		}
+	}
+	return calls
+}
+
+// DefaultModel returns the model used by the queries without model
+func (c *Client) DefaultModel() string {
+	return c.config.DefaultModel
+}
+
+func (c *Client) model(model string) (string, error) {
+	if model == "" {
+		model = c.config.DefaultModel
+	}
+	if model == "" {
+		return "", errors.New("A model name is mandatory")
+	}
+	return model, nil
+}
+
+func (c *Client) Query(ctx context.Context, messages []shared.Message, options aggregates.QueryOptions) (*aggregates.Answer, error) {
+	model, err := c.model(options.Model)
+	if err != nil {
+		return nil, err
+	}
+	options.Model = model
+	name := strings.ToLower(c.dialect.Name)
+	tracer := otel.Tracer("ai")
+	ctx, span := tracer.Start(ctx, "Provider message")
+	defer span.End()
+	span.SetAttributes(semconv.GenAIRequestTemperature(options.Temperature))
+	span.SetAttributes(semconv.GenAIRequestModel(options.Model))
+	span.SetAttributes(semconv.GenAIRequestMaxTokens(int(options.MaxTokens)))
+	span.SetAttributes(c.dialect.System)
+	payload, err := c.buildPayload(messages, options)
+	if err != nil {
+		otelspan.Error(span, err, "invalid messages")
+		return nil, err
+	}
+	request, err := c.newRequest(ctx, "/v1/chat/completions", payload)
+	if err != nil {
+		otelspan.Error(span, err, fmt.Sprintf("fail to build %s request", name))
+		return nil, err
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		otelspan.Error(span, err, fmt.Sprintf("%s api error", name))
+		return nil, err
+	}
+	defer response.Body.Close()
+	b, err := io.ReadAll(response.Body)
+	if err != nil {
+		otelspan.Error(span, err, "http body error")
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		err := fmt.Errorf("%s API returned an error: status %d\n%s", c.dialect.Name, response.StatusCode, string(b))
+		otelspan.Error(span, err, fmt.Sprintf("%s http error", name))
+		return nil, err
+	}
+	var result queryResponse
+	err = json.Unmarshal(b, &result)
+	if err != nil {
+		otelspan.Error(span, err, "json error")
+		return nil, err
+	}
+	answer := aggregates.Answer{
+		Model:        options.Model,
+		InputTokens:  result.Usage.PromptTokens,
+		OutputTokens: result.Usage.CompletionTokens,
+		Results:      []aggregates.Result{},
+	}
+	for _, choice := range result.Choices {
+		answer.Results = append(answer.Results, buildResults(choice.Message)...)
+	}
+	span.SetAttributes(semconv.GenAIUsageInputTokens(int(result.Usage.PromptTokens)))
+	span.SetAttributes(semconv.GenAIUsageOutputTokens(int(result.Usage.CompletionTokens)))
+	span.SetStatus(codes.Ok, "success")
+	return &answer, nil
+}
+
+func (c *Client) Embedding(ctx context.Context, query rag.EmbeddingQuery) (*rag.EmbeddingAnswer, error) {
+	return c.embedding(ctx, query.Model, []string{query.Input})
+}
+
+// BatchEmbedding computes the embeddings of all inputs in a single API call.
+// The embeddings are returned in the same order as the inputs.
+func (c *Client) BatchEmbedding(ctx context.Context, query rag.BatchEmbeddingQuery) (*rag.EmbeddingAnswer, error) {
+	if len(query.Inputs) == 0 {
+		return nil, errors.New("No input to embed")
+	}
+	return c.embedding(ctx, query.Model, query.Inputs)
+}
+
+func (c *Client) embedding(ctx context.Context, model string, inputs []string) (*rag.EmbeddingAnswer, error) {
+	// the chat default model can't compute embeddings
+	if model == "" {
+		model = c.config.DefaultEmbeddingModel
+	}
+	if model == "" {
+		return nil, errors.New("An embedding model name is mandatory")
+	}
+	embeddingQuery := embeddingQuery{
+		Model: model,
+		Input: inputs,
+	}
+	name := strings.ToLower(c.dialect.Name)
+	tracer := otel.Tracer("ai")
+	ctx, span := tracer.Start(ctx, "Provider embedding")
+	defer span.End()
+	span.SetAttributes(semconv.GenAIRequestModel(model))
+	span.SetAttributes(c.dialect.System)
+	request, err := c.newRequest(ctx, "/v1/embeddings", embeddingQuery)
+	if err != nil {
+		otelspan.Error(span, err, fmt.Sprintf("fail to build %s request", name))
+		return nil, err
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		otelspan.Error(span, err, fmt.Sprintf("%s api error", name))
+		return nil, err
+	}
+	defer response.Body.Close()
+	b, err := io.ReadAll(response.Body)
+	if err != nil {
+		otelspan.Error(span, err, "http body error")
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		err := fmt.Errorf("%s API returned an error: status %d\n%s", c.dialect.Name, response.StatusCode, string(b))
+		otelspan.Error(span, err, fmt.Sprintf("%s http error", name))
+		return nil, err
+	}
+	var result embeddingResponse
+	err = json.Unmarshal(b, &result)
+	if err != nil {
+		otelspan.Error(span, err, "json error")
+		return nil, err
+	}
+	answer := rag.EmbeddingAnswer{
+		Model:        model,
+		InputTokens:  result.Usage.PromptTokens,
+		OutputTokens: result.Usage.CompletionTokens,
+		Data:         []rag.Embedding{},
+	}
+	if len(result.Data) != len(inputs) {
+		err := fmt.Errorf("%s API returned %d embeddings for %d inputs", c.dialect.Name, len(result.Data), len(inputs))
+		otelspan.Error(span, err, "invalid embeddings count")
+		return nil, err
+	}
+	sort.SliceStable(result.Data, func(i, j int) bool {
+		return result.Data[i].Index < result.Data[j].Index
+	})
+	for _, data := range result.Data {
+		answer.Data = append(answer.Data, rag.Embedding{
+			Embedding: data.Embedding,
+		})
+	}
+	span.SetAttributes(semconv.GenAIUsageInputTokens(int(result.Usage.PromptTokens)))
+	span.SetStatus(codes.Ok, "success")
+	return &answer, nil
+}
+
+func (c *Client) Stream(ctx context.Context, messages []shared.Message, options aggregates.QueryOptions) (<-chan aggregates.Event, error) {
+	model, err := c.model(options.Model)
+	if err != nil {
+		return nil, err
+	}
+	options.Model = model
+	name := strings.ToLower(c.dialect.Name)
+	tracer := otel.Tracer("ai")
+	ctx, span := tracer.Start(ctx, "Stream message")
+	defer span.End()
+	span.SetAttributes(semconv.GenAIRequestTemperature(options.Temperature))
+	span.SetAttributes(semconv.GenAIRequestModel(options.Model))
+	span.SetAttributes(semconv.GenAIRequestMaxTokens(int(options.MaxTokens)))
+	span.SetAttributes(c.dialect.System)
+	payload, err := c.buildPayload(messages, options)
+	if err != nil {
+		otelspan.Error(span, err, "invalid messages")
+		return nil, err
+	}
+	payload.Stream = true
+	if c.dialect.StreamUsage {
+		payload.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+
+	request, err := c.newRequest(ctx, "/v1/chat/completions", payload)
+	if err != nil {
+		otelspan.Error(span, err, fmt.Sprintf("fail to build %s request", name))
+		return nil, err
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		otelspan.Error(span, err, fmt.Sprintf("%s api error", name))
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			otelspan.Error(span, err, "fail to read body")
+			return nil, err
+		}
+		err = fmt.Errorf("%s API returned an error: status %d\n%s", c.dialect.Name, response.StatusCode, string(body))
+		otelspan.Error(span, err, fmt.Sprintf("%s http error", name))
+		return nil, err
+	}
+	reader := bufio.NewReader(response.Body)
+	eventChan := make(chan aggregates.Event)
+
+	go func() {
+		ctx, bspan := tracer.Start(ctx, "Streaming background")
+		defer bspan.End()
+		defer response.Body.Close()
+		defer close(eventChan)
+		finalMessage := ""
+		toolCalls := []toolCall{}
+		var promptTokens, completionTokens uint64
+		for {
+			_, espan := tracer.Start(ctx, "Streaming event")
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				// the answer is incomplete: only the error is sent
+				otelspan.Error(espan, err, "failed to read data")
+				eventChan <- aggregates.Event{
+					Error: err,
+				}
+				espan.End()
+				return
+			}
+			lineStr := strings.TrimSpace(string(line))
+			// skip empty lines and SSE comments used as keepalive by some gateways
+			if lineStr == "" || strings.HasPrefix(lineStr, ":") {
+				espan.End()
+				continue
+			}
+			if strings.HasPrefix(lineStr, "data: [DONE]") {
+				bspan.SetAttributes(semconv.GenAIUsageInputTokens(int(promptTokens)))
+				bspan.SetAttributes(semconv.GenAIUsageOutputTokens(int(completionTokens)))
+				results := buildResults(answerMessage{
+					Content:   finalMessage,
+					ToolCalls: toolCalls,
+				})
+				eventChan <- aggregates.Event{
+					Answer: &aggregates.Answer{
+						Results:      results,
+						Model:        options.Model,
+						OutputTokens: completionTokens,
+						InputTokens:  promptTokens,
+					},
+				}
+				espan.SetStatus(codes.Ok, "success")
+				espan.End()
+				return
+			}
+			result, err := eventData(lineStr)
+			if err != nil {
+				otelspan.Error(espan, err, "failed to parse data")
+				eventChan <- aggregates.Event{
+					Error: err,
+				}
+				espan.End()
+				return
+			}
+			for _, choice := range result.Choices {
+				toolCalls = accumulateToolCalls(toolCalls, choice.Delta.ToolCalls)
+				if choice.Delta.Content == "" {
+					continue
+				}
+				finalMessage = fmt.Sprintf("%s%s", finalMessage, choice.Delta.Content)
+				eventChan <- aggregates.Event{
+					Delta: choice.Delta.Content,
+				}
+			}
+			if result.Usage.PromptTokens != 0 {
+				promptTokens = result.Usage.PromptTokens
+			}
+			if result.Usage.CompletionTokens != 0 {
+				completionTokens = result.Usage.CompletionTokens
+			}
+			bspan.SetStatus(codes.Ok, "success")
+			espan.End()
+		}
+	}()
+	span.SetStatus(codes.Ok, "success")
+	return eventChan, nil
+}
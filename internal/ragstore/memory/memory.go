@@ -0,0 +1,224 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/appclacks/maizai/internal/keywords"
+	"github.com/appclacks/maizai/internal/vector"
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	er "github.com/mcorbin/corbierror"
+)
+
+type MemoryRagStore struct {
+	documents map[string]aggregates.Document
+	// chunks are stored by document ID
+	chunks map[string][]aggregates.DocumentChunk
+	lock   sync.RWMutex
+}
+
+func New() *MemoryRagStore {
+	return &MemoryRagStore{
+		documents: make(map[string]aggregates.Document),
+		chunks:    make(map[string][]aggregates.DocumentChunk),
+	}
+}
+
+func (m *MemoryRagStore) CreateDocument(ctx context.Context, document aggregates.Document) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, doc := range m.documents {
+		if doc.Project == document.Project && doc.Name == document.Name {
+			return fmt.Errorf("A document with name %s already exists", document.Name)
+		}
+	}
+	m.documents[document.ID] = document
+	return nil
+}
+
+func (m *MemoryRagStore) GetDocument(ctx context.Context, project string, id string) (*aggregates.Document, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	document, ok := m.documents[id]
+	if !ok || document.Project != project {
+		return nil, er.Newf("document %s doesn't exist", er.NotFound, true, id)
+	}
+	return &document, nil
+}
+
+func (m *MemoryRagStore) ListDocuments(ctx context.Context, project string) ([]aggregates.Document, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	result := []aggregates.Document{}
+	for _, document := range m.documents {
+		if document.Project != project {
+			continue
+		}
+		result = append(result, document)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (m *MemoryRagStore) DeleteDocument(ctx context.Context, project string, id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if document, ok := m.documents[id]; !ok || document.Project != project {
+		return er.Newf("document %s doesn't exist", er.NotFound, true, id)
+	}
+	delete(m.chunks, id)
+	delete(m.documents, id)
+	return nil
+}
+
+func (m *MemoryRagStore) CreateDocumentChunk(ctx context.Context, documentChunk aggregates.DocumentChunk) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.documents[documentChunk.DocumentID]; !ok {
+		return er.Newf("document %s doesn't exist", er.NotFound, true, documentChunk.DocumentID)
+	}
+	m.chunks[documentChunk.DocumentID] = append(m.chunks[documentChunk.DocumentID], documentChunk)
+	return nil
+}
+
+// CreateDocumentChunks stores all chunks, or none of them if a document doesn't exist
+func (m *MemoryRagStore) CreateDocumentChunks(ctx context.Context, documentChunks []aggregates.DocumentChunk) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, documentChunk := range documentChunks {
+		if _, ok := m.documents[documentChunk.DocumentID]; !ok {
+			return er.Newf("document %s doesn't exist", er.NotFound, true, documentChunk.DocumentID)
+		}
+	}
+	for _, documentChunk := range documentChunks {
+		m.chunks[documentChunk.DocumentID] = append(m.chunks[documentChunk.DocumentID], documentChunk)
+	}
+	return nil
+}
+
+func (m *MemoryRagStore) DeleteDocumentChunk(ctx context.Context, project string, id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for docID, chunks := range m.chunks {
+		if m.documents[docID].Project != project {
+			continue
+		}
+		for i := range chunks {
+			if chunks[i].ID == id {
+				m.chunks[docID] = append(chunks[:i], chunks[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return er.Newf("document chunk %s doesn't exist", er.NotFound, true, id)
+}
+
+// NextChunkOrdinal returns the ordinal of the next chunk added to the document
+func (m *MemoryRagStore) NextChunkOrdinal(ctx context.Context, project string, docID string) (int, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if document, ok := m.documents[docID]; !ok || document.Project != project {
+		return 0, er.Newf("document %s doesn't exist", er.NotFound, true, docID)
+	}
+	ordinal := 0
+	for _, chunk := range m.chunks[docID] {
+		ordinal = max(ordinal, chunk.Ordinal+1)
+	}
+	return ordinal, nil
+}
+
+func (m *MemoryRagStore) ListDocumentChunksForDocument(ctx context.Context, project string, docID string) ([]aggregates.DocumentChunk, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if document, ok := m.documents[docID]; !ok || document.Project != project {
+		return nil, er.Newf("document %s doesn't exist", er.NotFound, true, docID)
+	}
+	result := []aggregates.DocumentChunk{}
+	result = append(result, m.chunks[docID]...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Ordinal < result[j].Ordinal
+	})
+	return result, nil
+}
+
+// UpdateDocumentEmbedding records the embedding settings of a document. It fails if the
+// document already uses different settings.
+func (m *MemoryRagStore) UpdateDocumentEmbedding(ctx context.Context, project string, id string, provider string, model string, dimension int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	document, ok := m.documents[id]
+	if !ok || document.Project != project {
+		return er.Newf("document %s doesn't exist", er.NotFound, true, id)
+	}
+	if !document.AcceptsEmbedding(provider, model, dimension) {
+		return er.Newf("document %s already uses other embedding settings", er.Conflict, true, id)
+	}
+	document.EmbeddingProvider = provider
+	document.EmbeddingModel = model
+	document.Dimension = dimension
+	m.documents[id] = document
+	return nil
+}
+
+// FindClosestChunks does a brute-force nearest neighbour search on the chunks of the
+// documents using the same embedding settings
+func (m *MemoryRagStore) FindClosestChunks(ctx context.Context, search aggregates.ChunkSearch) ([]aggregates.DocumentChunk, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	candidates := []aggregates.DocumentChunk{}
+	for docID, chunks := range m.chunks {
+		document := m.documents[docID]
+		if document.Project != search.Project {
+			continue
+		}
+		if document.EmbeddingProvider != search.Provider || document.EmbeddingModel != search.Model || document.Dimension != len(search.Embedding) {
+			continue
+		}
+		if !document.MatchesFilter(search.Filter) {
+			continue
+		}
+		for _, c := range chunks {
+			if !c.HasMetadata(search.Metadata) {
+				continue
+			}
+			distance, err := vector.Distance(search.Metric, c.Embedding, search.Embedding)
+			if err != nil {
+				return nil, err
+			}
+			c.SetDistance(search.Metric, distance)
+			candidates = append(candidates, c)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return *candidates[i].Distance < *candidates[j].Distance
+	})
+	limit := max(0, int(search.Limit))
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+// FindChunksByKeywords ranks the chunks of the documents matching the filter by the
+// number of occurrences of the query words
+func (m *MemoryRagStore) FindChunksByKeywords(ctx context.Context, search aggregates.KeywordSearch) ([]aggregates.DocumentChunk, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	candidates := []aggregates.DocumentChunk{}
+	for docID, chunks := range m.chunks {
+		document := m.documents[docID]
+		if document.Project != search.Project || !document.MatchesFilter(search.Filter) {
+			continue
+		}
+		for _, c := range chunks {
+			if c.HasMetadata(search.Metadata) {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	return keywords.Match(search.Query, candidates, int(search.Limit)), nil
+}
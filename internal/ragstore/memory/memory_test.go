@@ -0,0 +1,196 @@
+package memory_test
+
+import (
+	"context"
+	"github.com/appclacks/maizai/pkg/shared"
+	"math"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/ragstore/memory"
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	documents, err := store.ListDocuments(ctx, shared.DefaultProject)
+	assert.NoError(t, err)
+	assert.Len(t, documents, 0)
+
+	document, err := aggregates.NewDocument(shared.DefaultProject, "foo", "bar")
+	assert.NoError(t, err)
+
+	_, err = store.GetDocument(ctx, shared.DefaultProject, document.ID)
+	assert.Error(t, err)
+
+	err = store.CreateDocument(ctx, *document)
+	assert.NoError(t, err)
+	err = store.CreateDocument(ctx, *document)
+	assert.ErrorContains(t, err, "already exists")
+
+	result, err := store.GetDocument(ctx, shared.DefaultProject, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, document.Name, result.Name)
+	assert.Equal(t, document.Description, result.Description)
+
+	documents, err = store.ListDocuments(ctx, shared.DefaultProject)
+	assert.NoError(t, err)
+	assert.Len(t, documents, 1)
+
+	err = store.CreateDocumentChunk(ctx, aggregates.DocumentChunk{
+		ID:         uuid.NewString(),
+		DocumentID: uuid.NewString(),
+		Fragment:   "unknown document",
+		Embedding:  []float32{1, 1},
+	})
+	assert.Error(t, err)
+
+	embeddings := [][]float32{{0, 0}, {10, 10}, {1, 1}}
+	for _, embedding := range embeddings {
+		chunk, err := aggregates.NewDocumentChunk(document.ID, "fragment", embedding)
+		assert.NoError(t, err)
+		err = store.CreateDocumentChunk(ctx, *chunk)
+		assert.NoError(t, err)
+	}
+
+	chunks, err := store.ListDocumentChunksForDocument(ctx, shared.DefaultProject, document.ID)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 3)
+
+	err = store.UpdateDocumentEmbedding(ctx, shared.DefaultProject, document.ID, "mistral", "mistral-embed", 2)
+	assert.NoError(t, err)
+	err = store.UpdateDocumentEmbedding(ctx, shared.DefaultProject, document.ID, "openai", "mistral-embed", 2)
+	assert.ErrorContains(t, err, "already uses other embedding settings")
+	err = store.UpdateDocumentEmbedding(ctx, shared.DefaultProject, uuid.NewString(), "mistral", "mistral-embed", 2)
+	assert.ErrorContains(t, err, "doesn't exist")
+
+	closest, err := store.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{9, 9},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, closest, 2)
+	assert.Equal(t, []float32{10, 10}, closest[0].Embedding)
+	assert.InDelta(t, math.Sqrt(2), *closest[0].Distance, 0.0001)
+	assert.InDelta(t, 1/(1+math.Sqrt(2)), *closest[0].Score, 0.0001)
+	assert.Equal(t, []float32{1, 1}, closest[1].Embedding)
+
+	// a negative limit returns no chunk
+	none, err := store.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{9, 9},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     -1,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, none, 0)
+
+	byProduct, err := store.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{9, 9},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     1,
+		Metric:    aggregates.InnerProduct,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, byProduct, 1)
+	assert.Equal(t, []float32{10, 10}, byProduct[0].Embedding)
+	assert.Equal(t, float64(-180), *byProduct[0].Distance)
+	assert.Equal(t, float64(180), *byProduct[0].Score)
+
+	// the cosine distance is not defined for zero vectors
+	byCosine, err := store.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{1, 0},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     3,
+		Metric:    aggregates.Cosine,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, byCosine, 3)
+	assert.InDelta(t, math.Sqrt(2)/2, *byCosine[0].Score, 0.0001)
+	assert.Equal(t, []float32{0, 0}, byCosine[2].Embedding)
+	assert.Equal(t, float64(1), *byCosine[2].Distance)
+	assert.Equal(t, float64(0), *byCosine[2].Score)
+
+	byKeywords, err := store.FindChunksByKeywords(ctx, aggregates.KeywordSearch{
+		Project: shared.DefaultProject,
+		Query:   "Fragment",
+		Limit:   2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, byKeywords, 2)
+	byKeywords, err = store.FindChunksByKeywords(ctx, aggregates.KeywordSearch{
+		Project: shared.DefaultProject,
+		Query:   "fragment",
+		Limit:   2,
+		Filter:  aggregates.DocumentFilter{Names: []string{"other"}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, byKeywords, 0)
+
+	// embeddings from other models are never compared
+	other, err := store.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{9, 9, 9},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, other, 0)
+	other, err = store.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{9, 9},
+		Provider:  "openai",
+		Model:     "text-embedding-3-small",
+		Limit:     2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, other, 0)
+
+	err = store.DeleteDocumentChunk(ctx, shared.DefaultProject, closest[0].ID)
+	assert.NoError(t, err)
+	err = store.DeleteDocumentChunk(ctx, shared.DefaultProject, closest[0].ID)
+	assert.Error(t, err)
+	chunks, err = store.ListDocumentChunksForDocument(ctx, shared.DefaultProject, document.ID)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+
+	positioned := []aggregates.DocumentChunk{}
+	for _, ordinal := range []int{4, 3} {
+		chunk, err := aggregates.NewDocumentChunk(document.ID, "fragment", []float32{2, 2})
+		assert.NoError(t, err)
+		chunk.Ordinal = ordinal
+		positioned = append(positioned, *chunk)
+	}
+	err = store.CreateDocumentChunks(ctx, positioned)
+	assert.NoError(t, err)
+	chunks, err = store.ListDocumentChunksForDocument(ctx, shared.DefaultProject, document.ID)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 4)
+	assert.Equal(t, 3, chunks[2].Ordinal)
+	assert.Equal(t, 4, chunks[3].Ordinal)
+
+	err = store.DeleteDocument(ctx, shared.DefaultProject, document.ID)
+	assert.NoError(t, err)
+	_, err = store.ListDocumentChunksForDocument(ctx, shared.DefaultProject, document.ID)
+	assert.Error(t, err)
+	closest, err = store.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{9, 9},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, closest, 0)
+}
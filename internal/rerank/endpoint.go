@@ -0,0 +1,138 @@
+package rerank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/appclacks/maizai/internal/otelspan"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const defaultTimeout = 30 * time.Second
+
+type EndpointConfig struct {
+	Name string
+	// URL is the full URL of the rerank endpoint
+	URL     string
+	APIKey  string
+	Model   string
+	Timeout time.Duration
+}
+
+// Endpoint calls a rerank endpoint compatible with the Cohere rerank API,
+// which is also implemented by Jina, Voyage or text-embeddings-inference.
+type Endpoint struct {
+	config EndpointConfig
+	client *http.Client
+}
+
+func NewEndpoint(config EndpointConfig) *Endpoint {
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Endpoint{
+		config: config,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(
+				http.DefaultTransport,
+				otelhttp.WithClientTrace(func(ctx context.Context) *httptrace.ClientTrace {
+					return otelhttptrace.NewClientTrace(ctx)
+				}),
+			),
+			Timeout: config.Timeout,
+		},
+	}
+}
+
+type endpointQuery struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+type endpointResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+type endpointResponse struct {
+	Results []endpointResult `json:"results"`
+}
+
+// Rerank returns the relevance scores of the fragments, in the same order as the fragments
+func (e *Endpoint) Rerank(ctx context.Context, project string, query string, fragments []string) ([]float64, error) {
+	tracer := otel.Tracer("rerank")
+	ctx, span := tracer.Start(ctx, "Rerank")
+	defer span.End()
+	span.SetAttributes(attribute.String("reranker.name", e.config.Name))
+	payload, err := json.Marshal(endpointQuery{
+		Model:     e.config.Model,
+		Query:     query,
+		Documents: fragments,
+		TopN:      len(fragments),
+	})
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.URL, bytes.NewBuffer(payload))
+	if err != nil {
+		otelspan.Error(span, err, "fail to build request")
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	if e.config.APIKey != "" {
+		request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", e.config.APIKey))
+	}
+	response, err := e.client.Do(request)
+	if err != nil {
+		otelspan.Error(span, err, "fail to send request")
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		otelspan.Error(span, err, "fail to read response")
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		err := fmt.Errorf("reranker %s returned status %d: %s", e.config.Name, response.StatusCode, string(body))
+		otelspan.Error(span, err, "reranker error")
+		return nil, err
+	}
+	var result endpointResponse
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		otelspan.Error(span, err, "json error")
+		return nil, err
+	}
+	if len(result.Results) != len(fragments) {
+		err := fmt.Errorf("reranker %s returned %d results for %d fragments", e.config.Name, len(result.Results), len(fragments))
+		otelspan.Error(span, err, "invalid results count")
+		return nil, err
+	}
+	scores := make([]float64, len(fragments))
+	seen := make([]bool, len(fragments))
+	for _, r := range result.Results {
+		if r.Index < 0 || r.Index >= len(fragments) || seen[r.Index] {
+			err := fmt.Errorf("reranker %s returned an invalid result index %d", e.config.Name, r.Index)
+			otelspan.Error(span, err, "invalid result index")
+			return nil, err
+		}
+		seen[r.Index] = true
+		scores[r.Index] = r.RelevanceScore
+	}
+	span.SetStatus(codes.Ok, "success")
+	return scores, nil
+}
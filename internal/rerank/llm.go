@@ -0,0 +1,159 @@
+package rerank
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/appclacks/maizai/internal/metrics"
+	"github.com/appclacks/maizai/internal/otelspan"
+	"github.com/appclacks/maizai/pkg/assistant"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
+	usagedata "github.com/appclacks/maizai/pkg/usage/aggregates"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const defaultMaxTokens = 1024
+
+const judgePrompt = "Rate the relevance of each numbered fragment for the query, from 0 (not relevant) to 10 (fully answers the query). Answer with a JSON array containing one number per fragment, in the fragments order, and nothing else."
+
+var scoresRegexp = regexp.MustCompile(`(?s)\[.*\]`)
+
+type LLMConfig struct {
+	Name     string
+	Provider string
+	Model    string
+	// MaxTokens limits the size of the answer of the model
+	MaxTokens uint64
+	// Usage records the usage of the provider calls. Nothing is recorded if nil.
+	Usage assistant.UsageRecorder
+	// Metrics records the provider calls. Nothing is recorded if nil.
+	Metrics *metrics.Metrics
+}
+
+// LLM asks a model to judge the relevance of the fragments. The scores are normalized between 0 and 1.
+type LLM struct {
+	config   LLMConfig
+	provider assistant.Provider
+}
+
+func NewLLM(config LLMConfig, provider assistant.Provider) *LLM {
+	if config.MaxTokens == 0 {
+		config.MaxTokens = defaultMaxTokens
+	}
+	return &LLM{
+		config:   config,
+		provider: provider,
+	}
+}
+
+func judgeMessage(query string, fragments []string) string {
+	var builder strings.Builder
+	builder.WriteString(judgePrompt)
+	builder.WriteString("\n\nQuery: ")
+	builder.WriteString(query)
+	for i, fragment := range fragments {
+		fmt.Fprintf(&builder, "\n\nFragment %d:\n%s", i+1, fragment)
+	}
+	return builder.String()
+}
+
+// parseScores extracts the JSON array of scores from the answer of the model
+func parseScores(text string, count int) ([]float64, error) {
+	array := scoresRegexp.FindString(text)
+	if array == "" {
+		return nil, fmt.Errorf("no scores found in the answer: %s", text)
+	}
+	var scores []float64
+	err := json.Unmarshal([]byte(array), &scores)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scores in the answer: %w", err)
+	}
+	if len(scores) != count {
+		return nil, fmt.Errorf("the answer contains %d scores for %d fragments", len(scores), count)
+	}
+	for i := range scores {
+		scores[i] = min(max(scores[i], 0), 10) / 10
+	}
+	return scores, nil
+}
+
+// model returns the model used by the provider
+func (l *LLM) model(answer *aggregates.Answer) string {
+	if answer.Model != "" {
+		return answer.Model
+	}
+	return l.config.Model
+}
+
+// recordUsage stores the usage of a provider call started at start.
+// Errors are logged: a failed recording doesn't fail the reranking.
+func (l *LLM) recordUsage(ctx context.Context, project string, start time.Time, answer *aggregates.Answer, callErr error) {
+	if l.config.Usage == nil {
+		return
+	}
+	record := usagedata.Record{
+		Project:  project,
+		Provider: l.config.Provider,
+		Model:    l.config.Model,
+		Latency:  time.Since(start),
+		Status:   usagedata.SuccessStatus,
+	}
+	if callErr != nil {
+		record.Status = usagedata.ErrorStatus
+	}
+	if answer != nil {
+		record.Model = l.model(answer)
+		record.InputTokens = answer.InputTokens
+		record.OutputTokens = answer.OutputTokens
+	}
+	// the call is recorded even if the client went away
+	err := l.config.Usage.Record(context.WithoutCancel(ctx), record)
+	if err != nil {
+		slog.Error(fmt.Sprintf("reranker %s: fail to record usage: %s", l.config.Name, err.Error()))
+	}
+}
+
+// Rerank returns the relevance scores of the fragments, in the same order as the fragments
+func (l *LLM) Rerank(ctx context.Context, project string, query string, fragments []string) ([]float64, error) {
+	tracer := otel.Tracer("rerank")
+	ctx, span := tracer.Start(ctx, "Rerank")
+	defer span.End()
+	span.SetAttributes(attribute.String("reranker.name", l.config.Name))
+	message, err := shared.NewMessage(shared.UserRole, judgeMessage(query, fragments))
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	answer, err := l.provider.Query(ctx, []shared.Message{*message}, aggregates.QueryOptions{
+		Provider:  l.config.Provider,
+		Model:     l.config.Model,
+		MaxTokens: l.config.MaxTokens,
+	})
+	l.recordUsage(ctx, project, start, answer, err)
+	if err != nil {
+		l.config.Metrics.ProviderCall(l.config.Provider, l.config.Model, metrics.QueryMode, time.Since(start), 0, 0, err)
+		otelspan.Error(span, err, "provider error")
+		return nil, err
+	}
+	l.config.Metrics.ProviderCall(l.config.Provider, l.model(answer), metrics.QueryMode, time.Since(start), answer.InputTokens, answer.OutputTokens, nil)
+	texts := []string{}
+	for _, result := range answer.Results {
+		texts = append(texts, result.Text)
+	}
+	scores, err := parseScores(strings.Join(texts, ""), len(fragments))
+	if err != nil {
+		err = fmt.Errorf("reranker %s: %w", l.config.Name, err)
+		otelspan.Error(span, err, "invalid answer")
+		return nil, err
+	}
+	span.SetStatus(codes.Ok, "success")
+	return scores, nil
+}
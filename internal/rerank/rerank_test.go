@@ -0,0 +1,106 @@
+package rerank_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/metrics"
+	"github.com/appclacks/maizai/internal/rerank"
+	usagememory "github.com/appclacks/maizai/internal/usagestore/memory"
+	"github.com/appclacks/maizai/mocks/github.com/appclacks/maizai/pkg/assistant"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/appclacks/maizai/pkg/usage"
+	usagedata "github.com/appclacks/maizai/pkg/usage/aggregates"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var payload map[string]any
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err)
+		assert.Equal(t, "rerank-model", payload["model"])
+		assert.Equal(t, "query", payload["query"])
+		assert.Equal(t, "a", payload["documents"].([]any)[0])
+		// the results are sorted by relevance
+		fmt.Fprint(w, `{"results":[{"index":2,"relevance_score":0.9},{"index":0,"relevance_score":0.5},{"index":1,"relevance_score":0.1}]}`)
+	}))
+	defer server.Close()
+	reranker := rerank.NewEndpoint(rerank.EndpointConfig{
+		Name:   "test",
+		URL:    server.URL + "/v1/rerank",
+		APIKey: "secret",
+		Model:  "rerank-model",
+	})
+	scores, err := reranker.Rerank(context.Background(), "project", "query", []string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 0.1, 0.9}, scores)
+
+	_, err = reranker.Rerank(context.Background(), "project", "query", []string{"a", "b"})
+	assert.ErrorContains(t, err, "returned 3 results for 2 fragments")
+}
+
+func TestLLM(t *testing.T) {
+	provider := assistant.NewMockProvider(t)
+	provider.EXPECT().Query(mock.Anything, mock.Anything, mock.Anything).RunAndReturn(
+		func(ctx context.Context, messages []shared.Message, options aggregates.QueryOptions) (*aggregates.Answer, error) {
+			assert.Len(t, messages, 1)
+			assert.Contains(t, messages[0].Content, "Query: query")
+			assert.Contains(t, messages[0].Content, "Fragment 2:\nb")
+			assert.Equal(t, "mistral", options.Provider)
+			assert.Equal(t, "small", options.Model)
+			return &aggregates.Answer{
+				Results:      []aggregates.Result{{Text: "Scores: [2, 10, 15]"}},
+				Model:        "small-2026",
+				InputTokens:  30,
+				OutputTokens: 4,
+			}, nil
+		}).Once()
+	usageStore := usagememory.New()
+	registry := prometheus.NewRegistry()
+	providerMetrics, err := metrics.New(registry)
+	assert.NoError(t, err)
+	reranker := rerank.NewLLM(rerank.LLMConfig{
+		Name:     "judge",
+		Provider: "mistral",
+		Model:    "small",
+		Usage:    usage.New(usageStore, usage.Config{}),
+		Metrics:  providerMetrics,
+	}, provider)
+	scores, err := reranker.Rerank(context.Background(), "project", "query", []string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.2, 1, 1}, scores)
+	records, err := usageStore.ListUsageRecords(context.Background(), "project", usagedata.Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "mistral", records[0].Provider)
+	assert.Equal(t, "small-2026", records[0].Model)
+	assert.Equal(t, uint64(30), records[0].InputTokens)
+	assert.Equal(t, uint64(4), records[0].OutputTokens)
+	assert.Equal(t, usagedata.SuccessStatus, records[0].Status)
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP llm_requests_total Count the number of calls to the AI providers
+# TYPE llm_requests_total counter
+llm_requests_total{mode="query",model="small-2026",provider="mistral"} 1
+# HELP llm_input_tokens_total Count the number of input tokens used by the AI providers calls
+# TYPE llm_input_tokens_total counter
+llm_input_tokens_total{model="small-2026",provider="mistral"} 30
+`), "llm_requests_total", "llm_input_tokens_total")
+	assert.NoError(t, err)
+
+	provider.EXPECT().Query(mock.Anything, mock.Anything, mock.Anything).Return(&aggregates.Answer{
+		Results: []aggregates.Result{{Text: "I don't know"}},
+	}, nil).Once()
+	_, err = reranker.Rerank(context.Background(), "project", "query", []string{"a"})
+	assert.ErrorContains(t, err, "no scores found")
+}
@@ -0,0 +1,5 @@
+package sqlite
+
+type Configuration struct {
+	Path string `env:"MAIZAI_SQLITE_PATH, default=maizai.db"`
+}
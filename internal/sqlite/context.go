@@ -0,0 +1,376 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/appclacks/maizai/pkg/shared"
+	er "github.com/mcorbin/corbierror"
+)
+
+func createContextMessage(ctx context.Context, q querier, contextID string, message shared.Message) error {
+	var toolCalls sql.NullString
+	if len(message.ToolCalls) != 0 {
+		b, err := json.Marshal(message.ToolCalls)
+		if err != nil {
+			return err
+		}
+		toolCalls = sql.NullString{String: string(b), Valid: true}
+	}
+	var parts sql.NullString
+	if len(message.Parts) != 0 {
+		b, err := json.Marshal(message.Parts)
+		if err != nil {
+			return err
+		}
+		parts = sql.NullString{String: string(b), Valid: true}
+	}
+	toolCallID := sql.NullString{String: message.ToolCallID, Valid: message.ToolCallID != ""}
+	_, err := q.ExecContext(ctx,
+		"INSERT INTO context_message (id, role, content, created_at, context_id, tool_calls, tool_call_id, parts, pinned) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		message.ID, message.Role, message.Content, message.CreatedAt, contextID, toolCalls, toolCallID, parts, message.Pinned)
+	return err
+}
+
+func createContextSource(ctx context.Context, q querier, contextID string, sourceContextID string) error {
+	_, err := q.ExecContext(ctx,
+		"INSERT INTO context_source (context_id, source_context_id) VALUES (?, ?)",
+		contextID, sourceContextID)
+	return err
+}
+
+func getContextSources(ctx context.Context, q querier, contextID string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT source_context_id FROM context_source WHERE context_id = ? ORDER BY ordering", contextID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []string{}
+	for rows.Next() {
+		var source string
+		if err := rows.Scan(&source); err != nil {
+			return nil, err
+		}
+		result = append(result, source)
+	}
+	return result, rows.Err()
+}
+
+func createContext(ctx context.Context, q querier, context shared.Context) error {
+	_, err := q.ExecContext(ctx,
+		"INSERT INTO context (id, project, name, description, created_at) VALUES (?, ?, ?, ?, ?)",
+		context.ID, context.Project, context.Name, context.Description, context.CreatedAt)
+	if err != nil {
+		return err
+	}
+	for _, source := range context.Sources.Contexts {
+		err := createContextSource(ctx, q, context.ID, source)
+		if err != nil {
+			return err
+		}
+	}
+	for _, message := range context.Messages {
+		err := createContextMessage(ctx, q, context.ID, message)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Database) CreateContext(ctx context.Context, context shared.Context) error {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackFn()
+	err = createContext(ctx, tx, context)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *Database) GetContext(ctx context.Context, project string, id string) (*shared.Context, error) {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rollbackFn()
+	result := shared.Context{
+		ID:      id,
+		Project: project,
+	}
+	var description sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT name, description, created_at FROM context WHERE id = ? AND project = ?", id, project).Scan(&result.Name, &description, &result.CreatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, er.Newf("context %s doesn't exist", er.NotFound, true, id)
+	}
+	result.Description = description.String
+	rows, err := tx.QueryContext(ctx, "SELECT id, role, content, created_at, tool_calls, tool_call_id, parts, pinned FROM context_message WHERE context_id = ? ORDER BY ordering", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var message shared.Message
+		var toolCalls, toolCallID, parts sql.NullString
+		if err := rows.Scan(&message.ID, &message.Role, &message.Content, &message.CreatedAt, &toolCalls, &toolCallID, &parts, &message.Pinned); err != nil {
+			return nil, err
+		}
+		if toolCalls.Valid {
+			err := json.Unmarshal([]byte(toolCalls.String), &message.ToolCalls)
+			if err != nil {
+				return nil, fmt.Errorf("fail to read tool calls for message %s: %w", message.ID, err)
+			}
+		}
+		if parts.Valid {
+			err := json.Unmarshal([]byte(parts.String), &message.Parts)
+			if err != nil {
+				return nil, fmt.Errorf("fail to read content parts for message %s: %w", message.ID, err)
+			}
+		}
+		message.ToolCallID = toolCallID.String
+		result.Messages = append(result.Messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sources, err := getContextSources(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	result.Sources.Contexts = sources
+	return &result, tx.Commit()
+}
+
+func (d *Database) AddMessages(ctx context.Context, project string, id string, messages []shared.Message) error {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackFn()
+	exists, err := exists(ctx, tx, project, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return er.Newf("context %s doesn't exist", er.NotFound, true, id)
+	}
+	for _, message := range messages {
+		err := createContextMessage(ctx, tx, id, message)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CompactContext creates the archive context and replaces the messages of the context by the summary message.
+// The summary takes the place of the oldest replaced message.
+func (d *Database) CompactContext(ctx context.Context, project string, contextID string, messageIDs []string, summary shared.Message, archive shared.Context) error {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackFn()
+	exists, err := exists(ctx, tx, project, contextID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return er.Newf("context %s doesn't exist", er.NotFound, true, contextID)
+	}
+	err = createContext(ctx, tx, archive)
+	if err != nil {
+		return err
+	}
+	var ordering int64
+	for i, id := range messageIDs {
+		var o int64
+		err := tx.QueryRowContext(ctx, "DELETE FROM context_message WHERE context_id = ? AND id = ? RETURNING ordering", contextID, id).Scan(&o)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				return err
+			}
+			return er.Newf("the messages of context %s were modified during the compaction", er.Conflict, true, contextID)
+		}
+		if i == 0 || o < ordering {
+			ordering = o
+		}
+	}
+	err = createContextMessage(ctx, tx, contextID, summary)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE context_message SET ordering = ? WHERE id = ?", ordering, summary.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *Database) DeleteContext(ctx context.Context, project string, id string) error {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackFn()
+	exists, err := exists(ctx, tx, project, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM context_source WHERE context_id = ? OR source_context_id = ?", id, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM context_message WHERE context_id = ?", id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM context WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *Database) ContextExists(ctx context.Context, project string, id string) (bool, error) {
+	return exists(ctx, d.db, project, id)
+}
+
+func exists(ctx context.Context, q querier, project string, id string) (bool, error) {
+	var name string
+	err := q.QueryRowContext(ctx, "SELECT name FROM context WHERE id = ? AND project = ?", id, project).Scan(&name)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return false, fmt.Errorf("fail to get context %s: %w", id, err)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func (d *Database) ContextExistsByName(ctx context.Context, project string, name string) (bool, error) {
+	var id string
+	err := d.db.QueryRowContext(ctx, "SELECT id FROM context WHERE project = ? AND name = ?", project, name).Scan(&id)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return false, fmt.Errorf("fail to get context %s: %w", name, err)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func (d *Database) ListContexts(ctx context.Context, project string) ([]shared.ContextMetadata, error) {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rollbackFn()
+	rows, err := tx.QueryContext(ctx, "SELECT id, name, description, created_at FROM context WHERE project = ? ORDER BY created_at", project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []shared.ContextMetadata{}
+	for rows.Next() {
+		metadata := shared.ContextMetadata{Project: project}
+		var description sql.NullString
+		if err := rows.Scan(&metadata.ID, &metadata.Name, &description, &metadata.CreatedAt); err != nil {
+			return nil, err
+		}
+		metadata.Description = description.String
+		result = append(result, metadata)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range result {
+		sources, err := getContextSources(ctx, tx, result[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(sources) > 0 {
+			result[i].Sources.Contexts = sources
+		}
+	}
+	return result, tx.Commit()
+}
+
+// inProject restricts a query to the rows whose context belongs to the project
+const inProject = "context_id IN (SELECT id FROM context WHERE project = ?)"
+
+// messageAffected returns an error when a query on a message didn't affect any row
+func messageAffected(result sql.Result, err error, messageID string) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return er.Newf("context message %s doesn't exist", er.NotFound, true, messageID)
+	}
+	return nil
+}
+
+func (d *Database) UpdateContextMessage(ctx context.Context, project string, messageID string, role string, content string) error {
+	result, err := d.db.ExecContext(ctx, "UPDATE context_message SET content = ?, role = ? WHERE id = ? AND "+inProject, content, role, messageID, project)
+	return messageAffected(result, err, messageID)
+}
+
+func (d *Database) PinContextMessage(ctx context.Context, project string, messageID string, pinned bool) error {
+	result, err := d.db.ExecContext(ctx, "UPDATE context_message SET pinned = ? WHERE id = ? AND "+inProject, pinned, messageID, project)
+	return messageAffected(result, err, messageID)
+}
+
+func (d *Database) DeleteContextMessage(ctx context.Context, project string, messageID string) error {
+	result, err := d.db.ExecContext(ctx, "DELETE FROM context_message WHERE id = ? AND "+inProject, messageID, project)
+	return messageAffected(result, err, messageID)
+}
+
+func (d *Database) DeleteContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error {
+	_, err := d.db.ExecContext(ctx, "DELETE FROM context_source WHERE context_id = ? AND source_context_id = ? AND "+inProject, contextID, sourceContextID, project)
+	return err
+}
+
+func (d *Database) CreateContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackFn()
+	for _, id := range []string{contextID, sourceContextID} {
+		exists, err := exists(ctx, tx, project, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return er.Newf("context %s doesn't exist", er.NotFound, true, id)
+		}
+	}
+	err = createContextSource(ctx, tx, contextID, sourceContextID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *Database) DeleteContextMessages(ctx context.Context, project string, contextID string) error {
+	_, err := d.db.ExecContext(ctx, "DELETE FROM context_message WHERE context_id = ? AND "+inProject, contextID, project)
+	if err != nil {
+		return fmt.Errorf("failed to delete messages for context %s: %w", contextID, err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package sqlite_test
+
+import (
+	"testing"
+
+	"github.com/appclacks/maizai/internal/storetest"
+)
+
+func TestContextCRUD(t *testing.T) {
+	storetest.ContextCRUD(t, TestComponent)
+}
+
+func TestContextToolCalls(t *testing.T) {
+	storetest.ContextToolCalls(t, TestComponent)
+}
+
+func TestContextMessageParts(t *testing.T) {
+	storetest.ContextMessageParts(t, TestComponent)
+}
+
+func TestContextMessagePin(t *testing.T) {
+	storetest.ContextMessagePin(t, TestComponent)
+}
+
+func TestCompactContext(t *testing.T) {
+	storetest.CompactContext(t, TestComponent)
+}
+
+func TestContextProjects(t *testing.T) {
+	storetest.ContextProjects(t, TestComponent)
+}
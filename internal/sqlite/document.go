@@ -0,0 +1,376 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/appclacks/maizai/internal/keywords"
+	"github.com/appclacks/maizai/internal/vector"
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	er "github.com/mcorbin/corbierror"
+)
+
+// encodeMap encodes labels or metadata as JSON
+func encodeMap(values map[string]string) (string, error) {
+	if values == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// decodeMap decodes labels or metadata. Empty values are decoded as nil.
+func decodeMap(encoded string) (map[string]string, error) {
+	var result map[string]string
+	err := json.Unmarshal([]byte(encoded), &result)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+func (d *Database) CreateDocument(ctx context.Context, document aggregates.Document) error {
+	labels, err := encodeMap(document.Labels)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.ExecContext(ctx,
+		"INSERT INTO document (id, project, name, description, created_at, embedding_provider, embedding_model, dimension, labels, source) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		document.ID, document.Project, document.Name, document.Description, document.CreatedAt, document.EmbeddingProvider, document.EmbeddingModel, document.Dimension, labels, document.Source)
+	return err
+}
+
+func (d *Database) GetDocument(ctx context.Context, project string, id string) (*aggregates.Document, error) {
+	document, err := getDocument(ctx, d.db, project, id)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, er.Newf("document %s doesn't exist", er.NotFound, true, id)
+	}
+	return document, nil
+}
+
+func getDocument(ctx context.Context, q querier, project string, id string) (*aggregates.Document, error) {
+	document := aggregates.Document{
+		ID:      id,
+		Project: project,
+	}
+	var description sql.NullString
+	var labels string
+	err := q.QueryRowContext(ctx, "SELECT name, description, created_at, embedding_provider, embedding_model, dimension, labels, source FROM document WHERE id = ? AND project = ?", id, project).Scan(&document.Name, &description, &document.CreatedAt, &document.EmbeddingProvider, &document.EmbeddingModel, &document.Dimension, &labels, &document.Source)
+	if err != nil {
+		return nil, err
+	}
+	document.Description = description.String
+	document.Labels, err = decodeMap(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+func (d *Database) ListDocuments(ctx context.Context, project string) ([]aggregates.Document, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT id, name, description, created_at, embedding_provider, embedding_model, dimension, labels, source FROM document WHERE project = ? ORDER BY created_at", project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []aggregates.Document{}
+	for rows.Next() {
+		document := aggregates.Document{Project: project}
+		var description sql.NullString
+		var labels string
+		if err := rows.Scan(&document.ID, &document.Name, &description, &document.CreatedAt, &document.EmbeddingProvider, &document.EmbeddingModel, &document.Dimension, &labels, &document.Source); err != nil {
+			return nil, err
+		}
+		document.Description = description.String
+		decoded, err := decodeMap(labels)
+		if err != nil {
+			return nil, err
+		}
+		document.Labels = decoded
+		result = append(result, document)
+	}
+	return result, rows.Err()
+}
+
+func (d *Database) DeleteDocument(ctx context.Context, project string, id string) error {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackFn()
+	_, err = tx.ExecContext(ctx, "DELETE FROM document_chunk WHERE document_id IN (SELECT id FROM document WHERE id = ? AND project = ?)", id, project)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM document WHERE id = ? AND project = ?", id, project)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return er.Newf("document %s doesn't exist", er.NotFound, true, id)
+	}
+	return tx.Commit()
+}
+
+const insertDocumentChunk = "INSERT INTO document_chunk (id, document_id, fragment, embedding, created_at, ordinal, start_offset, end_offset, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+func chunkArgs(documentChunk aggregates.DocumentChunk) ([]any, error) {
+	metadata, err := encodeMap(documentChunk.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return []any{documentChunk.ID, documentChunk.DocumentID, documentChunk.Fragment, vector.Encode(documentChunk.Embedding), documentChunk.CreatedAt, documentChunk.Ordinal, documentChunk.Start, documentChunk.End, metadata}, nil
+}
+
+func (d *Database) CreateDocumentChunk(ctx context.Context, documentChunk aggregates.DocumentChunk) error {
+	args, err := chunkArgs(documentChunk)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.ExecContext(ctx, insertDocumentChunk, args...)
+	return err
+}
+
+// CreateDocumentChunks stores all chunks in a single transaction, using a prepared statement
+func (d *Database) CreateDocumentChunks(ctx context.Context, documentChunks []aggregates.DocumentChunk) error {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackFn()
+	statement, err := tx.PrepareContext(ctx, insertDocumentChunk)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+	for _, documentChunk := range documentChunks {
+		args, err := chunkArgs(documentChunk)
+		if err != nil {
+			return err
+		}
+		_, err = statement.ExecContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func scanChunks(rows *sql.Rows) ([]aggregates.DocumentChunk, error) {
+	defer rows.Close()
+	result := []aggregates.DocumentChunk{}
+	for rows.Next() {
+		var chunk aggregates.DocumentChunk
+		var fragment sql.NullString
+		var embedding []byte
+		var metadata string
+		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &fragment, &embedding, &chunk.CreatedAt, &chunk.Ordinal, &chunk.Start, &chunk.End, &metadata); err != nil {
+			return nil, err
+		}
+		decoded, err := vector.Decode(embedding)
+		if err != nil {
+			return nil, err
+		}
+		chunk.Metadata, err = decodeMap(metadata)
+		if err != nil {
+			return nil, err
+		}
+		chunk.Fragment = fragment.String
+		chunk.Embedding = decoded
+		result = append(result, chunk)
+	}
+	return result, rows.Err()
+}
+
+// UpdateDocumentEmbedding records the embedding settings of a document. It fails if the
+// document already uses different settings.
+func (d *Database) UpdateDocumentEmbedding(ctx context.Context, project string, id string, provider string, model string, dimension int) error {
+	result, err := d.db.ExecContext(ctx,
+		`UPDATE document SET embedding_provider = ?, embedding_model = ?, dimension = ?
+WHERE id = ? AND project = ?
+AND (embedding_provider = '' OR embedding_provider = ?)
+AND (embedding_model = '' OR embedding_model = ?)
+AND (dimension = 0 OR dimension = ?)`,
+		provider, model, dimension, id, project, provider, model, dimension)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		_, err := getDocument(ctx, d.db, project, id)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				return err
+			}
+			return er.Newf("document %s doesn't exist", er.NotFound, true, id)
+		}
+		return er.Newf("document %s already uses other embedding settings", er.Conflict, true, id)
+	}
+	return nil
+}
+
+// placeholders returns the placeholders for n values, for example "?, ?, ?"
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// documentFilter returns the SQL condition selecting the project documents matching
+// the filter and the chunks having the metadata
+func documentFilter(project string, filter aggregates.DocumentFilter, metadata map[string]string) (string, []any) {
+	conditions := []string{"d.project = ?"}
+	args := []any{project}
+	if len(filter.IDs) != 0 || len(filter.Names) != 0 {
+		conditions = append(conditions, fmt.Sprintf("(d.id IN (%s) OR d.name IN (%s))", placeholders(len(filter.IDs)), placeholders(len(filter.Names))))
+		for _, id := range filter.IDs {
+			args = append(args, id)
+		}
+		for _, name := range filter.Names {
+			args = append(args, name)
+		}
+	}
+	if filter.Source != "" {
+		conditions = append(conditions, "d.source = ?")
+		args = append(args, filter.Source)
+	}
+	for key, value := range filter.Labels {
+		conditions = append(conditions, "json_extract(d.labels, ?) = ?")
+		args = append(args, fmt.Sprintf("$.%q", key), value)
+	}
+	for key, value := range metadata {
+		conditions = append(conditions, "json_extract(c.metadata, ?) = ?")
+		args = append(args, fmt.Sprintf("$.%q", key), value)
+	}
+	return " AND " + strings.Join(conditions, " AND "), args
+}
+
+// FindClosestChunks computes the distance between the embedding and the stored chunks in Go.
+// Only the chunks of the documents using the same embedding settings are compared.
+func (d *Database) FindClosestChunks(ctx context.Context, search aggregates.ChunkSearch) ([]aggregates.DocumentChunk, error) {
+	filter, filterArgs := documentFilter(search.Project, search.Filter, search.Metadata)
+	args := append([]any{search.Provider, search.Model, len(search.Embedding)}, filterArgs...)
+	rows, err := d.db.QueryContext(ctx, `SELECT c.id, c.document_id, c.fragment, c.embedding, c.created_at, c.ordinal, c.start_offset, c.end_offset, c.metadata
+FROM document_chunk c
+JOIN document d ON d.id = c.document_id
+WHERE d.embedding_provider = ? AND d.embedding_model = ? AND d.dimension = ?`+filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := scanChunks(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i := range chunks {
+		distance, err := vector.Distance(search.Metric, chunks[i].Embedding, search.Embedding)
+		if err != nil {
+			return nil, err
+		}
+		chunks[i].SetDistance(search.Metric, distance)
+	}
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return *chunks[i].Distance < *chunks[j].Distance
+	})
+	limit := max(0, int(search.Limit))
+	if len(chunks) > limit {
+		chunks = chunks[:limit]
+	}
+	return chunks, nil
+}
+
+// FindChunksByKeywords ranks the chunks of the documents matching the filter in Go,
+// by the number of occurrences of the query words
+func (d *Database) FindChunksByKeywords(ctx context.Context, search aggregates.KeywordSearch) ([]aggregates.DocumentChunk, error) {
+	filter, args := documentFilter(search.Project, search.Filter, search.Metadata)
+	rows, err := d.db.QueryContext(ctx, `SELECT c.id, c.document_id, c.fragment, c.embedding, c.created_at, c.ordinal, c.start_offset, c.end_offset, c.metadata
+FROM document_chunk c
+JOIN document d ON d.id = c.document_id
+WHERE c.fragment IS NOT NULL`+filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := scanChunks(rows)
+	if err != nil {
+		return nil, err
+	}
+	return keywords.Match(search.Query, chunks, int(search.Limit)), nil
+}
+
+func (d *Database) DeleteDocumentChunk(ctx context.Context, project string, id string) error {
+	result, err := d.db.ExecContext(ctx, "DELETE FROM document_chunk WHERE id = ? AND document_id IN (SELECT id FROM document WHERE project = ?)", id, project)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return er.Newf("document chunk %s doesn't exist", er.NotFound, true, id)
+	}
+	return nil
+}
+
+// NextChunkOrdinal returns the ordinal of the next chunk added to the document
+func (d *Database) NextChunkOrdinal(ctx context.Context, project string, docID string) (int, error) {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer rollbackFn()
+	_, err = getDocument(ctx, tx, project, docID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
+		return 0, er.Newf("document %s doesn't exist", er.NotFound, true, docID)
+	}
+	var ordinal int
+	err = tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(ordinal) + 1, 0) FROM document_chunk WHERE document_id = ?", docID).Scan(&ordinal)
+	if err != nil {
+		return 0, err
+	}
+	return ordinal, tx.Commit()
+}
+
+func (d *Database) ListDocumentChunksForDocument(ctx context.Context, project string, docID string) ([]aggregates.DocumentChunk, error) {
+	tx, rollbackFn, err := d.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rollbackFn()
+	_, err = getDocument(ctx, tx, project, docID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, er.Newf("document %s doesn't exist", er.NotFound, true, docID)
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, document_id, fragment, embedding, created_at, ordinal, start_offset, end_offset, metadata FROM document_chunk WHERE document_id = ? ORDER BY ordinal, created_at", docID)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := scanChunks(rows)
+	if err != nil {
+		return nil, err
+	}
+	return chunks, tx.Commit()
+}
@@ -0,0 +1,144 @@
+package sqlite_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/appclacks/maizai/internal/storetest"
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentCRUD(t *testing.T) {
+	storetest.DocumentCRUD(t, TestComponent)
+}
+
+func TestCreateDocumentChunks(t *testing.T) {
+	storetest.CreateDocumentChunks(t, TestComponent)
+}
+
+func TestUpdateDocumentEmbedding(t *testing.T) {
+	storetest.UpdateDocumentEmbedding(t, TestComponent)
+}
+
+func TestFindChunksByKeywords(t *testing.T) {
+	storetest.FindChunksByKeywords(t, TestComponent)
+}
+
+func TestDocumentProjects(t *testing.T) {
+	storetest.DocumentProjects(t, TestComponent)
+}
+
+func TestFindClosestChunks(t *testing.T) {
+	ctx := context.Background()
+	doc := aggregates.Document{
+		ID:        uuid.NewString(),
+		Project:   shared.DefaultProject,
+		Name:      "closest",
+		CreatedAt: time.Now().UTC(),
+		Labels:    map[string]string{"team": "core"},
+		Source:    "https://example.com/closest",
+	}
+	err := TestComponent.CreateDocument(ctx, doc)
+	assert.NoError(t, err)
+	embeddings := [][]float32{{0, 0}, {10, 10}, {1, 1}}
+	for _, embedding := range embeddings {
+		chunk, err := aggregates.NewDocumentChunk(doc.ID, "fragment", embedding)
+		assert.NoError(t, err)
+		chunk.Metadata = map[string]string{"kind": "text"}
+		err = TestComponent.CreateDocumentChunk(ctx, *chunk)
+		assert.NoError(t, err)
+	}
+	err = TestComponent.UpdateDocumentEmbedding(ctx, shared.DefaultProject, doc.ID, "mistral", "mistral-embed", 2)
+	assert.NoError(t, err)
+	closest, err := TestComponent.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{9, 9},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, closest, 2)
+	assert.Equal(t, []float32{10, 10}, closest[0].Embedding)
+	assert.Equal(t, []float32{1, 1}, closest[1].Embedding)
+	assert.Equal(t, doc.ID, closest[0].DocumentID)
+	assert.InDelta(t, math.Sqrt(2), *closest[0].Distance, 0.0001)
+
+	// a negative limit returns no chunk
+	none, err := TestComponent.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{9, 9},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     -1,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, none, 0)
+
+	byCosine, err := TestComponent.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{1, 0},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     3,
+		Metric:    aggregates.Cosine,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, byCosine, 3)
+	assert.InDelta(t, math.Sqrt(2)/2, *byCosine[0].Score, 0.0001)
+	assert.Equal(t, []float32{0, 0}, byCosine[2].Embedding)
+
+	other, err := TestComponent.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   shared.DefaultProject,
+		Embedding: []float32{9, 9},
+		Provider:  "mistral",
+		Model:     "other-model",
+		Limit:     2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, other, 0)
+
+	result, err := TestComponent.GetDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.Labels, result.Labels)
+	assert.Equal(t, doc.Source, result.Source)
+	assert.Equal(t, map[string]string{"kind": "text"}, closest[0].Metadata)
+	filters := []struct {
+		filter   aggregates.DocumentFilter
+		metadata map[string]string
+		count    int
+	}{
+		{filter: aggregates.DocumentFilter{IDs: []string{doc.ID}}, count: 2},
+		{filter: aggregates.DocumentFilter{IDs: []string{uuid.NewString()}}, count: 0},
+		{filter: aggregates.DocumentFilter{Names: []string{"closest"}}, count: 2},
+		{filter: aggregates.DocumentFilter{IDs: []string{uuid.NewString()}, Names: []string{"closest"}}, count: 2},
+		{filter: aggregates.DocumentFilter{Labels: map[string]string{"team": "core"}}, count: 2},
+		{filter: aggregates.DocumentFilter{Labels: map[string]string{"team": "other"}}, count: 0},
+		{filter: aggregates.DocumentFilter{Names: []string{"closest"}, Labels: map[string]string{"env": "prod"}}, count: 0},
+		{filter: aggregates.DocumentFilter{Source: "https://example.com/closest"}, count: 2},
+		{filter: aggregates.DocumentFilter{Source: "https://example.com/other"}, count: 0},
+		{metadata: map[string]string{"kind": "text"}, count: 2},
+		{metadata: map[string]string{"kind": "image"}, count: 0},
+	}
+	for _, f := range filters {
+		filtered, err := TestComponent.FindClosestChunks(ctx, aggregates.ChunkSearch{
+			Project:   shared.DefaultProject,
+			Embedding: []float32{9, 9},
+			Provider:  "mistral",
+			Model:     "mistral-embed",
+			Limit:     2,
+			Filter:    f.filter,
+			Metadata:  f.metadata,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, filtered, f.count)
+	}
+
+	err = TestComponent.DeleteDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,4 @@
+ALTER TABLE context_message ADD COLUMN tool_calls text;
+--;;
+ALTER TABLE context_message ADD COLUMN tool_call_id varchar(255);
+--;;
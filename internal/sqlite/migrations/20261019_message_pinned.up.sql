@@ -0,0 +1,2 @@
+ALTER TABLE context_message ADD COLUMN pinned boolean NOT NULL DEFAULT false;
+--;;
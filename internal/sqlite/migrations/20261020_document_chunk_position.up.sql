@@ -0,0 +1,6 @@
+ALTER TABLE document_chunk ADD COLUMN ordinal integer NOT NULL DEFAULT 0;
+--;;
+ALTER TABLE document_chunk ADD COLUMN start_offset integer NOT NULL DEFAULT 0;
+--;;
+ALTER TABLE document_chunk ADD COLUMN end_offset integer NOT NULL DEFAULT 0;
+--;;
@@ -0,0 +1,9 @@
+ALTER TABLE document ADD COLUMN embedding_provider text NOT NULL DEFAULT '';
+--;;
+ALTER TABLE document ADD COLUMN embedding_model text NOT NULL DEFAULT '';
+--;;
+ALTER TABLE document ADD COLUMN dimension integer NOT NULL DEFAULT 0;
+--;;
+UPDATE document SET embedding_provider = 'mistral', embedding_model = 'mistral-embed', dimension = 1024
+WHERE dimension = 0 AND id IN (SELECT document_id FROM document_chunk WHERE length(embedding) = 4096);
+--;;
@@ -0,0 +1,2 @@
+ALTER TABLE document ADD COLUMN labels text NOT NULL DEFAULT '{}';
+--;;
@@ -0,0 +1,4 @@
+ALTER TABLE document ADD COLUMN source text NOT NULL DEFAULT '';
+--;;
+ALTER TABLE document_chunk ADD COLUMN metadata text NOT NULL DEFAULT '{}';
+--;;
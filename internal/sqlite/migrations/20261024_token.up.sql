@@ -0,0 +1,8 @@
+create table if not exists token (
+  id text not null primary key,
+  name text not null,
+  scopes text not null,
+  hash text not null unique,
+  created_at timestamp not null
+);
+--;;
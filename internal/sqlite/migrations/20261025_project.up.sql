@@ -0,0 +1,100 @@
+create table context_project (
+  id text not null primary key,
+  project varchar(63) not null default 'default',
+  name varchar(255) not null,
+  description text,
+  created_at timestamp not null
+);
+--;;
+INSERT INTO context_project (id, name, description, created_at) SELECT id, name, description, created_at FROM context;
+--;;
+create table context_source_project (
+  ordering integer primary key autoincrement,
+  context_id text not null,
+  source_context_id text not null,
+  constraint fk_context foreign key(context_id) references context_project(id),
+  constraint fk_source_context foreign key(source_context_id) references context_project(id),
+  unique(context_id, source_context_id)
+);
+--;;
+INSERT INTO context_source_project (ordering, context_id, source_context_id) SELECT ordering, context_id, source_context_id FROM context_source;
+--;;
+create table context_message_project (
+  ordering integer primary key autoincrement,
+  id text not null unique,
+  role varchar(255) not null,
+  content text not null,
+  created_at timestamp not null,
+  context_id text not null,
+  tool_calls text,
+  tool_call_id varchar(255),
+  parts text,
+  pinned boolean NOT NULL DEFAULT false,
+  constraint fk_context foreign key(context_id) references context_project(id)
+);
+--;;
+INSERT INTO context_message_project (ordering, id, role, content, created_at, context_id, tool_calls, tool_call_id, parts, pinned)
+SELECT ordering, id, role, content, created_at, context_id, tool_calls, tool_call_id, parts, pinned FROM context_message;
+--;;
+create table document_project (
+  id text not null primary key,
+  project varchar(63) not null default 'default',
+  name varchar(255) not null,
+  description text,
+  created_at timestamp not null,
+  embedding_provider text NOT NULL DEFAULT '',
+  embedding_model text NOT NULL DEFAULT '',
+  dimension integer NOT NULL DEFAULT 0,
+  labels text NOT NULL DEFAULT '{}',
+  source text NOT NULL DEFAULT ''
+);
+--;;
+INSERT INTO document_project (id, name, description, created_at, embedding_provider, embedding_model, dimension, labels, source)
+SELECT id, name, description, created_at, embedding_provider, embedding_model, dimension, labels, source FROM document;
+--;;
+create table document_chunk_project (
+  id text not null primary key,
+  document_id text not null,
+  fragment text,
+  embedding blob,
+  created_at timestamp not null,
+  ordinal integer NOT NULL DEFAULT 0,
+  start_offset integer NOT NULL DEFAULT 0,
+  end_offset integer NOT NULL DEFAULT 0,
+  metadata text NOT NULL DEFAULT '{}',
+  constraint fk_document_id foreign key(document_id) references document_project(id)
+);
+--;;
+INSERT INTO document_chunk_project (id, document_id, fragment, embedding, created_at, ordinal, start_offset, end_offset, metadata)
+SELECT id, document_id, fragment, embedding, created_at, ordinal, start_offset, end_offset, metadata FROM document_chunk;
+--;;
+DROP TABLE context_source;
+--;;
+DROP TABLE context_message;
+--;;
+DROP TABLE context;
+--;;
+DROP TABLE document_chunk;
+--;;
+DROP TABLE document;
+--;;
+ALTER TABLE context_project RENAME TO context;
+--;;
+ALTER TABLE context_source_project RENAME TO context_source;
+--;;
+ALTER TABLE context_message_project RENAME TO context_message;
+--;;
+ALTER TABLE document_project RENAME TO document;
+--;;
+ALTER TABLE document_chunk_project RENAME TO document_chunk;
+--;;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_context_project_name ON context(project, name);
+--;;
+CREATE INDEX IF NOT EXISTS idx_context_message_context_id ON context_message(context_id);
+--;;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_document_project_name ON document(project, name);
+--;;
+CREATE INDEX IF NOT EXISTS idx_document_chunk_document_id ON document_chunk(document_id);
+--;;
+ALTER TABLE token ADD COLUMN project varchar(63) NOT NULL DEFAULT '';
+--;;
@@ -0,0 +1,16 @@
+create table if not exists usage_record (
+  id text not null primary key,
+  project text not null,
+  context_id text not null,
+  token text not null,
+  provider text not null,
+  model text not null,
+  input_tokens integer not null,
+  output_tokens integer not null,
+  latency_ms integer not null,
+  status text not null,
+  created_at timestamp not null
+);
+--;;
+create index if not exists idx_usage_record_project_created_at on usage_record (project, created_at);
+--;;
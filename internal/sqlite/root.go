@@ -0,0 +1,97 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log/slog"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+type Database struct {
+	db *sql.DB
+}
+
+var CleanupQueries = []string{
+	"DELETE FROM context_message",
+	"DELETE FROM context_source",
+	"DELETE FROM context",
+	"DELETE FROM document_chunk",
+	"DELETE FROM document",
+	"DELETE FROM token",
+	"DELETE FROM usage_record",
+}
+
+func New(config Configuration) (*Database, error) {
+	// foreign keys are disabled by default on SQLite
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate", config.Path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	source, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("fail to create source  migration driver: %w", err)
+	}
+	driver, err := migratesqlite.WithInstance(db, &migratesqlite.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("fail to create sqlite migration driver: %w", err)
+	}
+	m, err := migrate.NewWithInstance(
+		"iofs",
+		source,
+		"sqlite",
+		driver)
+	if err != nil {
+		return nil, fmt.Errorf("fail to instantiate migrations: %w", err)
+	}
+	slog.Info("Applying databases migrations")
+	err = m.Up()
+	if err != nil && err != migrate.ErrNoChange {
+		return nil, fmt.Errorf("fail to apply migrations: %w", err)
+	}
+	slog.Info("Migrations applied")
+	return &Database{
+		db: db,
+	}, nil
+}
+
+func (d *Database) Exec(query string) (sql.Result, error) {
+	return d.db.Exec(query)
+}
+
+func (d *Database) Stop() {
+	err := d.db.Close()
+	if err != nil {
+		slog.Error(err.Error())
+	}
+}
+
+func (d *Database) beginTx(ctx context.Context) (*sql.Tx, func(), error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	rollbackFn := func() {
+		err := tx.Rollback()
+		if err != nil && err != sql.ErrTxDone {
+			slog.Error(err.Error())
+		}
+	}
+	return tx, rollbackFn, nil
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
@@ -0,0 +1,35 @@
+package sqlite_test
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/sqlite"
+)
+
+var TestComponent *sqlite.Database
+
+func TestMain(m *testing.M) {
+	logger := slog.Default()
+	dir, err := os.MkdirTemp("", "maizai-sqlite")
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	TestComponent, err = sqlite.New(sqlite.Configuration{
+		Path: filepath.Join(dir, "maizai.db"),
+	})
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	exitVal := m.Run()
+	TestComponent.Stop()
+	err = os.RemoveAll(dir)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	os.Exit(exitVal)
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/appclacks/maizai/pkg/auth/aggregates"
+	er "github.com/mcorbin/corbierror"
+)
+
+func scanToken(scanner interface{ Scan(dest ...any) error }) (*aggregates.Token, error) {
+	var token aggregates.Token
+	var scopes string
+	err := scanner.Scan(&token.ID, &token.Name, &scopes, &token.Project, &token.Hash, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(scopes), &token.Scopes)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read scopes for token %s: %w", token.ID, err)
+	}
+	return &token, nil
+}
+
+func (d *Database) CreateToken(ctx context.Context, token aggregates.Token) error {
+	scopes, err := json.Marshal(token.Scopes)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.ExecContext(ctx,
+		"INSERT INTO token (id, name, scopes, project, hash, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		token.ID, token.Name, string(scopes), token.Project, token.Hash, token.CreatedAt)
+	return err
+}
+
+func (d *Database) ListTokens(ctx context.Context) ([]aggregates.Token, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT id, name, scopes, project, hash, created_at FROM token ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []aggregates.Token{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *token)
+	}
+	return result, rows.Err()
+}
+
+func (d *Database) GetTokenByHash(ctx context.Context, hash string) (*aggregates.Token, error) {
+	row := d.db.QueryRowContext(ctx, "SELECT id, name, scopes, project, hash, created_at FROM token WHERE hash = ?", hash)
+	token, err := scanToken(row)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, er.New("token doesn't exist", er.NotFound, true)
+	}
+	return token, nil
+}
+
+func (d *Database) DeleteToken(ctx context.Context, id string) error {
+	result, err := d.db.ExecContext(ctx, "DELETE FROM token WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return er.Newf("token %s doesn't exist", er.NotFound, true, id)
+	}
+	return nil
+}
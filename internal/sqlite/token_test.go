@@ -0,0 +1,11 @@
+package sqlite_test
+
+import (
+	"testing"
+
+	"github.com/appclacks/maizai/internal/storetest"
+)
+
+func TestTokenCRUD(t *testing.T) {
+	storetest.TokenCRUD(t, TestComponent)
+}
@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/usage/aggregates"
+)
+
+func (d *Database) CreateUsageRecord(ctx context.Context, record aggregates.Record) error {
+	_, err := d.db.ExecContext(ctx,
+		"INSERT INTO usage_record (id, project, context_id, token, provider, model, input_tokens, output_tokens, latency_ms, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		record.ID, record.Project, record.Context, record.Token, record.Provider, record.Model, record.InputTokens, record.OutputTokens, record.Latency.Milliseconds(), record.Status, record.CreatedAt)
+	return err
+}
+
+func (d *Database) ListUsageRecords(ctx context.Context, project string, filter aggregates.Filter) ([]aggregates.Record, error) {
+	query := "SELECT id, project, context_id, token, provider, model, input_tokens, output_tokens, latency_ms, status, created_at FROM usage_record WHERE project = ?"
+	args := []any{project}
+	if !filter.Since.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, filter.Until.UTC())
+	}
+	if filter.Context != "" {
+		query += " AND context_id = ?"
+		args = append(args, filter.Context)
+	}
+	if filter.Token != "" {
+		query += " AND token = ?"
+		args = append(args, filter.Token)
+	}
+	if filter.Model != "" {
+		query += " AND model = ?"
+		args = append(args, filter.Model)
+	}
+	rows, err := d.db.QueryContext(ctx, query+" ORDER BY created_at", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []aggregates.Record{}
+	for rows.Next() {
+		var record aggregates.Record
+		var latency int64
+		err := rows.Scan(&record.ID, &record.Project, &record.Context, &record.Token, &record.Provider, &record.Model, &record.InputTokens, &record.OutputTokens, &latency, &record.Status, &record.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		record.Latency = time.Duration(latency) * time.Millisecond
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,11 @@
+package sqlite_test
+
+import (
+	"testing"
+
+	"github.com/appclacks/maizai/internal/storetest"
+)
+
+func TestUsageRecords(t *testing.T) {
+	storetest.UsageRecords(t, TestComponent)
+}
@@ -0,0 +1,465 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func ContextCRUD(t *testing.T, store Store) {
+	ctx := context.Background()
+	context := shared.Context{
+		Project:     shared.DefaultProject,
+		Name:        "test",
+		ID:          uuid.New().String(),
+		Description: "foo",
+		CreatedAt:   time.Now().UTC(),
+		Sources:     shared.ContextSources{},
+		Messages: []shared.Message{
+			{
+				ID:        uuid.New().String(),
+				Role:      shared.AssistantRole,
+				Content:   "1234",
+				CreatedAt: time.Now().UTC(),
+			},
+		},
+	}
+	err := store.CreateContext(ctx, context)
+	assert.NoError(t, err)
+
+	get, err := store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, get.ID, context.ID)
+	assert.Equal(t, get.Name, context.Name)
+	assert.Equal(t, get.Description, context.Description)
+	assert.Len(t, get.Messages, 1)
+	assert.Equal(t, get.Messages[0].Content, "1234")
+	assert.Equal(t, get.Messages[0].ID, context.Messages[0].ID)
+	assert.Equal(t, get.Messages[0].Role, context.Messages[0].Role)
+
+	err = store.UpdateContextMessage(ctx, shared.DefaultProject, context.Messages[0].ID, shared.UserRole, "new message")
+	assert.NoError(t, err)
+
+	get, err = store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, get.Messages[0].Content, "new message")
+	assert.Equal(t, get.Messages[0].ID, context.Messages[0].ID)
+	assert.Equal(t, get.Messages[0].Role, shared.UserRole)
+
+	listResult, err := store.ListContexts(ctx, shared.DefaultProject)
+	assert.NoError(t, err)
+
+	assert.Len(t, listResult, 1)
+	assert.Equal(t, listResult[0].ID, context.ID)
+	assert.Equal(t, listResult[0].Name, context.Name)
+	assert.Equal(t, listResult[0].Description, context.Description)
+
+	contextWithSource := shared.Context{
+		Project:     shared.DefaultProject,
+		Name:        "test2",
+		ID:          uuid.New().String(),
+		Description: "foo",
+		CreatedAt:   time.Now().UTC(),
+		Sources: shared.ContextSources{
+			Contexts: []string{context.ID},
+		},
+		Messages: []shared.Message{
+			{
+				ID:        uuid.New().String(),
+				Role:      shared.AssistantRole,
+				Content:   "1234",
+				CreatedAt: time.Now().UTC(),
+			},
+			{
+				ID:        uuid.New().String(),
+				Role:      shared.UserRole,
+				Content:   "456",
+				CreatedAt: time.Now().UTC(),
+			},
+		},
+	}
+	err = store.CreateContext(ctx, contextWithSource)
+	assert.NoError(t, err)
+
+	getSrc, err := store.GetContext(ctx, shared.DefaultProject, contextWithSource.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, getSrc.ID, contextWithSource.ID)
+	assert.Equal(t, getSrc.Name, contextWithSource.Name)
+	assert.Equal(t, getSrc.Description, contextWithSource.Description)
+	assert.Len(t, getSrc.Sources.Contexts, 1)
+	assert.Len(t, getSrc.Messages, 2)
+	assert.Equal(t, getSrc.Messages[0].Content, "1234")
+	assert.Equal(t, getSrc.Messages[0].ID, contextWithSource.Messages[0].ID)
+	assert.Equal(t, getSrc.Messages[0].Role, contextWithSource.Messages[0].Role)
+	assert.Equal(t, getSrc.Messages[1].Content, "456")
+	assert.Equal(t, getSrc.Messages[1].ID, contextWithSource.Messages[1].ID)
+	assert.Equal(t, getSrc.Messages[1].Role, contextWithSource.Messages[1].Role)
+
+	exists, err := store.ContextExists(ctx, shared.DefaultProject, getSrc.ID)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = store.ContextExists(ctx, shared.DefaultProject, uuid.New().String())
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = store.ContextExistsByName(ctx, shared.DefaultProject, getSrc.Name)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = store.ContextExistsByName(ctx, shared.DefaultProject, "azeaajazie")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	messagesToAdd := []shared.Message{
+		{
+			ID:        uuid.New().String(),
+			Role:      shared.AssistantRole,
+			Content:   "9876",
+			CreatedAt: time.Now().UTC(),
+		},
+		{
+			ID:        uuid.New().String(),
+			Role:      shared.UserRole,
+			Content:   "hello",
+			CreatedAt: time.Now().UTC(),
+		},
+	}
+	err = store.AddMessages(ctx, shared.DefaultProject, getSrc.ID, messagesToAdd)
+	assert.NoError(t, err)
+
+	getWithMsg, err := store.GetContext(ctx, shared.DefaultProject, contextWithSource.ID)
+	assert.NoError(t, err)
+	assert.Len(t, getWithMsg.Messages, 4)
+	assert.Equal(t, getWithMsg.Messages[0].Content, "1234")
+	assert.Equal(t, getWithMsg.Messages[0].ID, contextWithSource.Messages[0].ID)
+	assert.Equal(t, getWithMsg.Messages[0].Role, contextWithSource.Messages[0].Role)
+	assert.Equal(t, getWithMsg.Messages[1].Content, "456")
+	assert.Equal(t, getWithMsg.Messages[1].ID, contextWithSource.Messages[1].ID)
+	assert.Equal(t, getWithMsg.Messages[1].Role, contextWithSource.Messages[1].Role)
+	assert.Equal(t, getWithMsg.Messages[2].Content, "9876")
+	assert.Equal(t, getWithMsg.Messages[2].ID, messagesToAdd[0].ID)
+	assert.Equal(t, getWithMsg.Messages[2].Role, messagesToAdd[0].Role)
+	assert.Equal(t, getWithMsg.Messages[3].Content, "hello")
+	assert.Equal(t, getWithMsg.Messages[3].ID, messagesToAdd[1].ID)
+	assert.Equal(t, getWithMsg.Messages[3].Role, messagesToAdd[1].Role)
+
+	listResult, err = store.ListContexts(ctx, shared.DefaultProject)
+	assert.NoError(t, err)
+	assert.Len(t, listResult, 2)
+
+	err = store.DeleteContextMessage(ctx, shared.DefaultProject, getWithMsg.Messages[0].ID)
+	assert.NoError(t, err)
+	getWithMsg, err = store.GetContext(ctx, shared.DefaultProject, contextWithSource.ID)
+	assert.NoError(t, err)
+	assert.Len(t, getWithMsg.Messages, 3)
+
+	err = store.CreateContextSourceContext(ctx, shared.DefaultProject, context.ID, getSrc.ID)
+	assert.NoError(t, err)
+	get, err = store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, get.ID, context.ID)
+	assert.Len(t, get.Sources.Contexts, 1)
+	assert.Equal(t, getSrc.ID, get.Sources.Contexts[0])
+
+	err = store.DeleteContextSourceContext(ctx, shared.DefaultProject, context.ID, getSrc.ID)
+	assert.NoError(t, err)
+	get, err = store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, get.ID, context.ID)
+	assert.Len(t, get.Sources.Contexts, 0)
+
+	err = store.DeleteContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	listResult, err = store.ListContexts(ctx, shared.DefaultProject)
+	assert.NoError(t, err)
+	assert.Len(t, listResult, 1)
+}
+
+func ContextToolCalls(t *testing.T, store Store) {
+	ctx := context.Background()
+	context := shared.Context{
+		Project:   shared.DefaultProject,
+		Name:      "tools",
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().UTC(),
+		Messages: []shared.Message{
+			{
+				ID:        uuid.New().String(),
+				Role:      shared.AssistantRole,
+				CreatedAt: time.Now().UTC(),
+				ToolCalls: []shared.ToolCall{
+					{
+						ID:        "call_1",
+						Name:      "weather",
+						Arguments: []byte(`{"city":"Paris"}`),
+					},
+				},
+			},
+			{
+				ID:         uuid.New().String(),
+				Role:       shared.ToolRole,
+				Content:    "sunny",
+				ToolCallID: "call_1",
+				CreatedAt:  time.Now().UTC(),
+			},
+		},
+	}
+	err := store.CreateContext(ctx, context)
+	assert.NoError(t, err)
+
+	get, err := store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.Len(t, get.Messages, 2)
+	assert.Len(t, get.Messages[0].ToolCalls, 1)
+	assert.Equal(t, "call_1", get.Messages[0].ToolCalls[0].ID)
+	assert.Equal(t, "weather", get.Messages[0].ToolCalls[0].Name)
+	assert.JSONEq(t, `{"city":"Paris"}`, string(get.Messages[0].ToolCalls[0].Arguments))
+	assert.Equal(t, "", get.Messages[0].ToolCallID)
+	assert.Equal(t, "call_1", get.Messages[1].ToolCallID)
+	assert.Len(t, get.Messages[1].ToolCalls, 0)
+
+	err = store.DeleteContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+}
+
+func ContextMessageParts(t *testing.T, store Store) {
+	ctx := context.Background()
+	context := shared.Context{
+		Project:   shared.DefaultProject,
+		Name:      "parts",
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().UTC(),
+		Messages: []shared.Message{
+			{
+				ID:        uuid.New().String(),
+				Role:      shared.UserRole,
+				Content:   "what's in this image?",
+				CreatedAt: time.Now().UTC(),
+				Parts: []shared.ContentPart{
+					{
+						Type:      shared.ImagePart,
+						MediaType: "image/png",
+						Data:      "aGVsbG8=",
+					},
+					{
+						Type: shared.DocumentPart,
+						URL:  "https://example.com/doc.pdf",
+					},
+				},
+			},
+			{
+				ID:        uuid.New().String(),
+				Role:      shared.AssistantRole,
+				Content:   "a cat",
+				CreatedAt: time.Now().UTC(),
+			},
+		},
+	}
+	err := store.CreateContext(ctx, context)
+	assert.NoError(t, err)
+
+	get, err := store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.Len(t, get.Messages, 2)
+	assert.Equal(t, context.Messages[0].Parts, get.Messages[0].Parts)
+	assert.Len(t, get.Messages[1].Parts, 0)
+
+	err = store.DeleteContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+}
+
+func ContextMessagePin(t *testing.T, store Store) {
+	ctx := context.Background()
+	context := shared.Context{
+		Project:   shared.DefaultProject,
+		Name:      "pin",
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().UTC(),
+		Messages: []shared.Message{
+			{
+				ID:        uuid.New().String(),
+				Role:      shared.UserRole,
+				Content:   "remember this",
+				CreatedAt: time.Now().UTC(),
+				Pinned:    true,
+			},
+			{
+				ID:        uuid.New().String(),
+				Role:      shared.AssistantRole,
+				Content:   "ok",
+				CreatedAt: time.Now().UTC(),
+			},
+		},
+	}
+	err := store.CreateContext(ctx, context)
+	assert.NoError(t, err)
+
+	get, err := store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.True(t, get.Messages[0].Pinned)
+	assert.False(t, get.Messages[1].Pinned)
+
+	err = store.PinContextMessage(ctx, shared.DefaultProject, context.Messages[0].ID, false)
+	assert.NoError(t, err)
+	err = store.PinContextMessage(ctx, shared.DefaultProject, context.Messages[1].ID, true)
+	assert.NoError(t, err)
+
+	get, err = store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.False(t, get.Messages[0].Pinned)
+	assert.True(t, get.Messages[1].Pinned)
+
+	// unknown messages and messages of other projects are not found
+	for _, m := range []struct {
+		project string
+		id      string
+	}{
+		{project: shared.DefaultProject, id: uuid.NewString()},
+		{project: "other-project", id: context.Messages[0].ID},
+	} {
+		err = store.PinContextMessage(ctx, m.project, m.id, true)
+		assert.ErrorContains(t, err, "doesn't exist")
+		err = store.UpdateContextMessage(ctx, m.project, m.id, shared.UserRole, "updated")
+		assert.ErrorContains(t, err, "doesn't exist")
+		err = store.DeleteContextMessage(ctx, m.project, m.id)
+		assert.ErrorContains(t, err, "doesn't exist")
+	}
+	get, err = store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.Len(t, get.Messages, 2)
+	assert.False(t, get.Messages[0].Pinned)
+
+	err = store.DeleteContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+}
+
+func CompactContext(t *testing.T, store Store) {
+	ctx := context.Background()
+	messages := []shared.Message{}
+	for _, content := range []string{"m1", "m2", "m3", "m4"} {
+		messages = append(messages, shared.Message{
+			ID:        uuid.New().String(),
+			Role:      shared.UserRole,
+			Content:   content,
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+	context := shared.Context{
+		Project:   shared.DefaultProject,
+		Name:      "compact",
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().UTC(),
+		Messages:  messages,
+	}
+	err := store.CreateContext(ctx, context)
+	assert.NoError(t, err)
+
+	summary := shared.Message{
+		ID:        uuid.New().String(),
+		Role:      shared.UserRole,
+		Content:   "summary",
+		CreatedAt: time.Now().UTC(),
+	}
+	archive := shared.Context{
+		Project:   shared.DefaultProject,
+		Name:      "compact-archive",
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().UTC(),
+		Messages: []shared.Message{
+			{
+				ID:        uuid.New().String(),
+				Role:      shared.UserRole,
+				Content:   "m1",
+				CreatedAt: time.Now().UTC(),
+			},
+			{
+				ID:        uuid.New().String(),
+				Role:      shared.UserRole,
+				Content:   "m2",
+				CreatedAt: time.Now().UTC(),
+			},
+		},
+	}
+	err = store.CompactContext(ctx, shared.DefaultProject, context.ID, []string{messages[0].ID, messages[1].ID}, summary, archive)
+	assert.NoError(t, err)
+
+	get, err := store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.Len(t, get.Messages, 3)
+	assert.Equal(t, summary.ID, get.Messages[0].ID)
+	assert.Equal(t, "m3", get.Messages[1].Content)
+	assert.Equal(t, "m4", get.Messages[2].Content)
+
+	getArchive, err := store.GetContext(ctx, shared.DefaultProject, archive.ID)
+	assert.NoError(t, err)
+	assert.Len(t, getArchive.Messages, 2)
+	assert.Equal(t, "m1", getArchive.Messages[0].Content)
+	assert.Equal(t, "m2", getArchive.Messages[1].Content)
+
+	// the transaction is rolled back if a message doesn't exist anymore
+	archive.ID = uuid.New().String()
+	archive.Name = "compact-archive-2"
+	archive.Messages = nil
+	summary.ID = uuid.New().String()
+	err = store.CompactContext(ctx, shared.DefaultProject, context.ID, []string{messages[2].ID, messages[0].ID}, summary, archive)
+	assert.ErrorContains(t, err, "were modified during the compaction")
+	exists, err := store.ContextExists(ctx, shared.DefaultProject, archive.ID)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	get, err = store.GetContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	assert.Len(t, get.Messages, 3)
+
+	err = store.DeleteContext(ctx, shared.DefaultProject, context.ID)
+	assert.NoError(t, err)
+	err = store.DeleteContext(ctx, shared.DefaultProject, getArchive.ID)
+	assert.NoError(t, err)
+}
+
+func ContextProjects(t *testing.T, store Store) {
+	ctx := context.Background()
+	contexts := []shared.Context{}
+	for _, project := range []string{"project-a", "project-b"} {
+		c := shared.Context{
+			ID:        uuid.NewString(),
+			Project:   project,
+			Name:      "same-name",
+			CreatedAt: time.Now().UTC(),
+		}
+		err := store.CreateContext(ctx, c)
+		assert.NoError(t, err)
+		contexts = append(contexts, c)
+	}
+	exists, err := store.ContextExistsByName(ctx, "project-a", "same-name")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = store.ContextExistsByName(ctx, "project-c", "same-name")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	get, err := store.GetContext(ctx, "project-b", contexts[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "project-b", get.Project)
+	_, err = store.GetContext(ctx, "project-b", contexts[0].ID)
+	assert.ErrorContains(t, err, "doesn't exist")
+	err = store.DeleteContext(ctx, "project-b", contexts[0].ID)
+	assert.NoError(t, err)
+	exists, err = store.ContextExists(ctx, "project-a", contexts[0].ID)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	list, err := store.ListContexts(ctx, "project-a")
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, contexts[0].ID, list[0].ID)
+
+	for _, c := range contexts {
+		err = store.DeleteContext(ctx, c.Project, c.ID)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,265 @@
+package storetest
+
+import (
+	"context"
+	"github.com/appclacks/maizai/pkg/shared"
+	"testing"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func DocumentCRUD(t *testing.T, store Store) {
+	ctx := context.Background()
+	doc := aggregates.Document{
+		ID:          uuid.NewString(),
+		Project:     shared.DefaultProject,
+		Name:        "doc1",
+		CreatedAt:   time.Now().UTC(),
+		Description: "desc1",
+	}
+	err := store.CreateDocument(ctx, doc)
+	assert.NoError(t, err)
+
+	retrieved, err := store.GetDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.ID, retrieved.ID)
+	assert.Equal(t, doc.Name, retrieved.Name)
+	assert.Equal(t, doc.Description, retrieved.Description)
+
+	doc2 := aggregates.Document{
+		ID:          uuid.NewString(),
+		Project:     shared.DefaultProject,
+		Name:        "doc2",
+		CreatedAt:   time.Now().UTC(),
+		Description: "desc2",
+	}
+	err = store.CreateDocument(ctx, doc2)
+	assert.NoError(t, err)
+
+	list, err := store.ListDocuments(ctx, shared.DefaultProject)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	err = store.DeleteDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+	_, err = store.GetDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.ErrorContains(t, err, "doesn't exist")
+
+	list, err = store.ListDocuments(ctx, shared.DefaultProject)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	embedding := []float32{}
+	for i := 0; i < 1024; i++ {
+		embedding = append(embedding, float32(i))
+	}
+	chunk := aggregates.DocumentChunk{
+		ID:         uuid.NewString(),
+		DocumentID: doc2.ID,
+		Fragment:   "hello world",
+		Embedding:  embedding,
+	}
+	err = store.CreateDocumentChunk(ctx, chunk)
+	assert.NoError(t, err)
+
+	chunks, err := store.ListDocumentChunksForDocument(ctx, shared.DefaultProject, doc2.ID)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, chunk.ID, chunks[0].ID)
+	assert.Equal(t, chunk.DocumentID, chunks[0].DocumentID)
+	assert.Equal(t, chunk.Fragment, chunks[0].Fragment)
+
+	err = store.DeleteDocumentChunk(ctx, shared.DefaultProject, chunk.ID)
+	assert.NoError(t, err)
+
+	chunks, err = store.ListDocumentChunksForDocument(ctx, shared.DefaultProject, doc2.ID)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 0)
+
+	_, err = store.ListDocumentChunksForDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.ErrorContains(t, err, "doesn't exist")
+}
+
+func CreateDocumentChunks(t *testing.T, store Store) {
+	ctx := context.Background()
+	doc := aggregates.Document{
+		ID:        uuid.NewString(),
+		Project:   shared.DefaultProject,
+		Name:      "chunks",
+		CreatedAt: time.Now().UTC(),
+	}
+	err := store.CreateDocument(ctx, doc)
+	assert.NoError(t, err)
+	embedding := []float32{}
+	for i := 0; i < 1024; i++ {
+		embedding = append(embedding, float32(i))
+	}
+	ordinal, err := store.NextChunkOrdinal(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ordinal)
+	_, err = store.NextChunkOrdinal(ctx, "other-project", doc.ID)
+	assert.ErrorContains(t, err, "doesn't exist")
+	chunks := []aggregates.DocumentChunk{}
+	// chunks are created in the reverse order to check the ordering
+	for _, ordinal := range []int{2, 1, 0} {
+		chunk, err := aggregates.NewDocumentChunk(doc.ID, "fragment", embedding)
+		assert.NoError(t, err)
+		chunk.Ordinal = ordinal
+		chunk.Start = ordinal * 10
+		chunk.End = ordinal*10 + 8
+		chunk.Metadata = map[string]string{"section": "intro"}
+		chunks = append(chunks, *chunk)
+	}
+	err = store.CreateDocumentChunks(ctx, chunks)
+	assert.NoError(t, err)
+
+	result, err := store.ListDocumentChunksForDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+	assert.Len(t, result, 3)
+	for i, chunk := range result {
+		assert.Equal(t, i, chunk.Ordinal)
+		assert.Equal(t, i*10, chunk.Start)
+		assert.Equal(t, i*10+8, chunk.End)
+		assert.Equal(t, map[string]string{"section": "intro"}, chunk.Metadata)
+	}
+	ordinal, err = store.NextChunkOrdinal(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, ordinal)
+
+	// nothing is stored if a chunk is invalid
+	chunk, err := aggregates.NewDocumentChunk(doc.ID, "fragment", embedding)
+	assert.NoError(t, err)
+	err = store.CreateDocumentChunks(ctx, []aggregates.DocumentChunk{*chunk, *chunk})
+	assert.Error(t, err)
+	result, err = store.ListDocumentChunksForDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+	assert.Len(t, result, 3)
+
+	err = store.DeleteDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+}
+
+func UpdateDocumentEmbedding(t *testing.T, store Store) {
+	ctx := context.Background()
+	doc := aggregates.Document{
+		ID:             uuid.NewString(),
+		Project:        shared.DefaultProject,
+		Name:           "embedding-settings",
+		CreatedAt:      time.Now().UTC(),
+		EmbeddingModel: "mistral-embed",
+	}
+	err := store.CreateDocument(ctx, doc)
+	assert.NoError(t, err)
+
+	err = store.UpdateDocumentEmbedding(ctx, shared.DefaultProject, doc.ID, "mistral", "other-model", 1024)
+	assert.ErrorContains(t, err, "already uses other embedding settings")
+	err = store.UpdateDocumentEmbedding(ctx, shared.DefaultProject, doc.ID, "mistral", "mistral-embed", 1024)
+	assert.NoError(t, err)
+	// the same settings can be recorded again
+	err = store.UpdateDocumentEmbedding(ctx, shared.DefaultProject, doc.ID, "mistral", "mistral-embed", 1024)
+	assert.NoError(t, err)
+	err = store.UpdateDocumentEmbedding(ctx, shared.DefaultProject, doc.ID, "mistral", "mistral-embed", 512)
+	assert.ErrorContains(t, err, "already uses other embedding settings")
+	err = store.UpdateDocumentEmbedding(ctx, shared.DefaultProject, uuid.NewString(), "mistral", "mistral-embed", 1024)
+	assert.ErrorContains(t, err, "doesn't exist")
+
+	result, err := store.GetDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "mistral", result.EmbeddingProvider)
+	assert.Equal(t, "mistral-embed", result.EmbeddingModel)
+	assert.Equal(t, 1024, result.Dimension)
+
+	err = store.DeleteDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+}
+
+func FindChunksByKeywords(t *testing.T, store Store) {
+	ctx := context.Background()
+	doc := aggregates.Document{
+		ID:        uuid.NewString(),
+		Project:   shared.DefaultProject,
+		Name:      "keywords",
+		CreatedAt: time.Now().UTC(),
+		Labels:    map[string]string{"team": "keywords"},
+	}
+	err := store.CreateDocument(ctx, doc)
+	assert.NoError(t, err)
+	fragments := []string{"unrelated content", "the request failed with ERR_42", "timeout: ERR_42, increase the timeout"}
+	for _, fragment := range fragments {
+		chunk, err := aggregates.NewDocumentChunk(doc.ID, fragment, []float32{1, 1})
+		assert.NoError(t, err)
+		err = store.CreateDocumentChunk(ctx, *chunk)
+		assert.NoError(t, err)
+	}
+
+	chunks, err := store.FindChunksByKeywords(ctx, aggregates.KeywordSearch{
+		Project: shared.DefaultProject,
+		Query:   "ERR_42 timeout",
+		Limit:   10,
+		Filter:  aggregates.DocumentFilter{Labels: map[string]string{"team": "keywords"}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, fragments[2], chunks[0].Fragment)
+	assert.Equal(t, fragments[1], chunks[1].Fragment)
+
+	chunks, err = store.FindChunksByKeywords(ctx, aggregates.KeywordSearch{
+		Project: shared.DefaultProject,
+		Query:   "ERR_42",
+		Limit:   10,
+		Filter:  aggregates.DocumentFilter{Names: []string{"other"}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 0)
+
+	err = store.DeleteDocument(ctx, shared.DefaultProject, doc.ID)
+	assert.NoError(t, err)
+}
+
+func DocumentProjects(t *testing.T, store Store) {
+	ctx := context.Background()
+	documents := []aggregates.Document{}
+	for _, project := range []string{"project-a", "project-b"} {
+		doc := aggregates.Document{
+			ID:        uuid.NewString(),
+			Project:   project,
+			Name:      "same-name",
+			CreatedAt: time.Now().UTC(),
+		}
+		err := store.CreateDocument(ctx, doc)
+		assert.NoError(t, err)
+		chunk, err := aggregates.NewDocumentChunk(doc.ID, "fragment", []float32{1, 1})
+		assert.NoError(t, err)
+		err = store.CreateDocumentChunk(ctx, *chunk)
+		assert.NoError(t, err)
+		err = store.UpdateDocumentEmbedding(ctx, project, doc.ID, "mistral", "mistral-embed", 2)
+		assert.NoError(t, err)
+		documents = append(documents, doc)
+	}
+
+	_, err := store.GetDocument(ctx, "project-a", documents[1].ID)
+	assert.ErrorContains(t, err, "doesn't exist")
+	list, err := store.ListDocuments(ctx, "project-b")
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, documents[1].ID, list[0].ID)
+
+	closest, err := store.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   "project-a",
+		Embedding: []float32{1, 1},
+		Provider:  "mistral",
+		Model:     "mistral-embed",
+		Limit:     10,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, closest, 1)
+	assert.Equal(t, documents[0].ID, closest[0].DocumentID)
+
+	for _, doc := range documents {
+		err = store.DeleteDocument(ctx, doc.Project, doc.ID)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,17 @@
+// Package storetest contains the tests shared by the PostgreSQL and the SQLite stores.
+// Each store calls these functions from its own tests.
+package storetest
+
+import (
+	"github.com/appclacks/maizai/pkg/auth"
+	contextmanager "github.com/appclacks/maizai/pkg/context"
+	"github.com/appclacks/maizai/pkg/rag"
+	"github.com/appclacks/maizai/pkg/usage"
+)
+
+type Store interface {
+	auth.Store
+	contextmanager.ContextStore
+	rag.Store
+	usage.Store
+}
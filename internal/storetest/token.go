@@ -0,0 +1,46 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/auth/aggregates"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TokenCRUD(t *testing.T, store Store) {
+	ctx := context.Background()
+	token := aggregates.Token{
+		ID:        uuid.NewString(),
+		Name:      "ci",
+		Scopes:    []string{aggregates.ReadScope, aggregates.ConversationScope},
+		Hash:      "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069",
+		CreatedAt: time.Now().UTC(),
+	}
+	err := store.CreateToken(ctx, token)
+	assert.NoError(t, err)
+
+	get, err := store.GetTokenByHash(ctx, token.Hash)
+	assert.NoError(t, err)
+	assert.Equal(t, token.ID, get.ID)
+	assert.Equal(t, token.Name, get.Name)
+	assert.Equal(t, token.Scopes, get.Scopes)
+
+	tokens, err := store.ListTokens(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, token.ID, tokens[0].ID)
+
+	_, err = store.GetTokenByHash(ctx, "unknown")
+	assert.ErrorContains(t, err, "token doesn't exist")
+
+	err = store.DeleteToken(ctx, token.ID)
+	assert.NoError(t, err)
+	err = store.DeleteToken(ctx, token.ID)
+	assert.ErrorContains(t, err, "doesn't exist")
+	tokens, err = store.ListTokens(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 0)
+}
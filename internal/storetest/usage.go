@@ -0,0 +1,117 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/usage/aggregates"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func UsageRecords(t *testing.T, store Store) {
+	ctx := context.Background()
+	project := "usage-" + uuid.NewString()[:8]
+	day := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	records := []aggregates.Record{
+		{
+			ID:           uuid.NewString(),
+			Project:      project,
+			Context:      "ctx-a",
+			Token:        "token-a",
+			Provider:     "anthropic",
+			Model:        "model-a",
+			InputTokens:  10,
+			OutputTokens: 20,
+			Latency:      1500 * time.Millisecond,
+			Status:       aggregates.SuccessStatus,
+			CreatedAt:    day,
+		},
+		{
+			ID:        uuid.NewString(),
+			Project:   project,
+			Context:   "ctx-b",
+			Token:     "token-b",
+			Provider:  "mistral",
+			Latency:   200 * time.Millisecond,
+			Status:    aggregates.ErrorStatus,
+			CreatedAt: day.Add(24*time.Hour + 500*time.Millisecond),
+		},
+		{
+			ID:        uuid.NewString(),
+			Project:   "other",
+			Provider:  "mistral",
+			Status:    aggregates.SuccessStatus,
+			CreatedAt: day,
+		},
+	}
+	for _, record := range records {
+		err := store.CreateUsageRecord(ctx, record)
+		assert.NoError(t, err)
+	}
+
+	result, err := store.ListUsageRecords(ctx, project, aggregates.Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.Equal(t, records[0].ID, result[0].ID)
+	assert.Equal(t, "ctx-a", result[0].Context)
+	assert.Equal(t, "token-a", result[0].Token)
+	assert.Equal(t, "anthropic", result[0].Provider)
+	assert.Equal(t, "model-a", result[0].Model)
+	assert.Equal(t, uint64(10), result[0].InputTokens)
+	assert.Equal(t, uint64(20), result[0].OutputTokens)
+	assert.Equal(t, 1500*time.Millisecond, result[0].Latency)
+	assert.Equal(t, aggregates.SuccessStatus, result[0].Status)
+	assert.True(t, day.Equal(result[0].CreatedAt))
+	assert.Equal(t, records[1].ID, result[1].ID)
+	assert.Equal(t, aggregates.ErrorStatus, result[1].Status)
+
+	cases := []struct {
+		name   string
+		filter aggregates.Filter
+		ids    []string
+	}{
+		{
+			name:   "since",
+			filter: aggregates.Filter{Since: day.Add(24 * time.Hour)},
+			ids:    []string{records[1].ID},
+		},
+		{
+			name:   "until",
+			filter: aggregates.Filter{Until: day.Add(24*time.Hour + 500*time.Millisecond)},
+			ids:    []string{records[0].ID},
+		},
+		{
+			name:   "context",
+			filter: aggregates.Filter{Context: "ctx-b"},
+			ids:    []string{records[1].ID},
+		},
+		{
+			name:   "token",
+			filter: aggregates.Filter{Token: "token-a"},
+			ids:    []string{records[0].ID},
+		},
+		{
+			name:   "model",
+			filter: aggregates.Filter{Model: "model-a", Since: day, Until: day.Add(time.Second)},
+			ids:    []string{records[0].ID},
+		},
+		{
+			name:   "no match",
+			filter: aggregates.Filter{Model: "unknown"},
+			ids:    []string{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := store.ListUsageRecords(ctx, project, c.filter)
+			assert.NoError(t, err)
+			ids := []string{}
+			for _, record := range result {
+				ids = append(ids, record.ID)
+			}
+			assert.Equal(t, c.ids, ids)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package tokens
+
+import (
+	"encoding/base64"
+	"math"
+	"regexp"
+
+	"github.com/appclacks/maizai/pkg/shared"
+)
+
+var pdfPageRegexp = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+// Estimator approximates the number of tokens used by messages without calling the provider
+type Estimator struct {
+	// CharsPerToken is the average number of characters per token
+	CharsPerToken float64
+	// ImageTokens is the cost of an image
+	ImageTokens uint64
+	// PageTokens is the cost of a PDF document page
+	PageTokens uint64
+	// MessageOverhead is the cost added to every message (role, separators...)
+	MessageOverhead uint64
+}
+
+var Default = Estimator{
+	CharsPerToken:   4,
+	ImageTokens:     1000,
+	PageTokens:      1500,
+	MessageOverhead: 4,
+}
+
+// Text returns the estimated number of tokens of a text
+func (e Estimator) Text(text string) uint64 {
+	if text == "" {
+		return 0
+	}
+	return uint64(math.Ceil(float64(len([]rune(text))) / e.CharsPerToken))
+}
+
+// pages returns the number of pages of a base64 encoded PDF document, or 1 if it can't be computed
+func pages(data string) uint64 {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return 1
+	}
+	count := len(pdfPageRegexp.FindAll(decoded, -1))
+	if count == 0 {
+		return 1
+	}
+	return uint64(count)
+}
+
+// Message returns the estimated number of tokens of a message
+func (e Estimator) Message(message shared.Message) uint64 {
+	result := e.MessageOverhead + e.Text(message.Content)
+	for _, part := range message.Parts {
+		switch part.Type {
+		case shared.ImagePart:
+			result += e.ImageTokens
+		case shared.DocumentPart:
+			if part.Data != "" {
+				result += pages(part.Data) * e.PageTokens
+			} else {
+				result += e.PageTokens
+			}
+		default:
+			result += e.Text(part.Text)
+		}
+	}
+	for _, toolCall := range message.ToolCalls {
+		result += e.Text(toolCall.Name) + e.Text(string(toolCall.Arguments))
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package tokens_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/tokens"
+	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimator(t *testing.T) {
+	estimator := tokens.Estimator{
+		CharsPerToken:   4,
+		ImageTokens:     100,
+		PageTokens:      50,
+		MessageOverhead: 3,
+	}
+	assert.Equal(t, uint64(0), estimator.Text(""))
+	assert.Equal(t, uint64(1), estimator.Text("abc"))
+	assert.Equal(t, uint64(2), estimator.Text("abcdefgh"))
+	assert.Equal(t, uint64(2), estimator.Text("héllo"))
+
+	pdf := base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 << /Type /Pages /Count 2 >> << /Type /Page >> << /Type/Page >>"))
+	message := shared.Message{
+		Role:    shared.UserRole,
+		Content: "abcdefgh",
+		Parts: []shared.ContentPart{
+			{Type: shared.TextPart, Text: "abcd"},
+			{Type: shared.ImagePart, URL: "https://example.com/cat.png"},
+			{Type: shared.DocumentPart, MediaType: shared.PDFMediaType, Data: pdf},
+			{Type: shared.DocumentPart, URL: "https://example.com/doc.pdf"},
+		},
+	}
+	// overhead + content + text part + image + 2 pages + 1 page for the remote document
+	assert.Equal(t, uint64(3+2+1+100+100+50), estimator.Message(message))
+
+	message = shared.Message{
+		Role: shared.AssistantRole,
+		ToolCalls: []shared.ToolCall{
+			{ID: "1", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+		},
+	}
+	assert.Equal(t, uint64(3+2+4), estimator.Message(message))
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/appclacks/maizai/pkg/auth/aggregates"
+	er "github.com/mcorbin/corbierror"
+)
+
+type MemoryTokenStore struct {
+	state map[string]aggregates.Token
+	lock  sync.RWMutex
+}
+
+func New() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		state: make(map[string]aggregates.Token),
+	}
+}
+
+func clone(token aggregates.Token) aggregates.Token {
+	token.Scopes = append([]string{}, token.Scopes...)
+	return token
+}
+
+func (m *MemoryTokenStore) CreateToken(ctx context.Context, token aggregates.Token) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.state[token.ID] = clone(token)
+	return nil
+}
+
+func (m *MemoryTokenStore) ListTokens(ctx context.Context) ([]aggregates.Token, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	result := []aggregates.Token{}
+	for _, token := range m.state {
+		result = append(result, clone(token))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (m *MemoryTokenStore) GetTokenByHash(ctx context.Context, hash string) (*aggregates.Token, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, token := range m.state {
+		if token.Hash == hash {
+			result := clone(token)
+			return &result, nil
+		}
+	}
+	return nil, er.New("token doesn't exist", er.NotFound, true)
+}
+
+func (m *MemoryTokenStore) DeleteToken(ctx context.Context, id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.state[id]; !ok {
+		return er.Newf("token %s doesn't exist", er.NotFound, true, id)
+	}
+	delete(m.state, id)
+	return nil
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/appclacks/maizai/internal/otelspan"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// maxResponseSize limits the size of the tool output sent back to the AI provider
+const maxResponseSize = 1 << 20
+
+type Config struct {
+	Name        string
+	Description string
+	InputSchema json.RawMessage
+	URL         string
+	Timeout     time.Duration
+	Headers     map[string]string
+}
+
+// HTTPTool calls a tool exposed over HTTP: the tool arguments are sent as the
+// JSON body of a POST request and the response body is the tool output
+type HTTPTool struct {
+	config Config
+	client *http.Client
+}
+
+func NewHTTPTool(config Config) *HTTPTool {
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &HTTPTool{
+		config: config,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(
+				http.DefaultTransport,
+				otelhttp.WithClientTrace(func(ctx context.Context) *httptrace.ClientTrace {
+					return otelhttptrace.NewClientTrace(ctx)
+				}),
+			),
+			Timeout: config.Timeout,
+		},
+	}
+}
+
+func (t *HTTPTool) Definition() aggregates.Tool {
+	return aggregates.Tool{
+		Name:        t.config.Name,
+		Description: t.config.Description,
+		InputSchema: t.config.InputSchema,
+	}
+}
+
+func (t *HTTPTool) Run(ctx context.Context, arguments json.RawMessage) (string, error) {
+	tracer := otel.Tracer("tools")
+	ctx, span := tracer.Start(ctx, "Tool call")
+	defer span.End()
+	span.SetAttributes(attribute.String("tool.name", t.config.Name))
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.URL, bytes.NewBuffer(arguments))
+	if err != nil {
+		otelspan.Error(span, err, "fail to build request")
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for k, v := range t.config.Headers {
+		request.Header.Set(k, v)
+	}
+	response, err := t.client.Do(request)
+	if err != nil {
+		otelspan.Error(span, err, "fail to send request")
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		otelspan.Error(span, err, "fail to read response")
+		return "", err
+	}
+	if response.StatusCode >= 300 {
+		err := fmt.Errorf("tool %s returned status %d: %s", t.config.Name, response.StatusCode, string(body))
+		otelspan.Error(span, err, "tool error")
+		return "", err
+	}
+	span.SetStatus(codes.Ok, "success")
+	return string(body), nil
+}
@@ -0,0 +1,47 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPTool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer foo", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		if string(body) == `{"city":"Paris"}` {
+			_, err = w.Write([]byte("sunny"))
+			assert.NoError(t, err)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, err = w.Write([]byte("unknown city"))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	tool := tools.NewHTTPTool(tools.Config{
+		Name:        "weather",
+		InputSchema: json.RawMessage(`{"type":"object"}`),
+		URL:         server.URL,
+		Headers:     map[string]string{"Authorization": "Bearer foo"},
+	})
+	assert.Equal(t, "weather", tool.Definition().Name)
+
+	output, err := tool.Run(context.Background(), json.RawMessage(`{"city":"Paris"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "sunny", output)
+
+	_, err = tool.Run(context.Background(), json.RawMessage(`{"city":"Atlantis"}`))
+	assert.ErrorContains(t, err, "tool weather returned status 400: unknown city")
+}
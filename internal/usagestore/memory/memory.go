@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/appclacks/maizai/pkg/usage/aggregates"
+)
+
+type MemoryUsageStore struct {
+	records []aggregates.Record
+	lock    sync.RWMutex
+}
+
+func New() *MemoryUsageStore {
+	return &MemoryUsageStore{}
+}
+
+func (m *MemoryUsageStore) CreateUsageRecord(ctx context.Context, record aggregates.Record) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.records = append(m.records, record)
+	return nil
+}
+
+func (m *MemoryUsageStore) ListUsageRecords(ctx context.Context, project string, filter aggregates.Filter) ([]aggregates.Record, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	result := []aggregates.Record{}
+	for _, record := range m.records {
+		if record.Project == project && filter.Match(record) {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,65 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+)
+
+// L2Distance returns the euclidean distance between two embeddings, like the pgvector <-> operator
+func L2Distance(a []float32, b []float32) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("different vector dimensions %d and %d", len(a), len(b))
+	}
+	sum := 0.0
+	for i := range a {
+		diff := float64(a[i]) - float64(b[i])
+		sum += diff * diff
+	}
+	return math.Sqrt(sum), nil
+}
+
+// CosineDistance returns the cosine distance between two embeddings, like the pgvector <=> operator
+func CosineDistance(a []float32, b []float32) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("different vector dimensions %d and %d", len(a), len(b))
+	}
+	dot := 0.0
+	normA := 0.0
+	normB := 0.0
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return math.NaN(), nil
+	}
+	return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB)), nil
+}
+
+// NegativeInnerProduct returns the negative inner product of two embeddings, like the pgvector <#> operator
+func NegativeInnerProduct(a []float32, b []float32) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("different vector dimensions %d and %d", len(a), len(b))
+	}
+	dot := 0.0
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return -dot, nil
+}
+
+// Distance returns the distance between two embeddings for the metric (l2, cosine or inner-product)
+func Distance(metric string, a []float32, b []float32) (float64, error) {
+	switch metric {
+	case aggregates.Cosine:
+		return CosineDistance(a, b)
+	case aggregates.InnerProduct:
+		return NegativeInnerProduct(a, b)
+	case aggregates.L2, "":
+		return L2Distance(a, b)
+	}
+	return 0, fmt.Errorf("unsupported metric %s", metric)
+}
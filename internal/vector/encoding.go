@@ -0,0 +1,28 @@
+package vector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Encode serializes an embedding as little-endian float32 values
+func Encode(embedding []float32) []byte {
+	result := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(result[i*4:], math.Float32bits(v))
+	}
+	return result
+}
+
+// Decode deserializes an embedding created by Encode
+func Decode(data []byte) ([]float32, error) {
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("invalid embedding size %d", len(data))
+	}
+	result := make([]float32, len(data)/4)
+	for i := range result {
+		result[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return result, nil
+}
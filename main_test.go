@@ -5,6 +5,7 @@ package main_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -20,12 +21,18 @@ import (
 	mhttp "github.com/appclacks/maizai/internal/http"
 	"github.com/appclacks/maizai/internal/http/client"
 	"github.com/appclacks/maizai/internal/http/handlers"
+	"github.com/appclacks/maizai/internal/metrics"
+	"github.com/appclacks/maizai/internal/sqlite"
 	aimock "github.com/appclacks/maizai/mocks/github.com/appclacks/maizai/pkg/rag"
 	"github.com/appclacks/maizai/pkg/assistant"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	"github.com/appclacks/maizai/pkg/auth"
 	ct "github.com/appclacks/maizai/pkg/context"
+	"github.com/appclacks/maizai/pkg/limits"
 	"github.com/appclacks/maizai/pkg/rag"
 	ragdata "github.com/appclacks/maizai/pkg/rag/aggregates"
 	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/appclacks/maizai/pkg/usage"
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
@@ -42,12 +49,20 @@ type testCase struct {
 	expectedBody string
 	status       int
 	callback     func(t *testing.T, response []byte) error
+	// token is sent in the Authorization header instead of the admin token
+	token   string
+	tokenFn func() string
 }
 
+const adminToken = "integration-admin-token"
+
 var listResponse client.ListContextOutput
 var listDocumentsResponse client.ListDocumentsOutput
 var contextResponse shared.Context
 var ListchunksResponse client.ListDocumentChunksOutput
+var readToken client.CreateTokenOutput
+var projectToken client.CreateTokenOutput
+var projectContexts client.ListContextOutput
 
 func sortMeta(i, j int) bool {
 	res := strings.Compare(listResponse.Contexts[i].Name, listResponse.Contexts[j].Name)
@@ -298,6 +313,31 @@ var cases = []testCase{
 		expectedBody: "message deleted",
 		status:       200,
 	},
+	{
+		name: "delete a deleted context message",
+		pathFn: func() string {
+			return fmt.Sprintf("/api/v1/message/%s", contextResponse.Messages[1].ID)
+		},
+		method:       http.MethodDelete,
+		expectedBody: "doesn't exist",
+		status:       404,
+	},
+	{
+		name:         "update an unknown context message",
+		path:         "/api/v1/message/4b0c5a46-2c7e-4c4f-9a8e-3f0d5f6b1a2c",
+		method:       http.MethodPut,
+		expectedBody: "doesn't exist",
+		body:         `{"role":"assistant","content":"updated"}`,
+		status:       404,
+	},
+	{
+		name:         "pin an unknown context message",
+		path:         "/api/v1/message/4b0c5a46-2c7e-4c4f-9a8e-3f0d5f6b1a2c/pin",
+		method:       http.MethodPut,
+		expectedBody: "doesn't exist",
+		body:         `{"pinned":true}`,
+		status:       404,
+	},
 	{
 		name: "get context after messages addition",
 		pathFn: func() string {
@@ -330,11 +370,19 @@ var cases = []testCase{
 		path:   "/api/v1/document",
 		method: http.MethodPost,
 		bodyFn: func() string {
-			return `{"name":"doc1","description":"desc1"}`
+			return `{"name":"doc1","description":"desc1","labels":{"product":"maizai"},"source":"https://example.com/doc1"}`
 		},
 		expectedBody: "document created",
 		status:       200,
 	},
+	{
+		name:         "create document with an invalid source",
+		path:         "/api/v1/document",
+		method:       http.MethodPost,
+		body:         `{"name":"invalid","source":"not an uri"}`,
+		expectedBody: "Invalid source not an uri",
+		status:       400,
+	},
 	{
 		name:   "list documents",
 		path:   "/api/v1/document",
@@ -369,6 +417,7 @@ var cases = []testCase{
 			assert.NoError(t, uuid.Validate(doc.ID))
 			assert.Equal(t, doc.Name, "doc1")
 			assert.Equal(t, doc.Description, "desc1")
+			assert.Equal(t, "https://example.com/doc1", doc.Source)
 			assert.NotZero(t, doc.CreatedAt)
 			return nil
 		},
@@ -393,6 +442,36 @@ var cases = []testCase{
 		expectedBody: "document chunk created",
 		status:       200,
 	},
+	{
+		name: "get document embedding settings",
+		pathFn: func() string {
+			return fmt.Sprintf("/api/v1/document/%s", listDocumentsResponse.Documents[0].ID)
+		},
+		method: http.MethodGet,
+		status: 200,
+		callback: func(t *testing.T, response []byte) error {
+			t.Helper()
+			var doc client.Document
+			if err := json.Unmarshal(response, &doc); err != nil {
+				return err
+			}
+			assert.Equal(t, "mistral", doc.EmbeddingProvider)
+			assert.Equal(t, "mistral-embed", doc.EmbeddingModel)
+			assert.Equal(t, 1024, doc.Dimension)
+			assert.Equal(t, map[string]string{"product": "maizai"}, doc.Labels)
+			return nil
+		},
+	},
+	{
+		name: "Embed document with another model",
+		pathFn: func() string {
+			return fmt.Sprintf("/api/v1/document/%s", listDocumentsResponse.Documents[0].ID)
+		},
+		body:         `{"provider":"mistral","model":"other-model","input":"trololo"}`,
+		method:       http.MethodPost,
+		expectedBody: "can't store embeddings from the model other-model",
+		status:       400,
+	},
 	{
 		name: "List document chunks",
 		pathFn: func() string {
@@ -408,9 +487,212 @@ var cases = []testCase{
 			assert.Len(t, ListchunksResponse.Chunks, 2)
 			assert.Equal(t, ListchunksResponse.Chunks[0].DocumentID, listDocumentsResponse.Documents[0].ID)
 			assert.Equal(t, ListchunksResponse.Chunks[0].Fragment, "trololo")
+			assert.Equal(t, 0, ListchunksResponse.Chunks[0].Ordinal)
+			assert.Equal(t, 1, ListchunksResponse.Chunks[1].Ordinal)
+			return nil
+		},
+	},
+	{
+		name: "Ingest document",
+		pathFn: func() string {
+			return fmt.Sprintf("/api/v1/document/%s/ingest", listDocumentsResponse.Documents[0].ID)
+		},
+		body:   `{"provider":"mistral","model":"mistral-embed","strategy":"paragraph","size":20,"content":"first paragraph\n\nsecond paragraph","metadata":{"section":"intro"}}`,
+		method: http.MethodPost,
+		status: 200,
+		callback: func(t *testing.T, response []byte) error {
+			t.Helper()
+			var ingested client.ListDocumentChunksOutput
+			if err := json.Unmarshal(response, &ingested); err != nil {
+				return err
+			}
+			assert.Len(t, ingested.Chunks, 2)
+			assert.Equal(t, "first paragraph", ingested.Chunks[0].Fragment)
+			// the two embedded chunks have the ordinals 0 and 1
+			assert.Equal(t, 2, ingested.Chunks[0].Ordinal)
+			assert.Equal(t, 0, ingested.Chunks[0].Start)
+			assert.Equal(t, 15, ingested.Chunks[0].End)
+			assert.Equal(t, "second paragraph", ingested.Chunks[1].Fragment)
+			assert.Equal(t, 3, ingested.Chunks[1].Ordinal)
+			assert.Equal(t, 17, ingested.Chunks[1].Start)
+			assert.Equal(t, map[string]string{"section": "intro"}, ingested.Chunks[1].Metadata)
+			return nil
+		},
+	},
+	{
+		name: "List document chunks by metadata",
+		pathFn: func() string {
+			return fmt.Sprintf("/api/v1/document/%s/chunks?metadata=section%%3Dintro", listDocumentsResponse.Documents[0].ID)
+		},
+		method: http.MethodGet,
+		status: 200,
+		callback: func(t *testing.T, response []byte) error {
+			t.Helper()
+			var chunks client.ListDocumentChunksOutput
+			if err := json.Unmarshal(response, &chunks); err != nil {
+				return err
+			}
+			assert.Len(t, chunks.Chunks, 2)
+			assert.Equal(t, "first paragraph", chunks.Chunks[0].Fragment)
+			return nil
+		},
+	},
+	{
+		name:         "list documents by source",
+		path:         "/api/v1/document?source=https%3A%2F%2Fexample.com%2Fdoc1",
+		method:       http.MethodGet,
+		expectedBody: `"name":"doc1"`,
+		status:       200,
+	},
+	{
+		name:         "list documents by unknown label",
+		path:         "/api/v1/document?labels=product%3Dother",
+		method:       http.MethodGet,
+		expectedBody: `{"documents":[]}`,
+		status:       200,
+	},
+	{
+		name:         "list documents with invalid labels",
+		path:         "/api/v1/document?labels=product",
+		method:       http.MethodGet,
+		expectedBody: "Invalid labels parameter product",
+		status:       400,
+	},
+	{
+		name: "Ingest document with an invalid strategy",
+		pathFn: func() string {
+			return fmt.Sprintf("/api/v1/document/%s/ingest", listDocumentsResponse.Documents[0].ID)
+		},
+		body:         `{"provider":"mistral","strategy":"unknown","content":"content"}`,
+		method:       http.MethodPost,
+		expectedBody: "Invalid chunking strategy unknown",
+		status:       400,
+	},
+	{
+		name:   "search documents by label",
+		path:   "/api/v1/document-chunk",
+		body:   `{"provider":"mistral","model":"mistral-embed","input":"trololo","limit":2,"labels":{"product":"maizai"}}`,
+		method: http.MethodPut,
+		status: 200,
+		callback: func(t *testing.T, response []byte) error {
+			t.Helper()
+			var chunks client.ListDocumentChunksOutput
+			if err := json.Unmarshal(response, &chunks); err != nil {
+				return err
+			}
+			assert.Len(t, chunks.Chunks, 2)
+			assert.NotNil(t, chunks.Chunks[0].Score)
+			assert.Equal(t, listDocumentsResponse.Documents[0].ID, chunks.Chunks[0].DocumentID)
+			return nil
+		},
+	},
+	{
+		name:   "search chunks by metadata",
+		path:   "/api/v1/document-chunk",
+		body:   `{"provider":"mistral","model":"mistral-embed","input":"trololo","limit":5,"source":"https://example.com/doc1","metadata":{"section":"intro"}}`,
+		method: http.MethodPut,
+		status: 200,
+		callback: func(t *testing.T, response []byte) error {
+			t.Helper()
+			var chunks client.ListDocumentChunksOutput
+			if err := json.Unmarshal(response, &chunks); err != nil {
+				return err
+			}
+			assert.Len(t, chunks.Chunks, 2)
+			for _, chunk := range chunks.Chunks {
+				assert.Equal(t, "intro", chunk.Metadata["section"])
+			}
+			return nil
+		},
+	},
+	{
+		name:         "search documents by unknown label",
+		path:         "/api/v1/document-chunk",
+		body:         `{"provider":"mistral","model":"mistral-embed","input":"trololo","limit":2,"labels":{"product":"other"}}`,
+		method:       http.MethodPut,
+		expectedBody: `{"chunks":[]}`,
+		status:       200,
+	},
+	{
+		name:   "hybrid search",
+		path:   "/api/v1/document-chunk",
+		body:   `{"provider":"mistral","model":"mistral-embed","input":"trololo","limit":1,"mode":"hybrid"}`,
+		method: http.MethodPut,
+		status: 200,
+		callback: func(t *testing.T, response []byte) error {
+			t.Helper()
+			var chunks client.ListDocumentChunksOutput
+			if err := json.Unmarshal(response, &chunks); err != nil {
+				return err
+			}
+			assert.Len(t, chunks.Chunks, 1)
+			assert.Equal(t, "trololo", chunks.Chunks[0].Fragment)
 			return nil
 		},
 	},
+	{
+		name:         "search with a negative limit",
+		path:         "/api/v1/document-chunk",
+		body:         `{"provider":"mistral","input":"trololo","limit":-1}`,
+		method:       http.MethodPut,
+		expectedBody: "Invalid limit",
+		status:       400,
+	},
+	{
+		name:         "search with an invalid mode",
+		path:         "/api/v1/document-chunk",
+		body:         `{"provider":"mistral","input":"trololo","limit":1,"mode":"keyword"}`,
+		method:       http.MethodPut,
+		expectedBody: "Invalid search mode keyword",
+		status:       400,
+	},
+	{
+		name:         "search with an unknown reranker",
+		path:         "/api/v1/document-chunk",
+		body:         `{"provider":"mistral","input":"trololo","limit":1,"rerank":"unknown"}`,
+		method:       http.MethodPut,
+		expectedBody: "Reranker unknown is not configured",
+		status:       400,
+	},
+	{
+		name:         "search with less candidates than the limit",
+		path:         "/api/v1/document-chunk",
+		body:         `{"provider":"mistral","input":"trololo","limit":3,"candidates":2}`,
+		method:       http.MethodPut,
+		expectedBody: "Invalid candidates",
+		status:       400,
+	},
+	{
+		name:         "search with an invalid metric",
+		path:         "/api/v1/document-chunk",
+		body:         `{"provider":"mistral","input":"trololo","limit":1,"metric":"manhattan"}`,
+		method:       http.MethodPut,
+		expectedBody: "Invalid metric manhattan",
+		status:       400,
+	},
+	{
+		name:         "search with an invalid document ID",
+		path:         "/api/v1/document-chunk",
+		body:         `{"provider":"mistral","input":"trololo","limit":1,"document-ids":["invalid"]}`,
+		method:       http.MethodPut,
+		expectedBody: "invalid document ID",
+		status:       400,
+	},
+	{
+		name:         "delete vector index with an invalid name",
+		path:         "/api/v1/vector-index/document_chunk_pkey",
+		method:       http.MethodDelete,
+		expectedBody: "Invalid vector index name document_chunk_pkey",
+		status:       400,
+	},
+	{
+		name:         "create vector index with an invalid type",
+		path:         "/api/v1/vector-index",
+		body:         `{"type":"flat","dimension":1024}`,
+		method:       http.MethodPost,
+		expectedBody: "Invalid index type flat",
+		status:       400,
+	},
 	{
 		name: "delete document chunk",
 		pathFn: func() string {
@@ -454,6 +736,253 @@ var cases = []testCase{
 		expectedBody: "[]",
 		status:       200,
 	},
+	{
+		name:         "create read token",
+		path:         "/api/v1/token",
+		method:       http.MethodPost,
+		body:         `{"name": "reader", "scopes": ["read"]}`,
+		expectedBody: "maizai_",
+		status:       200,
+		callback: func(t *testing.T, response []byte) error {
+			return json.Unmarshal(response, &readToken)
+		},
+	},
+	{
+		name:         "create token with an invalid scope",
+		path:         "/api/v1/token",
+		method:       http.MethodPost,
+		body:         `{"name": "invalid", "scopes": ["superuser"]}`,
+		expectedBody: "Invalid scope superuser",
+		status:       400,
+	},
+	{
+		name:   "list tokens",
+		path:   "/api/v1/token",
+		method: http.MethodGet,
+		status: 200,
+		callback: func(t *testing.T, response []byte) error {
+			var result client.ListTokensOutput
+			err := json.Unmarshal(response, &result)
+			if err != nil {
+				return err
+			}
+			if len(result.Tokens) != 1 || result.Tokens[0].Name != "reader" {
+				return fmt.Errorf("invalid tokens %+v", result.Tokens)
+			}
+			if strings.Contains(string(response), readToken.Value) {
+				return errors.New("the token value should not be listed")
+			}
+			return nil
+		},
+	},
+	{
+		name:   "list contexts with the read token",
+		path:   "/api/v1/context",
+		method: http.MethodGet,
+		tokenFn: func() string {
+			return readToken.Value
+		},
+		status: 200,
+	},
+	{
+		name:   "create context with the read token",
+		path:   "/api/v1/context",
+		method: http.MethodPost,
+		body:   `{"name": "forbidden"}`,
+		tokenFn: func() string {
+			return readToken.Value
+		},
+		expectedBody: "Token reader doesn't have the conversation scope",
+		status:       403,
+	},
+	{
+		name:         "list tokens with the read token",
+		path:         "/api/v1/token",
+		method:       http.MethodGet,
+		tokenFn:      func() string { return readToken.Value },
+		expectedBody: "doesn't have the admin scope",
+		status:       403,
+	},
+	{
+		name:         "list contexts with an invalid token",
+		path:         "/api/v1/context",
+		method:       http.MethodGet,
+		token:        "invalid",
+		expectedBody: "Invalid token",
+		status:       401,
+	},
+	{
+		name: "revoke read token",
+		pathFn: func() string {
+			return fmt.Sprintf("/api/v1/token/%s", readToken.ID)
+		},
+		method:       http.MethodDelete,
+		expectedBody: "token revoked",
+		status:       200,
+	},
+	{
+		name:         "list contexts with a revoked token",
+		path:         "/api/v1/context",
+		method:       http.MethodGet,
+		tokenFn:      func() string { return readToken.Value },
+		expectedBody: "Invalid token",
+		status:       401,
+	},
+	{
+		name: "revoke unknown token",
+		pathFn: func() string {
+			return fmt.Sprintf("/api/v1/token/%s", uuid.NewString())
+		},
+		method:       http.MethodDelete,
+		expectedBody: "doesn't exist",
+		status:       404,
+	},
+	{
+		name:         "create context in a project",
+		path:         "/api/v1/projects/team-a/context",
+		method:       http.MethodPost,
+		body:         `{"name":"team-context"}`,
+		expectedBody: "context created",
+		status:       200,
+	},
+	{
+		name:         "create context with an invalid project",
+		path:         "/api/v1/projects/Team_A/context",
+		method:       http.MethodPost,
+		body:         `{"name":"team-context"}`,
+		expectedBody: "Invalid project Team_A",
+		status:       400,
+	},
+	{
+		name:         "list contexts in a project",
+		path:         "/api/v1/projects/team-a/context",
+		method:       http.MethodGet,
+		expectedBody: "team-context",
+		status:       200,
+		callback: func(t *testing.T, response []byte) error {
+			return json.Unmarshal(response, &projectContexts)
+		},
+	},
+	{
+		name:         "list contexts in the default project",
+		path:         "/api/v1/context",
+		method:       http.MethodGet,
+		expectedBody: "[]",
+		status:       200,
+	},
+	{
+		name: "get project context from the default project",
+		pathFn: func() string {
+			return fmt.Sprintf("/api/v1/context/%s", projectContexts.Contexts[0].ID)
+		},
+		method:       http.MethodGet,
+		expectedBody: "doesn't exist",
+		status:       404,
+	},
+	{
+		name:         "create project token",
+		path:         "/api/v1/token",
+		method:       http.MethodPost,
+		body:         `{"name": "team-a", "project": "team-a", "scopes": ["read"]}`,
+		expectedBody: "maizai_",
+		status:       200,
+		callback: func(t *testing.T, response []byte) error {
+			return json.Unmarshal(response, &projectToken)
+		},
+	},
+	{
+		name:         "list contexts with the project token",
+		path:         "/api/v1/context",
+		method:       http.MethodGet,
+		tokenFn:      func() string { return projectToken.Value },
+		expectedBody: "team-context",
+		status:       200,
+	},
+	{
+		name:         "list contexts of another project with the project token",
+		path:         "/api/v1/projects/team-b/context",
+		method:       http.MethodGet,
+		tokenFn:      func() string { return projectToken.Value },
+		expectedBody: "Token team-a can't access the project team-b",
+		status:       403,
+	},
+	{
+		name:         "list vector indexes with the project token",
+		path:         "/api/v1/vector-index",
+		method:       http.MethodGet,
+		tokenFn:      func() string { return projectToken.Value },
+		expectedBody: "Token team-a is restricted to the project team-a",
+		status:       403,
+	},
+	{
+		name: "delete project context",
+		pathFn: func() string {
+			return fmt.Sprintf("/api/v1/projects/team-a/context/%s", projectContexts.Contexts[0].ID)
+		},
+		method:       http.MethodDelete,
+		expectedBody: "context deleted",
+		status:       200,
+	},
+	{
+		name:         "get limits",
+		path:         "/api/v1/limits",
+		method:       http.MethodGet,
+		expectedBody: `"tenant":"token:admin","requests":`,
+		status:       200,
+		callback: func(t *testing.T, response []byte) error {
+			var result client.LimitsOutput
+			err := json.Unmarshal(response, &result)
+			if err != nil {
+				return err
+			}
+			if result.RequestsPerMinute != 1000 || result.Requests == 0 {
+				return fmt.Errorf("invalid limits %+v", result)
+			}
+			return nil
+		},
+	},
+	{
+		name:         "get limits with the project token",
+		path:         "/api/v1/limits",
+		method:       http.MethodGet,
+		tokenFn:      func() string { return projectToken.Value },
+		expectedBody: `"requests":2,`,
+		status:       200,
+	},
+	{
+		name:         "usage report",
+		path:         "/api/v1/usage?group-by=day,model&since=2026-01-01",
+		method:       http.MethodGet,
+		expectedBody: `"provider":"mistral","model":"mistral-embed","requests":7,"errors":0,"input-tokens":60,`,
+		status:       200,
+	},
+	{
+		name:         "usage report with an invalid group by",
+		path:         "/api/v1/usage?group-by=week",
+		method:       http.MethodGet,
+		expectedBody: "Invalid group by week",
+		status:       400,
+	},
+	{
+		name:         "usage report with an invalid date",
+		path:         "/api/v1/usage?since=yesterday",
+		method:       http.MethodGet,
+		expectedBody: "Invalid since parameter yesterday",
+		status:       400,
+	},
+	{
+		name:         "rag metrics",
+		path:         "/metrics",
+		method:       http.MethodGet,
+		expectedBody: `rag_match_duration_seconds_count{mode="vector"}`,
+		status:       200,
+		callback: func(t *testing.T, response []byte) error {
+			if !strings.Contains(string(response), `embedding_requests_total{model=`) {
+				return errors.New("embedding requests metric not found")
+			}
+			return nil
+		},
+	},
 }
 
 func httpTest(t *testing.T, client *http.Client, c testCase) error {
@@ -476,6 +1005,14 @@ func httpTest(t *testing.T, client *http.Client, c testCase) error {
 		return err
 	}
 	req.Header.Add("Content-Type", "application/json")
+	token := adminToken
+	if c.token != "" {
+		token = c.token
+	}
+	if c.tokenFn != nil {
+		token = c.tokenFn()
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -512,6 +1049,16 @@ func Cleanup(c *database.Database) error {
 	return nil
 }
 
+func CleanupSQLite(c *sqlite.Database) error {
+	for _, query := range sqlite.CleanupQueries {
+		_, err := c.Exec(query)
+		if err != nil {
+			return fmt.Errorf("fail to clean DB on query %s: %w", query, err)
+		}
+	}
+	return nil
+}
+
 func TestIntegration(t *testing.T) {
 	aiMock := aimock.NewMockAI(t)
 	os.Setenv("MAIZAI_ANTHROPIC_API_KEY", "random_api_key")
@@ -530,26 +1077,58 @@ func TestIntegration(t *testing.T) {
 				},
 			},
 		}, nil)
+	aiMock.EXPECT().BatchEmbedding(mock.Anything, mock.Anything).RunAndReturn(
+		func(ctx context.Context, query ragdata.BatchEmbeddingQuery) (*ragdata.EmbeddingAnswer, error) {
+			answer := &ragdata.EmbeddingAnswer{}
+			for range query.Inputs {
+				answer.Data = append(answer.Data, ragdata.Embedding{Embedding: embedding})
+			}
+			return answer, nil
+		})
 
 	registry := prometheus.NewRegistry()
 	config, err := config.Load()
 	assert.NoError(t, err)
-	db, err := database.New(config.Store.PostgreSQL)
-	assert.NoError(t, err)
-	err = Cleanup(db)
+	contextStore, ragStore, tokenStore, usageStore, err := cmd.BuildStores(config.Store)
 	assert.NoError(t, err)
+	if db, ok := contextStore.(*database.Database); ok {
+		err = Cleanup(db)
+		assert.NoError(t, err)
+	}
+	if db, ok := contextStore.(*sqlite.Database); ok {
+		err = CleanupSQLite(db)
+		assert.NoError(t, err)
+	}
 	clients, err := cmd.BuildProviders(config.Providers)
 	assert.NoError(t, err)
-	manager := ct.New(db)
+	manager := ct.New(contextStore)
 	assert.NoError(t, err)
 	embeddingClients := map[string]rag.AI{}
 	embeddingClients["mistral"] = aiMock
 
-	rag := rag.New(db, embeddingClients)
-	ai := assistant.New(clients, manager, rag)
+	metrics, err := metrics.New(registry)
+	assert.NoError(t, err)
+	usageManager := usage.New(usageStore, usage.Config{})
+	limiter := limits.New(limits.Config{RequestsPerMinute: 1000})
+	rag := rag.New(ragStore, embeddingClients, rag.Config{Metrics: metrics, Usage: usageManager})
+	ai := assistant.New(clients, manager, rag, cmd.BuildTools(config.Tools), usage.Recorders{usageManager, limiter}, assistant.Config{
+		MaxToolIterations: config.Tools.MaxIterations,
+		MaxSourcesDepth:   config.Contexts.SourcesMaxDepth,
+		Compaction: assistant.CompactionConfig{
+			Threshold: config.Contexts.Compaction.Threshold,
+			Options: aggregates.CompactOptions{
+				Provider:  config.Contexts.Compaction.Provider,
+				Model:     config.Contexts.Compaction.Model,
+				MaxTokens: config.Contexts.Compaction.MaxTokens,
+				KeepLast:  config.Contexts.Compaction.KeepLast,
+			},
+		},
+		Metrics: metrics,
+	})
 
-	handlersBuilder := handlers.NewBuilder(ai, manager, rag)
-	server, err := mhttp.New(config.HTTP, registry, handlersBuilder)
+	tokens := auth.New(tokenStore, auth.Config{AdminToken: adminToken})
+	handlersBuilder := handlers.NewBuilder(ai, manager, rag, tokens, limiter, usageManager)
+	server, err := mhttp.New(config.HTTP, registry, handlersBuilder, tokens, limiter)
 	assert.NoError(t, err)
 
 	go func() {
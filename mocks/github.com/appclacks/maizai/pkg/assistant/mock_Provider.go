@@ -25,6 +25,51 @@ func (_m *MockProvider) EXPECT() *MockProvider_Expecter {
 	return &MockProvider_Expecter{mock: &_m.Mock}
 }
 
+// DefaultModel provides a mock function with no fields
+func (_m *MockProvider) DefaultModel() string {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for DefaultModel")
+	}
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// MockProvider_DefaultModel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DefaultModel'
+type MockProvider_DefaultModel_Call struct {
+	*mock.Call
+}
+
+// DefaultModel is a helper method to define mock.On call
+func (_e *MockProvider_Expecter) DefaultModel() *MockProvider_DefaultModel_Call {
+	return &MockProvider_DefaultModel_Call{Call: _e.mock.On("DefaultModel")}
+}
+
+func (_c *MockProvider_DefaultModel_Call) Run(run func()) *MockProvider_DefaultModel_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockProvider_DefaultModel_Call) Return(_a0 string) *MockProvider_DefaultModel_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockProvider_DefaultModel_Call) RunAndReturn(run func() string) *MockProvider_DefaultModel_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Query provides a mock function with given fields: ctx, messages, options
 func (_m *MockProvider) Query(ctx context.Context, messages []shared.Message, options aggregates.QueryOptions) (*aggregates.Answer, error) {
 	ret := _m.Called(ctx, messages, options)
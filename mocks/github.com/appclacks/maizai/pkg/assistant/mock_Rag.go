@@ -23,9 +23,9 @@ func (_m *MockRag) EXPECT() *MockRag_Expecter {
 	return &MockRag_Expecter{mock: &_m.Mock}
 }
 
-// Match provides a mock function with given fields: ctx, query
-func (_m *MockRag) Match(ctx context.Context, query aggregates.SearchQuery) ([]aggregates.DocumentChunk, error) {
-	ret := _m.Called(ctx, query)
+// Match provides a mock function with given fields: ctx, project, query
+func (_m *MockRag) Match(ctx context.Context, project string, query aggregates.SearchQuery) ([]aggregates.DocumentChunk, error) {
+	ret := _m.Called(ctx, project, query)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Match")
@@ -33,19 +33,19 @@ func (_m *MockRag) Match(ctx context.Context, query aggregates.SearchQuery) ([]a
 
 	var r0 []aggregates.DocumentChunk
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, aggregates.SearchQuery) ([]aggregates.DocumentChunk, error)); ok {
-		return rf(ctx, query)
+	if rf, ok := ret.Get(0).(func(context.Context, string, aggregates.SearchQuery) ([]aggregates.DocumentChunk, error)); ok {
+		return rf(ctx, project, query)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, aggregates.SearchQuery) []aggregates.DocumentChunk); ok {
-		r0 = rf(ctx, query)
+	if rf, ok := ret.Get(0).(func(context.Context, string, aggregates.SearchQuery) []aggregates.DocumentChunk); ok {
+		r0 = rf(ctx, project, query)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]aggregates.DocumentChunk)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, aggregates.SearchQuery) error); ok {
-		r1 = rf(ctx, query)
+	if rf, ok := ret.Get(1).(func(context.Context, string, aggregates.SearchQuery) error); ok {
+		r1 = rf(ctx, project, query)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -60,14 +60,15 @@ type MockRag_Match_Call struct {
 
 // Match is a helper method to define mock.On call
 //   - ctx context.Context
+//   - project string
 //   - query aggregates.SearchQuery
-func (_e *MockRag_Expecter) Match(ctx interface{}, query interface{}) *MockRag_Match_Call {
-	return &MockRag_Match_Call{Call: _e.mock.On("Match", ctx, query)}
+func (_e *MockRag_Expecter) Match(ctx interface{}, project interface{}, query interface{}) *MockRag_Match_Call {
+	return &MockRag_Match_Call{Call: _e.mock.On("Match", ctx, project, query)}
 }
 
-func (_c *MockRag_Match_Call) Run(run func(ctx context.Context, query aggregates.SearchQuery)) *MockRag_Match_Call {
+func (_c *MockRag_Match_Call) Run(run func(ctx context.Context, project string, query aggregates.SearchQuery)) *MockRag_Match_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(aggregates.SearchQuery))
+		run(args[0].(context.Context), args[1].(string), args[2].(aggregates.SearchQuery))
 	})
 	return _c
 }
@@ -77,7 +78,7 @@ func (_c *MockRag_Match_Call) Return(_a0 []aggregates.DocumentChunk, _a1 error)
 	return _c
 }
 
-func (_c *MockRag_Match_Call) RunAndReturn(run func(context.Context, aggregates.SearchQuery) ([]aggregates.DocumentChunk, error)) *MockRag_Match_Call {
+func (_c *MockRag_Match_Call) RunAndReturn(run func(context.Context, string, aggregates.SearchQuery) ([]aggregates.DocumentChunk, error)) *MockRag_Match_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -0,0 +1,142 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package assistant
+
+import (
+	aggregates "github.com/appclacks/maizai/pkg/assistant/aggregates"
+
+	context "context"
+
+	jsontext "encoding/json/jsontext"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockToolRunner is an autogenerated mock type for the ToolRunner type
+type MockToolRunner struct {
+	mock.Mock
+}
+
+type MockToolRunner_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockToolRunner) EXPECT() *MockToolRunner_Expecter {
+	return &MockToolRunner_Expecter{mock: &_m.Mock}
+}
+
+// Definition provides a mock function with no fields
+func (_m *MockToolRunner) Definition() aggregates.Tool {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Definition")
+	}
+
+	var r0 aggregates.Tool
+	if rf, ok := ret.Get(0).(func() aggregates.Tool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(aggregates.Tool)
+	}
+
+	return r0
+}
+
+// MockToolRunner_Definition_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Definition'
+type MockToolRunner_Definition_Call struct {
+	*mock.Call
+}
+
+// Definition is a helper method to define mock.On call
+func (_e *MockToolRunner_Expecter) Definition() *MockToolRunner_Definition_Call {
+	return &MockToolRunner_Definition_Call{Call: _e.mock.On("Definition")}
+}
+
+func (_c *MockToolRunner_Definition_Call) Run(run func()) *MockToolRunner_Definition_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockToolRunner_Definition_Call) Return(_a0 aggregates.Tool) *MockToolRunner_Definition_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockToolRunner_Definition_Call) RunAndReturn(run func() aggregates.Tool) *MockToolRunner_Definition_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Run provides a mock function with given fields: ctx, arguments
+func (_m *MockToolRunner) Run(ctx context.Context, arguments jsontext.Value) (string, error) {
+	ret := _m.Called(ctx, arguments)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Run")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, jsontext.Value) (string, error)); ok {
+		return rf(ctx, arguments)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, jsontext.Value) string); ok {
+		r0 = rf(ctx, arguments)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, jsontext.Value) error); ok {
+		r1 = rf(ctx, arguments)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockToolRunner_Run_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Run'
+type MockToolRunner_Run_Call struct {
+	*mock.Call
+}
+
+// Run is a helper method to define mock.On call
+//   - ctx context.Context
+//   - arguments jsontext.Value
+func (_e *MockToolRunner_Expecter) Run(ctx interface{}, arguments interface{}) *MockToolRunner_Run_Call {
+	return &MockToolRunner_Run_Call{Call: _e.mock.On("Run", ctx, arguments)}
+}
+
+func (_c *MockToolRunner_Run_Call) Run(run func(ctx context.Context, arguments jsontext.Value)) *MockToolRunner_Run_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(jsontext.Value))
+	})
+	return _c
+}
+
+func (_c *MockToolRunner_Run_Call) Return(_a0 string, _a1 error) *MockToolRunner_Run_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockToolRunner_Run_Call) RunAndReturn(run func(context.Context, jsontext.Value) (string, error)) *MockToolRunner_Run_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockToolRunner creates a new instance of MockToolRunner. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockToolRunner(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockToolRunner {
+	mock := &MockToolRunner{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -23,6 +23,65 @@ func (_m *MockAI) EXPECT() *MockAI_Expecter {
 	return &MockAI_Expecter{mock: &_m.Mock}
 }
 
+// BatchEmbedding provides a mock function with given fields: ctx, query
+func (_m *MockAI) BatchEmbedding(ctx context.Context, query aggregates.BatchEmbeddingQuery) (*aggregates.EmbeddingAnswer, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BatchEmbedding")
+	}
+
+	var r0 *aggregates.EmbeddingAnswer
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, aggregates.BatchEmbeddingQuery) (*aggregates.EmbeddingAnswer, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, aggregates.BatchEmbeddingQuery) *aggregates.EmbeddingAnswer); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*aggregates.EmbeddingAnswer)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, aggregates.BatchEmbeddingQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAI_BatchEmbedding_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BatchEmbedding'
+type MockAI_BatchEmbedding_Call struct {
+	*mock.Call
+}
+
+// BatchEmbedding is a helper method to define mock.On call
+//   - ctx context.Context
+//   - query aggregates.BatchEmbeddingQuery
+func (_e *MockAI_Expecter) BatchEmbedding(ctx interface{}, query interface{}) *MockAI_BatchEmbedding_Call {
+	return &MockAI_BatchEmbedding_Call{Call: _e.mock.On("BatchEmbedding", ctx, query)}
+}
+
+func (_c *MockAI_BatchEmbedding_Call) Run(run func(ctx context.Context, query aggregates.BatchEmbeddingQuery)) *MockAI_BatchEmbedding_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(aggregates.BatchEmbeddingQuery))
+	})
+	return _c
+}
+
+func (_c *MockAI_BatchEmbedding_Call) Return(_a0 *aggregates.EmbeddingAnswer, _a1 error) *MockAI_BatchEmbedding_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAI_BatchEmbedding_Call) RunAndReturn(run func(context.Context, aggregates.BatchEmbeddingQuery) (*aggregates.EmbeddingAnswer, error)) *MockAI_BatchEmbedding_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Embedding provides a mock function with given fields: ctx, query
 func (_m *MockAI) Embedding(ctx context.Context, query aggregates.EmbeddingQuery) (*aggregates.EmbeddingAnswer, error) {
 	ret := _m.Called(ctx, query)
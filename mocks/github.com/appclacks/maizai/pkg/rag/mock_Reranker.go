@@ -0,0 +1,97 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package rag
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockReranker is an autogenerated mock type for the Reranker type
+type MockReranker struct {
+	mock.Mock
+}
+
+type MockReranker_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockReranker) EXPECT() *MockReranker_Expecter {
+	return &MockReranker_Expecter{mock: &_m.Mock}
+}
+
+// Rerank provides a mock function with given fields: ctx, project, query, fragments
+func (_m *MockReranker) Rerank(ctx context.Context, project string, query string, fragments []string) ([]float64, error) {
+	ret := _m.Called(ctx, project, query, fragments)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Rerank")
+	}
+
+	var r0 []float64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []string) ([]float64, error)); ok {
+		return rf(ctx, project, query, fragments)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []string) []float64); ok {
+		r0 = rf(ctx, project, query, fragments)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]float64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, []string) error); ok {
+		r1 = rf(ctx, project, query, fragments)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockReranker_Rerank_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Rerank'
+type MockReranker_Rerank_Call struct {
+	*mock.Call
+}
+
+// Rerank is a helper method to define mock.On call
+//   - ctx context.Context
+//   - project string
+//   - query string
+//   - fragments []string
+func (_e *MockReranker_Expecter) Rerank(ctx interface{}, project interface{}, query interface{}, fragments interface{}) *MockReranker_Rerank_Call {
+	return &MockReranker_Rerank_Call{Call: _e.mock.On("Rerank", ctx, project, query, fragments)}
+}
+
+func (_c *MockReranker_Rerank_Call) Run(run func(ctx context.Context, project string, query string, fragments []string)) *MockReranker_Rerank_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string), args[3].([]string))
+	})
+	return _c
+}
+
+func (_c *MockReranker_Rerank_Call) Return(_a0 []float64, _a1 error) *MockReranker_Rerank_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockReranker_Rerank_Call) RunAndReturn(run func(context.Context, string, string, []string) ([]float64, error)) *MockReranker_Rerank_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockReranker creates a new instance of MockReranker. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockReranker(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockReranker {
+	mock := &MockReranker{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,37 @@
+package aggregates
+
+import (
+	"errors"
+)
+
+const DefaultCompactionMaxTokens = 4096
+
+type CompactOptions struct {
+	Provider  string `json:"provider"`
+	Model     string `json:"model"`
+	MaxTokens uint64 `json:"max-tokens"`
+	// KeepLast is the number of recent messages which are not compacted
+	KeepLast int `json:"keep-last"`
+	// Prompt replaces the default summarization instructions
+	Prompt string `json:"prompt,omitempty"`
+}
+
+func (c CompactOptions) Validate() error {
+	if c.Provider == "" {
+		return errors.New("An AI provider name is mandatory")
+	}
+	if c.KeepLast < 0 {
+		return errors.New("The number of messages to keep can't be negative")
+	}
+	return nil
+}
+
+// Compaction is the result of the compaction of a context
+type Compaction struct {
+	Context           string `json:"context"`
+	ArchiveContext    string `json:"archive-context"`
+	SummaryMessage    string `json:"summary-message"`
+	CompactedMessages int    `json:"compacted-messages"`
+	InputTokens       uint64 `json:"input-tokens"`
+	OutputTokens      uint64 `json:"output-tokens"`
+}
@@ -1,11 +1,75 @@
 package aggregates
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
 )
 
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// InputSchema is the JSON schema of the tool arguments
+	InputSchema json.RawMessage `json:"input-schema"`
+}
+
+func (t Tool) Validate() error {
+	if t.Name == "" {
+		return errors.New("A tool name is mandatory")
+	}
+	if len(t.InputSchema) == 0 {
+		return fmt.Errorf("An input schema is mandatory for tool %s", t.Name)
+	}
+	var schema map[string]any
+	err := json.Unmarshal(t.InputSchema, &schema)
+	if err != nil {
+		return fmt.Errorf("Invalid input schema for tool %s: the schema should be a JSON object", t.Name)
+	}
+	return nil
+}
+
+const (
+	// DropOldest drops the oldest messages first
+	DropOldest = "drop-oldest"
+	// KeepFirstLast keeps the first and last messages of the conversation and drops the others
+	KeepFirstLast = "keep-first-last"
+	// KeepPinned drops the oldest messages first but keeps the pinned ones
+	KeepPinned = "keep-pinned"
+)
+
+// Budget limits the number of input tokens sent to the provider.
+// Messages are dropped from the conversation history according to the strategy
+// when the budget is exceeded.
+type Budget struct {
+	MaxTokens uint64 `json:"max-tokens"`
+	Strategy  string `json:"strategy"`
+	// KeepFirst is the number of messages kept at the beginning of the conversation
+	// by the keep-first-last strategy
+	KeepFirst int `json:"keep-first,omitempty"`
+	// KeepLast is the number of messages kept at the end of the conversation
+	// by the keep-first-last strategy
+	KeepLast int `json:"keep-last,omitempty"`
+}
+
+func (b Budget) Validate() error {
+	if b.MaxTokens == 0 {
+		return errors.New("The token budget should be greater than 0")
+	}
+	switch b.Strategy {
+	case DropOldest, KeepPinned:
+	case KeepFirstLast:
+		if b.KeepFirst < 0 || b.KeepLast < 0 {
+			return errors.New("The number of messages to keep can't be negative")
+		}
+	default:
+		return fmt.Errorf("Invalid truncation strategy %s, supported strategies are %s, %s and %s", b.Strategy, DropOldest, KeepFirstLast, KeepPinned)
+	}
+	return nil
+}
+
 type QueryOptions struct {
 	Model       string                 `json:"model"`
 	System      string                 `json:"system"`
@@ -13,31 +77,86 @@ type QueryOptions struct {
 	MaxTokens   uint64                 `json:"max-tokens"`
 	Provider    string                 `json:"provider"`
 	RagQuery    aggregates.SearchQuery `json:"rag,omitempty"`
+	Tools       []Tool                 `json:"tools,omitempty"`
+	// ServerTools are the names of the tools registered in MaizAI
+	// which can be called during the conversation
+	ServerTools []string `json:"server-tools,omitempty"`
+	// Budget enables the truncation of the conversation
+	Budget *Budget `json:"budget,omitempty"`
+	// Cite asks the provider to cite inline the RAG chunks used in the answer
+	Cite bool `json:"cite,omitempty"`
 }
 
 func (q QueryOptions) Validate() error {
-	if q.Model == "" {
-		return errors.New("A model name is mandatory")
-	}
 	if q.Provider == "" {
 		return errors.New("An AI provider name is mandatory")
 	}
+	names := make(map[string]bool)
+	for _, tool := range q.Tools {
+		err := tool.Validate()
+		if err != nil {
+			return err
+		}
+		if names[tool.Name] {
+			return fmt.Errorf("Tool %s is defined multiple times", tool.Name)
+		}
+		names[tool.Name] = true
+	}
+	if q.Budget != nil {
+		err := q.Budget.Validate()
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 type Result struct {
 	Text string `json:"text"`
+	// ToolCall is set when the provider asks to call a tool
+	ToolCall *shared.ToolCall `json:"tool-call,omitempty"`
+}
+
+// Truncation reports the messages dropped from the conversation to respect the token budget
+type Truncation struct {
+	DroppedMessages []string `json:"dropped-messages"`
+	// EstimatedInputTokens is the estimated number of input tokens of the last provider call
+	EstimatedInputTokens uint64 `json:"estimated-input-tokens"`
+}
+
+// Citation is a RAG chunk used to enrich the conversation
+type Citation struct {
+	ChunkID    string   `json:"chunk-id"`
+	DocumentID string   `json:"document-id"`
+	Score      *float64 `json:"score,omitempty"`
 }
 
 type Answer struct {
-	Results      []Result `json:"result"`
-	InputTokens  uint64   `json:"input-tokens"`
-	OutputTokens uint64   `json:"output-tokens"`
-	Context      string   `json:"context"`
+	Results []Result `json:"result"`
+	// Model is the model used by the provider, which can be the default model of the provider
+	Model        string      `json:"model"`
+	InputTokens  uint64      `json:"input-tokens"`
+	OutputTokens uint64      `json:"output-tokens"`
+	Context      string      `json:"context"`
+	Truncation   *Truncation `json:"truncation,omitempty"`
+	// Compaction is set when the context was automatically compacted after the conversation
+	Compaction *Compaction `json:"compaction,omitempty"`
+	// Citations are the RAG chunks injected in the conversation
+	Citations []Citation `json:"citations,omitempty"`
+}
+
+// ToolStep is a tool call executed by MaizAI during a conversation
+type ToolStep struct {
+	Iteration int
+	ToolCall  shared.ToolCall
+	Output    string
+	// Error is true if the tool call failed. The output contains the error in that case.
+	Error bool
 }
 
 type Event struct {
-	Answer *Answer
-	Delta  string
-	Error  error
+	Answer   *Answer
+	Delta    string
+	ToolStep *ToolStep
+	Error    error
 }
@@ -6,41 +6,101 @@ import (
 	"strings"
 	"time"
 
+	"github.com/appclacks/maizai/internal/metrics"
 	"github.com/appclacks/maizai/pkg/assistant/aggregates"
 	ragdata "github.com/appclacks/maizai/pkg/rag/aggregates"
 	"github.com/appclacks/maizai/pkg/shared"
 	"github.com/google/uuid"
+	er "github.com/mcorbin/corbierror"
 )
 
 var ragPlaceholder = "{ragdata}"
 
+const citationPrompt = "When you use one of the fragments above in your answer, cite it inline using its chunk ID between brackets, for example [chunk 01f0...]."
+
 type Provider interface {
+	// DefaultModel returns the model used by the queries without model
+	DefaultModel() string
 	Query(ctx context.Context, messages []shared.Message, options aggregates.QueryOptions) (*aggregates.Answer, error)
 	Stream(ctx context.Context, messages []shared.Message, options aggregates.QueryOptions) (<-chan aggregates.Event, error)
 }
 
 type ContextManager interface {
-	CreateOrGetContext(ctx context.Context, contextID string, options shared.ContextOptions) (*shared.Context, error)
-	GetContext(ctx context.Context, id string) (*shared.Context, error)
-	AddMessagesToContext(ctx context.Context, id string, messages []shared.Message) error
+	CreateOrGetContext(ctx context.Context, project string, contextID string, options shared.ContextOptions) (*shared.Context, error)
+	GetContext(ctx context.Context, project string, id string) (*shared.Context, error)
+	AddMessagesToContext(ctx context.Context, project string, id string, messages []shared.Message) error
+	CompactContext(ctx context.Context, project string, contextID string, messageIDs []string, summary shared.Message, archive shared.Context) error
 }
 
 type Rag interface {
-	Match(ctx context.Context, query ragdata.SearchQuery) ([]ragdata.DocumentChunk, error)
+	Match(ctx context.Context, project string, query ragdata.SearchQuery) ([]ragdata.DocumentChunk, error)
+}
+
+const DefaultMaxSourcesDepth = 10
+
+type Config struct {
+	// MaxToolIterations limits the number of provider calls done for a
+	// single conversation when server-side tools are used
+	MaxToolIterations int
+	// MaxSourcesDepth limits the depth of the context sources followed
+	// to enrich a conversation
+	MaxSourcesDepth int
+	// Compaction configures the automatic compaction of the contexts
+	Compaction CompactionConfig
+	// Metrics records the providers calls. Nothing is recorded if nil.
+	Metrics *metrics.Metrics
 }
 
 type Assistant struct {
 	rag        Rag
 	ctxManager ContextManager
 	providers  map[string]Provider
+	tools      map[string]ToolRunner
+	recorder   UsageRecorder
+	config     Config
 }
 
-func New(clients map[string]Provider, ctxManager ContextManager, rag Rag) *Assistant {
+// New creates an assistant. The usage of the providers is not recorded if recorder is nil.
+func New(clients map[string]Provider, ctxManager ContextManager, rag Rag, tools []ToolRunner, recorder UsageRecorder, config Config) *Assistant {
+	if config.MaxToolIterations <= 0 {
+		config.MaxToolIterations = DefaultMaxToolIterations
+	}
+	if config.MaxSourcesDepth <= 0 {
+		config.MaxSourcesDepth = DefaultMaxSourcesDepth
+	}
+	toolsMap := make(map[string]ToolRunner)
+	for _, tool := range tools {
+		toolsMap[tool.Definition().Name] = tool
+	}
 	return &Assistant{
 		rag:        rag,
 		ctxManager: ctxManager,
 		providers:  clients,
+		tools:      toolsMap,
+		recorder:   recorder,
+		config:     config,
+	}
+}
+
+// answerModel returns the model used by the provider
+func answerModel(answer *aggregates.Answer, model string) string {
+	if answer.Model != "" {
+		return answer.Model
+	}
+	return model
+}
+
+// Model returns the model used by the provider for the requested model,
+// which is the provider default model if no model is requested
+func (a *Assistant) Model(provider string, model string) string {
+	if model != "" {
+		return model
+	}
+	client, ok := a.providers[provider]
+	if !ok {
+		return model
 	}
+	return client.DefaultModel()
 }
 
 func (a *Assistant) Message(ctx context.Context, messages []shared.Message, options aggregates.QueryOptions) (*aggregates.Answer, error) {
@@ -48,10 +108,13 @@ func (a *Assistant) Message(ctx context.Context, messages []shared.Message, opti
 	if !ok {
 		return nil, fmt.Errorf("AI client %s not found", options.Provider)
 	}
+	start := time.Now()
 	answer, err := client.Query(ctx, messages, options)
 	if err != nil {
+		a.config.Metrics.ProviderCall(options.Provider, options.Model, metrics.QueryMode, time.Since(start), 0, 0, err)
 		return nil, err
 	}
+	a.config.Metrics.ProviderCall(options.Provider, answerModel(answer, options.Model), metrics.QueryMode, time.Since(start), answer.InputTokens, answer.OutputTokens, nil)
 	return answer, nil
 }
 
@@ -60,27 +123,79 @@ func (a *Assistant) Stream(ctx context.Context, messages []shared.Message, optio
 	if !ok {
 		return nil, fmt.Errorf("AI client %s not found", options.Provider)
 	}
+	start := time.Now()
 	streamChan, err := client.Stream(ctx, messages, options)
 	if err != nil {
+		a.config.Metrics.ProviderCall(options.Provider, options.Model, metrics.StreamMode, time.Since(start), 0, 0, err)
 		return nil, err
 	}
-	return streamChan, nil
+	if a.config.Metrics == nil {
+		return streamChan, nil
+	}
+	// the events are forwarded to measure the time to first token
+	// and the duration of the whole answer. The time to first token is
+	// recorded with the answer, which contains the model used by the provider.
+	result := make(chan aggregates.Event)
+	go func() {
+		defer close(result)
+		var firstToken time.Duration
+		for event := range streamChan {
+			if event.Delta != "" && firstToken == 0 {
+				firstToken = time.Since(start)
+			}
+			if event.Error != nil {
+				a.config.Metrics.ProviderCall(options.Provider, options.Model, metrics.StreamMode, time.Since(start), 0, 0, event.Error)
+			}
+			if event.Answer != nil {
+				model := answerModel(event.Answer, options.Model)
+				a.config.Metrics.ProviderCall(options.Provider, model, metrics.StreamMode, time.Since(start), event.Answer.InputTokens, event.Answer.OutputTokens, nil)
+				if firstToken != 0 {
+					a.config.Metrics.FirstToken(options.Provider, model, firstToken)
+				}
+			}
+			result <- event
+		}
+	}()
+	return result, nil
+}
+
+func pathNames(path []*shared.Context) string {
+	names := []string{}
+	for _, context := range path {
+		names = append(names, context.Name)
+	}
+	return strings.Join(names, " -> ")
 }
 
-func (a *Assistant) enrichRecursively(ctx context.Context, context *shared.Context, messages []shared.Message) ([]shared.Message, error) {
+// enrichRecursively returns the messages of the context sources followed by the messages of the context.
+// path contains the contexts being traversed, starting from the conversation context, and visited
+// contains the contexts whose messages were already added so shared sources are only added once.
+func (a *Assistant) enrichRecursively(ctx context.Context, context *shared.Context, path []*shared.Context, visited map[string]bool) ([]shared.Message, error) {
 	result := []shared.Message{}
-	if context.Sources.Contexts != nil {
-		for _, source := range context.Sources.Contexts {
-			sourceContext, err := a.ctxManager.GetContext(ctx, source)
-			if err != nil {
-				return nil, err
-			}
-			msg, err := a.enrichRecursively(ctx, sourceContext, messages)
-			if err != nil {
-				return nil, err
+	visited[context.ID] = true
+	path = append(path, context)
+	for _, source := range context.Sources.Contexts {
+		for _, c := range path {
+			if c.ID == source {
+				cycle := pathNames(append(path, c))
+				return nil, er.Newf("Context sources contain a cycle: %s", er.BadRequest, true, cycle)
 			}
-			result = append(result, msg...)
 		}
+		if visited[source] {
+			continue
+		}
+		sourceContext, err := a.ctxManager.GetContext(ctx, context.Project, source)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > a.config.MaxSourcesDepth {
+			return nil, er.Newf("Context sources exceed the maximum depth of %d: %s", er.BadRequest, true, a.config.MaxSourcesDepth, pathNames(append(path, sourceContext)))
+		}
+		msg, err := a.enrichRecursively(ctx, sourceContext, path, visited)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, msg...)
 	}
 
 	result = append(result, context.Messages...)
@@ -88,7 +203,7 @@ func (a *Assistant) enrichRecursively(ctx context.Context, context *shared.Conte
 }
 
 func (a *Assistant) Enrich(ctx context.Context, context *shared.Context, messages []shared.Message) ([]shared.Message, error) {
-	result, err := a.enrichRecursively(ctx, context, messages)
+	result, err := a.enrichRecursively(ctx, context, nil, make(map[string]bool))
 	if err != nil {
 		return nil, err
 	}
@@ -96,50 +211,139 @@ func (a *Assistant) Enrich(ctx context.Context, context *shared.Context, message
 	return result, nil
 }
 
-func (a *Assistant) UpdateContext(ctx context.Context, context string, messages []shared.Message, results []aggregates.Result) error {
-	update := []shared.Message{}
-	update = append(update, messages...)
+func answerMessages(results []aggregates.Result) ([]shared.Message, error) {
+	messages := []shared.Message{}
+	toolCalls := []shared.ToolCall{}
 	for _, result := range results {
+		if result.ToolCall != nil {
+			toolCalls = append(toolCalls, *result.ToolCall)
+		}
+	}
+	// tool calls must be stored in the same assistant message as the text
+	// produced by the provider
+	if len(toolCalls) != 0 {
+		texts := []string{}
+		for _, result := range results {
+			if result.Text != "" {
+				texts = append(texts, result.Text)
+			}
+		}
 		id, err := uuid.NewV6()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		update = append(update, shared.Message{
+		messages = append(messages, shared.Message{
+			ID:        id.String(),
+			CreatedAt: time.Now().UTC(),
+			Role:      shared.AssistantRole,
+			Content:   strings.Join(texts, "\n"),
+			ToolCalls: toolCalls,
+		})
+		return messages, nil
+	}
+	for _, result := range results {
+		id, err := uuid.NewV6()
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, shared.Message{
 			ID:        id.String(),
 			CreatedAt: time.Now().UTC(),
 			Role:      shared.AssistantRole,
 			Content:   result.Text,
 		})
 	}
-	return a.ctxManager.AddMessagesToContext(ctx, context, update)
+	return messages, nil
 }
 
-func (a *Assistant) EnrichWithRag(ctx context.Context, messages []shared.Message, ragQuery ragdata.SearchQuery) ([]shared.Message, error) {
+func (a *Assistant) UpdateContext(ctx context.Context, project string, context string, messages []shared.Message, results []aggregates.Result) error {
+	answer, err := answerMessages(results)
+	if err != nil {
+		return err
+	}
+	update := []shared.Message{}
+	update = append(update, messages...)
+	update = append(update, answer...)
+	return a.ctxManager.AddMessagesToContext(ctx, project, context, update)
+}
 
-	chunks, err := a.rag.Match(ctx, ragQuery)
+// nextStep stores the answer of the provider and the output of the server-side tools in the context.
+// It returns the messages to send to the provider for the next iteration, or nil if the conversation is over.
+func (a *Assistant) nextStep(ctx context.Context, project string, contextID string, options aggregates.QueryOptions, iteration int, conversation []shared.Message, messages []shared.Message, answer *aggregates.Answer, onStep func(aggregates.ToolStep)) ([]shared.Message, error) {
+	toolMessages, next, err := a.runTools(ctx, options, answer.Results, iteration, onStep)
+	if err != nil {
+		return nil, err
+	}
+	assistantMessages, err := answerMessages(answer.Results)
+	if err != nil {
+		return nil, err
+	}
+	update := []shared.Message{}
+	update = append(update, messages...)
+	update = append(update, assistantMessages...)
+	update = append(update, toolMessages...)
+	err = a.ctxManager.AddMessagesToContext(ctx, project, contextID, update)
+	if err != nil {
+		return nil, err
+	}
+	if !next {
+		return nil, nil
+	}
+	if iteration >= a.config.MaxToolIterations {
+		return nil, er.Newf("the maximum number of tool iterations (%d) was reached", er.BadRequest, true, a.config.MaxToolIterations)
+	}
+	result := []shared.Message{}
+	result = append(result, conversation...)
+	result = append(result, assistantMessages...)
+	result = append(result, toolMessages...)
+	return result, nil
+}
+
+// ragData labels each fragment with its chunk and document IDs so the
+// provider (and the users) can know where the information comes from
+func ragData(chunks []ragdata.DocumentChunk, cite bool) string {
 	fragments := []string{}
 	for _, chunk := range chunks {
-		fragments = append(fragments, chunk.Fragment)
+		fragments = append(fragments, fmt.Sprintf("[chunk %s from document %s]\n%s", chunk.ID, chunk.DocumentID, chunk.Fragment))
+	}
+	if cite && len(fragments) != 0 {
+		fragments = append(fragments, citationPrompt)
 	}
+	return strings.Join(fragments, "\n\n")
+}
+
+// EnrichWithRag replaces the RAG placeholder in the messages by the chunks of the project matching
+// the query. The chunks used are returned as citations.
+func (a *Assistant) EnrichWithRag(ctx context.Context, project string, messages []shared.Message, ragQuery ragdata.SearchQuery, cite bool) ([]shared.Message, []aggregates.Citation, error) {
+	chunks, err := a.rag.Match(ctx, project, ragQuery)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	citations := []aggregates.Citation{}
+	for _, chunk := range chunks {
+		citations = append(citations, aggregates.Citation{
+			ChunkID:    chunk.ID,
+			DocumentID: chunk.DocumentID,
+			Score:      chunk.Score,
+		})
 	}
-	ragData := strings.Join(fragments, "\n")
+	data := ragData(chunks, cite)
 	result := []shared.Message{}
 	for _, message := range messages {
-		message.Content = strings.ReplaceAll(message.Content, ragPlaceholder, ragData)
+		message.Content = strings.ReplaceAll(message.Content, ragPlaceholder, data)
 		result = append(result, message)
 	}
-	return result, nil
+	return result, citations, nil
 }
 
 func (a *Assistant) Pipeline(
 	ctx context.Context,
+	project string,
 	options aggregates.QueryOptions,
 	contextOptions shared.ContextOptions,
 	contextID string,
 	messages []shared.Message) (*aggregates.Answer, error) {
-	context, err := a.ctxManager.CreateOrGetContext(ctx, contextID, contextOptions)
+	context, err := a.ctxManager.CreateOrGetContext(ctx, project, contextID, contextOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -149,13 +353,18 @@ func (a *Assistant) Pipeline(
 			return nil, err
 		}
 	}
+	options, err = a.withServerTools(options)
+	if err != nil {
+		return nil, err
+	}
 	err = options.Validate()
 	if err != nil {
 		return nil, err
 	}
 
+	var citations []aggregates.Citation
 	if options.RagQuery.Input != "" {
-		messages, err = a.EnrichWithRag(ctx, messages, options.RagQuery)
+		messages, citations, err = a.EnrichWithRag(ctx, project, messages, options.RagQuery, options.Cite)
 		if err != nil {
 			return nil, err
 		}
@@ -165,20 +374,45 @@ func (a *Assistant) Pipeline(
 	if err != nil {
 		return nil, err
 	}
-	answer, err := a.Message(ctx, fullMessages, options)
-	if err != nil {
-		return nil, err
-	}
-	answer.Context = context.ID
-	err = a.UpdateContext(ctx, context.ID, messages, answer.Results)
-	if err != nil {
-		return nil, err
+	// messages produced during the conversation are never truncated
+	historySize := len(fullMessages) - len(messages)
+	var inputTokens, outputTokens uint64
+	var truncation *aggregates.Truncation
+	for iteration := 1; ; iteration++ {
+		sent, callTruncation, err := a.truncate(fullMessages, historySize, options)
+		if err != nil {
+			return nil, err
+		}
+		truncation = mergeTruncation(truncation, callTruncation)
+		start := time.Now()
+		answer, err := a.Message(ctx, sent, options)
+		a.recordUsage(ctx, project, context.ID, options, start, answer, err)
+		if err != nil {
+			return nil, err
+		}
+		inputTokens += answer.InputTokens
+		outputTokens += answer.OutputTokens
+		fullMessages, err = a.nextStep(ctx, project, context.ID, options, iteration, fullMessages, messages, answer, nil)
+		if err != nil {
+			return nil, err
+		}
+		if fullMessages == nil {
+			answer.Context = context.ID
+			answer.InputTokens = inputTokens
+			answer.OutputTokens = outputTokens
+			answer.Truncation = truncation
+			answer.Compaction = a.autoCompact(ctx, project, context.ID)
+			answer.Citations = citations
+			return answer, nil
+		}
+		// the input messages are already stored in the context
+		messages = nil
 	}
-	return answer, nil
 }
 
 func (a *Assistant) StreamPipeline(
 	ctx context.Context,
+	project string,
 	options aggregates.QueryOptions,
 	contextOptions shared.ContextOptions,
 	contextID string,
@@ -189,17 +423,22 @@ func (a *Assistant) StreamPipeline(
 			return nil, err
 		}
 	}
-	err := options.Validate()
+	options, err := a.withServerTools(options)
+	if err != nil {
+		return nil, err
+	}
+	err = options.Validate()
 	if err != nil {
 		return nil, err
 	}
-	context, err := a.ctxManager.CreateOrGetContext(ctx, contextID, contextOptions)
+	context, err := a.ctxManager.CreateOrGetContext(ctx, project, contextID, contextOptions)
 	if err != nil {
 		return nil, err
 	}
 
+	var citations []aggregates.Citation
 	if options.RagQuery.Input != "" {
-		messages, err = a.EnrichWithRag(ctx, messages, options.RagQuery)
+		messages, citations, err = a.EnrichWithRag(ctx, project, messages, options.RagQuery, options.Cite)
 		if err != nil {
 			return nil, err
 		}
@@ -209,33 +448,81 @@ func (a *Assistant) StreamPipeline(
 	if err != nil {
 		return nil, err
 	}
+	// messages produced during the conversation are never truncated
+	historySize := len(fullMessages) - len(messages)
+	sent, truncation, err := a.truncate(fullMessages, historySize, options)
+	if err != nil {
+		return nil, err
+	}
 	eventChan := make(chan aggregates.Event)
-	streamChan, err := a.Stream(ctx, fullMessages, options)
+	start := time.Now()
+	streamChan, err := a.Stream(ctx, sent, options)
 	if err != nil {
+		a.recordUsage(ctx, project, context.ID, options, start, nil, err)
 		return nil, err
 	}
 	go func() {
-		for event := range streamChan {
-			if event.Answer == nil {
-				eventChan <- event
-				if event.Error != nil {
+		defer close(eventChan)
+		var inputTokens, outputTokens uint64
+		onStep := func(step aggregates.ToolStep) {
+			eventChan <- aggregates.Event{
+				ToolStep: &step,
+			}
+		}
+		for iteration := 1; ; iteration++ {
+			var answer *aggregates.Answer
+			for event := range streamChan {
+				if event.Answer == nil {
+					eventChan <- event
+					if event.Error != nil {
+						a.recordUsage(ctx, project, context.ID, options, start, nil, event.Error)
+						return
+					}
+				} else {
+					// the answer is set only when the AI assistant sent all of its messages
+					// so it's safe to assume that the streamChan channel is closed
+					answer = event.Answer
 					break
 				}
-			} else {
-				// the answer is set only when the AI assistant sent all of its messages
-				// so it's safe to assume that the streamChan channel is closed
-				answer := event.Answer
+			}
+			if answer == nil {
+				return
+			}
+			a.recordUsage(ctx, project, context.ID, options, start, answer, nil)
+			inputTokens += answer.InputTokens
+			outputTokens += answer.OutputTokens
+			next, err := a.nextStep(ctx, project, context.ID, options, iteration, fullMessages, messages, answer, onStep)
+			if err != nil {
+				eventChan <- aggregates.Event{Error: err}
+				return
+			}
+			if next == nil {
 				answer.Context = context.ID
-				err = a.UpdateContext(ctx, context.ID, messages, answer.Results)
-				if err != nil {
-					event.Error = err
-				}
-				eventChan <- event
-				break
+				answer.InputTokens = inputTokens
+				answer.OutputTokens = outputTokens
+				answer.Truncation = truncation
+				answer.Compaction = a.autoCompact(ctx, project, context.ID)
+				answer.Citations = citations
+				eventChan <- aggregates.Event{Answer: answer}
+				return
+			}
+			// the input messages are already stored in the context
+			messages = nil
+			fullMessages = next
+			sent, callTruncation, err := a.truncate(fullMessages, historySize, options)
+			if err != nil {
+				eventChan <- aggregates.Event{Error: err}
+				return
+			}
+			truncation = mergeTruncation(truncation, callTruncation)
+			start = time.Now()
+			streamChan, err = a.Stream(ctx, sent, options)
+			if err != nil {
+				a.recordUsage(ctx, project, context.ID, options, start, nil, err)
+				eventChan <- aggregates.Event{Error: err}
+				return
 			}
 		}
-		close(eventChan)
-
 	}()
 	return eventChan, nil
 }
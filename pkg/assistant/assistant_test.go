@@ -2,18 +2,29 @@ package assistant_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/appclacks/maizai/internal/contextstore/memory"
+	"github.com/appclacks/maizai/internal/metrics"
+	usagememory "github.com/appclacks/maizai/internal/usagestore/memory"
 	mocks "github.com/appclacks/maizai/mocks/github.com/appclacks/maizai/pkg/assistant"
 	"github.com/google/uuid"
 
 	"github.com/appclacks/maizai/pkg/assistant"
 	"github.com/appclacks/maizai/pkg/assistant/aggregates"
 	ct "github.com/appclacks/maizai/pkg/context"
+	"github.com/appclacks/maizai/pkg/limits"
+	limitsdata "github.com/appclacks/maizai/pkg/limits/aggregates"
 	ragdata "github.com/appclacks/maizai/pkg/rag/aggregates"
 	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/appclacks/maizai/pkg/usage"
+	usagedata "github.com/appclacks/maizai/pkg/usage/aggregates"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -26,9 +37,10 @@ func TestPipeline(t *testing.T) {
 
 	clients := make(map[string]assistant.Provider)
 	clients["test"] = client
-	ai := assistant.New(clients, manager, rag)
+	ai := assistant.New(clients, manager, rag, nil, nil, assistant.Config{})
 
 	ctx := context.Background()
+	score := 0.9
 
 	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
 		&aggregates.Answer{
@@ -38,10 +50,13 @@ func TestPipeline(t *testing.T) {
 				},
 			},
 		}, nil)
-	rag.On("Match", mock.Anything, mock.Anything).Return(
+	rag.On("Match", mock.Anything, mock.Anything, mock.Anything).Return(
 		[]ragdata.DocumentChunk{
 			{
-				Fragment: "fragment from rag",
+				ID:         "chunk1",
+				DocumentID: "doc1",
+				Fragment:   "fragment from rag",
+				Score:      &score,
 			},
 		},
 		nil)
@@ -67,39 +82,50 @@ func TestPipeline(t *testing.T) {
 			CreatedAt: time.Now().UTC(),
 		},
 	}
-	answer, err := ai.Pipeline(ctx, queryOptions, contextOptions, "", messages)
+	answer, err := ai.Pipeline(ctx, shared.DefaultProject, queryOptions, contextOptions, "", messages)
 	assert.NoError(t, err)
 
 	assert.Equal(t, "this is the AI answer", answer.Results[0].Text)
+	assert.Equal(t, []aggregates.Citation{{ChunkID: "chunk1", DocumentID: "doc1", Score: &score}}, answer.Citations)
 
-	result, err := store.GetContext(ctx, answer.Context)
+	ragContent := "message1 [chunk chunk1 from document doc1]\nfragment from rag"
+	result, err := store.GetContext(ctx, shared.DefaultProject, answer.Context)
 	assert.NoError(t, err)
 	assert.Len(t, result.Messages, 2)
 	assert.Equal(t, shared.UserRole, result.Messages[0].Role)
-	assert.Equal(t, "message1 fragment from rag", result.Messages[0].Content)
+	assert.Equal(t, ragContent, result.Messages[0].Content)
 	assert.Equal(t, shared.AssistantRole, result.Messages[1].Role)
 	assert.Equal(t, "this is the AI answer", result.Messages[1].Content)
 
 	sentMessages := client.Calls[0].Arguments[1].([]shared.Message)
 	assert.Len(t, sentMessages, 1)
-	assert.Equal(t, sentMessages[0].Content, "message1 fragment from rag")
+	assert.Equal(t, sentMessages[0].Content, ragContent)
+
+	queryOptions.Cite = true
+	answer, err = ai.Pipeline(ctx, shared.DefaultProject, queryOptions, shared.ContextOptions{Name: "bar"}, "", messages)
+	assert.NoError(t, err)
+	assert.Len(t, answer.Citations, 1)
+	sentMessages = client.Calls[1].Arguments[1].([]shared.Message)
+	assert.True(t, strings.HasPrefix(sentMessages[0].Content, ragContent+"\n\n"))
+	assert.Contains(t, sentMessages[0].Content, "cite it inline using its chunk ID")
 }
 
 func TestEnrich(t *testing.T) {
 	store := memory.New()
 	manager := ct.New(store)
 
-	ai := assistant.New(nil, manager, nil)
+	ai := assistant.New(nil, manager, nil, nil, nil, assistant.Config{})
 	ctx := context.Background()
 
 	context1 := shared.Context{
 		ID:        uuid.NewString(),
+		Project:   shared.DefaultProject,
 		Name:      "context1",
 		CreatedAt: time.Now().UTC(),
 	}
 	err := manager.CreateContext(ctx, context1)
 	assert.NoError(t, err)
-	err = manager.AddMessagesToContext(ctx, context1.ID, []shared.Message{
+	err = manager.AddMessagesToContext(ctx, shared.DefaultProject, context1.ID, []shared.Message{
 		{
 			ID:        uuid.NewString(),
 			Role:      shared.AssistantRole,
@@ -110,12 +136,13 @@ func TestEnrich(t *testing.T) {
 	assert.NoError(t, err)
 	context2 := shared.Context{
 		ID:        uuid.NewString(),
+		Project:   shared.DefaultProject,
 		Name:      "context2",
 		CreatedAt: time.Now().UTC(),
 	}
 	err = manager.CreateContext(ctx, context2)
 	assert.NoError(t, err)
-	err = manager.AddMessagesToContext(ctx, context2.ID, []shared.Message{
+	err = manager.AddMessagesToContext(ctx, shared.DefaultProject, context2.ID, []shared.Message{
 		{
 			ID:        uuid.NewString(),
 			Role:      shared.UserRole,
@@ -124,7 +151,7 @@ func TestEnrich(t *testing.T) {
 		},
 	})
 	assert.NoError(t, err)
-	err = manager.AddMessagesToContext(ctx, context2.ID, []shared.Message{
+	err = manager.AddMessagesToContext(ctx, shared.DefaultProject, context2.ID, []shared.Message{
 		{
 			ID:        uuid.NewString(),
 			Role:      shared.UserRole,
@@ -135,6 +162,7 @@ func TestEnrich(t *testing.T) {
 	assert.NoError(t, err)
 	context3 := shared.Context{
 		ID:        uuid.NewString(),
+		Project:   shared.DefaultProject,
 		Name:      "context3",
 		CreatedAt: time.Now().UTC(),
 		Sources: shared.ContextSources{
@@ -143,7 +171,7 @@ func TestEnrich(t *testing.T) {
 	}
 	err = manager.CreateContext(ctx, context3)
 	assert.NoError(t, err)
-	err = manager.AddMessagesToContext(ctx, context3.ID, []shared.Message{
+	err = manager.AddMessagesToContext(ctx, shared.DefaultProject, context3.ID, []shared.Message{
 		{
 			ID:        uuid.NewString(),
 			Role:      shared.UserRole,
@@ -155,6 +183,7 @@ func TestEnrich(t *testing.T) {
 
 	context4 := shared.Context{
 		ID:        uuid.NewString(),
+		Project:   shared.DefaultProject,
 		Name:      "context4",
 		CreatedAt: time.Now().UTC(),
 		Sources: shared.ContextSources{
@@ -163,7 +192,7 @@ func TestEnrich(t *testing.T) {
 	}
 	err = manager.CreateContext(ctx, context4)
 	assert.NoError(t, err)
-	err = manager.AddMessagesToContext(ctx, context4.ID, []shared.Message{
+	err = manager.AddMessagesToContext(ctx, shared.DefaultProject, context4.ID, []shared.Message{
 		{
 			ID:        uuid.NewString(),
 			Role:      shared.AssistantRole,
@@ -195,7 +224,7 @@ func TestEnrich(t *testing.T) {
 	}
 	// context 4 (2 messages) => context 3 (1 message) => [ context 2 (2 message), context 1 (1 message) ]
 
-	context, err := store.GetContext(ctx, context4.ID)
+	context, err := store.GetContext(ctx, shared.DefaultProject, context4.ID)
 	assert.NoError(t, err)
 	result, err := ai.Enrich(ctx, context, messages)
 	assert.NoError(t, err)
@@ -218,3 +247,698 @@ func TestEnrich(t *testing.T) {
 	assert.Equal(t, shared.UserRole, result[7].Role)
 
 }
+
+func TestEnrichSourcesGraph(t *testing.T) {
+	store := memory.New()
+	manager := ct.New(store)
+	ai := assistant.New(nil, manager, nil, nil, nil, assistant.Config{MaxSourcesDepth: 2})
+	ctx := context.Background()
+
+	newContext := func(name string, sources ...string) shared.Context {
+		c := shared.Context{
+			ID:        uuid.NewString(),
+			Project:   shared.DefaultProject,
+			Name:      name,
+			CreatedAt: time.Now().UTC(),
+			Sources: shared.ContextSources{
+				Contexts: sources,
+			},
+			Messages: []shared.Message{
+				{
+					ID:        uuid.NewString(),
+					Role:      shared.UserRole,
+					Content:   name,
+					CreatedAt: time.Now().UTC(),
+				},
+			},
+		}
+		err := manager.CreateContext(ctx, c)
+		assert.NoError(t, err)
+		return c
+	}
+	// diamond: d => [b, c] => a
+	a := newContext("a")
+	b := newContext("b", a.ID)
+	c := newContext("c", a.ID)
+	d := newContext("d", b.ID, c.ID)
+
+	context, err := store.GetContext(ctx, shared.DefaultProject, d.ID)
+	assert.NoError(t, err)
+	result, err := ai.Enrich(ctx, context, nil)
+	assert.NoError(t, err)
+	contents := []string{}
+	for _, message := range result {
+		contents = append(contents, message.Content)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, contents)
+
+	err = manager.CreateContextSourceContext(ctx, shared.DefaultProject, a.ID, d.ID)
+	assert.ErrorContains(t, err, "Context d can't be a source of context a because it would create a cycle: a -> d -> b -> a")
+	err = manager.CreateContextSourceContext(ctx, shared.DefaultProject, a.ID, a.ID)
+	assert.ErrorContains(t, err, "cycle: a -> a")
+
+	// e => d => b => a exceeds the maximum depth
+	e := newContext("e", d.ID)
+	context, err = store.GetContext(ctx, shared.DefaultProject, e.ID)
+	assert.NoError(t, err)
+	_, err = ai.Enrich(ctx, context, nil)
+	assert.ErrorContains(t, err, "Context sources exceed the maximum depth of 2: e -> d -> b -> a")
+
+	// cycles created without the context manager are detected during the enrichment
+	err = store.CreateContextSourceContext(ctx, shared.DefaultProject, a.ID, b.ID)
+	assert.NoError(t, err)
+	context, err = store.GetContext(ctx, shared.DefaultProject, b.ID)
+	assert.NoError(t, err)
+	_, err = ai.Enrich(ctx, context, nil)
+	assert.ErrorContains(t, err, "Context sources contain a cycle: b -> a -> b")
+}
+
+func TestPipelineToolCalls(t *testing.T) {
+	store := memory.New()
+	client := mocks.NewMockProvider(t)
+	manager := ct.New(store)
+	clients := map[string]assistant.Provider{"test": client}
+	ai := assistant.New(clients, manager, mocks.NewMockRag(t), nil, nil, assistant.Config{})
+	ctx := context.Background()
+
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
+		&aggregates.Answer{
+			Results: []aggregates.Result{
+				{
+					Text: "let me check the weather",
+				},
+				{
+					ToolCall: &shared.ToolCall{
+						ID:        "call_1",
+						Name:      "weather",
+						Arguments: []byte(`{"city":"Paris"}`),
+					},
+				},
+			},
+		}, nil).Once()
+	queryOptions := aggregates.QueryOptions{
+		Provider: "test",
+		Tools: []aggregates.Tool{
+			{
+				Name:        "weather",
+				Description: "get the weather",
+				InputSchema: []byte(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+			},
+		},
+	}
+	messages, err := shared.NewUserMessages("what's the weather in Paris?")
+	assert.NoError(t, err)
+	answer, err := ai.Pipeline(ctx, shared.DefaultProject, queryOptions, shared.ContextOptions{Name: "tools"}, "", messages)
+	assert.NoError(t, err)
+	assert.Equal(t, "weather", answer.Results[1].ToolCall.Name)
+
+	result, err := store.GetContext(ctx, shared.DefaultProject, answer.Context)
+	assert.NoError(t, err)
+	assert.Len(t, result.Messages, 2)
+	assert.Equal(t, shared.AssistantRole, result.Messages[1].Role)
+	assert.Equal(t, "let me check the weather", result.Messages[1].Content)
+	assert.Len(t, result.Messages[1].ToolCalls, 1)
+	assert.Equal(t, "call_1", result.Messages[1].ToolCalls[0].ID)
+
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
+		&aggregates.Answer{
+			Results: []aggregates.Result{
+				{
+					Text: "it's sunny",
+				},
+			},
+		}, nil).Once()
+	toolMessage, err := shared.NewToolMessage("call_1", "sunny")
+	assert.NoError(t, err)
+	_, err = ai.Pipeline(ctx, shared.DefaultProject, queryOptions, shared.ContextOptions{}, answer.Context, []shared.Message{*toolMessage})
+	assert.NoError(t, err)
+	sentMessages := client.Calls[1].Arguments[1].([]shared.Message)
+	assert.Len(t, sentMessages, 3)
+	assert.Equal(t, shared.ToolRole, sentMessages[2].Role)
+	assert.Equal(t, "call_1", sentMessages[2].ToolCallID)
+
+	invalid := shared.Message{ID: uuid.NewString(), Role: shared.ToolRole, Content: "sunny"}
+	assert.ErrorContains(t, invalid.Validate(), "tool call ID is mandatory")
+}
+
+func TestPipelineServerTools(t *testing.T) {
+	store := memory.New()
+	client := mocks.NewMockProvider(t)
+	manager := ct.New(store)
+	clients := map[string]assistant.Provider{"test": client}
+	tool := mocks.NewMockToolRunner(t)
+	tool.On("Definition").Return(aggregates.Tool{
+		Name:        "weather",
+		InputSchema: []byte(`{"type":"object"}`),
+	})
+	ai := assistant.New(clients, manager, mocks.NewMockRag(t), []assistant.ToolRunner{tool}, nil, assistant.Config{MaxToolIterations: 2})
+	ctx := context.Background()
+
+	toolCallAnswer := &aggregates.Answer{
+		Results: []aggregates.Result{
+			{
+				ToolCall: &shared.ToolCall{
+					ID:        "call_1",
+					Name:      "weather",
+					Arguments: []byte(`{"city":"Paris"}`),
+				},
+			},
+		},
+		InputTokens:  10,
+		OutputTokens: 5,
+	}
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(toolCallAnswer, nil).Once()
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
+		&aggregates.Answer{
+			Results: []aggregates.Result{
+				{
+					Text: "it's sunny",
+				},
+			},
+			InputTokens:  20,
+			OutputTokens: 3,
+		}, nil).Once()
+	tool.On("Run", mock.Anything, json.RawMessage(`{"city":"Paris"}`)).Return("sunny", nil)
+
+	queryOptions := aggregates.QueryOptions{
+		Provider:    "test",
+		ServerTools: []string{"weather"},
+	}
+	messages, err := shared.NewUserMessages("what's the weather in Paris?")
+	assert.NoError(t, err)
+	answer, err := ai.Pipeline(ctx, shared.DefaultProject, queryOptions, shared.ContextOptions{Name: "server-tools"}, "", messages)
+	assert.NoError(t, err)
+	assert.Equal(t, "it's sunny", answer.Results[0].Text)
+	assert.Equal(t, uint64(30), answer.InputTokens)
+	assert.Equal(t, uint64(8), answer.OutputTokens)
+
+	sentOptions := client.Calls[0].Arguments[2].(aggregates.QueryOptions)
+	assert.Len(t, sentOptions.Tools, 1)
+	assert.Equal(t, "weather", sentOptions.Tools[0].Name)
+	sentMessages := client.Calls[1].Arguments[1].([]shared.Message)
+	assert.Len(t, sentMessages, 3)
+	assert.Equal(t, shared.ToolRole, sentMessages[2].Role)
+	assert.Equal(t, "sunny", sentMessages[2].Content)
+
+	result, err := store.GetContext(ctx, shared.DefaultProject, answer.Context)
+	assert.NoError(t, err)
+	assert.Len(t, result.Messages, 4)
+	assert.Equal(t, "call_1", result.Messages[2].ToolCallID)
+	assert.Equal(t, "it's sunny", result.Messages[3].Content)
+
+	// the provider keeps calling the tool
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(toolCallAnswer, nil).Twice()
+	_, err = ai.Pipeline(ctx, shared.DefaultProject, queryOptions, shared.ContextOptions{}, answer.Context, messages)
+	assert.ErrorContains(t, err, "maximum number of tool iterations")
+
+	_, err = ai.Pipeline(ctx, shared.DefaultProject, aggregates.QueryOptions{Provider: "test", ServerTools: []string{"unknown"}}, shared.ContextOptions{}, answer.Context, messages)
+	assert.ErrorContains(t, err, "tool unknown doesn't exist")
+}
+
+func TestStreamPipelineServerTools(t *testing.T) {
+	store := memory.New()
+	client := mocks.NewMockProvider(t)
+	manager := ct.New(store)
+	clients := map[string]assistant.Provider{"test": client}
+	tool := mocks.NewMockToolRunner(t)
+	tool.On("Definition").Return(aggregates.Tool{
+		Name:        "weather",
+		InputSchema: []byte(`{"type":"object"}`),
+	})
+	registry := prometheus.NewRegistry()
+	providerMetrics, err := metrics.New(registry)
+	assert.NoError(t, err)
+	ai := assistant.New(clients, manager, mocks.NewMockRag(t), []assistant.ToolRunner{tool}, nil, assistant.Config{Metrics: providerMetrics})
+	ctx := context.Background()
+
+	stream := func(events ...aggregates.Event) <-chan aggregates.Event {
+		c := make(chan aggregates.Event, len(events))
+		for _, event := range events {
+			c <- event
+		}
+		close(c)
+		return c
+	}
+	client.On("Stream", mock.Anything, mock.Anything, mock.Anything).Return(stream(
+		aggregates.Event{
+			Answer: &aggregates.Answer{
+				Results: []aggregates.Result{
+					{
+						ToolCall: &shared.ToolCall{ID: "call_1", Name: "weather"},
+					},
+				},
+				Model:       "test-model",
+				InputTokens: 10,
+			},
+		}), nil).Once()
+	client.On("Stream", mock.Anything, mock.Anything, mock.Anything).Return(stream(
+		aggregates.Event{Delta: "it's "},
+		aggregates.Event{Delta: "rainy"},
+		aggregates.Event{
+			Answer: &aggregates.Answer{
+				Results:     []aggregates.Result{{Text: "it's rainy"}},
+				Model:       "test-model",
+				InputTokens: 20,
+			},
+		}), nil).Once()
+	tool.On("Run", mock.Anything, mock.Anything).Return("", errors.New("unavailable"))
+
+	messages, err := shared.NewUserMessages("what's the weather?")
+	assert.NoError(t, err)
+	events, err := ai.StreamPipeline(ctx, shared.DefaultProject, aggregates.QueryOptions{Provider: "test", ServerTools: []string{"weather"}}, shared.ContextOptions{Name: "stream-tools"}, "", messages)
+	assert.NoError(t, err)
+	received := []aggregates.Event{}
+	for event := range events {
+		received = append(received, event)
+	}
+	assert.Len(t, received, 4)
+	assert.Equal(t, 1, received[0].ToolStep.Iteration)
+	assert.True(t, received[0].ToolStep.Error)
+	assert.Equal(t, "error: unavailable", received[0].ToolStep.Output)
+	assert.Equal(t, "it's ", received[1].Delta)
+	assert.Equal(t, "rainy", received[2].Delta)
+	assert.Equal(t, uint64(30), received[3].Answer.InputTokens)
+
+	result, err := store.GetContext(ctx, shared.DefaultProject, received[3].Answer.Context)
+	assert.NoError(t, err)
+	assert.Len(t, result.Messages, 4)
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP llm_requests_total Count the number of calls to the AI providers
+# TYPE llm_requests_total counter
+llm_requests_total{mode="stream",model="test-model",provider="test"} 2
+# HELP llm_input_tokens_total Count the number of input tokens used by the AI providers calls
+# TYPE llm_input_tokens_total counter
+llm_input_tokens_total{model="test-model",provider="test"} 30
+`), "llm_requests_total", "llm_input_tokens_total", "llm_errors_total")
+	assert.NoError(t, err)
+	// only the second answer contains text
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	var firstTokens uint64
+	for _, family := range families {
+		if family.GetName() == "llm_time_to_first_token_seconds" {
+			firstTokens = family.GetMetric()[0].GetHistogram().GetSampleCount()
+		}
+	}
+	assert.Equal(t, uint64(1), firstTokens)
+}
+
+func TestPipelineBudget(t *testing.T) {
+	// with the default estimator, each message costs 14 tokens (40 chars + overhead)
+	content := "0123456789012345678901234567890123456789"
+	cases := []struct {
+		name    string
+		budget  aggregates.Budget
+		sent    []string
+		dropped []string
+		err     string
+	}{
+		{
+			name:    "drop-oldest",
+			budget:  aggregates.Budget{MaxTokens: 42, Strategy: aggregates.DropOldest},
+			sent:    []string{"m3", "m4", "current"},
+			dropped: []string{"m1", "m2"},
+		},
+		{
+			name:    "keep-pinned",
+			budget:  aggregates.Budget{MaxTokens: 42, Strategy: aggregates.KeepPinned},
+			sent:    []string{"m2", "m4", "current"},
+			dropped: []string{"m1", "m3"},
+		},
+		{
+			name:    "keep-first-last",
+			budget:  aggregates.Budget{MaxTokens: 42, Strategy: aggregates.KeepFirstLast, KeepFirst: 1, KeepLast: 1},
+			sent:    []string{"m1", "m4", "current"},
+			dropped: []string{"m2", "m3"},
+		},
+		{
+			name:    "no truncation",
+			budget:  aggregates.Budget{MaxTokens: 1000, Strategy: aggregates.DropOldest},
+			sent:    []string{"m1", "m2", "m3", "m4", "current"},
+			dropped: []string{},
+		},
+		{
+			name:   "protected messages exceed the budget",
+			budget: aggregates.Budget{MaxTokens: 28, Strategy: aggregates.KeepFirstLast, KeepFirst: 2},
+			err:    "The conversation requires about 42 tokens after truncation with the keep-first-last strategy, which exceeds the token budget of 28",
+		},
+		{
+			name:   "current messages exceed the budget",
+			budget: aggregates.Budget{MaxTokens: 10, Strategy: aggregates.DropOldest},
+			err:    "The current messages require about 14 tokens, which exceeds the token budget of 10",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := memory.New()
+			client := mocks.NewMockProvider(t)
+			manager := ct.New(store)
+			ai := assistant.New(map[string]assistant.Provider{"test": client}, manager, nil, nil, nil, assistant.Config{})
+			ctx := context.Background()
+
+			ids := make(map[string]string)
+			history := []shared.Message{}
+			for i, name := range []string{"m1", "m2", "m3", "m4"} {
+				role := shared.UserRole
+				if i%2 == 1 {
+					role = shared.AssistantRole
+				}
+				id := uuid.NewString()
+				ids[id] = name
+				history = append(history, shared.Message{
+					ID:        id,
+					Role:      role,
+					Content:   content,
+					CreatedAt: time.Now().UTC(),
+					Pinned:    name == "m2",
+				})
+			}
+			conversationContext := shared.Context{
+				ID:        uuid.NewString(),
+				Project:   shared.DefaultProject,
+				Name:      "budget",
+				CreatedAt: time.Now().UTC(),
+				Messages:  history,
+			}
+			err := manager.CreateContext(ctx, conversationContext)
+			assert.NoError(t, err)
+			current := uuid.NewString()
+			ids[current] = "current"
+
+			if c.err == "" {
+				client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
+					&aggregates.Answer{
+						Results: []aggregates.Result{{Text: "answer"}},
+					}, nil)
+			}
+			budget := c.budget
+			answer, err := ai.Pipeline(ctx, shared.DefaultProject, aggregates.QueryOptions{Provider: "test", Budget: &budget}, shared.ContextOptions{}, conversationContext.ID, []shared.Message{
+				{
+					ID:        current,
+					Role:      shared.UserRole,
+					Content:   content,
+					CreatedAt: time.Now().UTC(),
+				},
+			})
+			if c.err != "" {
+				assert.ErrorContains(t, err, c.err)
+				return
+			}
+			assert.NoError(t, err)
+
+			sent := []string{}
+			for _, message := range client.Calls[0].Arguments[1].([]shared.Message) {
+				sent = append(sent, ids[message.ID])
+			}
+			assert.Equal(t, c.sent, sent)
+			dropped := []string{}
+			for _, id := range answer.Truncation.DroppedMessages {
+				dropped = append(dropped, ids[id])
+			}
+			assert.Equal(t, c.dropped, dropped)
+			assert.Equal(t, uint64(14*len(c.sent)), answer.Truncation.EstimatedInputTokens)
+
+			// truncation doesn't modify the stored context
+			result, err := store.GetContext(ctx, shared.DefaultProject, conversationContext.ID)
+			assert.NoError(t, err)
+			assert.Len(t, result.Messages, 6)
+		})
+	}
+}
+
+func TestPipelineBudgetToolCalls(t *testing.T) {
+	store := memory.New()
+	client := mocks.NewMockProvider(t)
+	manager := ct.New(store)
+	ai := assistant.New(map[string]assistant.Provider{"test": client}, manager, nil, nil, nil, assistant.Config{})
+	ctx := context.Background()
+
+	conversationContext := shared.Context{
+		ID:        uuid.NewString(),
+		Project:   shared.DefaultProject,
+		Name:      "budget",
+		CreatedAt: time.Now().UTC(),
+		Messages: []shared.Message{
+			{
+				ID:        uuid.NewString(),
+				Role:      shared.AssistantRole,
+				CreatedAt: time.Now().UTC(),
+				ToolCalls: []shared.ToolCall{
+					{ID: "call1", Name: "weather", Arguments: json.RawMessage(`{}`)},
+				},
+			},
+			{
+				ID:         uuid.NewString(),
+				Role:       shared.ToolRole,
+				Content:    "sunny",
+				ToolCallID: "call1",
+				CreatedAt:  time.Now().UTC(),
+			},
+			{
+				ID:        uuid.NewString(),
+				Role:      shared.AssistantRole,
+				Content:   "it's sunny",
+				CreatedAt: time.Now().UTC(),
+			},
+		},
+	}
+	err := manager.CreateContext(ctx, conversationContext)
+	assert.NoError(t, err)
+
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
+		&aggregates.Answer{
+			Results: []aggregates.Result{{Text: "answer"}},
+		}, nil)
+	options := aggregates.QueryOptions{
+		Provider: "test",
+		Budget:   &aggregates.Budget{MaxTokens: 20, Strategy: aggregates.DropOldest},
+	}
+	message, err := shared.NewMessage(shared.UserRole, "hello")
+	assert.NoError(t, err)
+	answer, err := ai.Pipeline(ctx, shared.DefaultProject, options, shared.ContextOptions{}, conversationContext.ID, []shared.Message{*message})
+	assert.NoError(t, err)
+
+	// the tool call and its result are dropped together
+	assert.Equal(t, []string{conversationContext.Messages[0].ID, conversationContext.Messages[1].ID}, answer.Truncation.DroppedMessages)
+	sent := client.Calls[0].Arguments[1].([]shared.Message)
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "it's sunny", sent[0].Content)
+	assert.Equal(t, "hello", sent[1].Content)
+}
+
+func TestCompact(t *testing.T) {
+	store := memory.New()
+	client := mocks.NewMockProvider(t)
+	manager := ct.New(store)
+	ai := assistant.New(map[string]assistant.Provider{"test": client}, manager, nil, nil, nil, assistant.Config{})
+	ctx := context.Background()
+
+	messages := []shared.Message{
+		{ID: uuid.NewString(), Role: shared.UserRole, Content: "what's the weather in Paris?", CreatedAt: time.Now().UTC()},
+		{ID: uuid.NewString(), Role: shared.AssistantRole, CreatedAt: time.Now().UTC(), ToolCalls: []shared.ToolCall{
+			{ID: "call1", Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+		}},
+		{ID: uuid.NewString(), Role: shared.ToolRole, Content: "sunny", ToolCallID: "call1", CreatedAt: time.Now().UTC()},
+		{ID: uuid.NewString(), Role: shared.AssistantRole, Content: "it's sunny", CreatedAt: time.Now().UTC()},
+	}
+	conversationContext := shared.Context{
+		ID:        uuid.NewString(),
+		Project:   shared.DefaultProject,
+		Name:      "compact",
+		CreatedAt: time.Now().UTC(),
+		Messages:  messages,
+	}
+	err := manager.CreateContext(ctx, conversationContext)
+	assert.NoError(t, err)
+
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
+		&aggregates.Answer{
+			Results:      []aggregates.Result{{Text: "the user asked for the weather"}},
+			InputTokens:  100,
+			OutputTokens: 10,
+		}, nil)
+
+	_, err = ai.Compact(ctx, shared.DefaultProject, conversationContext.ID, aggregates.CompactOptions{Provider: "test", KeepLast: 4})
+	assert.ErrorContains(t, err, "there is nothing to compact")
+
+	// keeping the last 2 messages would separate the tool call from its result
+	compaction, err := ai.Compact(ctx, shared.DefaultProject, conversationContext.ID, aggregates.CompactOptions{Provider: "test", Model: "corbi-3.5", KeepLast: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, compaction.CompactedMessages)
+	assert.Equal(t, uint64(100), compaction.InputTokens)
+	assert.Equal(t, uint64(10), compaction.OutputTokens)
+
+	sent := client.Calls[0].Arguments[1].([]shared.Message)
+	assert.Len(t, sent, 1)
+	assert.True(t, strings.HasPrefix(sent[0].Content, assistant.DefaultCompactionPrompt))
+	assert.True(t, strings.HasSuffix(sent[0].Content, "user: what's the weather in Paris?"))
+	options := client.Calls[0].Arguments[2].(aggregates.QueryOptions)
+	assert.Equal(t, "corbi-3.5", options.Model)
+	assert.Equal(t, uint64(aggregates.DefaultCompactionMaxTokens), options.MaxTokens)
+
+	result, err := store.GetContext(ctx, shared.DefaultProject, conversationContext.ID)
+	assert.NoError(t, err)
+	assert.Len(t, result.Messages, 4)
+	assert.Equal(t, compaction.SummaryMessage, result.Messages[0].ID)
+	assert.Contains(t, result.Messages[0].Content, "the user asked for the weather")
+	for i := 1; i < 4; i++ {
+		assert.Equal(t, messages[i].ID, result.Messages[i].ID)
+	}
+
+	archive, err := store.GetContext(ctx, shared.DefaultProject, compaction.ArchiveContext)
+	assert.NoError(t, err)
+	assert.Contains(t, result.Messages[0].Content, archive.Name)
+	assert.Len(t, archive.Messages, 1)
+	assert.Equal(t, messages[0].Content, archive.Messages[0].Content)
+	assert.NotEqual(t, messages[0].ID, archive.Messages[0].ID)
+
+	// the summary can be compacted again
+	compaction, err = ai.Compact(ctx, shared.DefaultProject, conversationContext.ID, aggregates.CompactOptions{Provider: "test", KeepLast: 1, Prompt: "summarize"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, compaction.CompactedMessages)
+	sent = client.Calls[1].Arguments[1].([]shared.Message)
+	assert.True(t, strings.HasPrefix(sent[0].Content, "summarize\n\nuser: Summary of the previous messages"))
+	assert.Contains(t, sent[0].Content, "assistant: [call to tool weather with arguments {\"city\":\"Paris\"}]\n\ntool: sunny")
+
+	result, err = store.GetContext(ctx, shared.DefaultProject, conversationContext.ID)
+	assert.NoError(t, err)
+	assert.Len(t, result.Messages, 2)
+	assert.Equal(t, compaction.SummaryMessage, result.Messages[0].ID)
+	assert.Equal(t, messages[3].ID, result.Messages[1].ID)
+	archive, err = store.GetContext(ctx, shared.DefaultProject, compaction.ArchiveContext)
+	assert.NoError(t, err)
+	assert.Len(t, archive.Messages, 3)
+	assert.Equal(t, messages[1].ToolCalls, archive.Messages[1].ToolCalls)
+}
+
+func TestPipelineAutoCompact(t *testing.T) {
+	store := memory.New()
+	client := mocks.NewMockProvider(t)
+	manager := ct.New(store)
+	ai := assistant.New(map[string]assistant.Provider{"test": client}, manager, nil, nil, nil, assistant.Config{
+		Compaction: assistant.CompactionConfig{
+			Threshold: 3,
+			Options:   aggregates.CompactOptions{Provider: "test", KeepLast: 2},
+		},
+	})
+	ctx := context.Background()
+
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
+		&aggregates.Answer{
+			Results: []aggregates.Result{{Text: "answer"}},
+		}, nil)
+	message, err := shared.NewMessage(shared.UserRole, "hello")
+	assert.NoError(t, err)
+	answer, err := ai.Pipeline(ctx, shared.DefaultProject, aggregates.QueryOptions{Provider: "test"}, shared.ContextOptions{Name: "auto"}, "", []shared.Message{*message})
+	assert.NoError(t, err)
+	assert.Nil(t, answer.Compaction)
+
+	message, err = shared.NewMessage(shared.UserRole, "hello again")
+	assert.NoError(t, err)
+	answer, err = ai.Pipeline(ctx, shared.DefaultProject, aggregates.QueryOptions{Provider: "test"}, shared.ContextOptions{}, answer.Context, []shared.Message{*message})
+	assert.NoError(t, err)
+	assert.NotNil(t, answer.Compaction)
+	assert.Equal(t, 2, answer.Compaction.CompactedMessages)
+
+	result, err := store.GetContext(ctx, shared.DefaultProject, answer.Context)
+	assert.NoError(t, err)
+	assert.Len(t, result.Messages, 3)
+	assert.Equal(t, "hello again", result.Messages[1].Content)
+}
+
+func TestPipelineRecordsUsage(t *testing.T) {
+	store := memory.New()
+	client := mocks.NewMockProvider(t)
+	manager := ct.New(store)
+	usageStore := usagememory.New()
+	recorder := usage.New(usageStore, usage.Config{})
+	ai := assistant.New(map[string]assistant.Provider{"test": client}, manager, nil, nil, recorder, assistant.Config{})
+	ctx := usage.WithToken(context.Background(), "token-id")
+
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
+		&aggregates.Answer{
+			Results:      []aggregates.Result{{Text: "answer"}},
+			Model:        "model-a-2026",
+			InputTokens:  10,
+			OutputTokens: 20,
+		}, nil).Once()
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("provider error")).Once()
+	options := aggregates.QueryOptions{
+		Provider: "test",
+		Model:    "model-a",
+	}
+	message, err := shared.NewMessage(shared.UserRole, "hello")
+	assert.NoError(t, err)
+	answer, err := ai.Pipeline(ctx, shared.DefaultProject, options, shared.ContextOptions{Name: "usage"}, "", []shared.Message{*message})
+	assert.NoError(t, err)
+	message, err = shared.NewMessage(shared.UserRole, "hello again")
+	assert.NoError(t, err)
+	_, err = ai.Pipeline(ctx, shared.DefaultProject, options, shared.ContextOptions{}, answer.Context, []shared.Message{*message})
+	assert.Error(t, err)
+
+	records, err := usageStore.ListUsageRecords(ctx, shared.DefaultProject, usagedata.Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	for _, record := range records {
+		assert.Equal(t, shared.DefaultProject, record.Project)
+		assert.Equal(t, answer.Context, record.Context)
+		assert.Equal(t, "token-id", record.Token)
+		assert.Equal(t, "test", record.Provider)
+	}
+	// the model used by the provider is recorded when the call succeeded
+	assert.Equal(t, "model-a-2026", records[0].Model)
+	assert.Equal(t, "model-a", records[1].Model)
+	assert.Equal(t, usagedata.SuccessStatus, records[0].Status)
+	assert.Equal(t, uint64(10), records[0].InputTokens)
+	assert.Equal(t, uint64(20), records[0].OutputTokens)
+	assert.Equal(t, usagedata.ErrorStatus, records[1].Status)
+	assert.Equal(t, uint64(0), records[1].InputTokens)
+}
+
+func TestPipelineCountsQuotas(t *testing.T) {
+	store := memory.New()
+	chat := mocks.NewMockProvider(t)
+	summary := mocks.NewMockProvider(t)
+	manager := ct.New(store)
+	limiter := limits.New(limits.Config{DailyInputTokens: 1000})
+	providers := map[string]assistant.Provider{"chat": chat, "summary": summary}
+	ai := assistant.New(providers, manager, nil, nil, usage.Recorders{limiter}, assistant.Config{
+		Compaction: assistant.CompactionConfig{
+			Threshold: 1,
+			Options:   aggregates.CompactOptions{Provider: "summary", KeepLast: 1},
+		},
+	})
+	ctx := limits.WithTenant(context.Background(), "tenant-a")
+
+	chat.On("DefaultModel").Return("chat-default")
+	assert.Equal(t, "chat-default", ai.Model("chat", ""))
+	assert.Equal(t, "chat-other", ai.Model("chat", "chat-other"))
+	assert.Equal(t, "", ai.Model("unknown", ""))
+
+	chat.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
+		&aggregates.Answer{
+			Results:      []aggregates.Result{{Text: "answer"}},
+			Model:        "chat-default",
+			InputTokens:  10,
+			OutputTokens: 20,
+		}, nil).Once()
+	summary.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(
+		&aggregates.Answer{
+			Results:      []aggregates.Result{{Text: "summary"}},
+			Model:        "summary-model",
+			InputTokens:  5,
+			OutputTokens: 1,
+		}, nil).Once()
+	message, err := shared.NewMessage(shared.UserRole, "hello")
+	assert.NoError(t, err)
+	answer, err := ai.Pipeline(ctx, shared.DefaultProject, aggregates.QueryOptions{Provider: "chat"}, shared.ContextOptions{Name: "quotas"}, "", []shared.Message{*message})
+	assert.NoError(t, err)
+	assert.NotNil(t, answer.Compaction)
+
+	// the conversation and the automatic compaction are counted with the models used by the providers
+	assert.Equal(t, []limitsdata.ModelUsage{
+		{Provider: "chat", Model: "chat-default", InputTokens: 10, OutputTokens: 20},
+		{Provider: "summary", Model: "summary-model", InputTokens: 5, OutputTokens: 1},
+	}, limiter.Usage("tenant-a").Models)
+}
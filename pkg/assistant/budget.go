@@ -0,0 +1,153 @@
+package assistant
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/appclacks/maizai/internal/tokens"
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
+	er "github.com/mcorbin/corbierror"
+)
+
+// TokenEstimator is implemented by providers able to estimate the number of tokens of a message.
+// A default estimation is used for the providers not implementing it.
+type TokenEstimator interface {
+	EstimateTokens(message shared.Message) uint64
+}
+
+func estimator(provider Provider) func(shared.Message) uint64 {
+	if e, ok := provider.(TokenEstimator); ok {
+		return e.EstimateTokens
+	}
+	return tokens.Default.Message
+}
+
+// unit is a group of consecutive messages which should be dropped together:
+// an assistant message calling tools must be followed by the tools results.
+type unit struct {
+	start     int
+	end       int
+	tokens    uint64
+	protected bool
+}
+
+func units(messages []shared.Message) []unit {
+	result := []unit{}
+	for i := 0; i < len(messages); {
+		end := i + 1
+		if len(messages[i].ToolCalls) != 0 {
+			for end < len(messages) && messages[end].Role == shared.ToolRole {
+				end++
+			}
+		}
+		result = append(result, unit{start: i, end: end})
+		i = end
+	}
+	return result
+}
+
+func protected(budget aggregates.Budget, messages []shared.Message, u unit) bool {
+	for i := u.start; i < u.end; i++ {
+		switch budget.Strategy {
+		case aggregates.KeepFirstLast:
+			if i < budget.KeepFirst || i >= len(messages)-budget.KeepLast {
+				return true
+			}
+		case aggregates.KeepPinned:
+			if messages[i].Pinned {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// truncate drops messages from the conversation history to respect the token budget.
+// The messages after the history (the current turn) are always kept.
+// The system prompt and the tools definitions are included in the budget.
+func (a *Assistant) truncate(conversation []shared.Message, historySize int, options aggregates.QueryOptions) ([]shared.Message, *aggregates.Truncation, error) {
+	if options.Budget == nil {
+		return conversation, nil, nil
+	}
+	provider, ok := a.providers[options.Provider]
+	if !ok {
+		return nil, nil, fmt.Errorf("AI client %s not found", options.Provider)
+	}
+	budget := *options.Budget
+	estimate := estimator(provider)
+	total := tokens.Default.Text(options.System)
+	if len(options.Tools) != 0 {
+		tools, err := json.Marshal(options.Tools)
+		if err != nil {
+			return nil, nil, err
+		}
+		total += tokens.Default.Text(string(tools))
+	}
+	for _, message := range conversation[historySize:] {
+		total += estimate(message)
+	}
+	if total > budget.MaxTokens {
+		return nil, nil, er.Newf("The current messages require about %d tokens, which exceeds the token budget of %d", er.BadRequest, true, total, budget.MaxTokens)
+	}
+	history := conversation[:historySize]
+	groups := units(history)
+	for i := range groups {
+		for j := groups[i].start; j < groups[i].end; j++ {
+			groups[i].tokens += estimate(history[j])
+		}
+		groups[i].protected = protected(budget, history, groups[i])
+		total += groups[i].tokens
+	}
+	dropped := make(map[int]bool)
+	for i := 0; i < len(groups) && total > budget.MaxTokens; i++ {
+		if groups[i].protected {
+			continue
+		}
+		dropped[i] = true
+		total -= groups[i].tokens
+	}
+	if total > budget.MaxTokens {
+		return nil, nil, er.Newf("The conversation requires about %d tokens after truncation with the %s strategy, which exceeds the token budget of %d", er.BadRequest, true, total, budget.Strategy, budget.MaxTokens)
+	}
+	truncation := &aggregates.Truncation{
+		DroppedMessages:      []string{},
+		EstimatedInputTokens: total,
+	}
+	result := []shared.Message{}
+	for i, group := range groups {
+		if dropped[i] {
+			for _, message := range history[group.start:group.end] {
+				truncation.DroppedMessages = append(truncation.DroppedMessages, message.ID)
+			}
+			continue
+		}
+		result = append(result, history[group.start:group.end]...)
+	}
+	result = append(result, conversation[historySize:]...)
+	return result, truncation, nil
+}
+
+// mergeTruncation merges the truncation of a provider call into the truncation of the conversation
+func mergeTruncation(conversation *aggregates.Truncation, call *aggregates.Truncation) *aggregates.Truncation {
+	if call == nil {
+		return conversation
+	}
+	if conversation == nil {
+		return call
+	}
+	for _, id := range call.DroppedMessages {
+		found := false
+		for _, existing := range conversation.DroppedMessages {
+			if existing == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			conversation.DroppedMessages = append(conversation.DroppedMessages, id)
+		}
+	}
+	conversation.EstimatedInputTokens = call.EstimatedInputTokens
+	return conversation
+}
@@ -0,0 +1,162 @@
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/google/uuid"
+	er "github.com/mcorbin/corbierror"
+)
+
+const DefaultCompactionPrompt = "Summarize the following conversation. Keep the facts, decisions, names, figures and open questions needed to continue the conversation. Answer with the summary only."
+
+type CompactionConfig struct {
+	// Threshold is the number of messages above which a context is compacted
+	// at the end of a conversation. Automatic compaction is disabled if 0.
+	Threshold int
+	Options   aggregates.CompactOptions
+}
+
+// transcript renders messages as text so they can be summarized by any provider,
+// even if the messages contain tool calls.
+func transcript(messages []shared.Message) string {
+	lines := []string{}
+	for _, message := range messages {
+		content := message.Content
+		for _, part := range message.Parts {
+			switch part.Type {
+			case shared.TextPart:
+				content += "\n" + part.Text
+			case shared.ImagePart:
+				content += "\n[image]"
+			case shared.DocumentPart:
+				content += "\n[document]"
+			}
+		}
+		for _, toolCall := range message.ToolCalls {
+			content += fmt.Sprintf("\n[call to tool %s with arguments %s]", toolCall.Name, string(toolCall.Arguments))
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", message.Role, strings.TrimSpace(content)))
+	}
+	return strings.Join(lines, "\n\n")
+}
+
+// Compact asks the provider to summarize the oldest messages of a context, and replaces them
+// by the summary. The original messages are kept in a new archive context.
+func (a *Assistant) Compact(ctx context.Context, project string, contextID string, options aggregates.CompactOptions) (*aggregates.Compaction, error) {
+	err := options.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if options.MaxTokens == 0 {
+		options.MaxTokens = aggregates.DefaultCompactionMaxTokens
+	}
+	if options.Prompt == "" {
+		options.Prompt = DefaultCompactionPrompt
+	}
+	context, err := a.ctxManager.GetContext(ctx, project, contextID)
+	if err != nil {
+		return nil, err
+	}
+	boundary := len(context.Messages) - options.KeepLast
+	// tool results are kept with the tool calls
+	for boundary > 0 && boundary < len(context.Messages) && context.Messages[boundary].Role == shared.ToolRole {
+		boundary--
+	}
+	if boundary <= 0 {
+		return nil, er.Newf("Context %s has %d messages, there is nothing to compact when keeping the last %d messages", er.BadRequest, true, context.Name, len(context.Messages), options.KeepLast)
+	}
+	compacted := context.Messages[:boundary]
+
+	request, err := shared.NewMessage(shared.UserRole, fmt.Sprintf("%s\n\n%s", options.Prompt, transcript(compacted)))
+	if err != nil {
+		return nil, err
+	}
+	queryOptions := aggregates.QueryOptions{
+		Provider:  options.Provider,
+		Model:     options.Model,
+		MaxTokens: options.MaxTokens,
+	}
+	start := time.Now()
+	answer, err := a.Message(ctx, []shared.Message{*request}, queryOptions)
+	a.recordUsage(ctx, project, context.ID, queryOptions, start, answer, err)
+	if err != nil {
+		return nil, err
+	}
+	texts := []string{}
+	for _, result := range answer.Results {
+		if result.Text != "" {
+			texts = append(texts, result.Text)
+		}
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("The AI provider returned an empty summary for context %s", context.Name)
+	}
+
+	archiveID, err := uuid.NewV6()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	archive := shared.Context{
+		ID:          archiveID.String(),
+		Project:     project,
+		Name:        fmt.Sprintf("%s-archive-%s", context.Name, archiveID.String()),
+		Description: fmt.Sprintf("Messages compacted from context %s", context.Name),
+		CreatedAt:   now,
+	}
+	messageIDs := []string{}
+	for _, message := range compacted {
+		messageIDs = append(messageIDs, message.ID)
+		// the archive contains copies of the messages
+		id, err := uuid.NewV6()
+		if err != nil {
+			return nil, err
+		}
+		message.ID = id.String()
+		archive.Messages = append(archive.Messages, message)
+	}
+	summary, err := shared.NewMessage(shared.UserRole, fmt.Sprintf("Summary of the previous messages of the conversation (the original messages are archived in context %s):\n\n%s", archive.Name, strings.Join(texts, "\n")))
+	if err != nil {
+		return nil, err
+	}
+	err = a.ctxManager.CompactContext(ctx, project, context.ID, messageIDs, *summary, archive)
+	if err != nil {
+		return nil, err
+	}
+	return &aggregates.Compaction{
+		Context:           context.ID,
+		ArchiveContext:    archive.ID,
+		SummaryMessage:    summary.ID,
+		CompactedMessages: len(compacted),
+		InputTokens:       answer.InputTokens,
+		OutputTokens:      answer.OutputTokens,
+	}, nil
+}
+
+// autoCompact compacts the context if it exceeds the configured threshold.
+// Errors are logged: a failed compaction doesn't fail the conversation.
+func (a *Assistant) autoCompact(ctx context.Context, project string, contextID string) *aggregates.Compaction {
+	if a.config.Compaction.Threshold <= 0 {
+		return nil
+	}
+	context, err := a.ctxManager.GetContext(ctx, project, contextID)
+	if err != nil {
+		slog.Error(fmt.Sprintf("fail to get context %s for compaction: %s", contextID, err.Error()))
+		return nil
+	}
+	if len(context.Messages) <= a.config.Compaction.Threshold {
+		return nil
+	}
+	compaction, err := a.Compact(ctx, project, contextID, a.config.Compaction.Options)
+	if err != nil {
+		slog.Error(fmt.Sprintf("fail to compact context %s: %s", contextID, err.Error()))
+		return nil
+	}
+	return compaction
+}
@@ -0,0 +1,97 @@
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	"github.com/appclacks/maizai/pkg/shared"
+	er "github.com/mcorbin/corbierror"
+)
+
+const DefaultMaxToolIterations = 10
+
+// ToolRunner is a tool registered in MaizAI and executed server-side
+type ToolRunner interface {
+	Definition() aggregates.Tool
+	Run(ctx context.Context, arguments json.RawMessage) (string, error)
+}
+
+func (a *Assistant) ListTools() []aggregates.Tool {
+	result := []aggregates.Tool{}
+	for _, tool := range a.tools {
+		result = append(result, tool.Definition())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// withServerTools adds the definitions of the server-side tools enabled
+// for the query to the tools sent to the provider
+func (a *Assistant) withServerTools(options aggregates.QueryOptions) (aggregates.QueryOptions, error) {
+	if len(options.ServerTools) == 0 {
+		return options, nil
+	}
+	tools := []aggregates.Tool{}
+	tools = append(tools, options.Tools...)
+	for _, name := range options.ServerTools {
+		tool, ok := a.tools[name]
+		if !ok {
+			return options, er.Newf("tool %s doesn't exist", er.BadRequest, true, name)
+		}
+		tools = append(tools, tool.Definition())
+	}
+	options.Tools = tools
+	return options, nil
+}
+
+// runTools executes the calls targeting the server-side tools enabled for the query.
+// It returns the tool messages to add to the conversation, and true if the provider
+// should be queried again, which is the case when every tool call was executed by MaizAI.
+func (a *Assistant) runTools(ctx context.Context, options aggregates.QueryOptions, results []aggregates.Result, iteration int, onStep func(aggregates.ToolStep)) ([]shared.Message, bool, error) {
+	enabled := make(map[string]bool)
+	for _, name := range options.ServerTools {
+		enabled[name] = true
+	}
+	messages := []shared.Message{}
+	clientCalls := 0
+	for _, result := range results {
+		if result.ToolCall == nil {
+			continue
+		}
+		call := *result.ToolCall
+		if !enabled[call.Name] {
+			clientCalls++
+			continue
+		}
+		step := aggregates.ToolStep{
+			Iteration: iteration,
+			ToolCall:  call,
+		}
+		output, err := a.tools[call.Name].Run(ctx, call.Arguments)
+		if err != nil {
+			// the error is sent back to the provider which can decide what to do with it
+			slog.Warn(fmt.Sprintf("tool %s failed: %s", call.Name, err.Error()))
+			output = fmt.Sprintf("error: %s", err.Error())
+			step.Error = true
+		}
+		if output == "" {
+			output = "the tool returned no output"
+		}
+		step.Output = output
+		message, err := shared.NewToolMessage(call.ID, output)
+		if err != nil {
+			return nil, false, err
+		}
+		messages = append(messages, *message)
+		if onStep != nil {
+			onStep(step)
+		}
+	}
+	return messages, len(messages) != 0 && clientCalls == 0, nil
+}
@@ -0,0 +1,44 @@
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/assistant/aggregates"
+	usagedata "github.com/appclacks/maizai/pkg/usage/aggregates"
+)
+
+type UsageRecorder interface {
+	Record(ctx context.Context, record usagedata.Record) error
+}
+
+// recordUsage stores the usage of a provider call started at start.
+// Errors are logged: a failed recording doesn't fail the conversation.
+func (a *Assistant) recordUsage(ctx context.Context, project string, contextID string, options aggregates.QueryOptions, start time.Time, answer *aggregates.Answer, callErr error) {
+	if a.recorder == nil {
+		return
+	}
+	record := usagedata.Record{
+		Project:  project,
+		Context:  contextID,
+		Provider: options.Provider,
+		Model:    options.Model,
+		Latency:  time.Since(start),
+		Status:   usagedata.SuccessStatus,
+	}
+	if callErr != nil {
+		record.Status = usagedata.ErrorStatus
+	}
+	if answer != nil {
+		record.Model = answerModel(answer, options.Model)
+		record.InputTokens = answer.InputTokens
+		record.OutputTokens = answer.OutputTokens
+	}
+	// the call is recorded even if the client went away
+	err := a.recorder.Record(context.WithoutCancel(ctx), record)
+	if err != nil {
+		slog.Error(fmt.Sprintf("fail to record usage for provider %s: %s", options.Provider, err.Error()))
+	}
+}
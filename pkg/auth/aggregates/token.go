@@ -0,0 +1,90 @@
+package aggregates
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/shared"
+)
+
+const (
+	// ReadScope allows the read-only endpoints
+	ReadScope = "read"
+	// ConversationScope allows the read-only endpoints, the conversations
+	// and the management of the contexts
+	ConversationScope = "conversation"
+	// AdminScope allows all the endpoints, including the tokens management
+	AdminScope = "admin"
+)
+
+// implied contains the scopes granted by each scope
+var implied = map[string][]string{
+	ReadScope:         {ReadScope},
+	ConversationScope: {ReadScope, ConversationScope},
+	AdminScope:        {ReadScope, ConversationScope, AdminScope},
+}
+
+func ValidateScope(scope string) error {
+	if _, ok := implied[scope]; !ok {
+		return fmt.Errorf("Invalid scope %s, supported scopes are %s, %s and %s", scope, ReadScope, ConversationScope, AdminScope)
+	}
+	return nil
+}
+
+type Token struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Project is the only project the token can access. The token
+	// can access all the projects if it's empty.
+	Project string `json:"project,omitempty"`
+	// Hash is the SHA-256 hash of the token. The token itself is never stored.
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `json:"created-at"`
+}
+
+func (t Token) Validate() error {
+	if t.Name == "" {
+		return errors.New("The token name is mandatory")
+	}
+	if len(t.Scopes) == 0 {
+		return errors.New("The token should have at least one scope")
+	}
+	for _, scope := range t.Scopes {
+		err := ValidateScope(scope)
+		if err != nil {
+			return err
+		}
+	}
+	if t.Project != "" {
+		return shared.ValidateProject(t.Project)
+	}
+	return nil
+}
+
+// Identifier returns the token ID, or its name for the admin token
+// which is not stored and has no ID
+func (t Token) Identifier() string {
+	if t.ID == "" {
+		return t.Name
+	}
+	return t.ID
+}
+
+// AllowsProject returns true if the token can access the project
+func (t Token) AllowsProject(project string) bool {
+	return t.Project == "" || t.Project == project
+}
+
+// Allows returns true if one of the token scopes grants the scope
+func (t Token) Allows(scope string) bool {
+	for _, s := range t.Scopes {
+		for _, granted := range implied[s] {
+			if granted == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
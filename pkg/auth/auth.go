@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+
+	"github.com/appclacks/maizai/internal/id"
+	"github.com/appclacks/maizai/pkg/auth/aggregates"
+	er "github.com/mcorbin/corbierror"
+)
+
+const tokenPrefix = "maizai_"
+
+type Store interface {
+	CreateToken(ctx context.Context, token aggregates.Token) error
+	ListTokens(ctx context.Context) ([]aggregates.Token, error)
+	GetTokenByHash(ctx context.Context, hash string) (*aggregates.Token, error)
+	DeleteToken(ctx context.Context, id string) error
+}
+
+type Config struct {
+	// AdminToken is a token with the admin scope which is not stored.
+	// It can be used to create the first tokens.
+	AdminToken string
+}
+
+type Manager struct {
+	store  Store
+	config Config
+}
+
+func New(store Store, config Config) *Manager {
+	return &Manager{
+		store:  store,
+		config: config,
+	}
+}
+
+// Hash returns the hash stored for a token
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateToken creates a token with the given scopes, restricted to the project if it's not empty.
+// The token value is returned with the token metadata: it can't be retrieved later.
+func (m *Manager) CreateToken(ctx context.Context, name string, project string, scopes []string) (*aggregates.Token, string, error) {
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return nil, "", err
+	}
+	value := tokenPrefix + hex.EncodeToString(random)
+	tokenID, err := id.New()
+	if err != nil {
+		return nil, "", err
+	}
+	token := aggregates.Token{
+		ID:        tokenID,
+		Name:      name,
+		Scopes:    scopes,
+		Project:   project,
+		Hash:      Hash(value),
+		CreatedAt: time.Now().UTC(),
+	}
+	err = token.Validate()
+	if err != nil {
+		return nil, "", er.New(err.Error(), er.BadRequest, true)
+	}
+	err = m.store.CreateToken(ctx, token)
+	if err != nil {
+		return nil, "", err
+	}
+	return &token, value, nil
+}
+
+func (m *Manager) ListTokens(ctx context.Context) ([]aggregates.Token, error) {
+	return m.store.ListTokens(ctx)
+}
+
+func (m *Manager) RevokeToken(ctx context.Context, tokenID string) error {
+	err := id.Validate(tokenID, "Invalid token ID")
+	if err != nil {
+		return err
+	}
+	return m.store.DeleteToken(ctx, tokenID)
+}
+
+// Authenticate returns the token matching the value
+func (m *Manager) Authenticate(ctx context.Context, value string) (*aggregates.Token, error) {
+	if m.config.AdminToken != "" && subtle.ConstantTimeCompare([]byte(value), []byte(m.config.AdminToken)) == 1 {
+		return &aggregates.Token{
+			Name:   "admin",
+			Scopes: []string{aggregates.AdminScope},
+		}, nil
+	}
+	token, err := m.store.GetTokenByHash(ctx, Hash(value))
+	if err != nil {
+		if e, ok := err.(*er.Error); ok && e.Type == er.NotFound {
+			return nil, er.New("Invalid token", er.Unauthorized, true)
+		}
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,68 @@
+package auth_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/tokenstore/memory"
+	"github.com/appclacks/maizai/pkg/auth"
+	"github.com/appclacks/maizai/pkg/auth/aggregates"
+	"github.com/google/uuid"
+	er "github.com/mcorbin/corbierror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokens(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	manager := auth.New(store, auth.Config{AdminToken: "bootstrap"})
+
+	token, value, err := manager.CreateToken(ctx, "ci", "", []string{aggregates.ConversationScope})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, "maizai_"))
+	assert.Equal(t, auth.Hash(value), token.Hash)
+
+	authenticated, err := manager.Authenticate(ctx, value)
+	assert.NoError(t, err)
+	assert.Equal(t, token.ID, authenticated.ID)
+	assert.True(t, authenticated.Allows(aggregates.ReadScope))
+	assert.True(t, authenticated.Allows(aggregates.ConversationScope))
+	assert.False(t, authenticated.Allows(aggregates.AdminScope))
+
+	admin, err := manager.Authenticate(ctx, "bootstrap")
+	assert.NoError(t, err)
+	assert.True(t, admin.Allows(aggregates.AdminScope))
+
+	_, err = manager.Authenticate(ctx, "invalid")
+	assert.ErrorContains(t, err, "Invalid token")
+	assert.Equal(t, er.Unauthorized, err.(*er.Error).Type)
+
+	_, _, err = manager.CreateToken(ctx, "invalid", "", []string{"superuser"})
+	assert.ErrorContains(t, err, "Invalid scope superuser")
+	_, _, err = manager.CreateToken(ctx, "", "", []string{aggregates.ReadScope})
+	assert.ErrorContains(t, err, "The token name is mandatory")
+	_, _, err = manager.CreateToken(ctx, "empty", "", nil)
+	assert.ErrorContains(t, err, "at least one scope")
+	_, _, err = manager.CreateToken(ctx, "invalid-project", "Team A", []string{aggregates.ReadScope})
+	assert.ErrorContains(t, err, "Invalid project")
+
+	restricted, _, err := manager.CreateToken(ctx, "team-a", "team-a", []string{aggregates.ReadScope})
+	assert.NoError(t, err)
+	assert.True(t, restricted.AllowsProject("team-a"))
+	assert.False(t, restricted.AllowsProject("team-b"))
+	assert.True(t, token.AllowsProject("team-b"))
+
+	tokens, err := manager.ListTokens(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 2)
+
+	err = manager.RevokeToken(ctx, token.ID)
+	assert.NoError(t, err)
+	_, err = manager.Authenticate(ctx, value)
+	assert.ErrorContains(t, err, "Invalid token")
+	err = manager.RevokeToken(ctx, uuid.NewString())
+	assert.ErrorContains(t, err, "doesn't exist")
+	err = manager.RevokeToken(ctx, "foo")
+	assert.ErrorContains(t, err, "Invalid token ID")
+}
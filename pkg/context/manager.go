@@ -4,26 +4,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/appclacks/maizai/internal/id"
 	"github.com/appclacks/maizai/pkg/shared"
 	"github.com/google/uuid"
+	er "github.com/mcorbin/corbierror"
 )
 
 type ContextStore interface {
-	GetContext(ctx context.Context, id string) (*shared.Context, error)
+	GetContext(ctx context.Context, project string, id string) (*shared.Context, error)
 	CreateContext(ctx context.Context, context shared.Context) error
-	ContextExists(ctx context.Context, id string) (bool, error)
-	ContextExistsByName(ctx context.Context, name string) (bool, error)
-	DeleteContext(ctx context.Context, id string) error
-	ListContexts(ctx context.Context) ([]shared.ContextMetadata, error)
-	AddMessages(ctx context.Context, id string, messages []shared.Message) error
-	DeleteContextMessage(ctx context.Context, id string) error
-	UpdateContextMessage(ctx context.Context, messageID string, role string, content string) error
-	DeleteContextSourceContext(ctx context.Context, contextID string, sourceContextID string) error
-	CreateContextSourceContext(ctx context.Context, contextID string, sourceContextID string) error
-	DeleteContextMessages(ctx context.Context, contextID string) error
+	ContextExists(ctx context.Context, project string, id string) (bool, error)
+	ContextExistsByName(ctx context.Context, project string, name string) (bool, error)
+	DeleteContext(ctx context.Context, project string, id string) error
+	ListContexts(ctx context.Context, project string) ([]shared.ContextMetadata, error)
+	AddMessages(ctx context.Context, project string, id string, messages []shared.Message) error
+	DeleteContextMessage(ctx context.Context, project string, id string) error
+	UpdateContextMessage(ctx context.Context, project string, messageID string, role string, content string) error
+	PinContextMessage(ctx context.Context, project string, messageID string, pinned bool) error
+	DeleteContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error
+	CreateContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error
+	DeleteContextMessages(ctx context.Context, project string, contextID string) error
+	CompactContext(ctx context.Context, project string, contextID string, messageIDs []string, summary shared.Message, archive shared.Context) error
 }
 
 type ContextManager struct {
@@ -36,7 +40,7 @@ func New(store ContextStore) *ContextManager {
 	}
 }
 
-func NewContext(options shared.ContextOptions) (*shared.Context, error) {
+func NewContext(project string, options shared.ContextOptions) (*shared.Context, error) {
 	err := options.Validate()
 	if err != nil {
 		return nil, err
@@ -48,6 +52,7 @@ func NewContext(options shared.ContextOptions) (*shared.Context, error) {
 	}
 	return &shared.Context{
 		ID:          uuid.String(),
+		Project:     project,
 		Sources:     options.Sources,
 		Name:        options.Name,
 		Description: options.Description,
@@ -60,24 +65,34 @@ func (c *ContextManager) CreateContext(ctx context.Context, context shared.Conte
 	if err != nil {
 		return err
 	}
-	exists, err := c.store.ContextExistsByName(ctx, context.Name)
+	exists, err := c.store.ContextExistsByName(ctx, context.Project, context.Name)
 	if err != nil {
 		return err
 	}
 	if exists {
 		return fmt.Errorf("A context with name %s already exists", context.Name)
 	}
+	// the sources should belong to the same project as the context
+	for _, source := range context.Sources.Contexts {
+		exists, err := c.store.ContextExists(ctx, context.Project, source)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return er.Newf("context %s doesn't exist", er.NotFound, true, source)
+		}
+	}
 	return c.store.CreateContext(ctx, context)
 }
 
-func (c *ContextManager) CreateOrGetContext(ctx context.Context, contextID string, options shared.ContextOptions) (*shared.Context, error) {
+func (c *ContextManager) CreateOrGetContext(ctx context.Context, project string, contextID string, options shared.ContextOptions) (*shared.Context, error) {
 	if contextID != "" {
 		if err := id.Validate(contextID, "Invalid context ID"); err != nil {
 			return nil, err
 		}
-		return c.store.GetContext(ctx, contextID)
+		return c.store.GetContext(ctx, project, contextID)
 	}
-	context, err := NewContext(options)
+	context, err := NewContext(project, options)
 	if err != nil {
 		return nil, err
 	}
@@ -88,42 +103,42 @@ func (c *ContextManager) CreateOrGetContext(ctx context.Context, contextID strin
 	return context, nil
 }
 
-func (c *ContextManager) GetContext(ctx context.Context, contextID string) (*shared.Context, error) {
+func (c *ContextManager) GetContext(ctx context.Context, project string, contextID string) (*shared.Context, error) {
 	if err := id.Validate(contextID, "Invalid context ID"); err != nil {
 		return nil, err
 	}
-	return c.store.GetContext(ctx, contextID)
+	return c.store.GetContext(ctx, project, contextID)
 }
 
-func (c *ContextManager) DeleteContext(ctx context.Context, contextID string) error {
+func (c *ContextManager) DeleteContext(ctx context.Context, project string, contextID string) error {
 	if err := id.Validate(contextID, "Invalid context ID"); err != nil {
 		return err
 	}
-	return c.store.DeleteContext(ctx, contextID)
+	return c.store.DeleteContext(ctx, project, contextID)
 }
 
-func (c *ContextManager) AddMessagesToContext(ctx context.Context, contextID string, messages []shared.Message) error {
+func (c *ContextManager) AddMessagesToContext(ctx context.Context, project string, contextID string, messages []shared.Message) error {
 	if err := id.Validate(contextID, "Invalid context ID"); err != nil {
 		return err
 	}
 	if len(messages) == 0 {
 		return errors.New("You need at least one message to add to the context")
 	}
-	return c.store.AddMessages(ctx, contextID, messages)
+	return c.store.AddMessages(ctx, project, contextID, messages)
 }
 
-func (c *ContextManager) DeleteContextMessage(ctx context.Context, messageID string) error {
+func (c *ContextManager) DeleteContextMessage(ctx context.Context, project string, messageID string) error {
 	if err := id.Validate(messageID, "Invalid message ID"); err != nil {
 		return err
 	}
-	return c.store.DeleteContextMessage(ctx, messageID)
+	return c.store.DeleteContextMessage(ctx, project, messageID)
 }
 
-func (c *ContextManager) ListContexts(ctx context.Context) ([]shared.ContextMetadata, error) {
-	return c.store.ListContexts(ctx)
+func (c *ContextManager) ListContexts(ctx context.Context, project string) ([]shared.ContextMetadata, error) {
+	return c.store.ListContexts(ctx, project)
 }
 
-func (c *ContextManager) UpdateContextMessage(ctx context.Context, messageID string, role string, content string) error {
+func (c *ContextManager) UpdateContextMessage(ctx context.Context, project string, messageID string, role string, content string) error {
 	if err := id.Validate(messageID, "Invalid message ID"); err != nil {
 		return err
 	}
@@ -133,10 +148,17 @@ func (c *ContextManager) UpdateContextMessage(ctx context.Context, messageID str
 	if role != shared.UserRole && role != shared.AssistantRole {
 		return errors.New("Invalid message role")
 	}
-	return c.store.UpdateContextMessage(ctx, messageID, role, content)
+	return c.store.UpdateContextMessage(ctx, project, messageID, role, content)
+}
+
+func (c *ContextManager) PinContextMessage(ctx context.Context, project string, messageID string, pinned bool) error {
+	if err := id.Validate(messageID, "Invalid message ID"); err != nil {
+		return err
+	}
+	return c.store.PinContextMessage(ctx, project, messageID, pinned)
 }
 
-func (c *ContextManager) DeleteContextSourceContext(ctx context.Context, contextID string, sourceContextID string) error {
+func (c *ContextManager) DeleteContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error {
 	if err := id.Validate(contextID, "Invalid context ID"); err != nil {
 		return err
 	}
@@ -144,20 +166,95 @@ func (c *ContextManager) DeleteContextSourceContext(ctx context.Context, context
 		return err
 	}
 
-	return c.store.DeleteContextSourceContext(ctx, contextID, sourceContextID)
+	return c.store.DeleteContextSourceContext(ctx, project, contextID, sourceContextID)
 }
 
-func (c *ContextManager) CreateContextSourceContext(ctx context.Context, contextID string, sourceContextID string) error {
+func (c *ContextManager) CreateContextSourceContext(ctx context.Context, project string, contextID string, sourceContextID string) error {
 	if err := id.Validate(contextID, "Invalid context ID"); err != nil {
 		return err
 	}
 	if err := id.Validate(sourceContextID, "Invalid source context ID"); err != nil {
 		return err
 	}
+	context, err := c.store.GetContext(ctx, project, contextID)
+	if err != nil {
+		return err
+	}
+	// the link creates a cycle if the context is already reachable from the source context
+	names := map[string]string{contextID: context.Name}
+	path, err := c.sourcePath(ctx, project, sourceContextID, contextID, names, make(map[string]bool))
+	if err != nil {
+		return err
+	}
+	if path != nil {
+		cycle := []string{context.Name}
+		for _, contextID := range path {
+			cycle = append(cycle, names[contextID])
+		}
+		return er.Newf("Context %s can't be a source of context %s because it would create a cycle: %s", er.BadRequest, true, names[sourceContextID], context.Name, strings.Join(cycle, " -> "))
+	}
 
-	return c.store.CreateContextSourceContext(ctx, contextID, sourceContextID)
+	return c.store.CreateContextSourceContext(ctx, project, contextID, sourceContextID)
 }
 
-func (c *ContextManager) DeleteContextMessages(ctx context.Context, contextID string) error {
-	return c.store.DeleteContextMessages(ctx, contextID)
+// sourcePath returns the IDs of the contexts on the path going from the context to the
+// target by following the contexts sources, or nil if the target is not reachable.
+// The names of the traversed contexts are stored in the names map.
+func (c *ContextManager) sourcePath(ctx context.Context, project string, contextID string, target string, names map[string]string, visited map[string]bool) ([]string, error) {
+	if contextID == target {
+		return []string{contextID}, nil
+	}
+	if visited[contextID] {
+		return nil, nil
+	}
+	visited[contextID] = true
+	context, err := c.store.GetContext(ctx, project, contextID)
+	if err != nil {
+		return nil, err
+	}
+	names[contextID] = context.Name
+	for _, source := range context.Sources.Contexts {
+		path, err := c.sourcePath(ctx, project, source, target, names, visited)
+		if err != nil {
+			return nil, err
+		}
+		if path != nil {
+			return append([]string{contextID}, path...), nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *ContextManager) DeleteContextMessages(ctx context.Context, project string, contextID string) error {
+	return c.store.DeleteContextMessages(ctx, project, contextID)
+}
+
+// CompactContext replaces messages of the context by a summary message.
+// The replaced messages are stored in the archive context.
+func (c *ContextManager) CompactContext(ctx context.Context, project string, contextID string, messageIDs []string, summary shared.Message, archive shared.Context) error {
+	if err := id.Validate(contextID, "Invalid context ID"); err != nil {
+		return err
+	}
+	if len(messageIDs) == 0 {
+		return errors.New("You need at least one message to compact")
+	}
+	err := summary.Validate()
+	if err != nil {
+		return err
+	}
+	err = archive.Validate()
+	if err != nil {
+		return err
+	}
+	if archive.Project != project {
+		return errors.New("The archive context should belong to the project of the compacted context")
+	}
+	exists, err := c.store.ContextExistsByName(ctx, archive.Project, archive.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("A context with name %s already exists", archive.Name)
+	}
+	return c.store.CompactContext(ctx, project, contextID, messageIDs, summary, archive)
 }
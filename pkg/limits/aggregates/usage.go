@@ -0,0 +1,43 @@
+package aggregates
+
+import (
+	"fmt"
+	"time"
+)
+
+// LimitError is returned when a tenant exceeds one of its limits
+type LimitError struct {
+	Message string
+	// RetryAfter is the duration after which the tenant is under the limit again
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+func NewLimitError(retryAfter time.Duration, message string, params ...any) *LimitError {
+	return &LimitError{
+		Message:    fmt.Sprintf(message, params...),
+		RetryAfter: retryAfter,
+	}
+}
+
+// ModelUsage is the number of tokens used by a tenant for a provider and a model during the current day
+type ModelUsage struct {
+	Provider     string
+	Model        string
+	InputTokens  uint64
+	OutputTokens uint64
+}
+
+// Usage is the current usage of a tenant and its limits. Limits set to 0 are disabled.
+type Usage struct {
+	Tenant string
+	// Requests is the number of requests done during the last minute
+	Requests          int
+	RequestsPerMinute int
+	DailyInputTokens  uint64
+	DailyOutputTokens uint64
+	Models            []ModelUsage
+}
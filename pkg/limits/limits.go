@@ -0,0 +1,188 @@
+package limits
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/limits/aggregates"
+	usagedata "github.com/appclacks/maizai/pkg/usage/aggregates"
+)
+
+// Config contains the limits applied to each tenant. Limits set to 0 are disabled.
+type Config struct {
+	RequestsPerMinute int
+	// DailyInputTokens and DailyOutputTokens are the number of tokens a tenant
+	// can use per day for each provider and model
+	DailyInputTokens  uint64
+	DailyOutputTokens uint64
+}
+
+type modelKey struct {
+	provider string
+	model    string
+}
+
+type tokens struct {
+	input  uint64
+	output uint64
+}
+
+// Limiter enforces the rate limits and the token quotas of the tenants.
+// The state is kept in memory: it's reset when MaizAI restarts.
+type Limiter struct {
+	config Config
+	lock   sync.Mutex
+	// requests contains the dates of the requests of each tenant during the last minute
+	requests map[string][]time.Time
+	// day is the day of the tokens usage, in UTC
+	day   string
+	usage map[string]map[modelKey]*tokens
+}
+
+func New(config Config) *Limiter {
+	return &Limiter{
+		config:   config,
+		requests: make(map[string][]time.Time),
+		usage:    make(map[string]map[modelKey]*tokens),
+	}
+}
+
+// recentRequests returns the requests of the tenant done during the last minute
+func (l *Limiter) recentRequests(tenant string, now time.Time) []time.Time {
+	requests := l.requests[tenant]
+	i := 0
+	for i < len(requests) && now.Sub(requests[i]) >= time.Minute {
+		i++
+	}
+	requests = requests[i:]
+	if len(requests) == 0 {
+		delete(l.requests, tenant)
+		return nil
+	}
+	l.requests[tenant] = requests
+	return requests
+}
+
+// Allow records a request for the tenant and returns an error if the tenant exceeds its rate limit
+func (l *Limiter) Allow(tenant string) error {
+	if l.config.RequestsPerMinute <= 0 {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	requests := l.recentRequests(tenant, now)
+	if len(requests) >= l.config.RequestsPerMinute {
+		retryAfter := requests[0].Add(time.Minute).Sub(now)
+		return aggregates.NewLimitError(retryAfter, "Rate limit exceeded: %d requests per minute are allowed", l.config.RequestsPerMinute)
+	}
+	l.requests[tenant] = append(requests, now)
+	return nil
+}
+
+// dayUsage returns the tokens usage of the tenant for the current day
+func (l *Limiter) dayUsage(tenant string, now time.Time) map[modelKey]*tokens {
+	day := now.UTC().Format(time.DateOnly)
+	if day != l.day {
+		l.day = day
+		l.usage = make(map[string]map[modelKey]*tokens)
+	}
+	return l.usage[tenant]
+}
+
+func untilTomorrow(now time.Time) time.Duration {
+	now = now.UTC()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return tomorrow.Sub(now)
+}
+
+// CheckQuota returns an error if the tenant exhausted its daily tokens quota for the provider and the model
+func (l *Limiter) CheckQuota(tenant string, provider string, model string) error {
+	if l.config.DailyInputTokens == 0 && l.config.DailyOutputTokens == 0 {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	used, ok := l.dayUsage(tenant, now)[modelKey{provider: provider, model: model}]
+	if !ok {
+		return nil
+	}
+	if l.config.DailyInputTokens != 0 && used.input >= l.config.DailyInputTokens {
+		return aggregates.NewLimitError(untilTomorrow(now), "Daily quota exceeded: %d input tokens are allowed per day for the provider %s and the model %s", l.config.DailyInputTokens, provider, model)
+	}
+	if l.config.DailyOutputTokens != 0 && used.output >= l.config.DailyOutputTokens {
+		return aggregates.NewLimitError(untilTomorrow(now), "Daily quota exceeded: %d output tokens are allowed per day for the provider %s and the model %s", l.config.DailyOutputTokens, provider, model)
+	}
+	return nil
+}
+
+type tenantKey struct{}
+
+// WithTenant attaches the tenant of the request to the context
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant attached to the context
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// Record adds the tokens used by a provider call to the usage of the tenant attached
+// to the context. The calls done without tenant are ignored.
+func (l *Limiter) Record(ctx context.Context, record usagedata.Record) error {
+	tenant := Tenant(ctx)
+	if tenant == "" {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	usage := l.dayUsage(tenant, time.Now())
+	if usage == nil {
+		usage = make(map[modelKey]*tokens)
+		l.usage[tenant] = usage
+	}
+	key := modelKey{provider: record.Provider, model: record.Model}
+	used, ok := usage[key]
+	if !ok {
+		used = &tokens{}
+		usage[key] = used
+	}
+	used.input += record.InputTokens
+	used.output += record.OutputTokens
+	return nil
+}
+
+// Usage returns the current usage of the tenant
+func (l *Limiter) Usage(tenant string) aggregates.Usage {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	result := aggregates.Usage{
+		Tenant:            tenant,
+		Requests:          len(l.recentRequests(tenant, now)),
+		RequestsPerMinute: l.config.RequestsPerMinute,
+		DailyInputTokens:  l.config.DailyInputTokens,
+		DailyOutputTokens: l.config.DailyOutputTokens,
+		Models:            []aggregates.ModelUsage{},
+	}
+	for key, used := range l.dayUsage(tenant, now) {
+		result.Models = append(result.Models, aggregates.ModelUsage{
+			Provider:     key.provider,
+			Model:        key.model,
+			InputTokens:  used.input,
+			OutputTokens: used.output,
+		})
+	}
+	sort.Slice(result.Models, func(i, j int) bool {
+		if result.Models[i].Provider != result.Models[j].Provider {
+			return result.Models[i].Provider < result.Models[j].Provider
+		}
+		return result.Models[i].Model < result.Models[j].Model
+	})
+	return result
+}
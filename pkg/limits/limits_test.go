@@ -0,0 +1,74 @@
+package limits_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/limits"
+	"github.com/appclacks/maizai/pkg/limits/aggregates"
+	usagedata "github.com/appclacks/maizai/pkg/usage/aggregates"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	limiter := limits.New(limits.Config{RequestsPerMinute: 2})
+	assert.NoError(t, limiter.Allow("a"))
+	assert.NoError(t, limiter.Allow("a"))
+	err := limiter.Allow("a")
+	assert.ErrorContains(t, err, "Rate limit exceeded: 2 requests per minute are allowed")
+	limitError, ok := err.(*aggregates.LimitError)
+	assert.True(t, ok)
+	assert.True(t, limitError.RetryAfter > 0 && limitError.RetryAfter <= time.Minute)
+	// the limits are per tenant
+	assert.NoError(t, limiter.Allow("b"))
+	assert.Equal(t, 2, limiter.Usage("a").Requests)
+	assert.Equal(t, 1, limiter.Usage("b").Requests)
+
+	unlimited := limits.New(limits.Config{})
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, unlimited.Allow("a"))
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	limiter := limits.New(limits.Config{DailyInputTokens: 100, DailyOutputTokens: 50})
+	record := func(tenant string, provider string, model string, inputTokens uint64, outputTokens uint64) {
+		err := limiter.Record(limits.WithTenant(context.Background(), tenant), usagedata.Record{
+			Provider:     provider,
+			Model:        model,
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+		})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, limiter.CheckQuota("a", "openai", "gpt-4o"))
+	record("a", "openai", "gpt-4o", 60, 10)
+	assert.NoError(t, limiter.CheckQuota("a", "openai", "gpt-4o"))
+	record("a", "openai", "gpt-4o", 60, 10)
+	err := limiter.CheckQuota("a", "openai", "gpt-4o")
+	assert.ErrorContains(t, err, "100 input tokens are allowed per day for the provider openai and the model gpt-4o")
+	limitError, ok := err.(*aggregates.LimitError)
+	assert.True(t, ok)
+	assert.True(t, limitError.RetryAfter > 0 && limitError.RetryAfter <= 24*time.Hour)
+
+	// the quotas are per tenant, provider and model
+	assert.NoError(t, limiter.CheckQuota("b", "openai", "gpt-4o"))
+	assert.NoError(t, limiter.CheckQuota("a", "openai", "gpt-4o-mini"))
+	record("a", "anthropic", "claude", 0, 50)
+	assert.ErrorContains(t, limiter.CheckQuota("a", "anthropic", "claude"), "50 output tokens are allowed per day")
+
+	usage := limiter.Usage("a")
+	assert.Equal(t, "a", usage.Tenant)
+	assert.Equal(t, uint64(100), usage.DailyInputTokens)
+	assert.Equal(t, []aggregates.ModelUsage{
+		{Provider: "anthropic", Model: "claude", InputTokens: 0, OutputTokens: 50},
+		{Provider: "openai", Model: "gpt-4o", InputTokens: 120, OutputTokens: 20},
+	}, usage.Models)
+	assert.Len(t, limiter.Usage("b").Models, 0)
+
+	// the calls done outside of a request are not counted
+	err = limiter.Record(context.Background(), usagedata.Record{Provider: "openai", Model: "gpt-4o", InputTokens: 1000})
+	assert.NoError(t, err)
+	assert.Len(t, limiter.Usage("").Models, 0)
+}
@@ -2,39 +2,128 @@ package aggregates
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/appclacks/maizai/internal/id"
+	"github.com/appclacks/maizai/pkg/shared"
 	"github.com/google/uuid"
 )
 
 type Document struct {
 	ID          string    `json:"id"`
+	Project     string    `json:"project"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	CreatedAt   time.Time `json:"created-at"`
+	// EmbeddingProvider, EmbeddingModel and Dimension describe the embeddings of the document chunks.
+	// They are recorded when the first chunk is embedded if they are not set at creation.
+	EmbeddingProvider string `json:"embedding-provider,omitempty"`
+	EmbeddingModel    string `json:"embedding-model,omitempty"`
+	Dimension         int    `json:"dimension,omitempty"`
+	// Labels can be used to filter the documents during a search
+	Labels map[string]string `json:"labels,omitempty"`
+	// Source is the URI the document content comes from
+	Source string `json:"source,omitempty"`
+}
+
+func validateLabels(labels map[string]string) error {
+	for key := range labels {
+		if key == "" {
+			return errors.New("Invalid label: the key is empty")
+		}
+	}
+	return nil
+}
+
+func validateMetadata(metadata map[string]string) error {
+	for key := range metadata {
+		if key == "" {
+			return errors.New("Invalid metadata: the key is empty")
+		}
+	}
+	return nil
+}
+
+func validateSource(source string) error {
+	if source == "" {
+		return nil
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("Invalid source %s: the source should be an URI, for example https://example.com/doc or file:///doc.md", source)
+	}
+	return nil
+}
+
+// hasAll returns true if the values contain all the expected keys and values
+func hasAll(values map[string]string, expected map[string]string) bool {
+	for key, value := range expected {
+		if v, ok := values[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
 }
 
 func (d Document) Validate() error {
 	if err := id.Validate(d.ID, "invalid document ID"); err != nil {
 		return err
 	}
+	if err := shared.ValidateProject(d.Project); err != nil {
+		return err
+	}
 	if d.Name == "" {
 		return errors.New("Invalid document name")
 	}
 	if d.CreatedAt.IsZero() {
 		return errors.New("Invalid creation date")
 	}
-	return nil
+	if d.Dimension < 0 {
+		return errors.New("Invalid embedding dimension")
+	}
+	if err := validateSource(d.Source); err != nil {
+		return err
+	}
+	return validateLabels(d.Labels)
+}
+
+// MatchesFilter returns true if the document is selected by the filter.
+// A document is selected if its ID or its name is in the filter (or if the filter
+// has no IDs and names), if it has the source of the filter and if it has all the
+// labels of the filter.
+func (d Document) MatchesFilter(filter DocumentFilter) bool {
+	if len(filter.IDs) != 0 || len(filter.Names) != 0 {
+		if !slices.Contains(filter.IDs, d.ID) && !slices.Contains(filter.Names, d.Name) {
+			return false
+		}
+	}
+	if filter.Source != "" && d.Source != filter.Source {
+		return false
+	}
+	return hasAll(d.Labels, filter.Labels)
+}
+
+// AcceptsEmbedding returns true if an embedding computed by the provider and model
+// can be stored with the existing embeddings of the document
+func (d Document) AcceptsEmbedding(provider string, model string, dimension int) bool {
+	return (d.EmbeddingProvider == "" || d.EmbeddingProvider == provider) &&
+		(d.EmbeddingModel == "" || d.EmbeddingModel == model) &&
+		(d.Dimension == 0 || d.Dimension == dimension)
 }
 
-func NewDocument(name string, description string) (*Document, error) {
+func NewDocument(project string, name string, description string) (*Document, error) {
 	id, err := uuid.NewV6()
 	if err != nil {
 		return nil, err
 	}
 	return &Document{
 		ID:          id.String(),
+		Project:     project,
 		Name:        name,
 		Description: description,
 		CreatedAt:   time.Now().UTC(),
@@ -47,6 +136,40 @@ type DocumentChunk struct {
 	Fragment   string    `json:"fragment"`
 	Embedding  []float32 `json:"-"`
 	CreatedAt  time.Time `json:"created-at"`
+	// Ordinal is the position of the chunk in the document
+	Ordinal int `json:"ordinal"`
+	// Start and End are the positions (in characters) of the fragment in the ingested text
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Metadata is free-form information about the chunk, for example a page number
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Distance and Score are only set for the chunks returned by a search.
+	// The distance depends on the metric of the search, lower is closer.
+	// The score is higher for the most relevant chunks. For hybrid searches,
+	// the score is the reciprocal rank fusion score.
+	Distance *float64 `json:"distance,omitempty"`
+	Score    *float64 `json:"score,omitempty"`
+}
+
+// SetDistance sets the distance between the chunk and the search input, and the matching score.
+// The cosine distance is not defined for zero vectors: they are considered orthogonal to the input.
+func (d *DocumentChunk) SetDistance(metric string, distance float64) {
+	if math.IsNaN(distance) {
+		distance = 1
+	}
+	var score float64
+	switch metric {
+	case Cosine:
+		// the cosine similarity
+		score = 1 - distance
+	case InnerProduct:
+		// the distance is the negative inner product
+		score = -distance
+	default:
+		score = 1 / (1 + distance)
+	}
+	d.Distance = &distance
+	d.Score = &score
 }
 
 func (d DocumentChunk) Validate() error {
@@ -65,7 +188,12 @@ func (d DocumentChunk) Validate() error {
 	if d.CreatedAt.IsZero() {
 		return errors.New("Invalid creation date")
 	}
-	return nil
+	return validateMetadata(d.Metadata)
+}
+
+// HasMetadata returns true if the chunk has all the metadata
+func (d DocumentChunk) HasMetadata(metadata map[string]string) bool {
+	return hasAll(d.Metadata, metadata)
 }
 
 func NewDocumentChunk(docID string, fragment string, embedding []float32) (*DocumentChunk, error) {
@@ -86,6 +214,82 @@ type EmbeddingQuery struct {
 	Input    string `json:"input"`
 	Model    string `json:"model"`
 	Provider string `json:"provider"`
+	// Metadata is stored with the chunk
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// BatchEmbeddingQuery computes the embeddings of several inputs in a single provider call
+type BatchEmbeddingQuery struct {
+	Inputs   []string `json:"inputs"`
+	Model    string   `json:"model"`
+	Provider string   `json:"provider"`
+}
+
+type IngestQuery struct {
+	Content  string `json:"content"`
+	Model    string `json:"model"`
+	Provider string `json:"provider"`
+	Strategy string `json:"strategy"`
+	Size     int    `json:"size"`
+	Overlap  int    `json:"overlap"`
+	// Metadata is stored with all the chunks
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (i IngestQuery) Validate() error {
+	if strings.TrimSpace(i.Content) == "" {
+		return errors.New("Invalid content field")
+	}
+	if i.Provider == "" {
+		return errors.New("Invalid provider")
+	}
+	return validateMetadata(i.Metadata)
+}
+
+const (
+	// VectorSearch only compares the embeddings
+	VectorSearch = "vector"
+	// HybridSearch merges the results of the vector search and of a keyword search
+	HybridSearch = "hybrid"
+)
+
+// DocumentFilter restricts a search or a listing to some documents
+type DocumentFilter struct {
+	IDs    []string
+	Names  []string
+	Source string
+	Labels map[string]string
+}
+
+// ChunkSearch searches for the chunks closest to the embedding. Only the chunks
+// embedded with the same provider, model and dimension are compared.
+type ChunkSearch struct {
+	// Project is the project of the searched documents
+	Project   string
+	Embedding []float32
+	Provider  string
+	Model     string
+	Limit     int32
+	// Metric is the distance metric, L2 by default
+	Metric string
+	Filter DocumentFilter
+	// Metadata restricts the search to the chunks having all these metadata
+	Metadata map[string]string
+	// EfSearch and Probes tune the HNSW and IVFFlat indexes for this search.
+	// They are ignored by the stores doing an exact search.
+	EfSearch int
+	Probes   int
+}
+
+// KeywordSearch searches for the chunks containing the words of the query
+type KeywordSearch struct {
+	// Project is the project of the searched documents
+	Project string
+	Query   string
+	Limit   int32
+	Filter  DocumentFilter
+	// Metadata restricts the search to the chunks having all these metadata
+	Metadata map[string]string
 }
 
 type SearchQuery struct {
@@ -93,22 +297,69 @@ type SearchQuery struct {
 	Model    string `json:"model"`
 	Provider string `json:"provider"`
 	Limit    int32  `json:"limit"`
+	Mode     string `json:"mode,omitempty"`
+	Metric   string `json:"metric,omitempty"`
+	EfSearch int    `json:"ef-search,omitempty"`
+	Probes   int    `json:"probes,omitempty"`
+	// DocumentIDs, DocumentNames, Source and Labels restrict the search to some documents
+	DocumentIDs   []string          `json:"document-ids,omitempty"`
+	DocumentNames []string          `json:"document-names,omitempty"`
+	Source        string            `json:"source,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	// Metadata restricts the search to the chunks having all these metadata
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// MinScore drops the chunks with a lower score. It applies to the
+	// scores of the vector search, before reranking.
+	MinScore *float64 `json:"min-score,omitempty"`
+	// Candidates is the number of chunks retrieved before being
+	// reranked and trimmed to the limit
+	Candidates int32 `json:"candidates,omitempty"`
+	// Rerank is the name of the reranker reordering the candidates
+	Rerank string `json:"rerank,omitempty"`
 }
 
 func (s SearchQuery) Validate() error {
 	if s.Input == "" {
 		return errors.New("Invalid input field")
 	}
-	if s.Model == "" {
-		return errors.New("Invalid model")
-	}
 	if s.Provider == "" {
 		return errors.New("Invalid provider")
 	}
-	if s.Limit == 0 {
-		return errors.New("Invalid limti")
+	if s.Limit <= 0 {
+		return errors.New("Invalid limit: the limit should be greater than 0")
 	}
-	return nil
+	if s.Metric != "" {
+		if err := ValidateMetric(s.Metric); err != nil {
+			return err
+		}
+	}
+	switch s.Mode {
+	case "", VectorSearch:
+	case HybridSearch:
+		if s.MinScore != nil {
+			return errors.New("The min-score option is not supported by hybrid searches")
+		}
+	default:
+		return fmt.Errorf("Invalid search mode %s, supported modes are %s and %s", s.Mode, VectorSearch, HybridSearch)
+	}
+	if s.Candidates < 0 || (s.Candidates != 0 && s.Candidates < s.Limit) {
+		return errors.New("Invalid candidates, it should be greater than or equal to the limit")
+	}
+	if s.EfSearch < 0 || s.EfSearch > MaxEfSearch {
+		return fmt.Errorf("Invalid ef-search, it should be between 1 and %d", MaxEfSearch)
+	}
+	if s.Probes < 0 {
+		return errors.New("Invalid probes, it should be positive")
+	}
+	for _, documentID := range s.DocumentIDs {
+		if err := id.Validate(documentID, "invalid document ID"); err != nil {
+			return err
+		}
+	}
+	if err := validateMetadata(s.Metadata); err != nil {
+		return err
+	}
+	return validateLabels(s.Labels)
 }
 
 type Embedding struct {
@@ -116,6 +367,8 @@ type Embedding struct {
 }
 
 type EmbeddingAnswer struct {
+	// Model is the model used by the provider, which can be the default model of the provider
+	Model        string `json:"model"`
 	InputTokens  uint64 `json:"input-tokens"`
 	OutputTokens uint64 `json:"output-tokens"`
 	Data         []Embedding
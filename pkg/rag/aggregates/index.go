@@ -0,0 +1,145 @@
+package aggregates
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+const (
+	// HNSW indexes have better query performance than IVFFlat indexes, but are slower to build
+	HNSW = "hnsw"
+	// IVFFlat indexes should be created once the table contains data
+	IVFFlat = "ivfflat"
+)
+
+const (
+	// L2 is the euclidean distance
+	L2 = "l2"
+	// Cosine is the cosine distance
+	Cosine = "cosine"
+	// InnerProduct is the inner product, only relevant for normalized embeddings
+	InnerProduct = "inner-product"
+)
+
+const (
+	DefaultM              = 16
+	DefaultEfConstruction = 64
+	DefaultLists          = 100
+	// MaxEfSearch is the maximum value accepted by pgvector for hnsw.ef_search
+	MaxEfSearch = 1000
+	// MaxIndexDimension is the maximum number of dimensions supported by pgvector indexes
+	MaxIndexDimension = 2000
+)
+
+var indexNameRegexp = regexp.MustCompile(`^idx_document_chunk_embedding_(hnsw|ivfflat)_((cosine|ip)_)?[0-9]+$`)
+
+// ValidateMetric checks that the distance metric is supported
+func ValidateMetric(metric string) error {
+	switch metric {
+	case L2, Cosine, InnerProduct:
+		return nil
+	}
+	return fmt.Errorf("Invalid metric %s, supported metrics are %s, %s and %s", metric, L2, Cosine, InnerProduct)
+}
+
+// VectorIndex is an index on the embeddings of the document chunks. An index only
+// contains the chunks of the given dimension, and is only used by the searches using its metric.
+type VectorIndex struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Metric    string `json:"metric"`
+	Dimension int    `json:"dimension"`
+	// M and EfConstruction are the HNSW parameters
+	M              int `json:"m,omitempty"`
+	EfConstruction int `json:"ef-construction,omitempty"`
+	// Lists is the IVFFlat parameter
+	Lists int `json:"lists,omitempty"`
+	// Valid is false if the index build failed or is in progress
+	Valid bool `json:"valid"`
+}
+
+// VectorIndexName returns the name of the index of the given type and metric for a dimension.
+// The metric is omitted for L2 indexes.
+func VectorIndexName(indexType string, metric string, dimension int) string {
+	switch metric {
+	case Cosine:
+		return fmt.Sprintf("idx_document_chunk_embedding_%s_cosine_%d", indexType, dimension)
+	case InnerProduct:
+		return fmt.Sprintf("idx_document_chunk_embedding_%s_ip_%d", indexType, dimension)
+	}
+	return fmt.Sprintf("idx_document_chunk_embedding_%s_%d", indexType, dimension)
+}
+
+// ValidateVectorIndexName checks that the name is the name of a vector index managed by MaizAI
+func ValidateVectorIndexName(name string) error {
+	if !indexNameRegexp.MatchString(name) {
+		return fmt.Errorf("Invalid vector index name %s", name)
+	}
+	return nil
+}
+
+func (v VectorIndex) Validate() error {
+	if v.Dimension <= 0 || v.Dimension > MaxIndexDimension {
+		return fmt.Errorf("Invalid dimension, it should be between 1 and %d", MaxIndexDimension)
+	}
+	if err := ValidateMetric(v.Metric); err != nil {
+		return err
+	}
+	switch v.Type {
+	case HNSW:
+		if v.M < 2 || v.M > 100 {
+			return errors.New("Invalid m parameter, it should be between 2 and 100")
+		}
+		if v.EfConstruction < 4 || v.EfConstruction > 1000 {
+			return errors.New("Invalid ef-construction parameter, it should be between 4 and 1000")
+		}
+		if v.EfConstruction < 2*v.M {
+			return errors.New("The ef-construction parameter should be greater than or equal to twice the m parameter")
+		}
+		if v.Lists != 0 {
+			return errors.New("The lists parameter is only supported by ivfflat indexes")
+		}
+	case IVFFlat:
+		if v.Lists < 1 || v.Lists > 32768 {
+			return errors.New("Invalid lists parameter, it should be between 1 and 32768")
+		}
+		if v.M != 0 || v.EfConstruction != 0 {
+			return errors.New("The m and ef-construction parameters are only supported by hnsw indexes")
+		}
+	default:
+		return fmt.Errorf("Invalid index type %s, supported types are %s and %s", v.Type, HNSW, IVFFlat)
+	}
+	return ValidateVectorIndexName(v.Name)
+}
+
+// NewVectorIndex builds a vector index, using the L2 metric and the default
+// parameters of the index type for the parameters which are not set
+func NewVectorIndex(indexType string, metric string, dimension int, m int, efConstruction int, lists int) VectorIndex {
+	if metric == "" {
+		metric = L2
+	}
+	index := VectorIndex{
+		Name:           VectorIndexName(indexType, metric, dimension),
+		Type:           indexType,
+		Metric:         metric,
+		Dimension:      dimension,
+		M:              m,
+		EfConstruction: efConstruction,
+		Lists:          lists,
+	}
+	switch indexType {
+	case HNSW:
+		if index.M == 0 {
+			index.M = DefaultM
+		}
+		if index.EfConstruction == 0 {
+			index.EfConstruction = DefaultEfConstruction
+		}
+	case IVFFlat:
+		if index.Lists == 0 {
+			index.Lists = DefaultLists
+		}
+	}
+	return index
+}
@@ -0,0 +1,44 @@
+package rag
+
+import (
+	"sort"
+
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+)
+
+// RRFK is the constant of the reciprocal rank fusion. It reduces the
+// impact of the first ranks on the final score.
+const RRFK = 60
+
+// CandidatesFactor is the default number of candidates fetched by the hybrid
+// searches and by the reranked searches, as a multiple of the limit
+const CandidatesFactor = 4
+
+// fuse merges the results of the vector and keyword searches using reciprocal rank fusion:
+// the score of a chunk is the sum of 1 / (RRFK + rank) for each result list containing it.
+func fuse(vectorResults []aggregates.DocumentChunk, keywordResults []aggregates.DocumentChunk, limit int) []aggregates.DocumentChunk {
+	scores := make(map[string]float64)
+	chunks := make(map[string]aggregates.DocumentChunk)
+	order := []string{}
+	for _, results := range [][]aggregates.DocumentChunk{vectorResults, keywordResults} {
+		for rank, chunk := range results {
+			if _, ok := chunks[chunk.ID]; !ok {
+				// the vector results are added first so the distance is kept
+				chunks[chunk.ID] = chunk
+				order = append(order, chunk.ID)
+			}
+			scores[chunk.ID] += 1 / float64(RRFK+rank+1)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	result := []aggregates.DocumentChunk{}
+	for i := 0; i < len(order) && i < limit; i++ {
+		chunk := chunks[order[i]]
+		score := scores[order[i]]
+		chunk.Score = &score
+		result = append(result, chunk)
+	}
+	return result
+}
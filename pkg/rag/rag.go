@@ -2,60 +2,162 @@ package rag
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/appclacks/maizai/internal/chunker"
 	"github.com/appclacks/maizai/internal/id"
+	"github.com/appclacks/maizai/internal/metrics"
 	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	er "github.com/mcorbin/corbierror"
 )
 
 type Store interface {
 	CreateDocument(ctx context.Context, document aggregates.Document) error
-	GetDocument(ctx context.Context, id string) (*aggregates.Document, error)
-	DeleteDocument(ctx context.Context, id string) error
-	DeleteDocumentChunk(ctx context.Context, id string) error
+	GetDocument(ctx context.Context, project string, id string) (*aggregates.Document, error)
+	DeleteDocument(ctx context.Context, project string, id string) error
+	DeleteDocumentChunk(ctx context.Context, project string, id string) error
 	CreateDocumentChunk(ctx context.Context, documentChunk aggregates.DocumentChunk) error
-	ListDocuments(ctx context.Context) ([]aggregates.Document, error)
-	FindClosestChunks(ctx context.Context, limit int32, chunk []float32) ([]aggregates.DocumentChunk, error)
-	ListDocumentChunksForDocument(ctx context.Context, docID string) ([]aggregates.DocumentChunk, error)
+	CreateDocumentChunks(ctx context.Context, documentChunks []aggregates.DocumentChunk) error
+	ListDocuments(ctx context.Context, project string) ([]aggregates.Document, error)
+	UpdateDocumentEmbedding(ctx context.Context, project string, id string, provider string, model string, dimension int) error
+	FindClosestChunks(ctx context.Context, search aggregates.ChunkSearch) ([]aggregates.DocumentChunk, error)
+	FindChunksByKeywords(ctx context.Context, search aggregates.KeywordSearch) ([]aggregates.DocumentChunk, error)
+	ListDocumentChunksForDocument(ctx context.Context, project string, docID string) ([]aggregates.DocumentChunk, error)
+	NextChunkOrdinal(ctx context.Context, project string, docID string) (int, error)
+}
+
+// VectorIndexStore is implemented by the stores supporting vector indexes.
+// The other stores always do an exact search.
+type VectorIndexStore interface {
+	ListVectorIndexes(ctx context.Context) ([]aggregates.VectorIndex, error)
+	CreateVectorIndex(ctx context.Context, index aggregates.VectorIndex) error
+	RebuildVectorIndex(ctx context.Context, name string) error
+	DropVectorIndex(ctx context.Context, name string) error
 }
 
 type AI interface {
 	Embedding(ctx context.Context, query aggregates.EmbeddingQuery) (*aggregates.EmbeddingAnswer, error)
+	BatchEmbedding(ctx context.Context, query aggregates.BatchEmbeddingQuery) (*aggregates.EmbeddingAnswer, error)
+}
+
+// Reranker scores the relevance of fragments for a query of the project.
+// The scores are returned in the same order as the fragments.
+type Reranker interface {
+	Rerank(ctx context.Context, project string, query string, fragments []string) ([]float64, error)
+}
+
+const DefaultEmbeddingBatchSize = 32
+
+type Config struct {
+	// EmbeddingBatchSize is the maximum number of chunks embedded in a single provider call
+	EmbeddingBatchSize int
+	// Rerankers are the rerankers available to the searches, by name
+	Rerankers map[string]Reranker
+	// Metrics records the embedding calls and the searches. Nothing is recorded if nil.
+	Metrics *metrics.Metrics
+	// Usage records the usage of the embedding calls. Nothing is recorded if nil.
+	Usage UsageRecorder
 }
 
 type Rag struct {
 	store   Store
 	clients map[string]AI
+	config  Config
 }
 
-func New(store Store, clients map[string]AI) *Rag {
+func New(store Store, clients map[string]AI, config Config) *Rag {
+	if config.EmbeddingBatchSize <= 0 {
+		config.EmbeddingBatchSize = DefaultEmbeddingBatchSize
+	}
 	return &Rag{
 		store:   store,
 		clients: clients,
+		config:  config,
 	}
 }
 
-func (r *Rag) Embed(ctx context.Context, docID string, query aggregates.EmbeddingQuery) error {
+// embeddingSettings returns the document and fills the provider and model to use to
+// embed its content. The settings recorded in the document are used by default.
+func (r *Rag) embeddingSettings(ctx context.Context, project string, docID string, provider *string, model *string) (*aggregates.Document, error) {
 	if err := id.Validate(docID, "invalid document ID"); err != nil {
+		return nil, err
+	}
+	document, err := r.store.GetDocument(ctx, project, docID)
+	if err != nil {
+		return nil, err
+	}
+	if *provider == "" {
+		*provider = document.EmbeddingProvider
+	}
+	if *model == "" {
+		*model = document.EmbeddingModel
+	}
+	if *provider == "" {
+		return nil, er.Newf("No embedding provider is configured for document %s", er.BadRequest, true, document.Name)
+	}
+	return document, nil
+}
+
+// recordEmbedding checks that the embeddings computed by the provider can be stored with the
+// existing embeddings of the document, and records the embedding settings of the document
+func (r *Rag) recordEmbedding(ctx context.Context, document aggregates.Document, provider string, model string, dimension int) error {
+	if !document.AcceptsEmbedding(provider, model, dimension) {
+		return er.Newf("Document %s uses the model %s of provider %s with %d dimensions, it can't store embeddings from the model %s of provider %s with %d dimensions", er.BadRequest, true, document.Name, document.EmbeddingModel, document.EmbeddingProvider, document.Dimension, model, provider, dimension)
+	}
+	if document.EmbeddingProvider == provider && document.EmbeddingModel == model && document.Dimension == dimension {
+		return nil
+	}
+	return r.store.UpdateDocumentEmbedding(ctx, document.Project, document.ID, provider, model, dimension)
+}
+
+// embeddingModel returns the model used by the provider, or the requested model if the call failed
+func embeddingModel(answer *aggregates.EmbeddingAnswer, model string) string {
+	if answer != nil && answer.Model != "" {
+		return answer.Model
+	}
+	return model
+}
+
+func (r *Rag) Embed(ctx context.Context, project string, docID string, query aggregates.EmbeddingQuery) error {
+	document, err := r.embeddingSettings(ctx, project, docID, &query.Provider, &query.Model)
+	if err != nil {
 		return err
 	}
 	client, ok := r.clients[query.Provider]
 	if !ok {
 		return fmt.Errorf("AI client %s not configured", query.Provider)
 	}
+	start := time.Now()
 	answer, err := client.Embedding(ctx, query)
+	r.config.Metrics.EmbeddingCall(query.Provider, embeddingModel(answer, query.Model), err)
+	r.recordUsage(ctx, project, query.Provider, query.Model, start, answer, err)
 	if err != nil {
 		return err
 	}
-	// TODO check index
-	chunk, err := aggregates.NewDocumentChunk(docID, query.Input, answer.Data[0].Embedding)
+	if len(answer.Data) == 0 {
+		return errors.New("The AI provider returned no embedding")
+	}
+	embedding := answer.Data[0].Embedding
+	err = r.recordEmbedding(ctx, *document, query.Provider, embeddingModel(answer, query.Model), len(embedding))
 	if err != nil {
 		return err
 	}
-	err = chunk.Validate()
+	chunk, err := aggregates.NewDocumentChunk(docID, query.Input, embedding)
 	if err != nil {
 		return err
 	}
+	// like the ingested chunks, the chunk is added after the existing ones
+	chunk.Ordinal, err = r.store.NextChunkOrdinal(ctx, project, docID)
+	if err != nil {
+		return err
+	}
+	chunk.Metadata = query.Metadata
+	err = chunk.Validate()
+	if err != nil {
+		return er.New(err.Error(), er.BadRequest, true)
+	}
 	err = r.store.CreateDocumentChunk(ctx, *chunk)
 	if err != nil {
 		return err
@@ -63,63 +165,312 @@ func (r *Rag) Embed(ctx context.Context, docID string, query aggregates.Embeddin
 	return nil
 }
 
+// Ingest splits the content in chunks using the chunking strategy, computes the
+// embeddings of the chunks by batches and stores the chunks with their positions.
+func (r *Rag) Ingest(ctx context.Context, project string, docID string, query aggregates.IngestQuery) ([]aggregates.DocumentChunk, error) {
+	document, err := r.embeddingSettings(ctx, project, docID, &query.Provider, &query.Model)
+	if err != nil {
+		return nil, err
+	}
+	if query.Strategy == "" {
+		query.Strategy = chunker.Recursive
+	}
+	if query.Size == 0 {
+		query.Size = chunker.DefaultSize
+	}
+	err = query.Validate()
+	if err != nil {
+		return nil, er.New(err.Error(), er.BadRequest, true)
+	}
+	client, ok := r.clients[query.Provider]
+	if !ok {
+		return nil, fmt.Errorf("AI client %s not configured", query.Provider)
+	}
+	parts, err := chunker.Split(query.Content, chunker.Options{
+		Strategy: query.Strategy,
+		Size:     query.Size,
+		Overlap:  query.Overlap,
+	})
+	if err != nil {
+		return nil, er.New(err.Error(), er.BadRequest, true)
+	}
+	if len(parts) == 0 {
+		return nil, er.New("The content doesn't contain any chunk to ingest", er.BadRequest, true)
+	}
+	// the new chunks are added after the existing ones
+	ordinal, err := r.store.NextChunkOrdinal(ctx, project, docID)
+	if err != nil {
+		return nil, err
+	}
+	model := query.Model
+	chunks := []aggregates.DocumentChunk{}
+	for start := 0; start < len(parts); start += r.config.EmbeddingBatchSize {
+		batch := parts[start:min(start+r.config.EmbeddingBatchSize, len(parts))]
+		inputs := []string{}
+		for _, part := range batch {
+			inputs = append(inputs, part.Text)
+		}
+		callStart := time.Now()
+		answer, err := client.BatchEmbedding(ctx, aggregates.BatchEmbeddingQuery{
+			Inputs:   inputs,
+			Model:    query.Model,
+			Provider: query.Provider,
+		})
+		r.config.Metrics.EmbeddingCall(query.Provider, embeddingModel(answer, query.Model), err)
+		r.recordUsage(ctx, project, query.Provider, query.Model, callStart, answer, err)
+		if err != nil {
+			return nil, err
+		}
+		if len(answer.Data) != len(batch) {
+			return nil, fmt.Errorf("The AI provider returned %d embeddings for %d chunks", len(answer.Data), len(batch))
+		}
+		model = embeddingModel(answer, query.Model)
+		for i, part := range batch {
+			chunk, err := aggregates.NewDocumentChunk(docID, part.Text, answer.Data[i].Embedding)
+			if err != nil {
+				return nil, err
+			}
+			chunk.Ordinal = ordinal + start + i
+			chunk.Start = part.Start
+			chunk.End = part.End
+			chunk.Metadata = query.Metadata
+			err = chunk.Validate()
+			if err != nil {
+				return nil, err
+			}
+			if len(chunks) > 0 && len(chunk.Embedding) != len(chunks[0].Embedding) {
+				return nil, errors.New("The AI provider returned embeddings with different dimensions")
+			}
+			chunks = append(chunks, *chunk)
+		}
+	}
+	err = r.recordEmbedding(ctx, *document, query.Provider, model, len(chunks[0].Embedding))
+	if err != nil {
+		return nil, err
+	}
+	err = r.store.CreateDocumentChunks(ctx, chunks)
+	if err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
 func (r *Rag) CreateDocument(ctx context.Context, document aggregates.Document) error {
 	err := document.Validate()
 	if err != nil {
-		return err
+		return er.New(err.Error(), er.BadRequest, true)
 	}
 	return r.store.CreateDocument(ctx, document)
 }
 
-func (r *Rag) ListDocuments(ctx context.Context) ([]aggregates.Document, error) {
-	return r.store.ListDocuments(ctx)
+// ListDocuments returns the documents selected by the filter
+func (r *Rag) ListDocuments(ctx context.Context, project string, filter aggregates.DocumentFilter) ([]aggregates.Document, error) {
+	documents, err := r.store.ListDocuments(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	result := []aggregates.Document{}
+	for _, document := range documents {
+		if document.MatchesFilter(filter) {
+			result = append(result, document)
+		}
+	}
+	return result, nil
 }
 
-func (r *Rag) Match(ctx context.Context, query aggregates.SearchQuery) ([]aggregates.DocumentChunk, error) {
+// Match searches for the chunks of the project documents matching the query
+func (r *Rag) Match(ctx context.Context, project string, query aggregates.SearchQuery) ([]aggregates.DocumentChunk, error) {
 	err := query.Validate()
 	if err != nil {
-		return nil, err
+		return nil, er.New(err.Error(), er.BadRequest, true)
+	}
+	var reranker Reranker
+	if query.Rerank != "" {
+		var ok bool
+		reranker, ok = r.config.Rerankers[query.Rerank]
+		if !ok {
+			return nil, er.Newf("Reranker %s is not configured", er.BadRequest, true, query.Rerank)
+		}
+	}
+	mode := query.Mode
+	if mode == "" {
+		mode = aggregates.VectorSearch
 	}
+	start := time.Now()
+	defer func() {
+		r.config.Metrics.RagMatch(mode, time.Since(start))
+	}()
 	client, ok := r.clients[query.Provider]
 	if !ok {
 		return nil, fmt.Errorf("AI provider %s not configured", query.Provider)
 	}
 	q := aggregates.EmbeddingQuery{
-		Input: query.Input,
-		Model: query.Model,
+		Input:    query.Input,
+		Model:    query.Model,
+		Provider: query.Provider,
 	}
+	embeddingStart := time.Now()
 	answer, err := client.Embedding(ctx, q)
+	r.config.Metrics.EmbeddingCall(query.Provider, embeddingModel(answer, query.Model), err)
+	r.recordUsage(ctx, project, query.Provider, query.Model, embeddingStart, answer, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(answer.Data) == 0 {
+		return nil, errors.New("The AI provider returned no embedding")
+	}
+	metric := query.Metric
+	if metric == "" {
+		metric = aggregates.L2
+	}
+	filter := aggregates.DocumentFilter{
+		IDs:    query.DocumentIDs,
+		Names:  query.DocumentNames,
+		Source: query.Source,
+		Labels: query.Labels,
+	}
+	limit := query.Candidates
+	if limit == 0 {
+		limit = query.Limit
+		if query.Mode == aggregates.HybridSearch || reranker != nil {
+			limit = query.Limit * CandidatesFactor
+		}
+	}
+	// only the chunks embedded with the same model are compared
+	chunks, err := r.store.FindClosestChunks(ctx, aggregates.ChunkSearch{
+		Project:   project,
+		Embedding: answer.Data[0].Embedding,
+		Provider:  query.Provider,
+		Model:     embeddingModel(answer, query.Model),
+		Limit:     limit,
+		Metric:    metric,
+		EfSearch:  query.EfSearch,
+		Probes:    query.Probes,
+		Filter:    filter,
+		Metadata:  query.Metadata,
+	})
 	if err != nil {
 		return nil, err
 	}
-	// TODO check index
-	return r.store.FindClosestChunks(ctx, query.Limit, answer.Data[0].Embedding)
+	if query.Mode == aggregates.HybridSearch {
+		keywordChunks, err := r.store.FindChunksByKeywords(ctx, aggregates.KeywordSearch{
+			Project:  project,
+			Query:    query.Input,
+			Limit:    limit,
+			Filter:   filter,
+			Metadata: query.Metadata,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chunks = fuse(chunks, keywordChunks, int(limit))
+	} else if query.MinScore != nil {
+		// the chunks are sorted by distance, so by decreasing score
+		result := []aggregates.DocumentChunk{}
+		for _, chunk := range chunks {
+			if *chunk.Score < *query.MinScore {
+				break
+			}
+			result = append(result, chunk)
+		}
+		chunks = result
+	}
+	if reranker != nil {
+		return rerank(ctx, reranker, project, query.Input, chunks, int(query.Limit))
+	}
+	if len(chunks) > int(query.Limit) {
+		chunks = chunks[:max(0, query.Limit)]
+	}
+	return chunks, nil
 }
 
-func (r *Rag) GetDocument(ctx context.Context, docID string) (*aggregates.Document, error) {
+func (r *Rag) GetDocument(ctx context.Context, project string, docID string) (*aggregates.Document, error) {
 	if err := id.Validate(docID, "invalid document ID"); err != nil {
 		return nil, err
 	}
-	return r.store.GetDocument(ctx, docID)
+	return r.store.GetDocument(ctx, project, docID)
 }
 
-func (r *Rag) DeleteDocument(ctx context.Context, docID string) error {
+func (r *Rag) DeleteDocument(ctx context.Context, project string, docID string) error {
 	if err := id.Validate(docID, "invalid document ID"); err != nil {
 		return err
 	}
-	return r.store.DeleteDocument(ctx, docID)
+	return r.store.DeleteDocument(ctx, project, docID)
 }
 
-func (r *Rag) DeleteDocumentChunk(ctx context.Context, chunkID string) error {
+func (r *Rag) DeleteDocumentChunk(ctx context.Context, project string, chunkID string) error {
 	if err := id.Validate(chunkID, "invalid document chunk ID"); err != nil {
 		return err
 	}
-	return r.store.DeleteDocumentChunk(ctx, chunkID)
+	return r.store.DeleteDocumentChunk(ctx, project, chunkID)
 }
 
-func (r *Rag) ListDocumentChunksForDocument(ctx context.Context, docID string) ([]aggregates.DocumentChunk, error) {
+// ListDocumentChunksForDocument returns the chunks of the document having all the metadata
+func (r *Rag) ListDocumentChunksForDocument(ctx context.Context, project string, docID string, metadata map[string]string) ([]aggregates.DocumentChunk, error) {
 	if err := id.Validate(docID, "invalid document ID"); err != nil {
 		return nil, err
 	}
-	return r.store.ListDocumentChunksForDocument(ctx, docID)
+	chunks, err := r.store.ListDocumentChunksForDocument(ctx, project, docID)
+	if err != nil {
+		return nil, err
+	}
+	result := []aggregates.DocumentChunk{}
+	for _, chunk := range chunks {
+		if chunk.HasMetadata(metadata) {
+			result = append(result, chunk)
+		}
+	}
+	return result, nil
+}
+
+func (r *Rag) indexStore() (VectorIndexStore, error) {
+	store, ok := r.store.(VectorIndexStore)
+	if !ok {
+		return nil, er.New("Vector indexes are only supported by the PostgreSQL store", er.BadRequest, true)
+	}
+	return store, nil
+}
+
+func (r *Rag) ListVectorIndexes(ctx context.Context) ([]aggregates.VectorIndex, error) {
+	store, err := r.indexStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.ListVectorIndexes(ctx)
+}
+
+func (r *Rag) CreateVectorIndex(ctx context.Context, index aggregates.VectorIndex) error {
+	err := index.Validate()
+	if err != nil {
+		return er.New(err.Error(), er.BadRequest, true)
+	}
+	store, err := r.indexStore()
+	if err != nil {
+		return err
+	}
+	return store.CreateVectorIndex(ctx, index)
+}
+
+func (r *Rag) RebuildVectorIndex(ctx context.Context, name string) error {
+	err := aggregates.ValidateVectorIndexName(name)
+	if err != nil {
+		return er.New(err.Error(), er.BadRequest, true)
+	}
+	store, err := r.indexStore()
+	if err != nil {
+		return err
+	}
+	return store.RebuildVectorIndex(ctx, name)
+}
+
+func (r *Rag) DropVectorIndex(ctx context.Context, name string) error {
+	err := aggregates.ValidateVectorIndexName(name)
+	if err != nil {
+		return er.New(err.Error(), er.BadRequest, true)
+	}
+	store, err := r.indexStore()
+	if err != nil {
+		return err
+	}
+	return store.DropVectorIndex(ctx, name)
 }
@@ -0,0 +1,538 @@
+package rag_test
+
+import (
+	"context"
+	"errors"
+	"github.com/appclacks/maizai/pkg/shared"
+	"testing"
+
+	"github.com/appclacks/maizai/internal/chunker"
+	"github.com/appclacks/maizai/internal/ragstore/memory"
+	usagememory "github.com/appclacks/maizai/internal/usagestore/memory"
+	aimock "github.com/appclacks/maizai/mocks/github.com/appclacks/maizai/pkg/rag"
+	"github.com/appclacks/maizai/pkg/rag"
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	"github.com/appclacks/maizai/pkg/usage"
+	usagedata "github.com/appclacks/maizai/pkg/usage/aggregates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIngest(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	ai := aimock.NewMockAI(t)
+	batches := [][]string{}
+	ai.EXPECT().BatchEmbedding(mock.Anything, mock.Anything).RunAndReturn(
+		func(ctx context.Context, query aggregates.BatchEmbeddingQuery) (*aggregates.EmbeddingAnswer, error) {
+			batches = append(batches, query.Inputs)
+			answer := &aggregates.EmbeddingAnswer{}
+			for range query.Inputs {
+				answer.Data = append(answer.Data, aggregates.Embedding{Embedding: []float32{1, 2}})
+			}
+			return answer, nil
+		})
+	manager := rag.New(store, map[string]rag.AI{"mistral": ai}, rag.Config{EmbeddingBatchSize: 2})
+
+	document, err := aggregates.NewDocument(shared.DefaultProject, "doc", "")
+	assert.NoError(t, err)
+	err = manager.CreateDocument(ctx, *document)
+	assert.NoError(t, err)
+
+	content := "# Title\nintro\n## Section\ncontent"
+	chunks, err := manager.Ingest(ctx, shared.DefaultProject, document.ID, aggregates.IngestQuery{
+		Content:  content,
+		Provider: "mistral",
+		Strategy: chunker.Markdown,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, "# Title\nintro", chunks[0].Fragment)
+	assert.Equal(t, 0, chunks[0].Ordinal)
+	assert.Equal(t, "## Section\ncontent", chunks[1].Fragment)
+	assert.Equal(t, 1, chunks[1].Ordinal)
+	assert.Equal(t, chunks[1].Fragment, content[chunks[1].Start:chunks[1].End])
+
+	// new chunks are added after the existing ones
+	chunks, err = manager.Ingest(ctx, shared.DefaultProject, document.ID, aggregates.IngestQuery{
+		Content:  "more content",
+		Provider: "mistral",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, 2, chunks[0].Ordinal)
+
+	stored, err := manager.ListDocumentChunksForDocument(ctx, shared.DefaultProject, document.ID, nil)
+	assert.NoError(t, err)
+	assert.Len(t, stored, 3)
+	for i, chunk := range stored {
+		assert.Equal(t, i, chunk.Ordinal)
+	}
+	assert.Equal(t, [][]string{{"# Title\nintro", "## Section\ncontent"}, {"more content"}}, batches)
+
+	// one provider call is done per batch
+	batches = [][]string{}
+	chunks, err = manager.Ingest(ctx, shared.DefaultProject, document.ID, aggregates.IngestQuery{
+		Content:  "a\n\nb\n\nc\n\nd\n\ne",
+		Provider: "mistral",
+		Strategy: chunker.Paragraph,
+		Size:     1,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 5)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, batches)
+	for i, chunk := range chunks {
+		assert.Equal(t, 3+i, chunk.Ordinal)
+	}
+
+	// the embedded chunks are also added after the existing ones
+	ai.EXPECT().Embedding(mock.Anything, mock.Anything).Return(&aggregates.EmbeddingAnswer{
+		Data: []aggregates.Embedding{{Embedding: []float32{1, 2}}},
+	}, nil)
+	err = manager.Embed(ctx, shared.DefaultProject, document.ID, aggregates.EmbeddingQuery{Input: "embedded", Provider: "mistral"})
+	assert.NoError(t, err)
+	stored, err = manager.ListDocumentChunksForDocument(ctx, shared.DefaultProject, document.ID, nil)
+	assert.NoError(t, err)
+	assert.Len(t, stored, 9)
+	assert.Equal(t, "embedded", stored[8].Fragment)
+	assert.Equal(t, 8, stored[8].Ordinal)
+
+	_, err = manager.Ingest(ctx, shared.DefaultProject, document.ID, aggregates.IngestQuery{
+		Content:  "content",
+		Provider: "mistral",
+		Strategy: "unknown",
+	})
+	assert.ErrorContains(t, err, "Invalid chunking strategy unknown")
+	_, err = manager.Ingest(ctx, shared.DefaultProject, document.ID, aggregates.IngestQuery{
+		Content:  "content",
+		Provider: "unknown",
+	})
+	assert.ErrorContains(t, err, "AI client unknown not configured")
+	_, err = manager.Ingest(ctx, shared.DefaultProject, document.ID, aggregates.IngestQuery{
+		Content:  "  ",
+		Provider: "mistral",
+	})
+	assert.ErrorContains(t, err, "Invalid content")
+}
+
+func TestEmbeddingSettings(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	ai := aimock.NewMockAI(t)
+	ai.EXPECT().Embedding(mock.Anything, mock.Anything).RunAndReturn(
+		func(ctx context.Context, query aggregates.EmbeddingQuery) (*aggregates.EmbeddingAnswer, error) {
+			model := query.Model
+			if model == "" {
+				model = "mistral-embed"
+			}
+			return &aggregates.EmbeddingAnswer{
+				Model: model,
+				Data:  []aggregates.Embedding{{Embedding: []float32{1, 2}}},
+			}, nil
+		})
+	manager := rag.New(store, map[string]rag.AI{"mistral": ai}, rag.Config{})
+
+	document, err := aggregates.NewDocument(shared.DefaultProject, "doc", "")
+	assert.NoError(t, err)
+	err = manager.CreateDocument(ctx, *document)
+	assert.NoError(t, err)
+
+	err = manager.Embed(ctx, shared.DefaultProject, document.ID, aggregates.EmbeddingQuery{Input: "content"})
+	assert.ErrorContains(t, err, "No embedding provider is configured for document doc")
+
+	// the settings are recorded with the first chunk
+	err = manager.Embed(ctx, shared.DefaultProject, document.ID, aggregates.EmbeddingQuery{Input: "first", Provider: "mistral"})
+	assert.NoError(t, err)
+	result, err := manager.GetDocument(ctx, shared.DefaultProject, document.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "mistral", result.EmbeddingProvider)
+	assert.Equal(t, "mistral-embed", result.EmbeddingModel)
+	assert.Equal(t, 2, result.Dimension)
+
+	// the settings of the document are used by default
+	err = manager.Embed(ctx, shared.DefaultProject, document.ID, aggregates.EmbeddingQuery{Input: "second"})
+	assert.NoError(t, err)
+
+	err = manager.Embed(ctx, shared.DefaultProject, document.ID, aggregates.EmbeddingQuery{Input: "third", Provider: "mistral", Model: "other-model"})
+	assert.ErrorContains(t, err, "Document doc uses the model mistral-embed of provider mistral with 2 dimensions")
+
+	chunks, err := manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Model: "other-model", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 0)
+}
+
+type indexStore struct {
+	*memory.MemoryRagStore
+	indexes []aggregates.VectorIndex
+}
+
+func (s *indexStore) ListVectorIndexes(ctx context.Context) ([]aggregates.VectorIndex, error) {
+	return s.indexes, nil
+}
+
+func (s *indexStore) CreateVectorIndex(ctx context.Context, index aggregates.VectorIndex) error {
+	s.indexes = append(s.indexes, index)
+	return nil
+}
+
+func (s *indexStore) RebuildVectorIndex(ctx context.Context, name string) error {
+	return nil
+}
+
+func (s *indexStore) DropVectorIndex(ctx context.Context, name string) error {
+	return nil
+}
+
+func TestVectorIndexes(t *testing.T) {
+	ctx := context.Background()
+	manager := rag.New(memory.New(), map[string]rag.AI{}, rag.Config{})
+	_, err := manager.ListVectorIndexes(ctx)
+	assert.ErrorContains(t, err, "Vector indexes are only supported by the PostgreSQL store")
+
+	store := &indexStore{MemoryRagStore: memory.New()}
+	manager = rag.New(store, map[string]rag.AI{}, rag.Config{})
+	err = manager.CreateVectorIndex(ctx, aggregates.NewVectorIndex(aggregates.HNSW, "", 1024, 0, 0, 0))
+	assert.NoError(t, err)
+	err = manager.CreateVectorIndex(ctx, aggregates.NewVectorIndex(aggregates.IVFFlat, aggregates.Cosine, 1536, 0, 0, 0))
+	assert.NoError(t, err)
+	indexes, err := manager.ListVectorIndexes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []aggregates.VectorIndex{
+		{Name: "idx_document_chunk_embedding_hnsw_1024", Type: aggregates.HNSW, Metric: aggregates.L2, Dimension: 1024, M: 16, EfConstruction: 64},
+		{Name: "idx_document_chunk_embedding_ivfflat_cosine_1536", Type: aggregates.IVFFlat, Metric: aggregates.Cosine, Dimension: 1536, Lists: 100},
+	}, indexes)
+
+	err = manager.CreateVectorIndex(ctx, aggregates.NewVectorIndex(aggregates.HNSW, "", 3072, 0, 0, 0))
+	assert.ErrorContains(t, err, "Invalid dimension")
+	err = manager.CreateVectorIndex(ctx, aggregates.NewVectorIndex(aggregates.HNSW, "", 1024, 32, 40, 0))
+	assert.ErrorContains(t, err, "twice the m parameter")
+	err = manager.CreateVectorIndex(ctx, aggregates.NewVectorIndex(aggregates.IVFFlat, "", 1024, 16, 0, 0))
+	assert.ErrorContains(t, err, "only supported by hnsw indexes")
+	err = manager.CreateVectorIndex(ctx, aggregates.NewVectorIndex("flat", "", 1024, 0, 0, 0))
+	assert.ErrorContains(t, err, "Invalid index type flat")
+	err = manager.CreateVectorIndex(ctx, aggregates.NewVectorIndex(aggregates.HNSW, "manhattan", 1024, 0, 0, 0))
+	assert.ErrorContains(t, err, "Invalid metric manhattan")
+	err = manager.DropVectorIndex(ctx, "document_chunk_pkey")
+	assert.ErrorContains(t, err, "Invalid vector index name document_chunk_pkey")
+	err = manager.RebuildVectorIndex(ctx, "idx_document_chunk_embedding_hnsw_1024")
+	assert.NoError(t, err)
+
+	_, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 1, EfSearch: 2000})
+	assert.ErrorContains(t, err, "Invalid ef-search")
+}
+
+func TestMatchFilters(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	ai := aimock.NewMockAI(t)
+	embeddings := map[string][]float32{
+		"query": {1, 0},
+		"close": {1, 0.1},
+		"far":   {-1, 0},
+		"other": {1, 0},
+	}
+	ai.EXPECT().Embedding(mock.Anything, mock.Anything).RunAndReturn(
+		func(ctx context.Context, query aggregates.EmbeddingQuery) (*aggregates.EmbeddingAnswer, error) {
+			return &aggregates.EmbeddingAnswer{
+				Model: "mistral-embed",
+				Data:  []aggregates.Embedding{{Embedding: embeddings[query.Input]}},
+			}, nil
+		})
+	manager := rag.New(store, map[string]rag.AI{"mistral": ai}, rag.Config{})
+
+	product, err := aggregates.NewDocument(shared.DefaultProject, "product", "")
+	assert.NoError(t, err)
+	product.Labels = map[string]string{"product": "maizai"}
+	err = manager.CreateDocument(ctx, *product)
+	assert.NoError(t, err)
+	other, err := aggregates.NewDocument(shared.DefaultProject, "other", "")
+	assert.NoError(t, err)
+	err = manager.CreateDocument(ctx, *other)
+	assert.NoError(t, err)
+	for _, input := range []string{"close", "far"} {
+		err = manager.Embed(ctx, shared.DefaultProject, product.ID, aggregates.EmbeddingQuery{Input: input, Provider: "mistral"})
+		assert.NoError(t, err)
+	}
+	err = manager.Embed(ctx, shared.DefaultProject, other.ID, aggregates.EmbeddingQuery{Input: "other", Provider: "mistral"})
+	assert.NoError(t, err)
+
+	chunks, err := manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 3)
+	assert.Equal(t, "other", chunks[0].Fragment)
+
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10, DocumentIDs: []string{product.ID}})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, "close", chunks[0].Fragment)
+
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10, DocumentIDs: []string{product.ID}, DocumentNames: []string{"other"}})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 3)
+
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10, DocumentNames: []string{"other"}})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, other.ID, chunks[0].DocumentID)
+
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10, Labels: map[string]string{"product": "maizai"}})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10, Labels: map[string]string{"product": "other"}})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 0)
+
+	minScore := 0.5
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10, Metric: aggregates.Cosine, MinScore: &minScore})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	for _, chunk := range chunks {
+		assert.GreaterOrEqual(t, *chunk.Score, minScore)
+	}
+
+	_, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10, DocumentIDs: []string{"invalid"}})
+	assert.ErrorContains(t, err, "invalid document ID")
+}
+
+func TestSourceAndMetadata(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	ai := aimock.NewMockAI(t)
+	ai.EXPECT().Embedding(mock.Anything, mock.Anything).Return(&aggregates.EmbeddingAnswer{
+		Model: "mistral-embed",
+		Data:  []aggregates.Embedding{{Embedding: []float32{1, 0}}},
+	}, nil)
+	manager := rag.New(store, map[string]rag.AI{"mistral": ai}, rag.Config{})
+
+	guide, err := aggregates.NewDocument(shared.DefaultProject, "guide", "")
+	assert.NoError(t, err)
+	guide.Source = "https://example.com/guide"
+	guide.Labels = map[string]string{"product": "maizai"}
+	err = manager.CreateDocument(ctx, *guide)
+	assert.NoError(t, err)
+	other, err := aggregates.NewDocument(shared.DefaultProject, "other", "")
+	assert.NoError(t, err)
+	err = manager.CreateDocument(ctx, *other)
+	assert.NoError(t, err)
+
+	invalid, err := aggregates.NewDocument(shared.DefaultProject, "invalid", "")
+	assert.NoError(t, err)
+	invalid.Source = "not an uri"
+	err = manager.CreateDocument(ctx, *invalid)
+	assert.ErrorContains(t, err, "Invalid source not an uri")
+
+	documents, err := manager.ListDocuments(ctx, shared.DefaultProject, aggregates.DocumentFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, documents, 2)
+	documents, err = manager.ListDocuments(ctx, shared.DefaultProject, aggregates.DocumentFilter{Source: "https://example.com/guide"})
+	assert.NoError(t, err)
+	assert.Len(t, documents, 1)
+	assert.Equal(t, guide.ID, documents[0].ID)
+	documents, err = manager.ListDocuments(ctx, shared.DefaultProject, aggregates.DocumentFilter{Labels: map[string]string{"product": "other"}})
+	assert.NoError(t, err)
+	assert.Len(t, documents, 0)
+	documents, err = manager.ListDocuments(ctx, shared.DefaultProject, aggregates.DocumentFilter{Names: []string{"other"}})
+	assert.NoError(t, err)
+	assert.Len(t, documents, 1)
+	assert.Equal(t, other.ID, documents[0].ID)
+
+	err = manager.Embed(ctx, shared.DefaultProject, guide.ID, aggregates.EmbeddingQuery{Input: "installation", Provider: "mistral", Metadata: map[string]string{"section": "install"}})
+	assert.NoError(t, err)
+	err = manager.Embed(ctx, shared.DefaultProject, guide.ID, aggregates.EmbeddingQuery{Input: "usage", Provider: "mistral", Metadata: map[string]string{"section": "usage"}})
+	assert.NoError(t, err)
+	err = manager.Embed(ctx, shared.DefaultProject, other.ID, aggregates.EmbeddingQuery{Input: "other", Provider: "mistral", Metadata: map[string]string{"section": "install"}})
+	assert.NoError(t, err)
+	err = manager.Embed(ctx, shared.DefaultProject, guide.ID, aggregates.EmbeddingQuery{Input: "invalid", Provider: "mistral", Metadata: map[string]string{"": "install"}})
+	assert.ErrorContains(t, err, "Invalid metadata")
+
+	chunks, err := manager.ListDocumentChunksForDocument(ctx, shared.DefaultProject, guide.ID, map[string]string{"section": "install"})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, "installation", chunks[0].Fragment)
+	assert.Equal(t, map[string]string{"section": "install"}, chunks[0].Metadata)
+
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10, Metadata: map[string]string{"section": "install"}})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 10, Source: "https://example.com/guide", Metadata: map[string]string{"section": "install"}})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, "installation", chunks[0].Fragment)
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "usage", Provider: "mistral", Limit: 10, Mode: aggregates.HybridSearch, Metadata: map[string]string{"section": "install"}})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	for _, chunk := range chunks {
+		assert.Equal(t, "install", chunk.Metadata["section"])
+	}
+}
+
+func TestHybridSearch(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	ai := aimock.NewMockAI(t)
+	embeddings := map[string][]float32{
+		"how to fix ERR_42": {1, 0},
+		"close":             {1, 0.1},
+		"closer":            {1, 0.05},
+		"The ERR_42 error is returned when the quota is exceeded": {-1, 0},
+	}
+	ai.EXPECT().Embedding(mock.Anything, mock.Anything).RunAndReturn(
+		func(ctx context.Context, query aggregates.EmbeddingQuery) (*aggregates.EmbeddingAnswer, error) {
+			return &aggregates.EmbeddingAnswer{
+				Model: "mistral-embed",
+				Data:  []aggregates.Embedding{{Embedding: embeddings[query.Input]}},
+			}, nil
+		})
+	manager := rag.New(store, map[string]rag.AI{"mistral": ai}, rag.Config{})
+
+	document, err := aggregates.NewDocument(shared.DefaultProject, "doc", "")
+	assert.NoError(t, err)
+	err = manager.CreateDocument(ctx, *document)
+	assert.NoError(t, err)
+	for _, input := range []string{"close", "closer", "The ERR_42 error is returned when the quota is exceeded"} {
+		err = manager.Embed(ctx, shared.DefaultProject, document.ID, aggregates.EmbeddingQuery{Input: input, Provider: "mistral"})
+		assert.NoError(t, err)
+	}
+
+	chunks, err := manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "how to fix ERR_42", Provider: "mistral", Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, "closer", chunks[0].Fragment)
+	assert.Equal(t, "close", chunks[1].Fragment)
+
+	// the chunk containing the error code is ranked by the keyword search
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "how to fix ERR_42", Provider: "mistral", Limit: 2, Mode: aggregates.HybridSearch})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, "The ERR_42 error is returned when the quota is exceeded", chunks[0].Fragment)
+	assert.InDelta(t, 1.0/63+1.0/61, *chunks[0].Score, 0.000001)
+	assert.Equal(t, "closer", chunks[1].Fragment)
+	assert.InDelta(t, 1.0/61, *chunks[1].Score, 0.000001)
+
+	minScore := 0.5
+	_, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "how to fix ERR_42", Provider: "mistral", Limit: 2, Mode: aggregates.HybridSearch, MinScore: &minScore})
+	assert.ErrorContains(t, err, "not supported by hybrid searches")
+	_, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "how to fix ERR_42", Provider: "mistral", Limit: 2, Mode: "keyword"})
+	assert.ErrorContains(t, err, "Invalid search mode keyword")
+}
+
+func TestRerank(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	ai := aimock.NewMockAI(t)
+	embeddings := map[string][]float32{
+		"query":  {1, 0},
+		"first":  {1, 0.01},
+		"second": {1, 0.02},
+		"third":  {1, 0.03},
+		"fourth": {1, 0.04},
+	}
+	ai.EXPECT().Embedding(mock.Anything, mock.Anything).RunAndReturn(
+		func(ctx context.Context, query aggregates.EmbeddingQuery) (*aggregates.EmbeddingAnswer, error) {
+			return &aggregates.EmbeddingAnswer{
+				Model: "mistral-embed",
+				Data:  []aggregates.Embedding{{Embedding: embeddings[query.Input]}},
+			}, nil
+		})
+	reranker := aimock.NewMockReranker(t)
+	manager := rag.New(store, map[string]rag.AI{"mistral": ai}, rag.Config{
+		Rerankers: map[string]rag.Reranker{"judge": reranker},
+	})
+
+	document, err := aggregates.NewDocument(shared.DefaultProject, "doc", "")
+	assert.NoError(t, err)
+	err = manager.CreateDocument(ctx, *document)
+	assert.NoError(t, err)
+	for _, input := range []string{"first", "second", "third", "fourth"} {
+		err = manager.Embed(ctx, shared.DefaultProject, document.ID, aggregates.EmbeddingQuery{Input: input, Provider: "mistral"})
+		assert.NoError(t, err)
+	}
+
+	// the candidates are retrieved by distance, then reordered by the reranker
+	reranker.EXPECT().Rerank(mock.Anything, shared.DefaultProject, "query", []string{"first", "second", "third"}).Return([]float64{0.1, 0.2, 0.9}, nil).Once()
+	chunks, err := manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 2, Candidates: 3, Rerank: "judge"})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, "third", chunks[0].Fragment)
+	assert.Equal(t, 0.9, *chunks[0].Score)
+	assert.NotNil(t, chunks[0].Distance)
+	assert.Equal(t, "second", chunks[1].Fragment)
+	assert.Equal(t, 0.2, *chunks[1].Score)
+
+	// 4 times the limit by default
+	reranker.EXPECT().Rerank(mock.Anything, shared.DefaultProject, "query", []string{"first", "second", "third", "fourth"}).Return([]float64{0.1, 0.2, 0.3, 0.4}, nil).Once()
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 1, Rerank: "judge"})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, "fourth", chunks[0].Fragment)
+
+	// without reranker, the candidates are trimmed to the limit
+	chunks, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 1, Candidates: 3})
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, "first", chunks[0].Fragment)
+
+	reranker.EXPECT().Rerank(mock.Anything, shared.DefaultProject, "query", []string{"first"}).Return([]float64{0.1, 0.2}, nil).Once()
+	_, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 1, Candidates: 1, Rerank: "judge"})
+	assert.ErrorContains(t, err, "The reranker returned 2 scores for 1 chunks")
+
+	_, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 1, Rerank: "unknown"})
+	assert.ErrorContains(t, err, "Reranker unknown is not configured")
+	_, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 2, Candidates: 1, Rerank: "judge"})
+	assert.ErrorContains(t, err, "Invalid candidates")
+}
+
+func TestEmbeddingUsage(t *testing.T) {
+	ctx := usage.WithToken(context.Background(), "token-id")
+	store := memory.New()
+	ai := aimock.NewMockAI(t)
+	ai.EXPECT().Embedding(mock.Anything, mock.Anything).Return(&aggregates.EmbeddingAnswer{
+		Model:       "mistral-embed",
+		InputTokens: 5,
+		Data:        []aggregates.Embedding{{Embedding: []float32{1, 0}}},
+	}, nil).Twice()
+	ai.EXPECT().BatchEmbedding(mock.Anything, mock.Anything).Return(&aggregates.EmbeddingAnswer{
+		Model:       "mistral-embed",
+		InputTokens: 7,
+		Data:        []aggregates.Embedding{{Embedding: []float32{1, 1}}},
+	}, nil).Once()
+	ai.EXPECT().Embedding(mock.Anything, mock.Anything).Return(nil, errors.New("provider error")).Once()
+	usageStore := usagememory.New()
+	manager := rag.New(store, map[string]rag.AI{"mistral": ai}, rag.Config{
+		Usage: usage.New(usageStore, usage.Config{}),
+	})
+
+	document, err := aggregates.NewDocument(shared.DefaultProject, "doc", "")
+	assert.NoError(t, err)
+	err = manager.CreateDocument(ctx, *document)
+	assert.NoError(t, err)
+	err = manager.Embed(ctx, shared.DefaultProject, document.ID, aggregates.EmbeddingQuery{Input: "first", Provider: "mistral"})
+	assert.NoError(t, err)
+	_, err = manager.Ingest(ctx, shared.DefaultProject, document.ID, aggregates.IngestQuery{Content: "second", Provider: "mistral"})
+	assert.NoError(t, err)
+	_, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 1})
+	assert.NoError(t, err)
+	_, err = manager.Match(ctx, shared.DefaultProject, aggregates.SearchQuery{Input: "query", Provider: "mistral", Limit: 1})
+	assert.Error(t, err)
+
+	records, err := usageStore.ListUsageRecords(ctx, shared.DefaultProject, usagedata.Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, records, 4)
+	for _, record := range records {
+		assert.Equal(t, shared.DefaultProject, record.Project)
+		assert.Equal(t, "token-id", record.Token)
+		assert.Equal(t, "mistral", record.Provider)
+		assert.Empty(t, record.Context)
+	}
+	// the records contain the model used by the provider
+	assert.Equal(t, "mistral-embed", records[0].Model)
+	assert.Equal(t, uint64(5), records[0].InputTokens)
+	assert.Equal(t, uint64(7), records[1].InputTokens)
+	assert.Equal(t, uint64(5), records[2].InputTokens)
+	assert.Equal(t, usagedata.SuccessStatus, records[2].Status)
+	assert.Equal(t, usagedata.ErrorStatus, records[3].Status)
+	assert.Equal(t, uint64(0), records[3].InputTokens)
+}
@@ -0,0 +1,41 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+)
+
+// rerank replaces the scores of the chunks by the scores of the reranker,
+// sorts the chunks by decreasing score and keeps the best ones.
+func rerank(ctx context.Context, reranker Reranker, project string, query string, chunks []aggregates.DocumentChunk, limit int) ([]aggregates.DocumentChunk, error) {
+	if len(chunks) == 0 {
+		return chunks, nil
+	}
+	fragments := []string{}
+	for _, chunk := range chunks {
+		fragments = append(fragments, chunk.Fragment)
+	}
+	scores, err := reranker.Rerank(ctx, project, query, fragments)
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) != len(chunks) {
+		return nil, fmt.Errorf("The reranker returned %d scores for %d chunks", len(scores), len(chunks))
+	}
+	result := []aggregates.DocumentChunk{}
+	for i, chunk := range chunks {
+		score := scores[i]
+		chunk.Score = &score
+		result = append(result, chunk)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return *result[i].Score > *result[j].Score
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
@@ -0,0 +1,43 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/appclacks/maizai/pkg/rag/aggregates"
+	usagedata "github.com/appclacks/maizai/pkg/usage/aggregates"
+)
+
+type UsageRecorder interface {
+	Record(ctx context.Context, record usagedata.Record) error
+}
+
+// recordUsage stores the usage of an embedding call started at start.
+// Errors are logged: a failed recording doesn't fail the embedding or the search.
+func (r *Rag) recordUsage(ctx context.Context, project string, provider string, model string, start time.Time, answer *aggregates.EmbeddingAnswer, callErr error) {
+	if r.config.Usage == nil {
+		return
+	}
+	record := usagedata.Record{
+		Project:  project,
+		Provider: provider,
+		Model:    model,
+		Latency:  time.Since(start),
+		Status:   usagedata.SuccessStatus,
+	}
+	if callErr != nil {
+		record.Status = usagedata.ErrorStatus
+	}
+	if answer != nil {
+		record.Model = embeddingModel(answer, model)
+		record.InputTokens = answer.InputTokens
+		record.OutputTokens = answer.OutputTokens
+	}
+	// the call is recorded even if the client went away
+	err := r.config.Usage.Record(context.WithoutCancel(ctx), record)
+	if err != nil {
+		slog.Error(fmt.Sprintf("fail to record usage for provider %s: %s", provider, err.Error()))
+	}
+}
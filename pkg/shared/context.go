@@ -1,8 +1,12 @@
 package shared
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/appclacks/maizai/internal/id"
@@ -12,11 +16,109 @@ import (
 const UserRole = "user"
 const AssistantRole = "assistant"
 
+// ToolRole is used for messages containing the result of a tool call
+const ToolRole = "tool"
+
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+func (t ToolCall) Validate() error {
+	if t.ID == "" {
+		return errors.New("A tool call ID is mandatory")
+	}
+	if t.Name == "" {
+		return errors.New("A tool call name is mandatory")
+	}
+	if len(t.Arguments) != 0 && !json.Valid(t.Arguments) {
+		return fmt.Errorf("Invalid arguments for tool call %s: arguments should be valid JSON", t.ID)
+	}
+	return nil
+}
+
+const TextPart = "text"
+const ImagePart = "image"
+
+// DocumentPart is used for PDF documents
+const DocumentPart = "document"
+
+const PDFMediaType = "application/pdf"
+
+var imageMediaTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// ContentPart is a typed part of a message. Images and documents are either
+// provided as base64 encoded data with their media type, or as an URL.
+type ContentPart struct {
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	MediaType string `json:"media-type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+func (p ContentPart) Validate() error {
+	switch p.Type {
+	case TextPart:
+		if p.Text == "" {
+			return errors.New("Text content parts can't be empty")
+		}
+		return nil
+	case ImagePart, DocumentPart:
+		if p.Data == "" && p.URL == "" {
+			return fmt.Errorf("Either data or an URL is mandatory for %s content parts", p.Type)
+		}
+		if p.Data != "" && p.URL != "" {
+			return fmt.Errorf("Data and URL are mutually exclusive for %s content parts", p.Type)
+		}
+		if p.Data != "" {
+			if _, err := base64.StdEncoding.DecodeString(p.Data); err != nil {
+				return fmt.Errorf("Invalid data for %s content part: data should be base64 encoded", p.Type)
+			}
+			if p.Type == ImagePart && !slices.Contains(imageMediaTypes, p.MediaType) {
+				return fmt.Errorf("Invalid media type %s for image content part: the media type should be one of %s", p.MediaType, strings.Join(imageMediaTypes, ", "))
+			}
+			if p.Type == DocumentPart && p.MediaType != PDFMediaType {
+				return fmt.Errorf("Invalid media type %s for document content part: only %s is supported", p.MediaType, PDFMediaType)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("Invalid content part type %s: the type should be %s, %s or %s", p.Type, TextPart, ImagePart, DocumentPart)
+}
+
+// DataURL returns the part as an URL, building a data URL for base64 encoded parts
+func (p ContentPart) DataURL() string {
+	if p.URL != "" {
+		return p.URL
+	}
+	return fmt.Sprintf("data:%s;base64,%s", p.MediaType, p.Data)
+}
+
 type Message struct {
 	ID        string    `json:"id"`
 	Role      string    `json:"role"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created-at"`
+	// ToolCalls are the tools the assistant asked to call
+	ToolCalls []ToolCall `json:"tool-calls,omitempty"`
+	// ToolCallID is the ID of the tool call answered by a tool message
+	ToolCallID string `json:"tool-call-id,omitempty"`
+	// Parts are sent to the provider after the message content
+	Parts []ContentPart `json:"parts,omitempty"`
+	// Pinned messages are kept when the context is truncated
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// AllParts returns the message content followed by its parts
+func (m Message) AllParts() []ContentPart {
+	result := []ContentPart{}
+	if m.Content != "" {
+		result = append(result, ContentPart{Type: TextPart, Text: m.Content})
+	}
+	result = append(result, m.Parts...)
+	return result
 }
 
 func NewMessage(role string, content string) (*Message, error) {
@@ -32,6 +134,15 @@ func NewMessage(role string, content string) (*Message, error) {
 	}, nil
 }
 
+func NewToolMessage(toolCallID string, content string) (*Message, error) {
+	msg, err := NewMessage(ToolRole, content)
+	if err != nil {
+		return nil, err
+	}
+	msg.ToolCallID = toolCallID
+	return msg, nil
+}
+
 func NewUserMessages(content string) ([]Message, error) {
 	msg, err := NewMessage(UserRole, content)
 	if err != nil {
@@ -47,10 +158,35 @@ func (m Message) Validate() error {
 	if m.Role == "" {
 		return errors.New("A role is mandatory for the message")
 	}
-	if m.Role != UserRole && m.Role != AssistantRole {
-		return fmt.Errorf("Invalid value for role %s: The message role should be %s or %s", m.Role, UserRole, AssistantRole)
+	if m.Role != UserRole && m.Role != AssistantRole && m.Role != ToolRole {
+		return fmt.Errorf("Invalid value for role %s: The message role should be %s, %s or %s", m.Role, UserRole, AssistantRole, ToolRole)
 	}
-	if m.Content == "" {
+	if len(m.ToolCalls) != 0 && m.Role != AssistantRole {
+		return fmt.Errorf("Only %s messages can contain tool calls", AssistantRole)
+	}
+	for _, toolCall := range m.ToolCalls {
+		err := toolCall.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if m.Role == ToolRole && m.ToolCallID == "" {
+		return errors.New("A tool call ID is mandatory for tool messages")
+	}
+	if m.Role != ToolRole && m.ToolCallID != "" {
+		return fmt.Errorf("Only %s messages can reference a tool call ID", ToolRole)
+	}
+	for _, part := range m.Parts {
+		err := part.Validate()
+		if err != nil {
+			return err
+		}
+		if part.Type != TextPart && m.Role != UserRole {
+			return fmt.Errorf("Only %s messages can contain images or documents", UserRole)
+		}
+	}
+	// an assistant message calling tools can have no text
+	if m.Content == "" && len(m.ToolCalls) == 0 && len(m.Parts) == 0 {
 		return errors.New("Message content can't be empty")
 	}
 	return nil
@@ -62,6 +198,7 @@ type ContextSources struct {
 
 type Context struct {
 	ID          string         `json:"id"`
+	Project     string         `json:"project"`
 	Name        string         `json:"name"`
 	Description string         `json:"description"`
 	Sources     ContextSources `json:"sources"`
@@ -71,6 +208,7 @@ type Context struct {
 
 type ContextMetadata struct {
 	ID          string         `json:"id"`
+	Project     string         `json:"project"`
 	Name        string         `json:"name"`
 	Description string         `json:"description"`
 	CreatedAt   time.Time      `json:"created-at"`
@@ -81,6 +219,9 @@ func (c Context) Validate() error {
 	if err := id.Validate(c.ID, "invalid context ID"); err != nil {
 		return err
 	}
+	if err := ValidateProject(c.Project); err != nil {
+		return err
+	}
 	if c.Name == "" {
 		return errors.New("A context name is mandatory")
 	}
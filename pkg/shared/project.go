@@ -0,0 +1,20 @@
+package shared
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultProject is the project of the requests which don't specify one
+const DefaultProject = "default"
+
+var projectRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidateProject checks that the project name is valid. Project names
+// contain up to 63 lowercase letters, digits and hyphens.
+func ValidateProject(project string) error {
+	if !projectRegexp.MatchString(project) {
+		return fmt.Errorf("Invalid project %s: the project should contain up to 63 lowercase letters, digits and hyphens, and start and end with a letter or a digit", project)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package aggregates
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	SuccessStatus = "success"
+	ErrorStatus   = "error"
+)
+
+const (
+	GroupByDay     = "day"
+	GroupByModel   = "model"
+	GroupByContext = "context"
+	GroupByToken   = "token"
+)
+
+// Record is the usage of a single call to an AI provider
+type Record struct {
+	ID      string
+	Project string
+	// Context is the conversation context, empty if the call is not related to a context
+	Context string
+	// Token is the identifier of the API token used for the request, empty if the authentication is disabled
+	Token        string
+	Provider     string
+	Model        string
+	InputTokens  uint64
+	OutputTokens uint64
+	Latency      time.Duration
+	Status       string
+	CreatedAt    time.Time
+}
+
+func (r Record) Validate() error {
+	if r.Provider == "" {
+		return errors.New("The provider of a usage record is mandatory")
+	}
+	if r.Status != SuccessStatus && r.Status != ErrorStatus {
+		return fmt.Errorf("Invalid status %s for usage record: the status should be %s or %s", r.Status, SuccessStatus, ErrorStatus)
+	}
+	if r.CreatedAt.IsZero() {
+		return errors.New("A usage record should have a creation date")
+	}
+	return nil
+}
+
+// Filter selects the usage records. Empty fields are ignored.
+type Filter struct {
+	Since   time.Time
+	Until   time.Time
+	Context string
+	Token   string
+	Model   string
+}
+
+func (f Filter) Match(record Record) bool {
+	return (f.Since.IsZero() || !record.CreatedAt.Before(f.Since)) &&
+		(f.Until.IsZero() || record.CreatedAt.Before(f.Until)) &&
+		(f.Context == "" || record.Context == f.Context) &&
+		(f.Token == "" || record.Token == f.Token) &&
+		(f.Model == "" || record.Model == f.Model)
+}
+
+type ReportQuery struct {
+	Filter
+	// GroupBy contains the dimensions of the report rows: day, model, context or token
+	GroupBy []string
+}
+
+func (q ReportQuery) Validate() error {
+	seen := make(map[string]bool)
+	for _, groupBy := range q.GroupBy {
+		if groupBy != GroupByDay && groupBy != GroupByModel && groupBy != GroupByContext && groupBy != GroupByToken {
+			return fmt.Errorf("Invalid group by %s: supported values are %s, %s, %s and %s", groupBy, GroupByDay, GroupByModel, GroupByContext, GroupByToken)
+		}
+		if seen[groupBy] {
+			return fmt.Errorf("The group by %s is defined multiple times", groupBy)
+		}
+		seen[groupBy] = true
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Since.Before(q.Until) {
+		return errors.New("The start of the report period should be before its end")
+	}
+	return nil
+}
+
+// ReportRow is the usage aggregated for a group. The fields which are not
+// part of the group by are empty.
+type ReportRow struct {
+	// Day is formatted as YYYY-MM-DD, in UTC
+	Day            string
+	Provider       string
+	Model          string
+	Context        string
+	Token          string
+	Requests       uint64
+	Errors         uint64
+	InputTokens    uint64
+	OutputTokens   uint64
+	AverageLatency time.Duration
+	// Cost is computed from the price table. Calls to models without price cost 0.
+	Cost float64
+}
+
+// Price is the price of a model for one million tokens. An empty model
+// matches the calls recorded without model.
+type Price struct {
+	Provider string
+	Model    string
+	Input    float64
+	Output   float64
+}
+
+// Cost returns the cost of the tokens
+func (p Price) Cost(inputTokens uint64, outputTokens uint64) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1000000
+}
@@ -0,0 +1,164 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/appclacks/maizai/internal/id"
+	"github.com/appclacks/maizai/pkg/usage/aggregates"
+	er "github.com/mcorbin/corbierror"
+)
+
+type Store interface {
+	CreateUsageRecord(ctx context.Context, record aggregates.Record) error
+	ListUsageRecords(ctx context.Context, project string, filter aggregates.Filter) ([]aggregates.Record, error)
+}
+
+type Config struct {
+	Prices []aggregates.Price
+}
+
+type priceKey struct {
+	provider string
+	model    string
+}
+
+type Manager struct {
+	store  Store
+	prices map[priceKey]aggregates.Price
+}
+
+func New(store Store, config Config) *Manager {
+	prices := make(map[priceKey]aggregates.Price)
+	for _, price := range config.Prices {
+		prices[priceKey{provider: price.Provider, model: price.Model}] = price
+	}
+	return &Manager{
+		store:  store,
+		prices: prices,
+	}
+}
+
+type tokenKey struct{}
+
+// WithToken attaches the identifier of the API token of the request to the context
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// Token returns the identifier of the API token attached to the context
+func Token(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	return token
+}
+
+// Record stores the usage of a provider call. The token is read from the context.
+func (m *Manager) Record(ctx context.Context, record aggregates.Record) error {
+	recordID, err := id.New()
+	if err != nil {
+		return err
+	}
+	record.ID = recordID
+	record.Token = Token(ctx)
+	record.CreatedAt = time.Now().UTC()
+	err = record.Validate()
+	if err != nil {
+		return err
+	}
+	return m.store.CreateUsageRecord(ctx, record)
+}
+
+// Recorder receives the usage of the provider calls
+type Recorder interface {
+	Record(ctx context.Context, record aggregates.Record) error
+}
+
+// Recorders sends the usage of the provider calls to several recorders
+type Recorders []Recorder
+
+func (r Recorders) Record(ctx context.Context, record aggregates.Record) error {
+	errs := []error{}
+	for _, recorder := range r {
+		err := recorder.Record(ctx, record)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) cost(record aggregates.Record) float64 {
+	price, ok := m.prices[priceKey{provider: record.Provider, model: record.Model}]
+	if !ok {
+		return 0
+	}
+	return price.Cost(record.InputTokens, record.OutputTokens)
+}
+
+// Report aggregates the usage records of the project matching the query
+func (m *Manager) Report(ctx context.Context, project string, query aggregates.ReportQuery) ([]aggregates.ReportRow, error) {
+	err := query.Validate()
+	if err != nil {
+		return nil, er.New(err.Error(), er.BadRequest, true)
+	}
+	records, err := m.store.ListUsageRecords(ctx, project, query.Filter)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[aggregates.ReportRow]*aggregates.ReportRow)
+	latencies := make(map[aggregates.ReportRow]time.Duration)
+	for _, record := range records {
+		var key aggregates.ReportRow
+		for _, groupBy := range query.GroupBy {
+			switch groupBy {
+			case aggregates.GroupByDay:
+				key.Day = record.CreatedAt.UTC().Format(time.DateOnly)
+			case aggregates.GroupByModel:
+				key.Provider = record.Provider
+				key.Model = record.Model
+			case aggregates.GroupByContext:
+				key.Context = record.Context
+			case aggregates.GroupByToken:
+				key.Token = record.Token
+			}
+		}
+		row, ok := groups[key]
+		if !ok {
+			newRow := key
+			row = &newRow
+			groups[key] = row
+		}
+		row.Requests++
+		if record.Status == aggregates.ErrorStatus {
+			row.Errors++
+		}
+		row.InputTokens += record.InputTokens
+		row.OutputTokens += record.OutputTokens
+		row.Cost += m.cost(record)
+		latencies[key] += record.Latency
+	}
+	result := []aggregates.ReportRow{}
+	for key, row := range groups {
+		row.AverageLatency = latencies[key] / time.Duration(row.Requests)
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		if a.Context != b.Context {
+			return a.Context < b.Context
+		}
+		return a.Token < b.Token
+	})
+	return result, nil
+}
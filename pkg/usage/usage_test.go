@@ -0,0 +1,100 @@
+package usage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/appclacks/maizai/internal/usagestore/memory"
+	"github.com/appclacks/maizai/pkg/shared"
+	"github.com/appclacks/maizai/pkg/usage"
+	"github.com/appclacks/maizai/pkg/usage/aggregates"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord(t *testing.T) {
+	store := memory.New()
+	manager := usage.New(store, usage.Config{})
+	ctx := usage.WithToken(context.Background(), "token-a")
+	err := manager.Record(ctx, aggregates.Record{
+		Project:  shared.DefaultProject,
+		Provider: "mistral",
+		Status:   aggregates.SuccessStatus,
+	})
+	assert.NoError(t, err)
+	records, err := store.ListUsageRecords(ctx, shared.DefaultProject, aggregates.Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.NotEmpty(t, records[0].ID)
+	assert.Equal(t, "token-a", records[0].Token)
+	assert.False(t, records[0].CreatedAt.IsZero())
+
+	err = manager.Record(ctx, aggregates.Record{
+		Project:  shared.DefaultProject,
+		Provider: "mistral",
+		Status:   "unknown",
+	})
+	assert.ErrorContains(t, err, "Invalid status unknown")
+}
+
+func TestReport(t *testing.T) {
+	store := memory.New()
+	manager := usage.New(store, usage.Config{
+		Prices: []aggregates.Price{
+			{Provider: "anthropic", Model: "model-a", Input: 3, Output: 15},
+			{Provider: "mistral", Input: 1, Output: 2},
+		},
+	})
+	ctx := context.Background()
+	day := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	records := []aggregates.Record{
+		{Context: "ctx-a", Token: "token-a", Provider: "anthropic", Model: "model-a", InputTokens: 1000000, OutputTokens: 100000, Latency: time.Second, Status: aggregates.SuccessStatus, CreatedAt: day},
+		{Context: "ctx-a", Token: "token-a", Provider: "anthropic", Model: "model-a", Latency: 3 * time.Second, Status: aggregates.ErrorStatus, CreatedAt: day.Add(time.Hour)},
+		{Context: "ctx-b", Token: "token-b", Provider: "mistral", InputTokens: 500000, OutputTokens: 500000, Latency: 2 * time.Second, Status: aggregates.SuccessStatus, CreatedAt: day.Add(24 * time.Hour)},
+		{Context: "ctx-b", Token: "token-b", Provider: "mistral", Model: "unpriced", InputTokens: 100, OutputTokens: 100, Latency: time.Second, Status: aggregates.SuccessStatus, CreatedAt: day.Add(24 * time.Hour)},
+	}
+	for _, record := range records {
+		record.ID = uuid.NewString()
+		record.Project = shared.DefaultProject
+		err := store.CreateUsageRecord(ctx, record)
+		assert.NoError(t, err)
+	}
+	err := store.CreateUsageRecord(ctx, aggregates.Record{ID: uuid.NewString(), Project: "other", Provider: "mistral", InputTokens: 10, Status: aggregates.SuccessStatus, CreatedAt: day})
+	assert.NoError(t, err)
+
+	rows, err := manager.Report(ctx, shared.DefaultProject, aggregates.ReportQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []aggregates.ReportRow{
+		{Requests: 4, Errors: 1, InputTokens: 1500100, OutputTokens: 600100, AverageLatency: 1750 * time.Millisecond, Cost: 3 + 1.5 + 0.5 + 1},
+	}, rows)
+
+	rows, err = manager.Report(ctx, shared.DefaultProject, aggregates.ReportQuery{GroupBy: []string{aggregates.GroupByDay, aggregates.GroupByModel}})
+	assert.NoError(t, err)
+	assert.Equal(t, []aggregates.ReportRow{
+		{Day: "2026-10-10", Provider: "anthropic", Model: "model-a", Requests: 2, Errors: 1, InputTokens: 1000000, OutputTokens: 100000, AverageLatency: 2 * time.Second, Cost: 4.5},
+		{Day: "2026-10-11", Provider: "mistral", Requests: 1, InputTokens: 500000, OutputTokens: 500000, AverageLatency: 2 * time.Second, Cost: 1.5},
+		{Day: "2026-10-11", Provider: "mistral", Model: "unpriced", Requests: 1, InputTokens: 100, OutputTokens: 100, AverageLatency: time.Second},
+	}, rows)
+
+	rows, err = manager.Report(ctx, shared.DefaultProject, aggregates.ReportQuery{
+		Filter:  aggregates.Filter{Since: day.Add(time.Hour)},
+		GroupBy: []string{aggregates.GroupByToken, aggregates.GroupByContext},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []aggregates.ReportRow{
+		{Context: "ctx-a", Token: "token-a", Requests: 1, Errors: 1, AverageLatency: 3 * time.Second},
+		{Context: "ctx-b", Token: "token-b", Requests: 2, InputTokens: 500100, OutputTokens: 500100, AverageLatency: 1500 * time.Millisecond, Cost: 1.5},
+	}, rows)
+
+	rows, err = manager.Report(ctx, shared.DefaultProject, aggregates.ReportQuery{Filter: aggregates.Filter{Model: "unknown"}})
+	assert.NoError(t, err)
+	assert.Empty(t, rows)
+
+	_, err = manager.Report(ctx, shared.DefaultProject, aggregates.ReportQuery{GroupBy: []string{"week"}})
+	assert.ErrorContains(t, err, "Invalid group by week")
+	_, err = manager.Report(ctx, shared.DefaultProject, aggregates.ReportQuery{GroupBy: []string{aggregates.GroupByDay, aggregates.GroupByDay}})
+	assert.ErrorContains(t, err, "defined multiple times")
+	_, err = manager.Report(ctx, shared.DefaultProject, aggregates.ReportQuery{Filter: aggregates.Filter{Since: day, Until: day}})
+	assert.ErrorContains(t, err, "should be before its end")
+}
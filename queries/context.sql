@@ -1,23 +1,24 @@
 -- name: GetContext :one
-SELECT id, name, description, created_at FROM context
-WHERE id = $1;
+SELECT id, name, description, created_at, project FROM context
+WHERE id = $1 AND project = $2;
 
 -- name: GetContextIDByName :one
 SELECT id FROM context
-WHERE name = $1;
+WHERE project = $1 AND name = $2;
 
 -- name: GetContextNameByID :one
 SELECT name FROM context
-WHERE id = $1;
+WHERE id = $1 AND project = $2;
 
 -- name: ListContexts :many
-SELECT id, name, description, created_at FROM context;
+SELECT id, name, description, created_at, project FROM context
+WHERE project = $1;
 
 -- name: CreateContext :one
 INSERT INTO context (
-  id, name, description, created_at
+  id, name, description, created_at, project
 ) VALUES (
-  $1, $2, $3, $4
+  $1, $2, $3, $4, $5
 )
 RETURNING *;
 
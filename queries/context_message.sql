@@ -1,13 +1,13 @@
 -- name: GetContextMessages :many
-SELECT id, role, content, created_at FROM context_message
+SELECT id, role, content, created_at, tool_calls, tool_call_id, parts, pinned FROM context_message
 WHERE context_id = $1
 ORDER BY ordering;
 
 -- name: CreateContextMessage :one
 INSERT INTO context_message (
-  id, role, content, created_at, context_id
+  id, role, content, created_at, context_id, tool_calls, tool_call_id, parts, pinned
 ) VALUES (
-  $1, $2, $3, $4, $5
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
 )
 RETURNING *;
 
@@ -15,11 +15,26 @@ RETURNING *;
 DELETE FROM context_message
 WHERE context_id = $1;
 
--- name: UpdateContextMessage :exec
+-- name: DeleteContextMessagesByIDs :many
+DELETE FROM context_message
+WHERE context_id = @context_id AND id = ANY(@ids::uuid[])
+RETURNING ordering;
+
+-- name: UpdateContextMessageOrdering :exec
 UPDATE context_message
-SET content = $2, role=$3
+SET ordering = $2
 WHERE id = $1;
 
--- name: DeleteContextMessage :exec
+-- name: UpdateContextMessage :execrows
+UPDATE context_message
+SET content = $2, role=$3
+WHERE id = $1 AND context_id IN (SELECT id FROM context WHERE project = $4);
+
+-- name: PinContextMessage :execrows
+UPDATE context_message
+SET pinned = $2
+WHERE id = $1 AND context_id IN (SELECT id FROM context WHERE project = $3);
+
+-- name: DeleteContextMessage :execrows
 DELETE FROM context_message
-WHERE id = $1;
+WHERE id = $1 AND context_id IN (SELECT id FROM context WHERE project = $2);
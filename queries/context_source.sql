@@ -16,4 +16,4 @@ WHERE context_id=$1 OR source_context_id=$1;
 
 -- name: DeleteContextSource :exec
 DELETE FROM context_source
-WHERE context_id=$1 AND source_context_id=$2;
+WHERE context_id=$1 AND source_context_id=$2 AND context_id IN (SELECT id FROM context WHERE project = $3);
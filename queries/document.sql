@@ -1,17 +1,25 @@
 -- name: CreateDocument :exec
 INSERT INTO document (
-  id, name, description, created_at)
+  id, name, description, created_at, embedding_provider, embedding_model, dimension, labels, source, project)
 VALUES (
-  $1, $2, $3, $4
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 );
 
 -- name: GetDocument :one
-SELECT id, name, description, created_at FROM document
-WHERE id = $1;
+SELECT id, name, description, created_at, embedding_provider, embedding_model, dimension, labels, source, project FROM document
+WHERE id = $1 AND project = $2;
 
 -- name: ListDocuments :many
-SELECT id, name, description, created_at
-FROM document;
+SELECT id, name, description, created_at, embedding_provider, embedding_model, dimension, labels, source, project
+FROM document
+WHERE project = $1;
+
+-- name: UpdateDocumentEmbedding :execrows
+UPDATE document SET embedding_provider = $2, embedding_model = $3, dimension = $4
+WHERE id = $1 AND project = $5
+AND (embedding_provider = '' OR embedding_provider = $2)
+AND (embedding_model = '' OR embedding_model = $3)
+AND (dimension = 0 OR dimension = $4);
 
 -- name: DeleteDocument :exec
 DELETE FROM document
@@ -19,25 +27,33 @@ WHERE id = $1;
 
 -- name: CreateDocumentChunk :exec
 INSERT INTO document_chunk (
-  id, document_id, fragment, embedding, created_at)
+  id, document_id, fragment, embedding, created_at, ordinal, start_offset, end_offset, metadata)
 VALUES (
-  $1, $2, $3, $4, $5
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
 );
 
--- name: FindClosestChunks :many
-SELECT id, document_id, fragment, embedding, created_at
-FROM document_chunk
-ORDER BY embedding <-> $1 LIMIT $2;
+-- name: CreateDocumentChunks :copyfrom
+INSERT INTO document_chunk (
+  id, document_id, fragment, embedding, created_at, ordinal, start_offset, end_offset, metadata)
+VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+);
 
--- name: DeleteDocumentChunk :exec
+-- name: DeleteDocumentChunk :execrows
 DELETE FROM document_chunk
-WHERE id = $1;
+WHERE id = $1 AND document_id IN (SELECT id FROM document WHERE project = $2);
 
 -- name: DeleteDocumentChunkForDocument :exec
 DELETE FROM document_chunk
 WHERE document_id = $1;
 
 -- name: ListDocumentChunksForDocument :many
-SELECT id, fragment, created_at, embedding
+SELECT id, fragment, created_at, embedding, ordinal, start_offset, end_offset, metadata
+FROM document_chunk
+WHERE document_id = $1
+ORDER BY ordinal, created_at;
+
+-- name: NextDocumentChunkOrdinal :one
+SELECT (COALESCE(MAX(ordinal) + 1, 0))::integer AS next_ordinal
 FROM document_chunk
 WHERE document_id = $1;
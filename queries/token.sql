@@ -0,0 +1,18 @@
+-- name: CreateToken :exec
+INSERT INTO token (
+  id, name, scopes, hash, created_at, project
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+);
+
+-- name: ListTokens :many
+SELECT id, name, scopes, hash, created_at, project FROM token
+ORDER BY created_at;
+
+-- name: GetTokenByHash :one
+SELECT id, name, scopes, hash, created_at, project FROM token
+WHERE hash = $1;
+
+-- name: DeleteToken :execrows
+DELETE FROM token
+WHERE id = $1;
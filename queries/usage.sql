@@ -0,0 +1,16 @@
+-- name: CreateUsageRecord :exec
+INSERT INTO usage_record (
+  id, project, context_id, token, provider, model, input_tokens, output_tokens, latency_ms, status, created_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+);
+
+-- name: ListUsageRecords :many
+SELECT id, project, context_id, token, provider, model, input_tokens, output_tokens, latency_ms, status, created_at FROM usage_record
+WHERE project = $1
+AND ($2::timestamp IS NULL OR created_at >= $2::timestamp)
+AND ($3::timestamp IS NULL OR created_at < $3::timestamp)
+AND ($4::text = '' OR context_id = $4::text)
+AND ($5::text = '' OR token = $5::text)
+AND ($6::text = '' OR model = $6::text)
+ORDER BY created_at;